@@ -0,0 +1,60 @@
+package analysis
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Token is a word found in a text, with its byte offsets in the original
+// text so matches can be highlighted
+type Token struct {
+	Term  string
+	Start int
+	End   int
+}
+
+// Tokens splits text into lowercase words made of letters and digits.
+// Apostrophes inside a word are dropped, so "don't" becomes "dont".
+func Tokens(text string) []Token {
+	tokens := make([]Token, 0)
+
+	var b strings.Builder
+	start := -1
+
+	flush := func(end int) {
+		if start >= 0 && b.Len() > 0 {
+			tokens = append(tokens, Token{Term: b.String(), Start: start, End: end})
+		}
+		b.Reset()
+		start = -1
+	}
+
+	for i, r := range text {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if start < 0 {
+				start = i
+			}
+			b.WriteRune(unicode.ToLower(r))
+		case (r == '\'' || r == '’') && start >= 0:
+			// Part of the word, but not of the term
+		default:
+			flush(i)
+		}
+	}
+	flush(len(text))
+
+	return tokens
+}
+
+// Tokenize returns the lowercase words of a text
+func Tokenize(text string) []string {
+	tokens := Tokens(text)
+
+	terms := make([]string, len(tokens))
+	for i, t := range tokens {
+		terms[i] = t.Term
+	}
+
+	return terms
+}
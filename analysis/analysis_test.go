@@ -0,0 +1,69 @@
+package analysis
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenize(t *testing.T) {
+	assert.Equal(t, []string{"don", "t"}, Tokenize("don 't"))
+	assert.Equal(t, []string{"dont", "panic", "42", "times"}, Tokenize("Don't PANIC: 42 times!"))
+	assert.Equal(t, []string{"café", "au", "lait"}, Tokenize("Café-au-lait"))
+	assert.Empty(t, Tokenize(" ... "))
+}
+
+func TestTokens(t *testing.T) {
+	text := "Hello, World"
+	tokens := Tokens(text)
+
+	assert.Len(t, tokens, 2)
+	assert.Equal(t, "world", tokens[1].Term)
+	assert.Equal(t, "World", text[tokens[1].Start:tokens[1].End])
+}
+
+func TestStem(t *testing.T) {
+	words := map[string]string{
+		"caresses":        "caress",
+		"ponies":          "poni",
+		"cats":            "cat",
+		"feed":            "feed",
+		"agreed":          "agre",
+		"plastered":       "plaster",
+		"motoring":        "motor",
+		"sing":            "sing",
+		"conflated":       "conflat",
+		"hopping":         "hop",
+		"falling":         "fall",
+		"filing":          "file",
+		"happy":           "happi",
+		"relational":      "relat",
+		"generalizations": "gener",
+		"adjustment":      "adjust",
+		"adoption":        "adopt",
+		"controlling":     "control",
+		"running":         "run",
+		"go":              "go",
+		"café":            "café",
+	}
+
+	for word, stem := range words {
+		assert.Equal(t, stem, Stem(word), word)
+	}
+
+	assert.Equal(t, Stem("connection"), Stem("connected"))
+	assert.Equal(t, Stem("connections"), Stem("connecting"))
+}
+
+func TestAnalyze(t *testing.T) {
+	text := "The Habits of highly effective people"
+	tokens := Analyze(text)
+
+	terms := make([]string, len(tokens))
+	for i, tok := range tokens {
+		terms[i] = tok.Term
+	}
+
+	assert.Equal(t, []string{"habit", "highli", "effect", "peopl"}, terms)
+	assert.Equal(t, "Habits", text[tokens[0].Start:tokens[0].End])
+}
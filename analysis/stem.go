@@ -0,0 +1,275 @@
+package analysis
+
+// Stem reduces an English word to its stem with the Porter algorithm, so
+// "connected", "connecting" and "connections" all become "connect". Words
+// that are not plain lowercase ASCII are returned unchanged.
+func Stem(word string) string {
+	if len(word) <= 2 {
+		return word
+	}
+
+	for i := 0; i < len(word); i++ {
+		if word[i] < 'a' || word[i] > 'z' {
+			return word
+		}
+	}
+
+	b := []byte(word)
+	b = step1a(b)
+	b = step1b(b)
+	b = step1c(b)
+	b = step2(b)
+	b = step3(b)
+	b = step4(b)
+	b = step5(b)
+
+	return string(b)
+}
+
+// A consonant is a letter other than a, e, i, o, u, and other than y when
+// it follows a consonant
+func isConsonant(b []byte, i int) bool {
+	switch b[i] {
+	case 'a', 'e', 'i', 'o', 'u':
+		return false
+	case 'y':
+		return i == 0 || !isConsonant(b, i-1)
+	}
+
+	return true
+}
+
+// measure counts the vowel-consonant sequences in b, the m in [C](VC)^m[V]
+func measure(b []byte) int {
+	m := 0
+	i := 0
+
+	for i < len(b) && isConsonant(b, i) {
+		i++
+	}
+
+	for i < len(b) {
+		for i < len(b) && !isConsonant(b, i) {
+			i++
+		}
+		if i >= len(b) {
+			break
+		}
+
+		for i < len(b) && isConsonant(b, i) {
+			i++
+		}
+		m++
+	}
+
+	return m
+}
+
+func hasVowel(b []byte) bool {
+	for i := range b {
+		if !isConsonant(b, i) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func endsWithDoubleConsonant(b []byte) bool {
+	l := len(b)
+	return l >= 2 && b[l-1] == b[l-2] && isConsonant(b, l-1)
+}
+
+// endsWithCVC reports whether b ends consonant-vowel-consonant with the last
+// consonant not w, x or y, as in "hop" or "fil"
+func endsWithCVC(b []byte) bool {
+	l := len(b)
+	if l < 3 || !isConsonant(b, l-3) || isConsonant(b, l-2) || !isConsonant(b, l-1) {
+		return false
+	}
+
+	switch b[l-1] {
+	case 'w', 'x', 'y':
+		return false
+	}
+
+	return true
+}
+
+func hasSuffix(b []byte, suffix string) bool {
+	return len(b) >= len(suffix) && string(b[len(b)-len(suffix):]) == suffix
+}
+
+func replaceSuffix(b []byte, suffix, replacement string) []byte {
+	return append(b[:len(b)-len(suffix)], replacement...)
+}
+
+type rule struct {
+	suffix      string
+	replacement string
+}
+
+// applyRules replaces the first matching suffix if the measure of what
+// precedes it is above min. Only the first match is considered.
+func applyRules(b []byte, rules []rule, min int) []byte {
+	for _, r := range rules {
+		if !hasSuffix(b, r.suffix) {
+			continue
+		}
+
+		if measure(b[:len(b)-len(r.suffix)]) > min {
+			return replaceSuffix(b, r.suffix, r.replacement)
+		}
+
+		return b
+	}
+
+	return b
+}
+
+func step1a(b []byte) []byte {
+	switch {
+	case hasSuffix(b, "sses"):
+		return replaceSuffix(b, "sses", "ss")
+	case hasSuffix(b, "ies"):
+		return replaceSuffix(b, "ies", "i")
+	case hasSuffix(b, "ss"):
+		return b
+	case hasSuffix(b, "s"):
+		return b[:len(b)-1]
+	}
+
+	return b
+}
+
+func step1b(b []byte) []byte {
+	if hasSuffix(b, "eed") {
+		if measure(b[:len(b)-3]) > 0 {
+			return b[:len(b)-1]
+		}
+		return b
+	}
+
+	var stem []byte
+	switch {
+	case hasSuffix(b, "ed"):
+		stem = b[:len(b)-2]
+	case hasSuffix(b, "ing"):
+		stem = b[:len(b)-3]
+	default:
+		return b
+	}
+
+	if !hasVowel(stem) {
+		return b
+	}
+
+	b = stem
+	switch {
+	case hasSuffix(b, "at"), hasSuffix(b, "bl"), hasSuffix(b, "iz"):
+		return append(b, 'e')
+	case endsWithDoubleConsonant(b):
+		switch b[len(b)-1] {
+		case 'l', 's', 'z':
+			return b
+		}
+		return b[:len(b)-1]
+	case measure(b) == 1 && endsWithCVC(b):
+		return append(b, 'e')
+	}
+
+	return b
+}
+
+func step1c(b []byte) []byte {
+	if hasSuffix(b, "y") && hasVowel(b[:len(b)-1]) {
+		b[len(b)-1] = 'i'
+	}
+
+	return b
+}
+
+var step2Rules = []rule{
+	{"ational", "ate"},
+	{"tional", "tion"},
+	{"enci", "ence"},
+	{"anci", "ance"},
+	{"izer", "ize"},
+	{"bli", "ble"},
+	{"alli", "al"},
+	{"entli", "ent"},
+	{"eli", "e"},
+	{"ousli", "ous"},
+	{"ization", "ize"},
+	{"ation", "ate"},
+	{"ator", "ate"},
+	{"alism", "al"},
+	{"iveness", "ive"},
+	{"fulness", "ful"},
+	{"ousness", "ous"},
+	{"aliti", "al"},
+	{"iviti", "ive"},
+	{"biliti", "ble"},
+	{"logi", "log"},
+}
+
+func step2(b []byte) []byte {
+	return applyRules(b, step2Rules, 0)
+}
+
+var step3Rules = []rule{
+	{"icate", "ic"},
+	{"ative", ""},
+	{"alize", "al"},
+	{"iciti", "ic"},
+	{"ical", "ic"},
+	{"ful", ""},
+	{"ness", ""},
+}
+
+func step3(b []byte) []byte {
+	return applyRules(b, step3Rules, 0)
+}
+
+// Longer suffixes come first where one ends with another
+var step4Suffixes = []string{
+	"al", "ance", "ence", "er", "ic", "able", "ible", "ant", "ement", "ment",
+	"ent", "ion", "ou", "ism", "ate", "iti", "ous", "ive", "ize",
+}
+
+func step4(b []byte) []byte {
+	for _, suffix := range step4Suffixes {
+		if !hasSuffix(b, suffix) {
+			continue
+		}
+
+		stem := b[:len(b)-len(suffix)]
+		if suffix == "ion" && (len(stem) == 0 || (stem[len(stem)-1] != 's' && stem[len(stem)-1] != 't')) {
+			return b
+		}
+
+		if measure(stem) > 1 {
+			return stem
+		}
+
+		return b
+	}
+
+	return b
+}
+
+func step5(b []byte) []byte {
+	if hasSuffix(b, "e") {
+		stem := b[:len(b)-1]
+		m := measure(stem)
+		if m > 1 || (m == 1 && !endsWithCVC(stem)) {
+			b = stem
+		}
+	}
+
+	if measure(b) > 1 && endsWithDoubleConsonant(b) && b[len(b)-1] == 'l' {
+		b = b[:len(b)-1]
+	}
+
+	return b
+}
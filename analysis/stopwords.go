@@ -0,0 +1,46 @@
+package analysis
+
+// stopWords are common English words that carry little meaning on their own
+var stopWords = map[string]struct{}{}
+
+func init() {
+	for _, w := range []string{
+		"a", "about", "above", "after", "again", "against", "all", "am", "an", "and", "any", "are", "as", "at",
+		"be", "because", "been", "before", "being", "below", "between", "both", "but", "by",
+		"can", "could", "did", "do", "does", "doing", "down", "during",
+		"each", "few", "for", "from", "further", "had", "has", "have", "having", "he", "her", "here", "hers",
+		"herself", "him", "himself", "his", "how", "i", "if", "in", "into", "is", "it", "its", "itself",
+		"just", "me", "more", "most", "my", "myself", "no", "nor", "not", "now",
+		"of", "off", "on", "once", "only", "or", "other", "our", "ours", "ourselves", "out", "over", "own",
+		"same", "she", "should", "so", "some", "such", "than", "that", "the", "their", "theirs", "them",
+		"themselves", "then", "there", "these", "they", "this", "those", "through", "to", "too",
+		"under", "until", "up", "very", "was", "we", "were", "what", "when", "where", "which", "while",
+		"who", "whom", "why", "will", "with", "would", "you", "your", "yours", "yourself", "yourselves",
+	} {
+		stopWords[w] = struct{}{}
+	}
+}
+
+// IsStopWord reports whether a lowercase word is an English stop word
+func IsStopWord(term string) bool {
+	_, ok := stopWords[term]
+	return ok
+}
+
+// Analyze tokenizes text for indexing: stop words are dropped and the
+// remaining terms are stemmed, while keeping their offsets
+func Analyze(text string) []Token {
+	tokens := Tokens(text)
+
+	terms := tokens[:0]
+	for _, tok := range tokens {
+		if IsStopWord(tok.Term) {
+			continue
+		}
+
+		tok.Term = Stem(tok.Term)
+		terms = append(terms, tok)
+	}
+
+	return terms
+}
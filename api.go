@@ -2,27 +2,45 @@ package main
 
 import (
 	"context"
+	"errors"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
 	"reflect"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/sikozonpc/notebase/author"
 	"github.com/sikozonpc/notebase/book"
+	"github.com/sikozonpc/notebase/categorize"
+	"github.com/sikozonpc/notebase/collection"
 	"github.com/sikozonpc/notebase/config"
+	"github.com/sikozonpc/notebase/cover"
 	"github.com/sikozonpc/notebase/highlight"
+	"github.com/sikozonpc/notebase/library"
+	"github.com/sikozonpc/notebase/link"
 	"github.com/sikozonpc/notebase/medium"
+	"github.com/sikozonpc/notebase/metadata"
+	"github.com/sikozonpc/notebase/note"
+	"github.com/sikozonpc/notebase/reading"
+	"github.com/sikozonpc/notebase/search"
 	"github.com/sikozonpc/notebase/storage"
+	"github.com/sikozonpc/notebase/trash"
+	t "github.com/sikozonpc/notebase/types"
 	"github.com/sikozonpc/notebase/user"
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
 type APIServer struct {
 	addr string
-	db   *mongo.Client
+	db   *mongo.Database
 }
 
-func NewAPIServer(addr string, db *mongo.Client) *APIServer {
+func NewAPIServer(addr string, db *mongo.Database) *APIServer {
 	return &APIServer{
 		addr: addr,
 		db:   db,
@@ -33,9 +51,18 @@ func (s *APIServer) Run() error {
 	router := mux.NewRouter()
 	subrouter := router.PathPrefix("/api/v1").Subrouter()
 
-	ctx := context.Background()
+	// Cancelled on shutdown, background jobs that have to finish their work
+	// first are waited for
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	var background sync.WaitGroup
 
-	gcpStorage, err := storage.NewGCPStorage(ctx)
+	gcpStorage, err := storage.NewGCPStorage(ctx, config.Envs.GCPBooksBucketName)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	coverStorage, err := storage.NewGCPStorage(ctx, config.Envs.GCPCoversBucketName)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -43,14 +70,112 @@ func (s *APIServer) Run() error {
 	mailer := medium.NewMailer(config.Envs.SendGridAPIKey, config.Envs.SendGridFromEmail)
 
 	bookStore := book.NewStore(s.db)
+	libraryStore := library.NewStore(s.db)
+	authorStore := author.NewStore(s.db)
+	readingStore := reading.NewStore(s.db)
+	noteStore := note.NewStore(s.db)
+	linkStore := link.NewStore(s.db)
 
 	userStore := user.NewStore(s.db)
 	userHandler := user.NewHandler(userStore)
 	userHandler.RegisterRoutes(subrouter)
 
 	highlightStore := highlight.NewStore(s.db)
-	highlightHandler := highlight.NewHandler(highlightStore, userStore, gcpStorage, bookStore, mailer)
+	collectionStore := collection.NewStore(s.db)
+
+	highlightHandler := highlight.NewHandler(highlightStore, userStore, gcpStorage, bookStore, libraryStore, authorStore, collectionStore, noteStore, linkStore, mailer)
 	highlightHandler.RegisterRoutes(subrouter)
+	highlightStore.RegisterHook(link.NewLinker(linkStore))
+
+	collectionHandler := collection.NewHandler(collectionStore, highlightStore, userStore)
+	collectionHandler.RegisterRoutes(subrouter)
+
+	bookHandler := book.NewHandler(bookStore, libraryStore, readingStore, noteStore, authorStore, highlightStore, userStore)
+	bookHandler.RegisterRoutes(subrouter)
+
+	readingHandler := reading.NewHandler(readingStore, bookStore, libraryStore, userStore)
+	readingHandler.RegisterRoutes(subrouter)
+
+	noteHandler := note.NewHandler(noteStore, libraryStore, linkStore, userStore)
+	noteHandler.RegisterRoutes(subrouter)
+
+	linkHandler := link.NewHandler(linkStore, highlightStore, noteStore, userStore)
+	linkHandler.RegisterRoutes(subrouter)
+
+	authorHandler := author.NewHandler(authorStore, bookStore, libraryStore, highlightStore, userStore)
+	authorHandler.RegisterRoutes(subrouter)
+
+	trashHandler := trash.NewHandler(highlightStore, bookStore, libraryStore, userStore)
+	trashHandler.RegisterRoutes(subrouter)
+
+	var providers []metadata.Provider
+	for _, name := range strings.Split(config.Envs.MetadataProviders, ",") {
+		switch strings.TrimSpace(name) {
+		case "catalog":
+			catalog, err := metadata.LoadCatalog(config.Envs.MetadataCatalogPath)
+			if err != nil {
+				log.Fatal(err)
+			}
+			providers = append(providers, catalog)
+		case "openlibrary":
+			providers = append(providers, metadata.NewOpenLibrary(config.Envs.OpenLibraryURL))
+		case "googlebooks":
+			providers = append(providers, metadata.NewGoogleBooks(config.Envs.GoogleBooksURL, config.Envs.GoogleBooksAPIKey))
+		case "":
+		default:
+			log.Fatalf("unknown metadata provider %q", name)
+		}
+	}
+
+	covers := cover.NewCovers(coverStorage, bookStore)
+	metadataCache := metadata.NewStore(s.db, config.Envs.MetadataCacheTTL)
+
+	purger := trash.NewPurger(highlightStore, bookStore, libraryStore, collectionStore, linkStore, covers, metadataCache, config.Envs.TrashRetention, config.Envs.TrashPurgeInterval)
+	go purger.Run(ctx)
+
+	var enricher cover.Enricher
+	if len(providers) > 0 {
+		metadataEnricher := metadata.NewEnricher(bookStore, metadataCache, covers, config.Envs.MetadataEnrichInterval, providers...)
+		go metadataEnricher.Run(ctx)
+
+		enricher = metadataEnricher
+	}
+
+	coverHandler := cover.NewHandler(covers, bookStore, libraryStore, enricher, userStore)
+	coverHandler.RegisterRoutes(subrouter)
+
+	topicStore := categorize.NewStore(s.db)
+	categorizer := categorize.NewCategorizer(highlightStore, topicStore, config.Envs.CategorizeDelay)
+	highlightStore.RegisterHook(categorizer)
+	go categorizer.Run(ctx)
+
+	categorizeHandler := categorize.NewHandler(categorizer, topicStore, userStore)
+	categorizeHandler.RegisterRoutes(subrouter)
+
+	var searcher t.Searcher
+	switch config.Envs.SearchBackend {
+	case "scan":
+		searcher = search.NewScanSearcher(highlightStore, bookStore)
+	case "index":
+		index := search.NewIndex(bookStore)
+		if err := index.LoadOrRebuild(ctx, config.Envs.SearchIndexPath, s.db); err != nil {
+			log.Fatal(err)
+		}
+
+		highlightStore.RegisterHook(index)
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			index.AutoSave(ctx, config.Envs.SearchIndexPath, config.Envs.SearchIndexSaveInterval)
+		}()
+
+		searcher = index
+	default:
+		searcher = search.NewMongoSearcher(s.db)
+	}
+
+	searchHandler := search.NewHandler(search.WithNotes(searcher, noteStore, bookStore), userStore)
+	searchHandler.RegisterRoutes(subrouter)
 
 	// Serve static files
 	router.PathPrefix("/").Handler(http.FileServer(http.Dir("./static")))
@@ -63,9 +188,38 @@ func (s *APIServer) Run() error {
 		v := reflect.ValueOf(config.Envs)
 
 		for i := 0; i < v.NumField(); i++ {
-			log.Println(v.Type().Field(i).Name, "=", v.Field(i).Interface())
+			name := v.Type().Field(i).Name
+			// Keys for external services don't belong in logs
+			if strings.HasSuffix(name, "APIKey") {
+				log.Println(name, "= ****")
+				continue
+			}
+
+			log.Println(name, "=", v.Field(i).Interface())
+		}
+	}
+
+	server := &http.Server{Addr: s.addr, Handler: router}
+	go func() {
+		<-ctx.Done()
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			log.Printf("failed to shut down: %v", err)
 		}
+	}()
+
+	err = server.ListenAndServe()
+	if errors.Is(err, http.ErrServerClosed) {
+		err = nil
 	}
 
-	return http.ListenAndServe(s.addr, router)
+	stop()
+	background.Wait()
+
+	return err
 }
+
+// shutdownTimeout is how long requests in flight get to finish on shutdown
+const shutdownTimeout = 10 * time.Second
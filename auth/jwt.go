@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/mux"
 	"github.com/sikozonpc/notebase/config"
 	t "github.com/sikozonpc/notebase/types"
 	u "github.com/sikozonpc/notebase/utils"
@@ -20,6 +21,12 @@ func permissionDenied(w http.ResponseWriter) {
 	})
 }
 
+func forbidden(w http.ResponseWriter) {
+	u.WriteJSON(w, http.StatusForbidden, t.APIError{
+		Error: fmt.Errorf("forbidden").Error(),
+	})
+}
+
 func GetUserFromToken(t string) (string, error) {
 	token, err := validateJWT(t)
 	if err != nil {
@@ -75,6 +82,13 @@ func WithJWTAuth(handlerFunc http.HandlerFunc, store t.UserStore) http.HandlerFu
 			return
 		}
 
+		// Users can only access resources under their own /user/{userID} routes
+		if routeUserID, ok := mux.Vars(r)["userID"]; ok && routeUserID != claimsUserID {
+			log.Printf("user %s tried to access resources of user %s", claimsUserID, routeUserID)
+			forbidden(w)
+			return
+		}
+
 		// Call the function if the token is valid
 		handlerFunc(w, r)
 	}
@@ -1,9 +1,15 @@
 package auth
 
 import (
+	"context"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
+	"github.com/gorilla/mux"
+	"github.com/sikozonpc/notebase/types"
 	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 func TestCreateJWT(t *testing.T) {
@@ -16,4 +22,71 @@ func TestCreateJWT(t *testing.T) {
 	}
 
 	assert.NotEmpty(t, token)
-}
\ No newline at end of file
+}
+
+func TestWithJWTAuth(t *testing.T) {
+	t.Setenv("JWT_SECRET", "secret")
+
+	token, err := CreateJWT([]byte("secret"), "123")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := WithJWTAuth(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}, &mockUserStore{})
+
+	router := mux.NewRouter()
+	router.HandleFunc("/user/{userID}/highlight", handler)
+
+	t.Run("should allow access to the token owner routes", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/user/123/highlight", nil)
+		req.Header.Set("Authorization", token)
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+	})
+
+	t.Run("should forbid access to another user routes", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/user/456/highlight", nil)
+		req.Header.Set("Authorization", token)
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusForbidden, rr.Code)
+	})
+
+	t.Run("should deny access without a token", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/user/123/highlight", nil)
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	})
+}
+
+type mockUserStore struct{}
+
+func (m *mockUserStore) Create(context.Context, types.RegisterRequest) (primitive.ObjectID, error) {
+	return primitive.NilObjectID, nil
+}
+
+func (m *mockUserStore) GetUserByID(context.Context, string) (*types.User, error) {
+	return &types.User{}, nil
+}
+
+func (m *mockUserStore) GetUsers(context.Context) ([]*types.User, error) {
+	return []*types.User{}, nil
+}
+
+func (m *mockUserStore) GetUserByEmail(context.Context, string) (*types.User, error) {
+	return &types.User{}, nil
+}
+
+func (m *mockUserStore) UpdateUser(context.Context, types.User) error {
+	return nil
+}
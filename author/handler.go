@@ -0,0 +1,242 @@
+package author
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+
+	"github.com/gorilla/mux"
+	"github.com/sikozonpc/notebase/auth"
+	t "github.com/sikozonpc/notebase/types"
+	u "github.com/sikozonpc/notebase/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type Handler struct {
+	store          t.AuthorStore
+	bookStore      t.BookStore
+	libraryStore   t.LibraryStore
+	highlightStore t.HighlightStore
+	userStore      t.UserStore
+}
+
+func NewHandler(store t.AuthorStore, bookStore t.BookStore, libraryStore t.LibraryStore, highlightStore t.HighlightStore, userStore t.UserStore) *Handler {
+	return &Handler{
+		store:          store,
+		bookStore:      bookStore,
+		libraryStore:   libraryStore,
+		highlightStore: highlightStore,
+		userStore:      userStore,
+	}
+}
+
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc(
+		"/user/{userID}/author",
+		auth.WithJWTAuth(u.MakeHTTPHandler(h.handleGetUserAuthors), h.userStore),
+	).Methods("GET")
+
+	router.HandleFunc(
+		"/user/{userID}/author/{id}/merge",
+		auth.WithJWTAuth(u.MakeHTTPHandler(h.handleMergeAuthors), h.userStore),
+	).Methods("POST")
+}
+
+// handleGetUserAuthors lists the authors of the books in the user's library
+// by sort name, with how many of their books and highlights the user has
+func (h *Handler) handleGetUserAuthors(w http.ResponseWriter, r *http.Request) error {
+	userID, err := u.GetStringParamFromRequest(r, "userID")
+	if err != nil {
+		return err
+	}
+	oUserID, _ := primitive.ObjectIDFromHex(userID)
+
+	summaries, err := h.getUserAuthors(r.Context(), oUserID)
+	if err != nil {
+		return err
+	}
+
+	authors := make([]*t.AuthorSummary, 0, len(summaries))
+	for _, s := range summaries {
+		authors = append(authors, s)
+	}
+
+	sort.Slice(authors, func(i, j int) bool {
+		return authors[i].SortName < authors[j].SortName
+	})
+
+	return u.WriteJSON(w, http.StatusOK, authors)
+}
+
+// getUserAuthors summarizes the authors in the user's library by ID. Books
+// added before authors were parsed are linked on the way.
+func (h *Handler) getUserAuthors(ctx context.Context, userID primitive.ObjectID) (map[primitive.ObjectID]*t.AuthorSummary, error) {
+	entries, err := h.libraryStore.GetLibrary(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]primitive.ObjectID, len(entries))
+	for i, e := range entries {
+		ids[i] = e.BookID
+	}
+
+	books, err := h.bookStore.GetByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	stats, err := h.highlightStore.GetUserBookStats(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	highlights := make(map[string]int, len(stats))
+	for _, s := range stats {
+		highlights[s.BookID] = s.HighlightCount
+	}
+
+	summaries := make(map[primitive.ObjectID]*t.AuthorSummary)
+	authorIDs := make([]primitive.ObjectID, 0)
+	for _, b := range books {
+		if err := LinkBook(ctx, h.store, h.bookStore, b); err != nil {
+			return nil, err
+		}
+
+		for _, id := range b.AuthorIDs {
+			s, ok := summaries[id]
+			if !ok {
+				s = &t.AuthorSummary{}
+				summaries[id] = s
+				authorIDs = append(authorIDs, id)
+			}
+
+			s.BookCount++
+			s.HighlightCount += highlights[b.ISBN]
+		}
+	}
+
+	authors, err := h.store.GetByIDs(ctx, authorIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, a := range authors {
+		summaries[a.ID].Author = a
+	}
+
+	// Authors merged away while being read
+	for id, s := range summaries {
+		if s.Author == nil {
+			delete(summaries, id)
+		}
+	}
+
+	return summaries, nil
+}
+
+// handleMergeAuthors merges duplicate authors into the one in the path. All
+// of them must be authors of books in the user's library, and only those
+// books are linked to it. A duplicate no other book has is folded into it.
+func (h *Handler) handleMergeAuthors(w http.ResponseWriter, r *http.Request) error {
+	userID, err := u.GetStringParamFromRequest(r, "userID")
+	if err != nil {
+		return err
+	}
+	oUserID, _ := primitive.ObjectIDFromHex(userID)
+
+	id, err := u.GetStringParamFromRequest(r, "id")
+	if err != nil {
+		return err
+	}
+	oID, _ := primitive.ObjectIDFromHex(id)
+
+	payload := new(MergeAuthorsRequest)
+	if err := json.NewDecoder(r.Body).Decode(payload); err != nil {
+		return u.WriteJSON(w, http.StatusBadRequest, t.APIError{Error: err.Error()})
+	}
+
+	if len(payload.AuthorIDs) == 0 {
+		return u.WriteJSON(w, http.StatusBadRequest, t.APIError{Error: fmt.Errorf("authorIds is required").Error()})
+	}
+
+	summaries, err := h.getUserAuthors(r.Context(), oUserID)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := summaries[oID]; !ok {
+		return notFound(w, oID)
+	}
+
+	ids := make([]primitive.ObjectID, 0, len(payload.AuthorIDs))
+	for _, raw := range payload.AuthorIDs {
+		mergedID, _ := primitive.ObjectIDFromHex(raw)
+		if mergedID == oID {
+			return u.WriteJSON(w, http.StatusBadRequest, t.APIError{Error: fmt.Errorf("cannot merge an author into itself").Error()})
+		}
+
+		if _, ok := summaries[mergedID]; !ok {
+			return notFound(w, mergedID)
+		}
+
+		if !slices.Contains(ids, mergedID) {
+			ids = append(ids, mergedID)
+		}
+	}
+
+	entries, err := h.libraryStore.GetLibrary(r.Context(), oUserID)
+	if err != nil {
+		return err
+	}
+
+	bookIDs := make([]primitive.ObjectID, len(entries))
+	for i, e := range entries {
+		bookIDs[i] = e.BookID
+	}
+
+	if err := h.bookStore.ReplaceAuthors(r.Context(), bookIDs, ids, oID); err != nil {
+		return err
+	}
+
+	// Authors of books outside the user's library stay for their readers
+	gone := make([]primitive.ObjectID, 0, len(ids))
+	for _, mergedID := range ids {
+		count, err := h.bookStore.CountAuthorBooks(r.Context(), mergedID)
+		if err != nil {
+			return err
+		}
+
+		if count == 0 {
+			gone = append(gone, mergedID)
+		}
+	}
+
+	var author *t.Author
+	if len(gone) > 0 {
+		author, err = h.store.Merge(r.Context(), oID, gone)
+	} else {
+		author = summaries[oID].Author
+	}
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return notFound(w, oID)
+	}
+	if err != nil {
+		return err
+	}
+
+	return u.WriteJSON(w, http.StatusOK, author)
+}
+
+func notFound(w http.ResponseWriter, id primitive.ObjectID) error {
+	return u.WriteJSON(w, http.StatusNotFound, t.APIError{Error: fmt.Errorf("author with id %v not found", id.Hex()).Error()})
+}
+
+type MergeAuthorsRequest struct {
+	AuthorIDs []string `json:"authorIds"`
+}
@@ -0,0 +1,320 @@
+package author
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	types "github.com/sikozonpc/notebase/types"
+	u "github.com/sikozonpc/notebase/utils"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestAuthorHandler(t *testing.T) {
+	userID := primitive.NewObjectID()
+
+	deepWork := &types.Book{ID: primitive.NewObjectID(), ISBN: "B01", Title: "Deep Work", Authors: "Cal Newport"}
+	digital := &types.Book{ID: primitive.NewObjectID(), ISBN: "B02", Title: "Digital Minimalism", Authors: "Newport, Calvin"}
+	cProgramming := &types.Book{ID: primitive.NewObjectID(), ISBN: "B03", Title: "The C Programming Language", Authors: "Brian Kernighan; Dennis Ritchie"}
+	notMine := &types.Book{ID: primitive.NewObjectID(), ISBN: "B04", Title: "Not in the library", Authors: "Jane Doe"}
+
+	authorStore := &mockAuthorStore{}
+	bookStore := &mockBookStore{books: []*types.Book{deepWork, digital, cProgramming, notMine}}
+	if err := LinkBook(context.Background(), authorStore, bookStore, notMine); err != nil {
+		t.Fatal(err)
+	}
+
+	libraryStore := &mockLibraryStore{entries: []*types.LibraryBook{
+		{UserID: userID, BookID: deepWork.ID},
+		{UserID: userID, BookID: digital.ID},
+		{UserID: userID, BookID: cProgramming.ID},
+	}}
+	highlightStore := &mockHighlightStore{stats: []*types.BookHighlightStats{
+		{BookID: "B01", HighlightCount: 3},
+		{BookID: "B02", HighlightCount: 2},
+		{BookID: "B03", HighlightCount: 1},
+	}}
+	handler := NewHandler(authorStore, bookStore, libraryStore, highlightStore, &mockUserStore{})
+
+	serve := func(t *testing.T, method, path, body string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(method, path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		router := mux.NewRouter()
+
+		router.HandleFunc("/user/{userID}/author", u.MakeHTTPHandler(handler.handleGetUserAuthors)).Methods(http.MethodGet)
+		router.HandleFunc("/user/{userID}/author/{id}/merge", u.MakeHTTPHandler(handler.handleMergeAuthors)).Methods(http.MethodPost)
+
+		router.ServeHTTP(rr, req)
+
+		return rr
+	}
+
+	getAuthors := func(t *testing.T) []*types.AuthorSummary {
+		rr := serve(t, http.MethodGet, "/user/"+userID.Hex()+"/author", "")
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		var authors []*types.AuthorSummary
+		if err := json.NewDecoder(rr.Body).Decode(&authors); err != nil {
+			t.Fatal(err)
+		}
+
+		return authors
+	}
+
+	t.Run("should list the user's authors by sort name", func(t *testing.T) {
+		authors := getAuthors(t)
+
+		names := make([]string, len(authors))
+		for i, a := range authors {
+			names[i] = a.Name
+		}
+
+		assert.Equal(t, []string{"Brian Kernighan", "Cal Newport", "Calvin Newport", "Dennis Ritchie"}, names)
+		assert.Equal(t, 1, authors[1].BookCount)
+		assert.Equal(t, 3, authors[1].HighlightCount)
+	})
+
+	t.Run("should not merge an author outside of the user's library", func(t *testing.T) {
+		rr := serve(t, http.MethodPost, "/user/"+userID.Hex()+"/author/"+deepWork.AuthorIDs[0].Hex()+"/merge", `{"authorIds": ["`+notMine.AuthorIDs[0].Hex()+`"]}`)
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("expected status code %d, got %d", http.StatusNotFound, rr.Code)
+		}
+	})
+
+	t.Run("should fail to merge an author into itself", func(t *testing.T) {
+		rr := serve(t, http.MethodPost, "/user/"+userID.Hex()+"/author/"+deepWork.AuthorIDs[0].Hex()+"/merge", `{"authorIds": ["`+deepWork.AuthorIDs[0].Hex()+`"]}`)
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+
+	t.Run("should merge duplicate authors", func(t *testing.T) {
+		target := deepWork.AuthorIDs[0]
+
+		rr := serve(t, http.MethodPost, "/user/"+userID.Hex()+"/author/"+target.Hex()+"/merge", `{"authorIds": ["`+digital.AuthorIDs[0].Hex()+`"]}`)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		var merged types.Author
+		if err := json.NewDecoder(rr.Body).Decode(&merged); err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, []string{"Calvin Newport"}, merged.Aliases)
+		assert.Equal(t, []primitive.ObjectID{target}, digital.AuthorIDs)
+
+		authors := getAuthors(t)
+		assert.Len(t, authors, 3)
+		assert.Equal(t, 2, authors[1].BookCount)
+		assert.Equal(t, 5, authors[1].HighlightCount)
+
+		// Found by the merged spelling from now on
+		found, err := authorStore.GetOrCreateAll(context.Background(), "Calvin Newport")
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, target, found[0].ID)
+	})
+
+	t.Run("should keep an author other users' books still have", func(t *testing.T) {
+		awk := &types.Book{ID: primitive.NewObjectID(), ISBN: "B05", Title: "The AWK Programming Language", Authors: "Brian W. Kernighan"}
+		unix := &types.Book{ID: primitive.NewObjectID(), ISBN: "B06", Title: "The Unix Programming Environment", Authors: "Kernighan, Brian W."}
+		bookStore.books = append(bookStore.books, awk, unix)
+		if err := LinkBook(context.Background(), authorStore, bookStore, unix); err != nil {
+			t.Fatal(err)
+		}
+		libraryStore.entries = append(libraryStore.entries, &types.LibraryBook{UserID: userID, BookID: awk.ID})
+		getAuthors(t)
+
+		target := cProgramming.AuthorIDs[0]
+		duplicate := awk.AuthorIDs[0]
+
+		rr := serve(t, http.MethodPost, "/user/"+userID.Hex()+"/author/"+target.Hex()+"/merge", `{"authorIds": ["`+duplicate.Hex()+`"]}`)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		assert.Equal(t, []primitive.ObjectID{target}, awk.AuthorIDs)
+		assert.Equal(t, []primitive.ObjectID{duplicate}, unix.AuthorIDs)
+
+		kept, err := authorStore.GetByIDs(context.Background(), []primitive.ObjectID{duplicate})
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Len(t, kept, 1)
+	})
+}
+
+type mockAuthorStore struct {
+	authors []*types.Author
+}
+
+func (m *mockAuthorStore) GetOrCreateAll(_ context.Context, authors string) ([]*types.Author, error) {
+	found := make([]*types.Author, 0)
+	for _, name := range ParseAuthors(authors) {
+		a := m.find(Key(name.Display))
+		if a == nil {
+			a = &types.Author{ID: primitive.NewObjectID(), Name: name.Display, SortName: name.Sort, Keys: []string{Key(name.Display)}}
+			m.authors = append(m.authors, a)
+		}
+
+		found = append(found, a)
+	}
+
+	return found, nil
+}
+
+func (m *mockAuthorStore) find(key string) *types.Author {
+	for _, a := range m.authors {
+		for _, k := range a.Keys {
+			if k == key {
+				return a
+			}
+		}
+	}
+
+	return nil
+}
+
+func (m *mockAuthorStore) GetByIDs(_ context.Context, ids []primitive.ObjectID) ([]*types.Author, error) {
+	authors := make([]*types.Author, 0)
+	for _, a := range m.authors {
+		for _, id := range ids {
+			if a.ID == id {
+				authors = append(authors, a)
+			}
+		}
+	}
+
+	return authors, nil
+}
+
+func (m *mockAuthorStore) Merge(ctx context.Context, targetID primitive.ObjectID, ids []primitive.ObjectID) (*types.Author, error) {
+	targets, _ := m.GetByIDs(ctx, []primitive.ObjectID{targetID})
+	merged, _ := m.GetByIDs(ctx, ids)
+	if len(targets) == 0 || len(merged) != len(ids) {
+		return nil, mongo.ErrNoDocuments
+	}
+
+	target := targets[0]
+	kept := make([]*types.Author, 0)
+	for _, a := range m.authors {
+		isMerged := false
+		for _, ma := range merged {
+			if a == ma {
+				isMerged = true
+				target.Aliases = append(target.Aliases, a.Name)
+				target.Keys = append(target.Keys, a.Keys...)
+			}
+		}
+
+		if !isMerged {
+			kept = append(kept, a)
+		}
+	}
+	m.authors = kept
+
+	return target, nil
+}
+
+type mockBookStore struct {
+	types.BookStore
+	books []*types.Book
+}
+
+func (m *mockBookStore) GetByIDs(_ context.Context, ids []primitive.ObjectID) ([]*types.Book, error) {
+	books := make([]*types.Book, 0)
+	for _, b := range m.books {
+		for _, id := range ids {
+			if b.ID == id {
+				books = append(books, b)
+			}
+		}
+	}
+
+	return books, nil
+}
+
+func (m *mockBookStore) SetAuthors(_ context.Context, id primitive.ObjectID, authorIDs []primitive.ObjectID) error {
+	for _, b := range m.books {
+		if b.ID == id {
+			b.AuthorIDs = authorIDs
+		}
+	}
+
+	return nil
+}
+
+func (m *mockBookStore) ReplaceAuthors(_ context.Context, bookIDs []primitive.ObjectID, from []primitive.ObjectID, to primitive.ObjectID) error {
+	for _, b := range m.books {
+		if !slices.Contains(bookIDs, b.ID) {
+			continue
+		}
+
+		ids := make([]primitive.ObjectID, 0)
+		for _, id := range b.AuthorIDs {
+			if slices.Contains(from, id) {
+				id = to
+			}
+			if !slices.Contains(ids, id) {
+				ids = append(ids, id)
+			}
+		}
+		b.AuthorIDs = ids
+	}
+
+	return nil
+}
+
+func (m *mockBookStore) CountAuthorBooks(_ context.Context, authorID primitive.ObjectID) (int64, error) {
+	var count int64
+	for _, b := range m.books {
+		if slices.Contains(b.AuthorIDs, authorID) {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+type mockLibraryStore struct {
+	types.LibraryStore
+	entries []*types.LibraryBook
+}
+
+func (m *mockLibraryStore) GetLibrary(_ context.Context, userID primitive.ObjectID) ([]*types.LibraryBook, error) {
+	entries := make([]*types.LibraryBook, 0)
+	for _, e := range m.entries {
+		if e.UserID == userID {
+			entries = append(entries, e)
+		}
+	}
+
+	return entries, nil
+}
+
+type mockHighlightStore struct {
+	types.HighlightStore
+	stats []*types.BookHighlightStats
+}
+
+func (m *mockHighlightStore) GetUserBookStats(context.Context, primitive.ObjectID) ([]*types.BookHighlightStats, error) {
+	return m.stats, nil
+}
+
+type mockUserStore struct {
+	types.UserStore
+}
@@ -0,0 +1,35 @@
+package author
+
+import (
+	"context"
+
+	t "github.com/sikozonpc/notebase/types"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// LinkBook links the book to the authors parsed from its authors string. A
+// book that is already linked is left as is, its authors may have been
+// merged since.
+func LinkBook(ctx context.Context, authors t.AuthorStore, books t.BookStore, b *t.Book) error {
+	if len(b.AuthorIDs) > 0 || b.Authors == "" {
+		return nil
+	}
+
+	found, err := authors.GetOrCreateAll(ctx, b.Authors)
+	if err != nil {
+		return err
+	}
+
+	ids := make([]primitive.ObjectID, len(found))
+	for i, a := range found {
+		ids[i] = a.ID
+	}
+
+	if err := books.SetAuthors(ctx, b.ID, ids); err != nil {
+		return err
+	}
+
+	b.AuthorIDs = ids
+
+	return nil
+}
@@ -0,0 +1,156 @@
+package author
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Name is an author's name as displayed and as sorted
+type Name struct {
+	Display string // "Ursula K. Le Guin"
+	Sort    string // "Le Guin, Ursula K."
+}
+
+var separators = regexp.MustCompile(`(?i)\s*(?:;|&|\band\b|\bwith\b)\s*`)
+
+// ParseAuthors splits a free-text authors string like "Jane Doe; John Smith"
+// or "Doe, Jane and Smith, John" into names. A single comma is read as
+// "Last, First", more of them separate authors.
+func ParseAuthors(s string) []Name {
+	names := make([]Name, 0)
+	seen := make(map[string]bool)
+
+	for _, part := range separators.Split(s, -1) {
+		for _, raw := range splitCommas(part) {
+			name := ParseName(raw)
+			if name.Display == "" || seen[Key(name.Display)] {
+				continue
+			}
+
+			seen[Key(name.Display)] = true
+			names = append(names, name)
+		}
+	}
+
+	return names
+}
+
+// splitCommas tells "Doe, Jane" apart from "Jane Doe, John Smith"
+func splitCommas(s string) []string {
+	parts := strings.Split(s, ",")
+	if len(parts) == 1 {
+		return parts
+	}
+
+	if len(parts) == 2 && (isSuffix(parts[1]) || isLastName(parts[0])) {
+		return []string{s}
+	}
+
+	// "Doe, Jane, Smith, John" has names made of pairs
+	if len(parts)%2 == 0 && allSingleWords(parts) {
+		names := make([]string, 0, len(parts)/2)
+		for i := 0; i < len(parts); i += 2 {
+			names = append(names, parts[i]+","+parts[i+1])
+		}
+		return names
+	}
+
+	return parts
+}
+
+// isLastName reports whether the words look like a last name alone, a
+// single word or one led by particles like "Le Guin"
+func isLastName(s string) bool {
+	words := strings.Fields(s)
+	for _, w := range words[:max(len(words)-1, 0)] {
+		if !particles[strings.ToLower(w)] {
+			return false
+		}
+	}
+
+	return len(words) > 0
+}
+
+func allSingleWords(parts []string) bool {
+	for _, p := range parts {
+		if len(strings.Fields(p)) != 1 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Family name particles that sort with the last name
+var particles = map[string]bool{
+	"da": true, "de": true, "del": true, "della": true, "der": true, "di": true,
+	"du": true, "la": true, "le": true, "van": true, "von": true,
+}
+
+var suffixes = map[string]bool{
+	"jr": true, "sr": true, "ii": true, "iii": true, "iv": true, "phd": true,
+}
+
+func isSuffix(s string) bool {
+	return suffixes[strings.Trim(strings.ToLower(strings.TrimSpace(s)), ".")]
+}
+
+// ParseName reads a single name written as "First Last" or "Last, First"
+func ParseName(s string) Name {
+	s = strings.Join(strings.Fields(s), " ")
+	if s == "" {
+		return Name{}
+	}
+
+	if last, first, ok := strings.Cut(s, ","); ok {
+		last, first = strings.TrimSpace(last), strings.TrimSpace(first)
+		switch {
+		case first == "":
+			s = last
+		case isSuffix(first):
+			// "Martin Luther King, Jr."
+			name := ParseName(last)
+			return Name{Display: last + ", " + first, Sort: name.Sort + ", " + first}
+		case last == "":
+			s = first
+		default:
+			return Name{Display: first + " " + last, Sort: last + ", " + first}
+		}
+	}
+
+	words := strings.Fields(s)
+	suffix := ""
+	if len(words) > 1 && isSuffix(words[len(words)-1]) {
+		suffix = words[len(words)-1]
+		words = words[:len(words)-1]
+	}
+
+	if len(words) == 1 {
+		return Name{Display: s, Sort: s}
+	}
+
+	// The last name starts at its particles, "Ludwig van Beethoven" sorts as
+	// "van Beethoven, Ludwig"
+	start := len(words) - 1
+	for start > 1 && particles[strings.ToLower(words[start-1])] {
+		start--
+	}
+
+	sort := strings.Join(words[start:], " ") + ", " + strings.Join(words[:start], " ")
+	if suffix != "" {
+		sort += ", " + suffix
+	}
+
+	return Name{Display: s, Sort: sort}
+}
+
+// Key makes spellings of the same name equal, "J.R.R. Tolkien" and
+// "j. r. r. tolkien" have the same key
+func Key(name string) string {
+	n := ParseName(name)
+
+	return strings.Join(strings.FieldsFunc(strings.ToLower(n.Display), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}), " ")
+}
@@ -0,0 +1,36 @@
+package author
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseAuthors(t *testing.T) {
+	cases := map[string][]Name{
+		"Cal Newport":                {{"Cal Newport", "Newport, Cal"}},
+		"Newport, Cal":               {{"Cal Newport", "Newport, Cal"}},
+		"Jane Doe; John Smith":       {{"Jane Doe", "Doe, Jane"}, {"John Smith", "Smith, John"}},
+		"Doe, Jane and Smith, John":  {{"Jane Doe", "Doe, Jane"}, {"John Smith", "Smith, John"}},
+		"Jane Doe, John Smith":       {{"Jane Doe", "Doe, Jane"}, {"John Smith", "Smith, John"}},
+		"Doe, Jane, Smith, John":     {{"Jane Doe", "Doe, Jane"}, {"John Smith", "Smith, John"}},
+		"Kernighan & Ritchie":        {{"Kernighan", "Kernighan"}, {"Ritchie", "Ritchie"}},
+		"Ursula K. Le Guin":          {{"Ursula K. Le Guin", "Le Guin, Ursula K."}},
+		"Le Guin, Ursula K.":         {{"Ursula K. Le Guin", "Le Guin, Ursula K."}},
+		"Martin Luther King, Jr.":    {{"Martin Luther King, Jr.", "King, Martin Luther, Jr."}},
+		"Ludwig  van Beethoven":      {{"Ludwig van Beethoven", "van Beethoven, Ludwig"}},
+		"Jane Doe; jane   doe":       {{"Jane Doe", "Doe, Jane"}},
+		" ; ":                        {},
+		"Steve Krug with Jane Smith": {{"Steve Krug", "Krug, Steve"}, {"Jane Smith", "Smith, Jane"}},
+	}
+
+	for in, want := range cases {
+		assert.Equal(t, want, ParseAuthors(in), in)
+	}
+}
+
+func TestKey(t *testing.T) {
+	assert.Equal(t, "j r r tolkien", Key("J.R.R. Tolkien"))
+	assert.Equal(t, Key("J.R.R. Tolkien"), Key("Tolkien, J. R. R."))
+	assert.NotEqual(t, Key("Jane Doe"), Key("John Doe"))
+}
@@ -0,0 +1,129 @@
+package author
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	t "github.com/sikozonpc/notebase/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const CollName = "authors"
+
+type Store struct {
+	db *mongo.Database
+}
+
+func NewStore(db *mongo.Database) *Store {
+	return &Store{db: db}
+}
+
+// GetOrCreateAll returns the authors of a free-text authors string, in the
+// order they are written, creating the ones that don't exist. An author is
+// found by any spelling it was merged with.
+func (s *Store) GetOrCreateAll(ctx context.Context, authors string) ([]*t.Author, error) {
+	col := s.db.Collection(CollName)
+
+	names := ParseAuthors(authors)
+	found := make([]*t.Author, 0, len(names))
+	for _, name := range names {
+		key := Key(name.Display)
+
+		var a t.Author
+		err := col.FindOneAndUpdate(ctx, bson.M{
+			"keys": key,
+		}, bson.M{
+			"$setOnInsert": bson.M{
+				"_id":       primitive.NewObjectID(),
+				"name":      name.Display,
+				"sortName":  name.Sort,
+				"keys":      []string{key},
+				"createdAt": time.Now().UTC(),
+			},
+		}, options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)).Decode(&a)
+		if err != nil {
+			return nil, err
+		}
+
+		found = append(found, &a)
+	}
+
+	return found, nil
+}
+
+func (s *Store) GetByIDs(ctx context.Context, ids []primitive.ObjectID) ([]*t.Author, error) {
+	col := s.db.Collection(CollName)
+
+	cursor, err := col.Find(ctx, bson.M{
+		"_id": bson.M{"$in": ids},
+	}, options.Find().SetSort(bson.D{{Key: "sortName", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+
+	authors := make([]*t.Author, 0)
+	if err = cursor.All(ctx, &authors); err != nil {
+		return nil, err
+	}
+
+	return authors, nil
+}
+
+// Merge folds the other authors into the target, their names become its
+// aliases. Books have to be linked to the target beforehand.
+func (s *Store) Merge(ctx context.Context, targetID primitive.ObjectID, ids []primitive.ObjectID) (*t.Author, error) {
+	col := s.db.Collection(CollName)
+
+	var target t.Author
+	if err := col.FindOne(ctx, bson.M{"_id": targetID}).Decode(&target); err != nil {
+		return nil, err
+	}
+
+	merged, err := s.GetByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	if len(merged) != len(ids) {
+		return nil, mongo.ErrNoDocuments
+	}
+
+	aliases := make([]string, 0)
+	keys := make([]string, 0)
+	for _, a := range merged {
+		for _, name := range append([]string{a.Name}, a.Aliases...) {
+			if name != target.Name && !slices.Contains(aliases, name) {
+				aliases = append(aliases, name)
+			}
+		}
+		keys = append(keys, a.Keys...)
+	}
+
+	if _, err := col.UpdateOne(ctx, bson.M{
+		"_id": targetID,
+	}, bson.M{
+		"$addToSet": bson.M{"aliases": bson.M{"$each": aliases}},
+	}); err != nil {
+		return nil, err
+	}
+
+	// The keys are unique, they can only move once the merged authors are gone
+	if _, err := col.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": ids}}); err != nil {
+		return nil, err
+	}
+
+	var a t.Author
+	err = col.FindOneAndUpdate(ctx, bson.M{
+		"_id": targetID,
+	}, bson.M{
+		"$addToSet": bson.M{"keys": bson.M{"$each": keys}},
+	}, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&a)
+	if err != nil {
+		return nil, err
+	}
+
+	return &a, nil
+}
@@ -0,0 +1,232 @@
+package book
+
+import (
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/sikozonpc/notebase/author"
+	t "github.com/sikozonpc/notebase/types"
+)
+
+// Books at least this similar are considered duplicates by default
+const DefaultDuplicateThreshold = 0.8
+
+// FindDuplicates groups the books that are likely the same one: they share
+// an ISBN, or have titles with a Jaccard similarity of their words of at
+// least threshold and an author in common. Books are grouped transitively,
+// and groups are returned largest first.
+func FindDuplicates(books []*t.Book, threshold float64) []*t.DuplicateBookGroup {
+	docs := make([]*bookDoc, len(books))
+	for i, b := range books {
+		docs[i] = newBookDoc(b)
+	}
+
+	parent := make([]int, len(docs))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+
+	lowest := make(map[int]float64)
+	for x := 0; x < len(docs); x++ {
+		for y := x + 1; y < len(docs); y++ {
+			sim := similarity(docs[x], docs[y])
+			if sim < threshold {
+				continue
+			}
+
+			parent[find(x)] = find(y)
+			for _, i := range []int{x, y} {
+				if s, ok := lowest[i]; !ok || sim < s {
+					lowest[i] = sim
+				}
+			}
+		}
+	}
+
+	members := make(map[int][]int)
+	for i := range docs {
+		root := find(i)
+		members[root] = append(members[root], i)
+	}
+
+	groups := make([]*t.DuplicateBookGroup, 0)
+	for _, m := range members {
+		if len(m) < 2 {
+			continue
+		}
+
+		group := &t.DuplicateBookGroup{Similarity: 1}
+		for _, i := range m {
+			group.Books = append(group.Books, docs[i].book)
+			if lowest[i] < group.Similarity {
+				group.Similarity = lowest[i]
+			}
+		}
+
+		// Oldest first, it's usually the one to keep
+		sort.Slice(group.Books, func(i, j int) bool {
+			a, b := group.Books[i], group.Books[j]
+			if !a.CreatedAt.Equal(b.CreatedAt) {
+				return a.CreatedAt.Before(b.CreatedAt)
+			}
+			return a.ID.Hex() < b.ID.Hex()
+		})
+
+		groups = append(groups, group)
+	}
+
+	sort.Slice(groups, func(i, j int) bool {
+		if len(groups[i].Books) != len(groups[j].Books) {
+			return len(groups[i].Books) > len(groups[j].Books)
+		}
+		return groups[i].Books[0].ID.Hex() < groups[j].Books[0].ID.Hex()
+	})
+
+	return groups
+}
+
+type bookDoc struct {
+	book        *t.Book
+	identifiers []string // ISBN-13s where possible, so both forms compare equal
+	title       map[string]bool
+	authors     []string // Author IDs if linked, name keys otherwise
+	linked      bool
+}
+
+func newBookDoc(b *t.Book) *bookDoc {
+	d := &bookDoc{book: b, title: titleWords(b.Title)}
+
+	for _, raw := range append([]string{b.ISBN}, b.Identifiers...) {
+		if id, err := ParseIdentifier(raw); err == nil {
+			raw = id.Value
+			if id.Type == IdentifierISBN10 {
+				raw = ISBN10To13(id.Value)
+			}
+		}
+		d.identifiers = append(d.identifiers, raw)
+	}
+	if b.Metadata != nil && b.Metadata.ISBN13 != "" {
+		d.identifiers = append(d.identifiers, b.Metadata.ISBN13)
+	}
+
+	d.linked = len(b.AuthorIDs) > 0
+	if d.linked {
+		for _, id := range b.AuthorIDs {
+			d.authors = append(d.authors, id.Hex())
+		}
+	} else {
+		for _, name := range author.ParseAuthors(b.Authors) {
+			d.authors = append(d.authors, author.Key(name.Display))
+		}
+	}
+
+	return d
+}
+
+// similarity is 1 for books sharing an identifier, otherwise the similarity
+// of their titles if their authors match
+func similarity(a, b *bookDoc) float64 {
+	for _, id := range a.identifiers {
+		if slices.Contains(b.identifiers, id) {
+			return 1
+		}
+	}
+
+	// Linked and unlinked books can't be compared by author, the title decides
+	if len(a.authors) > 0 && len(b.authors) > 0 && a.linked == b.linked {
+		shared := false
+		for _, name := range a.authors {
+			shared = shared || slices.Contains(b.authors, name)
+		}
+		if !shared {
+			return 0
+		}
+	}
+
+	return jaccard(a.title, b.title)
+}
+
+// titleWords normalizes a title to its main words: subtitles, edition notes
+// in parentheses and leading articles are dropped
+func titleWords(title string) map[string]bool {
+	title = strings.ToLower(title)
+	for _, sep := range []string{":", " - ", "("} {
+		if i := strings.Index(title, sep); i > 0 {
+			title = title[:i]
+		}
+	}
+
+	words := strings.FieldsFunc(title, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(words) > 1 && articles[words[0]] {
+		words = words[1:]
+	}
+
+	set := make(map[string]bool, len(words))
+	for _, w := range words {
+		set[w] = true
+	}
+
+	return set
+}
+
+var articles = map[string]bool{"the": true, "a": true, "an": true}
+
+func jaccard(a, b map[string]bool) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+
+	shared := 0
+	for w := range a {
+		if b[w] {
+			shared++
+		}
+	}
+
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
+
+// SameEdition reports whether the books share a valid ISBN, in either form.
+// Unlike a similar title, that's proof enough they're the same book for
+// every user who has one of them. The metadata's ISBN isn't, providers may
+// have matched the book by title.
+func SameEdition(a, b *t.Book) bool {
+	isbns := editionISBNs(a)
+	for _, isbn := range editionISBNs(b) {
+		if slices.Contains(isbns, isbn) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// editionISBNs returns the book's own valid ISBNs as ISBN-13s
+func editionISBNs(b *t.Book) []string {
+	raws := append([]string{b.ISBN}, b.Identifiers...)
+
+	isbns := make([]string, 0, len(raws))
+	for _, raw := range raws {
+		id, err := ParseIdentifier(raw)
+		switch {
+		case err != nil:
+		case id.Type == IdentifierISBN13:
+			isbns = append(isbns, id.Value)
+		case id.Type == IdentifierISBN10:
+			isbns = append(isbns, ISBN10To13(id.Value))
+		}
+	}
+
+	return isbns
+}
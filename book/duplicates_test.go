@@ -0,0 +1,70 @@
+package book
+
+import (
+	"testing"
+	"time"
+
+	types "github.com/sikozonpc/notebase/types"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestFindDuplicates(t *testing.T) {
+	day := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	book := func(isbn, title, authors string, age int) *types.Book {
+		return &types.Book{ID: primitive.NewObjectID(), ISBN: isbn, Title: title, Authors: authors, CreatedAt: day.AddDate(0, 0, age)}
+	}
+
+	t.Run("should group books with the same title and author", func(t *testing.T) {
+		kindle := book("B01", "Deep Work: Rules for Focused Success in a Distracted World", "Cal Newport", 1)
+		paper := book("9780306406157", "Deep Work", "Newport, Cal", 0)
+		other := book("B02", "Deep Work", "Someone Else", 2)
+
+		groups := FindDuplicates([]*types.Book{kindle, paper, other}, DefaultDuplicateThreshold)
+
+		assert.Len(t, groups, 1)
+		assert.Equal(t, []*types.Book{paper, kindle}, groups[0].Books)
+		assert.Equal(t, 1.0, groups[0].Similarity)
+	})
+
+	t.Run("should group books sharing an ISBN under either form", func(t *testing.T) {
+		isbn10 := book("0306406152", "Some Title", "Jane Doe", 0)
+		isbn13 := book("9780306406157", "A Different Title", "John Smith", 1)
+
+		groups := FindDuplicates([]*types.Book{isbn10, isbn13}, DefaultDuplicateThreshold)
+
+		assert.Len(t, groups, 1)
+		assert.Len(t, groups[0].Books, 2)
+	})
+
+	t.Run("should not group different books", func(t *testing.T) {
+		a := book("B01", "The Pragmatic Programmer", "Andy Hunt", 0)
+		b := book("B02", "The Passionate Programmer", "Chad Fowler", 1)
+		c := book("B03", "The Mythical Man-Month", "Fred Brooks", 2)
+
+		assert.Empty(t, FindDuplicates([]*types.Book{a, b, c}, DefaultDuplicateThreshold))
+	})
+
+	t.Run("should use the threshold for similar titles", func(t *testing.T) {
+		a := book("B01", "Thinking, Fast and Slow", "Daniel Kahneman", 0)
+		b := book("B02", "Thinking Fast", "Daniel Kahneman", 1)
+
+		assert.Empty(t, FindDuplicates([]*types.Book{a, b}, DefaultDuplicateThreshold))
+
+		groups := FindDuplicates([]*types.Book{a, b}, 0.5)
+		assert.Len(t, groups, 1)
+		assert.Equal(t, 0.5, groups[0].Similarity)
+	})
+}
+
+func TestSameEdition(t *testing.T) {
+	isbn13 := &types.Book{ISBN: "9780306406157"}
+	isbn10 := &types.Book{ISBN: "B01", Identifiers: []string{"0-306-40615-2"}}
+	asin := &types.Book{ISBN: "B01"}
+	invalid := &types.Book{ISBN: "9780306406158"}
+
+	assert.True(t, SameEdition(isbn13, isbn10))
+	assert.False(t, SameEdition(isbn10, asin), "an ASIN isn't an edition")
+	assert.False(t, SameEdition(invalid, &types.Book{ISBN: "9780306406158"}), "an invalid ISBN proves nothing")
+	assert.False(t, SameEdition(isbn13, &types.Book{ISBN: "B02", Metadata: &types.BookMetadata{ISBN13: "9780306406157"}}), "providers may have found the wrong book")
+}
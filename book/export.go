@@ -0,0 +1,153 @@
+package book
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	t "github.com/sikozonpc/notebase/types"
+	u "github.com/sikozonpc/notebase/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// exportedBook is a book of the user's library with what they wrote about it
+type exportedBook struct {
+	book       *t.UserBook
+	highlights []*t.Highlight
+	notes      []*t.Note
+}
+
+// handleExport exports the user's highlights and notes as Markdown, a section
+// per book followed by the notes not about a book
+func (h *Handler) handleExport(w http.ResponseWriter, r *http.Request) error {
+	userID, err := u.GetStringParamFromRequest(r, "userID")
+	if err != nil {
+		return err
+	}
+	oUserID, _ := primitive.ObjectIDFromHex(userID)
+
+	entries, err := h.libraryStore.GetLibrary(r.Context(), oUserID)
+	if err != nil {
+		return err
+	}
+
+	ids := make([]primitive.ObjectID, len(entries))
+	for i, e := range entries {
+		ids[i] = e.BookID
+	}
+
+	books, err := h.store.GetByIDs(r.Context(), ids)
+	if err != nil {
+		return err
+	}
+
+	byID := make(map[primitive.ObjectID]*t.Book, len(books))
+	for _, b := range books {
+		byID[b.ID] = b
+	}
+
+	notes, err := h.noteStore.GetUserNotes(r.Context(), oUserID, &t.NoteFilter{})
+	if err != nil {
+		return err
+	}
+
+	notesByBook := make(map[primitive.ObjectID][]*t.Note)
+	for _, n := range notes {
+		if n.BookID != nil {
+			notesByBook[*n.BookID] = append(notesByBook[*n.BookID], n)
+		}
+	}
+
+	exported := make([]*exportedBook, 0, len(entries))
+	for _, e := range entries {
+		b, ok := byID[e.BookID]
+		if !ok {
+			continue
+		}
+
+		page, err := h.highlightStore.GetUserHighlights(r.Context(), oUserID, &t.HighlightFilter{
+			BookID: b.ISBN,
+			Sort:   t.HighlightSortLocation,
+		})
+		if err != nil {
+			return err
+		}
+
+		exported = append(exported, &exportedBook{
+			book:       userBook(b, e),
+			highlights: page.Highlights,
+			notes:      notesByBook[b.ID],
+		})
+		delete(notesByBook, b.ID)
+	}
+
+	// Notes about a book that's no longer in the library are kept too
+	loose := make([]*t.Note, 0)
+	for _, n := range notes {
+		if n.BookID == nil || notesByBook[*n.BookID] != nil {
+			loose = append(loose, n)
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="notebase.md"`)
+	w.WriteHeader(http.StatusOK)
+
+	_, err = w.Write([]byte(exportMarkdown(exported, loose)))
+	return err
+}
+
+// exportMarkdown writes the books that have highlights or notes, each
+// highlight as a quote followed by its note
+func exportMarkdown(books []*exportedBook, notes []*t.Note) string {
+	var sb strings.Builder
+
+	for _, b := range books {
+		if len(b.highlights) == 0 && len(b.notes) == 0 {
+			continue
+		}
+
+		fmt.Fprintf(&sb, "# %s\n\n", b.book.Title)
+		if b.book.Authors != "" {
+			fmt.Fprintf(&sb, "_%s_\n\n", b.book.Authors)
+		}
+
+		for _, h := range b.highlights {
+			for _, line := range strings.Split(strings.TrimSpace(h.Text), "\n") {
+				fmt.Fprintf(&sb, "> %s\n", line)
+			}
+			sb.WriteString("\n")
+
+			if note := strings.TrimSpace(h.Note); note != "" {
+				fmt.Fprintf(&sb, "%s\n\n", note)
+			}
+			writeTags(&sb, h.Tags)
+		}
+
+		if len(b.notes) > 0 {
+			sb.WriteString("## Notes\n\n")
+			writeNotes(&sb, b.notes)
+		}
+	}
+
+	if len(notes) > 0 {
+		sb.WriteString("# Notes\n\n")
+		writeNotes(&sb, notes)
+	}
+
+	return sb.String()
+}
+
+func writeNotes(sb *strings.Builder, notes []*t.Note) {
+	for _, n := range notes {
+		fmt.Fprintf(sb, "%s\n\n", strings.TrimSpace(n.Body))
+		writeTags(sb, n.Tags)
+		sb.WriteString("---\n\n")
+	}
+}
+
+func writeTags(sb *strings.Builder, tags []string) {
+	if len(tags) > 0 {
+		fmt.Fprintf(sb, "Tags: %s\n\n", strings.Join(tags, ", "))
+	}
+}
@@ -0,0 +1,79 @@
+package book
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	types "github.com/sikozonpc/notebase/types"
+	u "github.com/sikozonpc/notebase/utils"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestExportMarkdown(t *testing.T) {
+	book := &exportedBook{
+		book: &types.UserBook{Book: &types.Book{Title: "Deep Work", Authors: "Cal Newport"}},
+		highlights: []*types.Highlight{
+			{Text: "Clarity about what matters\nprovides clarity about what does not.", Note: "Say no more often", Tags: []string{"focus"}},
+			{Text: "Who you are is what you focus on."},
+		},
+		notes: []*types.Note{{Body: "Try a shutdown ritual"}},
+	}
+	empty := &exportedBook{book: &types.UserBook{Book: &types.Book{Title: "The Mythical Man-Month"}}}
+
+	md := exportMarkdown([]*exportedBook{book, empty}, []*types.Note{{Body: "# Ideas\n\nWrite every day", Tags: []string{"writing"}}})
+
+	assert.Equal(t, "# Deep Work\n\n"+
+		"_Cal Newport_\n\n"+
+		"> Clarity about what matters\n> provides clarity about what does not.\n\n"+
+		"Say no more often\n\n"+
+		"Tags: focus\n\n"+
+		"> Who you are is what you focus on.\n\n"+
+		"## Notes\n\n"+
+		"Try a shutdown ritual\n\n---\n\n"+
+		"# Notes\n\n"+
+		"# Ideas\n\nWrite every day\n\nTags: writing\n\n---\n\n", md)
+}
+
+func TestHandleExport(t *testing.T) {
+	userID := primitive.NewObjectID()
+	book := &types.Book{ID: primitive.NewObjectID(), ISBN: "B01", Title: "Deep Work"}
+	removed := primitive.NewObjectID()
+
+	store := &mockBookStore{books: []*types.Book{book}}
+	libraryStore := &mockLibraryStore{entries: []*types.LibraryBook{{UserID: userID, BookID: book.ID, Title: "Deep Work (2016)"}}}
+	highlightStore := &mockHighlightStore{highlights: []*types.Highlight{
+		{ID: primitive.NewObjectID(), UserID: userID, BookID: book.ISBN, Text: "Focus is a skill."},
+	}}
+	noteStore := &mockNoteStore{notes: []*types.Note{
+		{Body: "About the book", BookID: &book.ID},
+		{Body: "About a removed book", BookID: &removed},
+		{Body: "Not about a book"},
+	}}
+	handler := NewHandler(store, libraryStore, &mockReadingStore{}, noteStore, &mockAuthorStore{}, highlightStore, &mockUserStore{})
+
+	req, err := http.NewRequest(http.MethodGet, "/user/"+userID.Hex()+"/export", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	router := mux.NewRouter()
+	router.HandleFunc("/user/{userID}/export", u.MakeHTTPHandler(handler.handleExport)).Methods(http.MethodGet)
+	router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status code %d, got %d", http.StatusOK, rr.Code)
+	}
+
+	assert.Equal(t, "text/markdown; charset=utf-8", rr.Header().Get("Content-Type"))
+	assert.Equal(t, "# Deep Work (2016)\n\n"+
+		"> Focus is a skill.\n\n"+
+		"## Notes\n\n"+
+		"About the book\n\n---\n\n"+
+		"# Notes\n\n"+
+		"About a removed book\n\n---\n\n"+
+		"Not about a book\n\n---\n\n", rr.Body.String())
+}
@@ -0,0 +1,581 @@
+package book
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/sikozonpc/notebase/auth"
+	"github.com/sikozonpc/notebase/author"
+	"github.com/sikozonpc/notebase/cover"
+	t "github.com/sikozonpc/notebase/types"
+	u "github.com/sikozonpc/notebase/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type Handler struct {
+	store          t.BookStore
+	libraryStore   t.LibraryStore
+	readingStore   t.ReadingLogStore
+	noteStore      t.NoteStore
+	authorStore    t.AuthorStore
+	highlightStore t.HighlightStore
+	userStore      t.UserStore
+}
+
+func NewHandler(store t.BookStore, libraryStore t.LibraryStore, readingStore t.ReadingLogStore, noteStore t.NoteStore, authorStore t.AuthorStore, highlightStore t.HighlightStore, userStore t.UserStore) *Handler {
+	return &Handler{
+		store:          store,
+		libraryStore:   libraryStore,
+		readingStore:   readingStore,
+		noteStore:      noteStore,
+		authorStore:    authorStore,
+		highlightStore: highlightStore,
+		userStore:      userStore,
+	}
+}
+
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc(
+		"/user/{userID}/book",
+		auth.WithJWTAuth(u.MakeHTTPHandler(h.handleGetUserBooks), h.userStore),
+	).Methods("GET")
+
+	router.HandleFunc(
+		"/user/{userID}/book",
+		auth.WithJWTAuth(u.MakeHTTPHandler(h.handleAddBook), h.userStore),
+	).Methods("POST")
+
+	// Registered before /book/{id} so "duplicates" isn't read as an id
+	router.HandleFunc(
+		"/user/{userID}/book/duplicates",
+		auth.WithJWTAuth(u.MakeHTTPHandler(h.handleGetDuplicateBooks), h.userStore),
+	).Methods("GET")
+
+	router.HandleFunc(
+		"/user/{userID}/book/{id}",
+		auth.WithJWTAuth(u.MakeHTTPHandler(h.handleGetBook), h.userStore),
+	).Methods("GET")
+
+	router.HandleFunc(
+		"/user/{userID}/book/{id}",
+		auth.WithJWTAuth(u.MakeHTTPHandler(h.handleUpdateBook), h.userStore),
+	).Methods("PATCH")
+
+	router.HandleFunc(
+		"/user/{userID}/book/{id}",
+		auth.WithJWTAuth(u.MakeHTTPHandler(h.handleDeleteBook), h.userStore),
+	).Methods("DELETE")
+
+	router.HandleFunc(
+		"/user/{userID}/book/{id}/merge",
+		auth.WithJWTAuth(u.MakeHTTPHandler(h.handleMergeBooks), h.userStore),
+	).Methods("POST")
+
+	router.HandleFunc(
+		"/user/{userID}/export",
+		auth.WithJWTAuth(u.MakeHTTPHandler(h.handleExport), h.userStore),
+	).Methods("GET")
+}
+
+// handleGetUserBooks lists the books in the user's library, most recently
+// highlighted first
+func (h *Handler) handleGetUserBooks(w http.ResponseWriter, r *http.Request) error {
+	userID, err := u.GetStringParamFromRequest(r, "userID")
+	if err != nil {
+		return err
+	}
+	oUserID, _ := primitive.ObjectIDFromHex(userID)
+
+	entries, err := h.libraryStore.GetLibrary(r.Context(), oUserID)
+	if err != nil {
+		return err
+	}
+
+	ids := make([]primitive.ObjectID, len(entries))
+	for i, e := range entries {
+		ids[i] = e.BookID
+	}
+
+	books, err := h.store.GetByIDs(r.Context(), ids)
+	if err != nil {
+		return err
+	}
+
+	byID := make(map[primitive.ObjectID]*t.Book, len(books))
+	for _, b := range books {
+		byID[b.ID] = b
+	}
+
+	stats, err := h.highlightStore.GetUserBookStats(r.Context(), oUserID)
+	if err != nil {
+		return err
+	}
+
+	byISBN := make(map[string]*t.BookHighlightStats, len(stats))
+	for _, s := range stats {
+		byISBN[s.BookID] = s
+	}
+
+	userBooks := make([]*t.UserBook, 0, len(entries))
+	for _, e := range entries {
+		b, ok := byID[e.BookID]
+		if !ok {
+			continue
+		}
+
+		ub := userBook(b, e)
+		if s, ok := byISBN[b.ISBN]; ok {
+			ub.HighlightCount = s.HighlightCount
+			ub.LastHighlightedAt = &s.LastHighlightedAt
+		}
+
+		userBooks = append(userBooks, ub)
+	}
+
+	// Books without highlights go last, in the order they were added
+	sort.SliceStable(userBooks, func(i, j int) bool {
+		a, b := userBooks[i].LastHighlightedAt, userBooks[j].LastHighlightedAt
+		if a == nil || b == nil {
+			return a != nil && b == nil
+		}
+
+		return a.After(*b)
+	})
+
+	return u.WriteJSON(w, http.StatusOK, userBooks)
+}
+
+// handleAddBook adds a book to the user's library, creating it if no user
+// has it yet
+func (h *Handler) handleAddBook(w http.ResponseWriter, r *http.Request) error {
+	userID, err := u.GetStringParamFromRequest(r, "userID")
+	if err != nil {
+		return err
+	}
+	oUserID, _ := primitive.ObjectIDFromHex(userID)
+
+	payload := new(t.CreateBookRequest)
+	if err := json.NewDecoder(r.Body).Decode(payload); err != nil {
+		return u.WriteJSON(w, http.StatusBadRequest, t.APIError{Error: err.Error()})
+	}
+
+	if strings.TrimSpace(payload.ISBN) == "" || strings.TrimSpace(payload.Title) == "" {
+		return u.WriteJSON(w, http.StatusBadRequest, t.APIError{Error: fmt.Errorf("isbn and title are required").Error()})
+	}
+
+	id, err := ParseIdentifier(payload.ISBN)
+	if err != nil || id.Type == IdentifierID {
+		return u.WriteJSON(w, http.StatusBadRequest, t.APIError{Error: fmt.Errorf("isbn must be an ISBN-10, ISBN-13 or ASIN").Error()})
+	}
+	payload.ISBN = id.Value
+
+	book, err := h.store.GetOrCreate(r.Context(), payload)
+	if err != nil {
+		return err
+	}
+
+	if err := author.LinkBook(r.Context(), h.authorStore, h.store, book); err != nil {
+		return err
+	}
+
+	entry, err := h.libraryStore.AddBook(r.Context(), oUserID, book.ID)
+	if err != nil {
+		return err
+	}
+
+	return u.WriteJSON(w, http.StatusCreated, userBook(book, entry))
+}
+
+// handleGetBook returns the book with the user's highlights of it in reading
+// order, and their notes about it
+func (h *Handler) handleGetBook(w http.ResponseWriter, r *http.Request) error {
+	oUserID, oID, err := getIDsFromRequest(r)
+	if err != nil {
+		return err
+	}
+
+	book, entry, err := h.getUserBook(r, oUserID, oID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return notFound(w, oID)
+	}
+	if err != nil {
+		return err
+	}
+
+	page, err := h.highlightStore.GetUserHighlights(r.Context(), oUserID, &t.HighlightFilter{
+		BookID: book.ISBN,
+		Sort:   t.HighlightSortLocation,
+	})
+	if err != nil {
+		return err
+	}
+
+	notes, err := h.noteStore.GetUserNotes(r.Context(), oUserID, &t.NoteFilter{BookID: &book.ID})
+	if err != nil {
+		return err
+	}
+
+	detail := &t.BookDetail{
+		UserBook:   *userBook(book, entry),
+		Highlights: page.Highlights,
+		Notes:      notes,
+	}
+	detail.HighlightCount = len(page.Highlights)
+	for _, hl := range page.Highlights {
+		if detail.LastHighlightedAt == nil || hl.CreatedAt.After(*detail.LastHighlightedAt) {
+			createdAt := hl.CreatedAt
+			detail.LastHighlightedAt = &createdAt
+		}
+	}
+
+	return u.WriteJSON(w, http.StatusOK, detail)
+}
+
+// handleUpdateBook changes the book in the user's library only, other users
+// keep seeing the book as it was
+func (h *Handler) handleUpdateBook(w http.ResponseWriter, r *http.Request) error {
+	oUserID, oID, err := getIDsFromRequest(r)
+	if err != nil {
+		return err
+	}
+
+	payload := new(t.UpdateLibraryBookRequest)
+	if err := json.NewDecoder(r.Body).Decode(payload); err != nil {
+		return u.WriteJSON(w, http.StatusBadRequest, t.APIError{Error: err.Error()})
+	}
+
+	if err := validateUpdateBookRequest(payload); err != nil {
+		return u.WriteJSON(w, http.StatusBadRequest, t.APIError{Error: err.Error()})
+	}
+
+	book, entry, err := h.getUserBook(r, oUserID, oID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return notFound(w, oID)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Starting or finishing a book without a date happened now
+	now := time.Now().UTC()
+	if payload.Status != nil && *payload.Status != entry.Status {
+		switch *payload.Status {
+		case t.ReadingStatusReading:
+			if payload.StartedAt == nil && entry.StartedAt == nil {
+				payload.StartedAt = &now
+			}
+		case t.ReadingStatusFinished:
+			if payload.FinishedAt == nil {
+				payload.FinishedAt = &now
+			}
+		}
+	}
+
+	entry, err = h.libraryStore.UpdateLibraryBook(r.Context(), oUserID, oID, payload)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return notFound(w, oID)
+	}
+	if err != nil {
+		return err
+	}
+
+	return u.WriteJSON(w, http.StatusOK, userBook(book, entry))
+}
+
+// getUserBook returns the book and its entry in the user's library, or
+// mongo.ErrNoDocuments if it's not in it
+func (h *Handler) getUserBook(r *http.Request, userID, id primitive.ObjectID) (*t.Book, *t.LibraryBook, error) {
+	entry, err := h.libraryStore.GetLibraryBook(r.Context(), userID, id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	book, err := h.store.GetByID(r.Context(), id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return book, entry, nil
+}
+
+// userBook applies what the user changed about the book to a copy of it
+func userBook(b *t.Book, entry *t.LibraryBook) *t.UserBook {
+	book := *b
+	if entry.Title != "" {
+		book.Title = entry.Title
+	}
+	if entry.Authors != "" {
+		book.Authors = entry.Authors
+	}
+
+	return &t.UserBook{
+		Book:       &book,
+		Status:     entry.Status,
+		StartedAt:  entry.StartedAt,
+		FinishedAt: entry.FinishedAt,
+		CoverURL:   cover.URL(b, false),
+		Progress:   entry.Progress,
+	}
+}
+
+func validateUpdateBookRequest(req *t.UpdateLibraryBookRequest) error {
+	if req.Title == nil && req.Authors == nil && req.Status == nil && req.StartedAt == nil && req.FinishedAt == nil {
+		return fmt.Errorf("nothing to update")
+	}
+
+	if req.Title != nil && *req.Title != "" && strings.TrimSpace(*req.Title) == "" {
+		return fmt.Errorf("title cannot be blank")
+	}
+
+	if req.Status != nil {
+		switch *req.Status {
+		case "", t.ReadingStatusToRead, t.ReadingStatusReading, t.ReadingStatusFinished, t.ReadingStatusAbandoned:
+		default:
+			return fmt.Errorf("status must be one of %s, %s, %s or %s", t.ReadingStatusToRead, t.ReadingStatusReading, t.ReadingStatusFinished, t.ReadingStatusAbandoned)
+		}
+	}
+
+	if req.StartedAt != nil && req.FinishedAt != nil && req.FinishedAt.Before(*req.StartedAt) {
+		return fmt.Errorf("finishedAt cannot be before startedAt")
+	}
+
+	return nil
+}
+
+// handleDeleteBook removes the book from the user's library and moves their
+// highlights of it to the trash. Books are shared between users, so the book
+// itself only goes to the trash once no library has it.
+func (h *Handler) handleDeleteBook(w http.ResponseWriter, r *http.Request) error {
+	oUserID, oID, err := getIDsFromRequest(r)
+	if err != nil {
+		return err
+	}
+
+	book, err := h.store.GetByID(r.Context(), oID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return notFound(w, oID)
+	}
+	if err != nil {
+		return err
+	}
+
+	err = h.libraryStore.RemoveBook(r.Context(), oUserID, oID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return notFound(w, oID)
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, err := h.highlightStore.DeleteBookHighlights(r.Context(), oUserID, book.ISBN); err != nil {
+		return err
+	}
+
+	readers, err := h.libraryStore.CountBookReaders(r.Context(), oID)
+	if err != nil {
+		return err
+	}
+
+	if readers == 0 {
+		if err := h.store.Delete(r.Context(), oID); err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+			return err
+		}
+	}
+
+	return u.WriteJSON(w, http.StatusOK, nil)
+}
+
+// handleGetDuplicateBooks suggests books in the user's library that are
+// likely the same one, with ?threshold= from 0 to 1 to tune how similar
+// their titles must be
+func (h *Handler) handleGetDuplicateBooks(w http.ResponseWriter, r *http.Request) error {
+	userID, err := u.GetStringParamFromRequest(r, "userID")
+	if err != nil {
+		return err
+	}
+	oUserID, _ := primitive.ObjectIDFromHex(userID)
+
+	threshold := DefaultDuplicateThreshold
+	if v := r.URL.Query().Get("threshold"); v != "" {
+		threshold, err = strconv.ParseFloat(v, 64)
+		if err != nil || threshold <= 0 || threshold > 1 {
+			return u.WriteJSON(w, http.StatusBadRequest, t.APIError{Error: fmt.Errorf("threshold must be between 0 and 1").Error()})
+		}
+	}
+
+	books, err := h.getLibraryBooks(r, oUserID)
+	if err != nil {
+		return err
+	}
+
+	return u.WriteJSON(w, http.StatusOK, FindDuplicates(books, threshold))
+}
+
+// handleMergeBooks merges the books in the body into the one in the path.
+// All of them must be in the user's library. Books are shared, so only the
+// user's own highlights, library, reading log and notes move. A duplicate
+// book itself is merged away only when it's the same edition, which moves
+// every user's data of it, or when no one else has it in their library.
+func (h *Handler) handleMergeBooks(w http.ResponseWriter, r *http.Request) error {
+	oUserID, oID, err := getIDsFromRequest(r)
+	if err != nil {
+		return err
+	}
+
+	payload := new(MergeBooksRequest)
+	if err := json.NewDecoder(r.Body).Decode(payload); err != nil {
+		return u.WriteJSON(w, http.StatusBadRequest, t.APIError{Error: err.Error()})
+	}
+
+	if len(payload.BookIDs) == 0 {
+		return u.WriteJSON(w, http.StatusBadRequest, t.APIError{Error: fmt.Errorf("bookIds is required").Error()})
+	}
+
+	target, _, err := h.getUserBook(r, oUserID, oID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return notFound(w, oID)
+	}
+	if err != nil {
+		return err
+	}
+
+	merged := make([]*t.Book, 0, len(payload.BookIDs))
+	for _, raw := range payload.BookIDs {
+		mergedID, _ := primitive.ObjectIDFromHex(raw)
+		if mergedID == oID {
+			return u.WriteJSON(w, http.StatusBadRequest, t.APIError{Error: fmt.Errorf("cannot merge a book into itself").Error()})
+		}
+		if slices.ContainsFunc(merged, func(b *t.Book) bool { return b.ID == mergedID }) {
+			continue
+		}
+
+		b, _, err := h.getUserBook(r, oUserID, mergedID)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return notFound(w, mergedID)
+		}
+		if err != nil {
+			return err
+		}
+
+		merged = append(merged, b)
+	}
+
+	if err := h.moveBooks(r.Context(), &oUserID, merged, target); err != nil {
+		return err
+	}
+
+	gone := make([]*t.Book, 0, len(merged))
+	for _, b := range merged {
+		readers, err := h.libraryStore.CountBookReaders(r.Context(), b.ID)
+		if err != nil {
+			return err
+		}
+
+		if readers == 0 || SameEdition(target, b) {
+			gone = append(gone, b)
+		}
+	}
+
+	book := target
+	if len(gone) > 0 {
+		// What's left of them, from other users or in the trash, would point
+		// to a book that no longer exists
+		if err := h.moveBooks(r.Context(), nil, gone, target); err != nil {
+			return err
+		}
+
+		ids := make([]primitive.ObjectID, len(gone))
+		for i, b := range gone {
+			ids[i] = b.ID
+		}
+
+		book, err = h.store.Merge(r.Context(), oID, ids)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return notFound(w, oID)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	entry, err := h.libraryStore.GetLibraryBook(r.Context(), oUserID, oID)
+	if err != nil {
+		return err
+	}
+
+	return u.WriteJSON(w, http.StatusOK, userBook(book, entry))
+}
+
+// moveBooks moves the user's highlights, library, reading log and notes of
+// the books to the target, or every user's when userID is nil
+func (h *Handler) moveBooks(ctx context.Context, userID *primitive.ObjectID, books []*t.Book, target *t.Book) error {
+	ids := make([]primitive.ObjectID, len(books))
+	isbns := make([]string, len(books))
+	for i, b := range books {
+		ids[i] = b.ID
+		isbns[i] = b.ISBN
+	}
+
+	if _, err := h.highlightStore.MoveBookHighlights(ctx, userID, isbns, target.ISBN); err != nil {
+		return err
+	}
+
+	if err := h.libraryStore.MoveBooks(ctx, userID, ids, target.ID); err != nil {
+		return err
+	}
+
+	if err := h.readingStore.MoveBooks(ctx, userID, ids, target.ID); err != nil {
+		return err
+	}
+
+	return h.noteStore.MoveBooks(ctx, userID, ids, target.ID)
+}
+
+// getLibraryBooks returns the books in the user's library
+func (h *Handler) getLibraryBooks(r *http.Request, userID primitive.ObjectID) ([]*t.Book, error) {
+	entries, err := h.libraryStore.GetLibrary(r.Context(), userID)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]primitive.ObjectID, len(entries))
+	for i, e := range entries {
+		ids[i] = e.BookID
+	}
+
+	return h.store.GetByIDs(r.Context(), ids)
+}
+
+func getIDsFromRequest(r *http.Request) (primitive.ObjectID, primitive.ObjectID, error) {
+	userID, err := u.GetStringParamFromRequest(r, "userID")
+	if err != nil {
+		return primitive.NilObjectID, primitive.NilObjectID, err
+	}
+	oUserID, _ := primitive.ObjectIDFromHex(userID)
+
+	id, err := u.GetStringParamFromRequest(r, "id")
+	if err != nil {
+		return primitive.NilObjectID, primitive.NilObjectID, err
+	}
+	oID, _ := primitive.ObjectIDFromHex(id)
+
+	return oUserID, oID, nil
+}
+
+func notFound(w http.ResponseWriter, id primitive.ObjectID) error {
+	return u.WriteJSON(w, http.StatusNotFound, t.APIError{Error: fmt.Errorf("book with id %v not found", id.Hex()).Error()})
+}
+
+type MergeBooksRequest struct {
+	BookIDs []string `json:"bookIds"`
+}
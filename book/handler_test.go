@@ -0,0 +1,651 @@
+package book
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	types "github.com/sikozonpc/notebase/types"
+	u "github.com/sikozonpc/notebase/utils"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestBookHandler(t *testing.T) {
+	userID := primitive.NewObjectID()
+	book := &types.Book{ID: primitive.NewObjectID(), ISBN: "B01", Title: "Deep Work", Authors: "Cal Newport"}
+	toRead := &types.Book{ID: primitive.NewObjectID(), ISBN: "B02", Title: "The Mythical Man-Month"}
+	other := &types.Book{ID: primitive.NewObjectID(), ISBN: "B03", Title: "Not in the library"}
+
+	older := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	newer := older.AddDate(0, 1, 0)
+
+	store := &mockBookStore{books: []*types.Book{book, toRead, other}}
+	libraryStore := &mockLibraryStore{entries: []*types.LibraryBook{
+		{UserID: userID, BookID: toRead.ID, Progress: &types.ReadingProgress{Page: 10, Source: types.ProgressSourceManual}},
+		{UserID: userID, BookID: book.ID},
+	}}
+	highlightStore := &mockHighlightStore{highlights: []*types.Highlight{
+		{ID: primitive.NewObjectID(), UserID: userID, BookID: book.ISBN, Position: 20, CreatedAt: older},
+		{ID: primitive.NewObjectID(), UserID: userID, BookID: book.ISBN, Position: 10, CreatedAt: newer},
+	}}
+	handler := NewHandler(store, libraryStore, &mockReadingStore{}, &mockNoteStore{}, &mockAuthorStore{}, highlightStore, &mockUserStore{})
+
+	serve := func(t *testing.T, method, path, body string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(method, path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		router := mux.NewRouter()
+
+		router.HandleFunc("/user/{userID}/book", u.MakeHTTPHandler(handler.handleGetUserBooks)).Methods(http.MethodGet)
+		router.HandleFunc("/user/{userID}/book", u.MakeHTTPHandler(handler.handleAddBook)).Methods(http.MethodPost)
+		router.HandleFunc("/user/{userID}/book/{id}", u.MakeHTTPHandler(handler.handleGetBook)).Methods(http.MethodGet)
+		router.HandleFunc("/user/{userID}/book/{id}", u.MakeHTTPHandler(handler.handleUpdateBook)).Methods(http.MethodPatch)
+
+		router.ServeHTTP(rr, req)
+
+		return rr
+	}
+
+	t.Run("should list the user's books with their highlight counts", func(t *testing.T) {
+		rr := serve(t, http.MethodGet, "/user/"+userID.Hex()+"/book", "")
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		var books []*types.UserBook
+		if err := json.NewDecoder(rr.Body).Decode(&books); err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Len(t, books, 2)
+		assert.Equal(t, "Deep Work", books[0].Title)
+		assert.Equal(t, 2, books[0].HighlightCount)
+		assert.True(t, newer.Equal(*books[0].LastHighlightedAt))
+
+		// Books without highlights go last
+		assert.Equal(t, "The Mythical Man-Month", books[1].Title)
+		assert.Zero(t, books[1].HighlightCount)
+		assert.Nil(t, books[1].LastHighlightedAt)
+		assert.Equal(t, 10, books[1].Progress.Page)
+	})
+
+	t.Run("should add a book to the library", func(t *testing.T) {
+		rr := serve(t, http.MethodPost, "/user/"+userID.Hex()+"/book", `{"isbn": "978-0-13-475759-9", "title": "Refactoring", "authors": "Martin Fowler"}`)
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("expected status code %d, got %d", http.StatusCreated, rr.Code)
+		}
+
+		assert.Len(t, libraryStore.entries, 3)
+		assert.Len(t, store.books[3].AuthorIDs, 1)
+
+		for _, body := range []string{
+			`{"isbn": "0134757599"}`,
+			`{"isbn": "9780134757590", "title": "Refactoring"}`,
+			`{"isbn": "` + book.ID.Hex() + `", "title": "Refactoring"}`,
+		} {
+			rr = serve(t, http.MethodPost, "/user/"+userID.Hex()+"/book", body)
+			if rr.Code != http.StatusBadRequest {
+				t.Errorf("expected status code %d for %s, got %d", http.StatusBadRequest, body, rr.Code)
+			}
+		}
+	})
+
+	t.Run("should get a book with its highlights in reading order", func(t *testing.T) {
+		rr := serve(t, http.MethodGet, "/user/"+userID.Hex()+"/book/"+book.ID.Hex(), "")
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		var detail types.BookDetail
+		if err := json.NewDecoder(rr.Body).Decode(&detail); err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, 2, detail.HighlightCount)
+		assert.Equal(t, 10, detail.Highlights[0].Position)
+		assert.Equal(t, 20, detail.Highlights[1].Position)
+	})
+
+	t.Run("should not get a book that isn't in the user's library", func(t *testing.T) {
+		rr := serve(t, http.MethodGet, "/user/"+userID.Hex()+"/book/"+other.ID.Hex(), "")
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("expected status code %d, got %d", http.StatusNotFound, rr.Code)
+		}
+	})
+
+	t.Run("should change the book for the user only", func(t *testing.T) {
+		rr := serve(t, http.MethodPatch, "/user/"+userID.Hex()+"/book/"+book.ID.Hex(), `{"title": "Deep Work: Rules for Focused Success", "status": "reading"}`)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		var ub types.UserBook
+		if err := json.NewDecoder(rr.Body).Decode(&ub); err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, "Deep Work: Rules for Focused Success", ub.Title)
+		assert.Equal(t, "Cal Newport", ub.Authors)
+		assert.Equal(t, types.ReadingStatusReading, ub.Status)
+		assert.NotNil(t, ub.StartedAt)
+
+		// The shared book is unchanged
+		assert.Equal(t, "Deep Work", book.Title)
+	})
+
+	t.Run("should fail to update with an invalid payload", func(t *testing.T) {
+		for _, body := range []string{
+			`{}`,
+			`{"title": " "}`,
+			`{"status": "skimmed"}`,
+			`{"startedAt": "2024-02-01T00:00:00Z", "finishedAt": "2024-01-01T00:00:00Z"}`,
+			`{`,
+		} {
+			rr := serve(t, http.MethodPatch, "/user/"+userID.Hex()+"/book/"+book.ID.Hex(), body)
+			if rr.Code != http.StatusBadRequest {
+				t.Errorf("expected status code %d for %s, got %d", http.StatusBadRequest, body, rr.Code)
+			}
+		}
+	})
+}
+
+func TestHandleDeleteBook(t *testing.T) {
+	userID := primitive.NewObjectID()
+	otherUserID := primitive.NewObjectID()
+	book := &types.Book{ID: primitive.NewObjectID(), ISBN: "B01", Title: "Deep Work"}
+
+	newHandler := func(readers ...primitive.ObjectID) (*Handler, *mockBookStore, *mockHighlightStore) {
+		store := &mockBookStore{books: []*types.Book{{ID: book.ID, ISBN: book.ISBN, Title: book.Title}}}
+		libraryStore := &mockLibraryStore{}
+		highlightStore := &mockHighlightStore{}
+		for _, reader := range readers {
+			libraryStore.entries = append(libraryStore.entries, &types.LibraryBook{UserID: reader, BookID: book.ID})
+			highlightStore.highlights = append(highlightStore.highlights, &types.Highlight{ID: primitive.NewObjectID(), UserID: reader, BookID: book.ISBN})
+		}
+
+		return NewHandler(store, libraryStore, &mockReadingStore{}, &mockNoteStore{}, &mockAuthorStore{}, highlightStore, &mockUserStore{}), store, highlightStore
+	}
+
+	deleteBook := func(handler *Handler, userID primitive.ObjectID) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(http.MethodDelete, "/user/"+userID.Hex()+"/book/"+book.ID.Hex(), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		router := mux.NewRouter()
+
+		router.HandleFunc("/user/{userID}/book/{id}", u.MakeHTTPHandler(handler.handleDeleteBook)).Methods(http.MethodDelete)
+
+		router.ServeHTTP(rr, req)
+
+		return rr
+	}
+
+	t.Run("should move the book to the trash with the last reader", func(t *testing.T) {
+		handler, store, highlightStore := newHandler(userID)
+
+		rr := deleteBook(handler, userID)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		assert.NotNil(t, store.books[0].DeletedAt)
+		assert.NotNil(t, highlightStore.highlights[0].DeletedAt)
+	})
+
+	t.Run("should keep the book while it's in other libraries", func(t *testing.T) {
+		handler, store, highlightStore := newHandler(userID, otherUserID)
+
+		rr := deleteBook(handler, userID)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		assert.Nil(t, store.books[0].DeletedAt)
+		assert.NotNil(t, highlightStore.highlights[0].DeletedAt)
+		assert.Nil(t, highlightStore.highlights[1].DeletedAt)
+	})
+
+	t.Run("should not delete a book that isn't in the user's library", func(t *testing.T) {
+		handler, store, highlightStore := newHandler(otherUserID)
+
+		rr := deleteBook(handler, userID)
+		if rr.Code != http.StatusNotFound {
+			t.Fatalf("expected status code %d, got %d", http.StatusNotFound, rr.Code)
+		}
+
+		assert.Nil(t, store.books[0].DeletedAt)
+		assert.Nil(t, highlightStore.highlights[0].DeletedAt)
+	})
+}
+
+func TestHandleMergeBooks(t *testing.T) {
+	userID := primitive.NewObjectID()
+	otherUserID := primitive.NewObjectID()
+
+	// dupISBN is the duplicate's ISBN, the other user has it in their library
+	// unless shared is false
+	newHandler := func(dupISBN string, shared bool) (*Handler, *mockBookStore, *mockLibraryStore, *mockHighlightStore) {
+		keep := &types.Book{ID: primitive.NewObjectID(), ISBN: "9780306406157", Title: "Deep Work"}
+		dup := &types.Book{ID: primitive.NewObjectID(), ISBN: dupISBN, Title: "Deep Work (Kindle Edition)"}
+		stranger := &types.Book{ID: primitive.NewObjectID(), ISBN: "B02", Title: "Not in the library"}
+
+		store := &mockBookStore{books: []*types.Book{keep, dup, stranger}}
+		libraryStore := &mockLibraryStore{entries: []*types.LibraryBook{
+			{ID: primitive.NewObjectID(), UserID: userID, BookID: keep.ID},
+			{ID: primitive.NewObjectID(), UserID: userID, BookID: dup.ID},
+			{ID: primitive.NewObjectID(), UserID: otherUserID, BookID: stranger.ID},
+		}}
+		highlightStore := &mockHighlightStore{highlights: []*types.Highlight{
+			{ID: primitive.NewObjectID(), UserID: userID, BookID: dup.ISBN},
+		}}
+
+		if shared {
+			libraryStore.entries = append(libraryStore.entries, &types.LibraryBook{ID: primitive.NewObjectID(), UserID: otherUserID, BookID: dup.ID})
+			highlightStore.highlights = append(highlightStore.highlights, &types.Highlight{ID: primitive.NewObjectID(), UserID: otherUserID, BookID: dup.ISBN})
+		}
+
+		return NewHandler(store, libraryStore, &mockReadingStore{}, &mockNoteStore{}, &mockAuthorStore{}, highlightStore, &mockUserStore{}), store, libraryStore, highlightStore
+	}
+
+	merge := func(handler *Handler, id primitive.ObjectID, ids ...primitive.ObjectID) *httptest.ResponseRecorder {
+		hexes := make([]string, len(ids))
+		for i, id := range ids {
+			hexes[i] = id.Hex()
+		}
+		body, _ := json.Marshal(MergeBooksRequest{BookIDs: hexes})
+
+		req, err := http.NewRequest(http.MethodPost, "/user/"+userID.Hex()+"/book/"+id.Hex()+"/merge", strings.NewReader(string(body)))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		router := mux.NewRouter()
+
+		router.HandleFunc("/user/{userID}/book/{id}/merge", u.MakeHTTPHandler(handler.handleMergeBooks)).Methods(http.MethodPost)
+
+		router.ServeHTTP(rr, req)
+
+		return rr
+	}
+
+	t.Run("should only move the user's own data of a book others have", func(t *testing.T) {
+		handler, store, libraryStore, highlightStore := newHandler("B01", true)
+		keep, dup := store.books[0], store.books[1]
+
+		rr := merge(handler, keep.ID, dup.ID)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		var book types.UserBook
+		if err := json.NewDecoder(rr.Body).Decode(&book); err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, keep.ID, book.ID)
+		assert.Empty(t, book.Identifiers)
+
+		assert.Equal(t, keep.ISBN, highlightStore.highlights[0].BookID)
+		assert.Equal(t, dup.ISBN, highlightStore.highlights[1].BookID)
+
+		_, err := store.GetByID(context.Background(), dup.ID)
+		assert.NoError(t, err)
+
+		_, err = libraryStore.GetLibraryBook(context.Background(), otherUserID, dup.ID)
+		assert.NoError(t, err)
+
+		library, _ := libraryStore.GetLibrary(context.Background(), userID)
+		assert.Len(t, library, 1)
+	})
+
+	t.Run("should merge away a book no one else has", func(t *testing.T) {
+		handler, store, _, highlightStore := newHandler("B01", false)
+		keep, dup := store.books[0], store.books[1]
+
+		rr := merge(handler, keep.ID, dup.ID)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		assert.Equal(t, []string{"B01"}, keep.Identifiers)
+		assert.Equal(t, keep.ISBN, highlightStore.highlights[0].BookID)
+
+		_, err := store.GetByID(context.Background(), dup.ID)
+		assert.ErrorIs(t, err, mongo.ErrNoDocuments)
+	})
+
+	t.Run("should move every user's data of the same edition", func(t *testing.T) {
+		// The ISBN-10 of the kept book
+		handler, store, libraryStore, highlightStore := newHandler("0306406152", true)
+		keep, dup := store.books[0], store.books[1]
+
+		rr := merge(handler, keep.ID, dup.ID)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		for _, h := range highlightStore.highlights {
+			assert.Equal(t, keep.ISBN, h.BookID)
+		}
+
+		_, err := store.GetByID(context.Background(), dup.ID)
+		assert.ErrorIs(t, err, mongo.ErrNoDocuments)
+
+		_, err = libraryStore.GetLibraryBook(context.Background(), otherUserID, keep.ID)
+		assert.NoError(t, err)
+	})
+
+	t.Run("should not merge a book into itself", func(t *testing.T) {
+		handler, store, _, _ := newHandler("B01", true)
+
+		rr := merge(handler, store.books[0].ID, store.books[0].ID)
+		if rr.Code != http.StatusBadRequest {
+			t.Fatalf("expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+
+	t.Run("should not merge a book that isn't in the user's library", func(t *testing.T) {
+		handler, store, _, highlightStore := newHandler("B01", true)
+
+		rr := merge(handler, store.books[0].ID, store.books[2].ID)
+		if rr.Code != http.StatusNotFound {
+			t.Fatalf("expected status code %d, got %d", http.StatusNotFound, rr.Code)
+		}
+
+		assert.Len(t, store.books, 3)
+		assert.Equal(t, "B01", highlightStore.highlights[0].BookID)
+	})
+}
+
+type mockBookStore struct {
+	types.BookStore
+	books []*types.Book
+}
+
+func (m *mockBookStore) GetByID(_ context.Context, id primitive.ObjectID) (*types.Book, error) {
+	for _, b := range m.books {
+		if b.ID == id && b.DeletedAt == nil {
+			return b, nil
+		}
+	}
+
+	return nil, mongo.ErrNoDocuments
+}
+
+func (m *mockBookStore) GetByIDs(ctx context.Context, ids []primitive.ObjectID) ([]*types.Book, error) {
+	books := make([]*types.Book, 0)
+	for _, id := range ids {
+		if b, err := m.GetByID(ctx, id); err == nil {
+			books = append(books, b)
+		}
+	}
+
+	return books, nil
+}
+
+func (m *mockBookStore) GetOrCreate(_ context.Context, req *types.CreateBookRequest) (*types.Book, error) {
+	for _, b := range m.books {
+		if b.ISBN == req.ISBN {
+			return b, nil
+		}
+	}
+
+	b := &types.Book{ID: primitive.NewObjectID(), ISBN: req.ISBN, Title: req.Title, Authors: req.Authors}
+	m.books = append(m.books, b)
+
+	return b, nil
+}
+
+func (m *mockBookStore) Delete(_ context.Context, id primitive.ObjectID) error {
+	for _, b := range m.books {
+		if b.ID == id && b.DeletedAt == nil {
+			now := time.Now()
+			b.DeletedAt = &now
+			return nil
+		}
+	}
+
+	return mongo.ErrNoDocuments
+}
+
+func (m *mockBookStore) SetAuthors(ctx context.Context, id primitive.ObjectID, authorIDs []primitive.ObjectID) error {
+	b, err := m.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	b.AuthorIDs = authorIDs
+
+	return nil
+}
+
+func (m *mockBookStore) Merge(ctx context.Context, targetID primitive.ObjectID, ids []primitive.ObjectID) (*types.Book, error) {
+	target, err := m.GetByID(ctx, targetID)
+	if err != nil {
+		return nil, err
+	}
+
+	books := make([]*types.Book, 0, len(m.books))
+	for _, b := range m.books {
+		if slices.Contains(ids, b.ID) {
+			target.Identifiers = append(target.Identifiers, b.ISBN)
+		} else {
+			books = append(books, b)
+		}
+	}
+	m.books = books
+
+	return target, nil
+}
+
+type mockAuthorStore struct {
+	types.AuthorStore
+}
+
+func (m *mockAuthorStore) GetOrCreateAll(_ context.Context, authors string) ([]*types.Author, error) {
+	return []*types.Author{{ID: primitive.NewObjectID(), Name: authors}}, nil
+}
+
+type mockLibraryStore struct {
+	types.LibraryStore
+	entries []*types.LibraryBook
+}
+
+func (m *mockLibraryStore) AddBook(_ context.Context, userID primitive.ObjectID, bookID primitive.ObjectID) (*types.LibraryBook, error) {
+	e := &types.LibraryBook{ID: primitive.NewObjectID(), UserID: userID, BookID: bookID}
+	m.entries = append(m.entries, e)
+
+	return e, nil
+}
+
+func (m *mockLibraryStore) GetLibrary(_ context.Context, userID primitive.ObjectID) ([]*types.LibraryBook, error) {
+	entries := make([]*types.LibraryBook, 0)
+	for _, e := range m.entries {
+		if e.UserID == userID && e.DeletedAt == nil {
+			entries = append(entries, e)
+		}
+	}
+
+	return entries, nil
+}
+
+func (m *mockLibraryStore) GetLibraryBook(_ context.Context, userID primitive.ObjectID, bookID primitive.ObjectID) (*types.LibraryBook, error) {
+	for _, e := range m.entries {
+		if e.UserID == userID && e.BookID == bookID && e.DeletedAt == nil {
+			return e, nil
+		}
+	}
+
+	return nil, mongo.ErrNoDocuments
+}
+
+func (m *mockLibraryStore) UpdateLibraryBook(ctx context.Context, userID primitive.ObjectID, bookID primitive.ObjectID, req *types.UpdateLibraryBookRequest) (*types.LibraryBook, error) {
+	e, err := m.GetLibraryBook(ctx, userID, bookID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Title != nil {
+		e.Title = *req.Title
+	}
+	if req.Authors != nil {
+		e.Authors = *req.Authors
+	}
+	if req.Status != nil {
+		e.Status = *req.Status
+	}
+	if req.StartedAt != nil {
+		e.StartedAt = req.StartedAt
+	}
+	if req.FinishedAt != nil {
+		e.FinishedAt = req.FinishedAt
+	}
+
+	return e, nil
+}
+
+func (m *mockLibraryStore) RemoveBook(ctx context.Context, userID primitive.ObjectID, bookID primitive.ObjectID) error {
+	e, err := m.GetLibraryBook(ctx, userID, bookID)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	e.DeletedAt = &now
+
+	return nil
+}
+
+func (m *mockLibraryStore) CountBookReaders(_ context.Context, bookID primitive.ObjectID) (int64, error) {
+	var n int64
+	for _, e := range m.entries {
+		if e.BookID == bookID && e.DeletedAt == nil {
+			n++
+		}
+	}
+
+	return n, nil
+}
+
+func (m *mockLibraryStore) MoveBooks(ctx context.Context, userID *primitive.ObjectID, from []primitive.ObjectID, to primitive.ObjectID) error {
+	entries := make([]*types.LibraryBook, 0, len(m.entries))
+	for _, e := range m.entries {
+		if slices.Contains(from, e.BookID) && (userID == nil || e.UserID == *userID) {
+			if _, err := m.GetLibraryBook(ctx, e.UserID, to); err == nil {
+				continue
+			}
+			e.BookID = to
+		}
+		entries = append(entries, e)
+	}
+	m.entries = entries
+
+	return nil
+}
+
+type mockReadingStore struct {
+	types.ReadingLogStore
+}
+
+func (m *mockReadingStore) MoveBooks(context.Context, *primitive.ObjectID, []primitive.ObjectID, primitive.ObjectID) error {
+	return nil
+}
+
+type mockNoteStore struct {
+	types.NoteStore
+	notes []*types.Note
+}
+
+func (m *mockNoteStore) GetUserNotes(context.Context, primitive.ObjectID, *types.NoteFilter) ([]*types.Note, error) {
+	return append([]*types.Note{}, m.notes...), nil
+}
+
+func (m *mockNoteStore) MoveBooks(context.Context, *primitive.ObjectID, []primitive.ObjectID, primitive.ObjectID) error {
+	return nil
+}
+
+type mockHighlightStore struct {
+	types.HighlightStore
+	highlights []*types.Highlight
+}
+
+func (m *mockHighlightStore) GetUserHighlights(_ context.Context, userID primitive.ObjectID, filter *types.HighlightFilter) (*types.HighlightPage, error) {
+	hs := make([]*types.Highlight, 0)
+	for _, h := range m.highlights {
+		if h.UserID == userID && h.BookID == filter.BookID && h.DeletedAt == nil {
+			hs = append(hs, h)
+		}
+	}
+
+	sort.Slice(hs, func(i, j int) bool { return hs[i].Position < hs[j].Position })
+
+	return &types.HighlightPage{Highlights: hs, Total: int64(len(hs))}, nil
+}
+
+func (m *mockHighlightStore) GetUserBookStats(_ context.Context, userID primitive.ObjectID) ([]*types.BookHighlightStats, error) {
+	byBook := make(map[string]*types.BookHighlightStats)
+	stats := make([]*types.BookHighlightStats, 0)
+	for _, h := range m.highlights {
+		if h.UserID != userID || h.DeletedAt != nil {
+			continue
+		}
+
+		s, ok := byBook[h.BookID]
+		if !ok {
+			s = &types.BookHighlightStats{BookID: h.BookID}
+			byBook[h.BookID] = s
+			stats = append(stats, s)
+		}
+
+		s.HighlightCount++
+		if h.CreatedAt.After(s.LastHighlightedAt) {
+			s.LastHighlightedAt = h.CreatedAt
+		}
+	}
+
+	return stats, nil
+}
+
+func (m *mockHighlightStore) DeleteBookHighlights(_ context.Context, userID primitive.ObjectID, bookID string) (int64, error) {
+	var n int64
+	for _, h := range m.highlights {
+		if h.UserID == userID && h.BookID == bookID && h.DeletedAt == nil {
+			now := time.Now()
+			h.DeletedAt = &now
+			n++
+		}
+	}
+
+	return n, nil
+}
+
+func (m *mockHighlightStore) MoveBookHighlights(_ context.Context, userID *primitive.ObjectID, from []string, to string) (int64, error) {
+	var n int64
+	for _, h := range m.highlights {
+		if slices.Contains(from, h.BookID) && (userID == nil || h.UserID == *userID) {
+			h.BookID = to
+			n++
+		}
+	}
+
+	return n, nil
+}
+
+type mockUserStore struct {
+	types.UserStore
+}
@@ -0,0 +1,163 @@
+package book
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ErrInvalidIdentifier is returned for a value that is not an identifier a
+// book can have
+var ErrInvalidIdentifier = errors.New("invalid book identifier")
+
+const (
+	IdentifierID     = "id" // The book's own ObjectID
+	IdentifierASIN   = "asin"
+	IdentifierISBN10 = "isbn10"
+	IdentifierISBN13 = "isbn13"
+)
+
+// Identifier is a normalized book identifier, without hyphens or spaces and
+// with the ISBN-10 check character in upper case
+type Identifier struct {
+	Type  string
+	Value string
+}
+
+// ParseIdentifier recognizes what kind of identifier the value is. ISBNs
+// must have a valid check digit, a 10 character value that is not an ISBN-10
+// is taken as an ASIN.
+func ParseIdentifier(s string) (Identifier, error) {
+	v := strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(strings.TrimSpace(s)))
+
+	if primitive.IsValidObjectID(strings.ToLower(v)) {
+		return Identifier{Type: IdentifierID, Value: strings.ToLower(v)}, nil
+	}
+
+	switch len(v) {
+	case 10:
+		if ValidISBN10(v) {
+			return Identifier{Type: IdentifierISBN10, Value: v}, nil
+		}
+		if isDigits(v[:9]) {
+			return Identifier{}, fmt.Errorf("%w: %q has a wrong isbn check digit", ErrInvalidIdentifier, s)
+		}
+		if isAlphanumeric(v) {
+			return Identifier{Type: IdentifierASIN, Value: v}, nil
+		}
+	case 13:
+		if ValidISBN13(v) {
+			return Identifier{Type: IdentifierISBN13, Value: v}, nil
+		}
+		if isDigits(v) {
+			return Identifier{}, fmt.Errorf("%w: %q has a wrong isbn check digit", ErrInvalidIdentifier, s)
+		}
+	}
+
+	return Identifier{}, fmt.Errorf("%w: %q", ErrInvalidIdentifier, s)
+}
+
+// Equivalents returns every value a book with this identifier could be
+// stored under, an ISBN-10 and its ISBN-13 are the same book
+func (id Identifier) Equivalents() []string {
+	switch id.Type {
+	case IdentifierISBN10:
+		return []string{id.Value, ISBN10To13(id.Value)}
+	case IdentifierISBN13:
+		if isbn10, ok := ISBN13To10(id.Value); ok {
+			return []string{id.Value, isbn10}
+		}
+	}
+
+	return []string{id.Value}
+}
+
+// ValidISBN10 checks the length and check digit of a normalized ISBN-10
+func ValidISBN10(s string) bool {
+	if len(s) != 10 || !isDigits(s[:9]) {
+		return false
+	}
+
+	sum := 0
+	for i := 0; i < 9; i++ {
+		sum += int(s[i]-'0') * (10 - i)
+	}
+
+	return s[9:] == isbn10CheckDigit(sum)
+}
+
+// ValidISBN13 checks the length, prefix and check digit of a normalized
+// ISBN-13
+func ValidISBN13(s string) bool {
+	if len(s) != 13 || !isDigits(s) || !(strings.HasPrefix(s, "978") || strings.HasPrefix(s, "979")) {
+		return false
+	}
+
+	return s[12:] == isbn13CheckDigit(s[:12])
+}
+
+// ISBN10To13 converts a valid ISBN-10 to its ISBN-13
+func ISBN10To13(isbn10 string) string {
+	body := "978" + isbn10[:9]
+	return body + isbn13CheckDigit(body)
+}
+
+// ISBN13To10 converts a valid ISBN-13 to its ISBN-10. Only 978 ISBNs have
+// one.
+func ISBN13To10(isbn13 string) (string, bool) {
+	if !strings.HasPrefix(isbn13, "978") {
+		return "", false
+	}
+
+	body := isbn13[3:12]
+	sum := 0
+	for i := 0; i < 9; i++ {
+		sum += int(body[i]-'0') * (10 - i)
+	}
+
+	return body + isbn10CheckDigit(sum), true
+}
+
+func isbn10CheckDigit(sum int) string {
+	switch check := (11 - sum%11) % 11; check {
+	case 10:
+		return "X"
+	default:
+		return fmt.Sprint(check)
+	}
+}
+
+func isbn13CheckDigit(body string) string {
+	sum := 0
+	for i := 0; i < 12; i++ {
+		d := int(body[i] - '0')
+		if i%2 == 1 {
+			d *= 3
+		}
+		sum += d
+	}
+
+	return fmt.Sprint((10 - sum%10) % 10)
+}
+
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+
+	return s != ""
+}
+
+func isAlphanumeric(s string) bool {
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'A' || c > 'Z') {
+			return false
+		}
+	}
+
+	return true
+}
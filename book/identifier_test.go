@@ -0,0 +1,60 @@
+package book
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseIdentifier(t *testing.T) {
+	cases := []struct {
+		in    string
+		typ   string
+		value string
+	}{
+		{"0-306-40615-2", IdentifierISBN10, "0306406152"},
+		{"080442957x", IdentifierISBN10, "080442957X"},
+		{"978-0-306-40615-7", IdentifierISBN13, "9780306406157"},
+		{"979 10 90636 07 1", IdentifierISBN13, "9791090636071"},
+		{"B004XCFJ3E", IdentifierASIN, "B004XCFJ3E"},
+		{"65a1b2c3d4e5f60718293a4b", IdentifierID, "65a1b2c3d4e5f60718293a4b"},
+	}
+
+	for _, c := range cases {
+		id, err := ParseIdentifier(c.in)
+		if err != nil {
+			t.Fatalf("%s: %v", c.in, err)
+		}
+
+		assert.Equal(t, c.typ, id.Type, c.in)
+		assert.Equal(t, c.value, id.Value, c.in)
+	}
+
+	for _, in := range []string{"", "0306406153", "9780306406158", "1234567890123", "B004XCFJ3", "not-an-isbn!"} {
+		_, err := ParseIdentifier(in)
+		if !errors.Is(err, ErrInvalidIdentifier) {
+			t.Errorf("expected %q to be invalid, got %v", in, err)
+		}
+	}
+}
+
+func TestISBNConversion(t *testing.T) {
+	assert.Equal(t, "9780306406157", ISBN10To13("0306406152"))
+	assert.Equal(t, "9780804429573", ISBN10To13("080442957X"))
+
+	isbn10, ok := ISBN13To10("9780804429573")
+	assert.True(t, ok)
+	assert.Equal(t, "080442957X", isbn10)
+
+	_, ok = ISBN13To10("9791090636071")
+	assert.False(t, ok)
+}
+
+func TestIdentifierEquivalents(t *testing.T) {
+	id, _ := ParseIdentifier("978-0-306-40615-7")
+	assert.Equal(t, []string{"9780306406157", "0306406152"}, id.Equivalents())
+
+	id, _ = ParseIdentifier("B004XCFJ3E")
+	assert.Equal(t, []string{"B004XCFJ3E"}, id.Equivalents())
+}
@@ -2,44 +2,448 @@ package book
 
 import (
 	"context"
+	"errors"
+	"slices"
+	"time"
 
 	t "github.com/sikozonpc/notebase/types"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-const (
-	DbName   = "notebase"
-	CollName = "books"
-)
+const CollName = "books"
 
 type Store struct {
-	db *mongo.Client
+	db *mongo.Database
 }
 
-func NewStore(db *mongo.Client) *Store {
+func NewStore(db *mongo.Database) *Store {
 	return &Store{db: db}
 }
 
-func (s *Store) GetByISBN(ctx context.Context, isbn string) (*t.Book, error) {
-	col := s.db.Database(DbName).Collection(CollName)
+// GetByIdentifier finds the book by its ID, ISBN-10, ISBN-13 or ASIN. ISBNs
+// match the book under either form. Returns a *t.BookNotFoundError if there
+// is none.
+func (s *Store) GetByIdentifier(ctx context.Context, identifier string) (*t.Book, error) {
+	col := s.db.Collection(CollName)
+
+	filter := bson.M{"deletedAt": nil}
 
-	oID, _ := primitive.ObjectIDFromHex(isbn)
+	id, err := ParseIdentifier(identifier)
+	switch {
+	case err != nil:
+		// Books imported before identifiers were checked keep their raw value
+		filter["isbn"] = identifier
+	case id.Type == IdentifierID:
+		oID, _ := primitive.ObjectIDFromHex(id.Value)
+		filter["_id"] = oID
+	default:
+		filter["$or"] = bson.A{
+			bson.M{"isbn": bson.M{"$in": id.Equivalents()}},
+			bson.M{"identifiers": bson.M{"$in": id.Equivalents()}},
+		}
+	}
+
+	var b t.Book
+	err = col.FindOne(ctx, filter).Decode(&b)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, &t.BookNotFoundError{Identifier: identifier}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &b, nil
+}
+
+func (s *Store) GetByID(ctx context.Context, id primitive.ObjectID) (*t.Book, error) {
+	col := s.db.Collection(CollName)
 
 	var b t.Book
 	err := col.FindOne(ctx, bson.M{
-		"isbn":        oID,
+		"_id":       id,
+		"deletedAt": nil,
 	}).Decode(&b)
+	if err != nil {
+		return nil, err
+	}
+
+	return &b, nil
+}
+
+// GetByISBNs returns the books with the given ISBNs, skipping the ones that
+// don't exist
+func (s *Store) GetByISBNs(ctx context.Context, isbns []string) ([]*t.Book, error) {
+	col := s.db.Collection(CollName)
+
+	cursor, err := col.Find(ctx, bson.M{
+		"isbn":      bson.M{"$in": isbns},
+		"deletedAt": nil,
+	})
+	if err != nil {
+		return nil, err
+	}
 
-	return &b, err
+	books := make([]*t.Book, 0)
+	if err = cursor.All(ctx, &books); err != nil {
+		return nil, err
+	}
+
+	return books, nil
+}
+
+func (s *Store) GetByIDs(ctx context.Context, ids []primitive.ObjectID) ([]*t.Book, error) {
+	col := s.db.Collection(CollName)
+
+	cursor, err := col.Find(ctx, bson.M{
+		"_id":       bson.M{"$in": ids},
+		"deletedAt": nil,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	books := make([]*t.Book, 0)
+	if err = cursor.All(ctx, &books); err != nil {
+		return nil, err
+	}
+
+	return books, nil
 }
 
 func (s *Store) Create(ctx context.Context, b *t.CreateBookRequest) (primitive.ObjectID, error) {
-	col := s.db.Database(DbName).Collection(CollName)
+	col := s.db.Collection(CollName)
 
 	newBook, err := col.InsertOne(ctx, b)
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
 
 	id := newBook.InsertedID.(primitive.ObjectID)
-	return id, err
+	return id, nil
+}
+
+// GetOrCreate returns the book with the ISBN, creating it if there is none.
+// An ISBN matches the book under either of its forms, or the book it was
+// merged into. A book in the trash is restored, since it's being used again.
+func (s *Store) GetOrCreate(ctx context.Context, b *t.CreateBookRequest) (*t.Book, error) {
+	col := s.db.Collection(CollName)
+
+	isbn, filter := b.ISBN, bson.M{"isbn": b.ISBN}
+	if id, err := ParseIdentifier(b.ISBN); err == nil && id.Type != IdentifierID {
+		isbn, filter = id.Value, bson.M{"$or": bson.A{
+			bson.M{"isbn": bson.M{"$in": id.Equivalents()}},
+			bson.M{"identifiers": bson.M{"$in": id.Equivalents()}},
+		}}
+	}
+
+	var book t.Book
+	err := col.FindOneAndUpdate(ctx, filter, bson.M{
+		"$setOnInsert": bson.M{
+			"isbn":      isbn,
+			"title":     b.Title,
+			"authors":   b.Authors,
+			"createdAt": time.Now().UTC(),
+		},
+		"$unset": bson.M{"deletedAt": ""},
+	}, options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)).Decode(&book)
+	// Another import created it at the same time, the retry finds it
+	if mongo.IsDuplicateKeyError(err) {
+		err = col.FindOneAndUpdate(ctx, filter, bson.M{
+			"$unset": bson.M{"deletedAt": ""},
+		}, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&book)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &book, nil
+}
+
+// Delete moves the book to the trash, it's purged after the retention period
+// unless restored
+func (s *Store) Delete(ctx context.Context, id primitive.ObjectID) error {
+	col := s.db.Collection(CollName)
+
+	res, err := col.UpdateOne(ctx, bson.M{
+		"_id":       id,
+		"deletedAt": nil,
+	}, bson.M{
+		"$set": bson.M{"deletedAt": time.Now().UTC()},
+	})
+	if err != nil {
+		return err
+	}
+
+	if res.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+
+	return nil
+}
+
+// GetDeletedByISBNs returns the books in the trash among the given ones
+func (s *Store) GetDeletedByISBNs(ctx context.Context, isbns []string) ([]*t.Book, error) {
+	col := s.db.Collection(CollName)
+
+	cursor, err := col.Find(ctx, bson.M{
+		"isbn":      bson.M{"$in": isbns},
+		"deletedAt": bson.M{"$ne": nil},
+	}, options.Find().SetSort(bson.D{{Key: "deletedAt", Value: -1}}))
+	if err != nil {
+		return nil, err
+	}
+
+	books := make([]*t.Book, 0)
+	if err = cursor.All(ctx, &books); err != nil {
+		return nil, err
+	}
+
+	return books, nil
+}
+
+// Restore takes the book out of the trash
+func (s *Store) Restore(ctx context.Context, id primitive.ObjectID) (*t.Book, error) {
+	col := s.db.Collection(CollName)
+
+	var b t.Book
+	err := col.FindOneAndUpdate(ctx, bson.M{
+		"_id":       id,
+		"deletedAt": bson.M{"$ne": nil},
+	}, bson.M{
+		"$unset": bson.M{"deletedAt": ""},
+	}, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&b)
+	if err != nil {
+		return nil, err
+	}
+
+	return &b, nil
+}
+
+// PurgeDeleted permanently deletes the books that went to the trash before
+// the given time, and returns them, even when it stops early
+func (s *Store) PurgeDeleted(ctx context.Context, before time.Time) ([]*t.Book, error) {
+	col := s.db.Collection(CollName)
+
+	cursor, err := col.Find(ctx, bson.M{
+		"deletedAt": bson.M{"$lt": before},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	books := make([]*t.Book, 0)
+	if err = cursor.All(ctx, &books); err != nil {
+		return nil, err
+	}
+
+	// One by one, so a book restored in the meantime stays
+	purged := make([]*t.Book, 0, len(books))
+	for _, b := range books {
+		res, err := col.DeleteOne(ctx, bson.M{
+			"_id":       b.ID,
+			"deletedAt": bson.M{"$lt": before},
+		})
+		if err != nil {
+			return purged, err
+		}
+
+		if res.DeletedCount > 0 {
+			purged = append(purged, b)
+		}
+	}
+
+	return purged, nil
+}
+
+// GetUnenriched returns books that metadata providers haven't been asked
+// about yet, oldest first
+func (s *Store) GetUnenriched(ctx context.Context, limit int) ([]*t.Book, error) {
+	col := s.db.Collection(CollName)
+
+	cursor, err := col.Find(ctx, bson.M{
+		"metadata":  nil,
+		"deletedAt": nil,
+	}, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}).SetLimit(int64(limit)))
+	if err != nil {
+		return nil, err
+	}
+
+	books := make([]*t.Book, 0)
+	if err = cursor.All(ctx, &books); err != nil {
+		return nil, err
+	}
+
+	return books, nil
+}
+
+func (s *Store) SetMetadata(ctx context.Context, id primitive.ObjectID, md *t.BookMetadata) error {
+	col := s.db.Collection(CollName)
+
+	_, err := col.UpdateByID(ctx, id, bson.M{
+		"$set": bson.M{"metadata": md},
+	})
+
+	return err
+}
+
+// SetCover records that the book's cover was stored at the given time
+func (s *Store) SetCover(ctx context.Context, id primitive.ObjectID, at time.Time) error {
+	col := s.db.Collection(CollName)
+
+	res, err := col.UpdateByID(ctx, id, bson.M{
+		"$set": bson.M{"coverAt": at},
+	})
+	if err != nil {
+		return err
+	}
+
+	if res.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+
+	return nil
+}
+
+func (s *Store) SetAuthors(ctx context.Context, id primitive.ObjectID, authorIDs []primitive.ObjectID) error {
+	col := s.db.Collection(CollName)
+
+	_, err := col.UpdateByID(ctx, id, bson.M{
+		"$set": bson.M{"authorIds": authorIDs},
+	})
+
+	return err
+}
+
+// ReplaceAuthors links the books to another author in place of the given
+// ones, after they were merged into it
+func (s *Store) ReplaceAuthors(ctx context.Context, bookIDs []primitive.ObjectID, from []primitive.ObjectID, to primitive.ObjectID) error {
+	col := s.db.Collection(CollName)
+
+	filter := bson.M{
+		"_id":       bson.M{"$in": bookIDs},
+		"authorIds": bson.M{"$in": from},
+	}
+
+	if _, err := col.UpdateMany(ctx, filter, bson.M{
+		"$addToSet": bson.M{"authorIds": to},
+	}); err != nil {
+		return err
+	}
+
+	_, err := col.UpdateMany(ctx, filter, bson.M{
+		"$pull": bson.M{"authorIds": bson.M{"$in": from}},
+	})
+
+	return err
+}
+
+// CountAuthorBooks counts the books linked to the author
+func (s *Store) CountAuthorBooks(ctx context.Context, authorID primitive.ObjectID) (int64, error) {
+	col := s.db.Collection(CollName)
+
+	return col.CountDocuments(ctx, bson.M{"authorIds": authorID})
+}
+
+// Merge folds the duplicates into the target book. Their identifiers and
+// authors are added to it, and what the target's metadata lacks is taken
+// from theirs, then they are deleted. Highlights and libraries have to be
+// moved to the target first.
+func (s *Store) Merge(ctx context.Context, targetID primitive.ObjectID, ids []primitive.ObjectID) (*t.Book, error) {
+	col := s.db.Collection(CollName)
+
+	target, err := s.GetByID(ctx, targetID)
+	if err != nil {
+		return nil, err
+	}
+
+	merged, err := s.GetByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	if len(merged) != len(ids) {
+		return nil, mongo.ErrNoDocuments
+	}
+
+	identifiers := make([]string, 0)
+	authorIDs := make([]primitive.ObjectID, 0)
+	md := target.Metadata
+	for _, b := range merged {
+		for _, id := range append([]string{b.ISBN}, b.Identifiers...) {
+			if id != target.ISBN && !slices.Contains(identifiers, id) {
+				identifiers = append(identifiers, id)
+			}
+		}
+		authorIDs = append(authorIDs, b.AuthorIDs...)
+		md = mergeMetadata(md, b.Metadata)
+	}
+
+	update := bson.M{
+		"$addToSet": bson.M{
+			"identifiers": bson.M{"$each": identifiers},
+			"authorIds":   bson.M{"$each": authorIDs},
+		},
+	}
+	if md != nil {
+		update["$set"] = bson.M{"metadata": md}
+	}
+
+	// The kept book takes their identifiers before they're deleted, so a
+	// failure in between never leaves their highlights without a book
+	var b t.Book
+	err = col.FindOneAndUpdate(ctx, bson.M{
+		"_id": targetID,
+	}, update, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&b)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := col.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": ids}}); err != nil {
+		return nil, err
+	}
+
+	return &b, nil
+}
+
+// mergeMetadata fills what the kept metadata lacks from the other's
+func mergeMetadata(keep, other *t.BookMetadata) *t.BookMetadata {
+	if other == nil {
+		return keep
+	}
+	if keep == nil {
+		md := *other
+		return &md
+	}
+
+	md := *keep
+	md.Subjects, md.Sources = slices.Clone(keep.Subjects), slices.Clone(keep.Sources)
+	if md.ISBN13 == "" {
+		md.ISBN13 = other.ISBN13
+	}
+	if md.Publisher == "" {
+		md.Publisher = other.Publisher
+	}
+	if md.PublishedYear == 0 {
+		md.PublishedYear = other.PublishedYear
+	}
+	if md.PageCount == 0 {
+		md.PageCount = other.PageCount
+	}
+	if md.CoverURL == "" {
+		md.CoverURL = other.CoverURL
+	}
+	for _, subject := range other.Subjects {
+		if !slices.Contains(md.Subjects, subject) {
+			md.Subjects = append(md.Subjects, subject)
+		}
+	}
+	for _, source := range other.Sources {
+		if !slices.Contains(md.Sources, source) {
+			md.Sources = append(md.Sources, source)
+		}
+	}
+
+	return &md
 }
@@ -0,0 +1,136 @@
+package categorize
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sikozonpc/notebase/types"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func library(userID primitive.ObjectID) []*types.Highlight {
+	texts := []string{
+		"Goroutines are cheap, start thousands of goroutines.",
+		"Channels orchestrate goroutines, mutexes serialize.",
+		"Do not communicate by sharing memory with goroutines.",
+		"Unbuffered channels synchronize goroutines.",
+		"Habits are the compound interest of self-improvement.",
+		"Every habit begins with a cue and a craving.",
+		"Make good habits obvious and bad habits invisible.",
+		"Habits shape identity, identity shapes habits.",
+	}
+
+	hs := make([]*types.Highlight, len(texts))
+	for i, text := range texts {
+		hs[i] = &types.Highlight{ID: primitive.NewObjectID(), UserID: userID, Text: text}
+	}
+
+	return hs
+}
+
+func TestCategorize(t *testing.T) {
+	hs := library(primitive.NewObjectID())
+	hs[0].Tags = []string{"goroutines"}
+	hs[4].RejectedTags = []string{"habits"}
+
+	result := Categorize(hs)
+
+	assert.Len(t, result.Topics, 2)
+	labels := []string{result.Topics[0].Label, result.Topics[1].Label}
+	assert.ElementsMatch(t, []string{"goroutines", "habits"}, labels)
+
+	for _, topic := range result.Topics {
+		assert.Len(t, topic.HighlightIDs, 4)
+		assert.LessOrEqual(t, len(topic.Keywords), TopicKeywords)
+	}
+
+	assert.Contains(t, result.Suggestions[hs[1].ID], "goroutines")
+	assert.Contains(t, result.Suggestions[hs[5].ID], "habits")
+
+	// Tags the highlight has or the user rejected are never suggested
+	assert.NotContains(t, result.Suggestions[hs[0].ID], "goroutines")
+	assert.NotContains(t, result.Suggestions[hs[4].ID], "habits")
+
+	for _, tags := range result.Suggestions {
+		assert.LessOrEqual(t, len(tags), SuggestedTagsLimit)
+	}
+
+	// Running again on the same library gives the same suggestions
+	assert.Equal(t, result.Suggestions, Categorize(hs).Suggestions)
+}
+
+func TestCategorizeSmallLibrary(t *testing.T) {
+	hs := library(primitive.NewObjectID())[:2]
+
+	result := Categorize(hs)
+
+	assert.Empty(t, result.Topics)
+	assert.Equal(t, []string{"goroutines"}, result.Suggestions[hs[0].ID])
+}
+
+func TestCategorizer(t *testing.T) {
+	userID := primitive.NewObjectID()
+	hs := library(userID)
+	highlights := &mockHighlightStore{highlights: hs}
+	topics := &mockTopicStore{}
+
+	c := NewCategorizer(highlights, topics, 0)
+
+	c.HighlightSaved(context.Background(), hs[0])
+	assert.Equal(t, []primitive.ObjectID{userID}, c.settled())
+	assert.Empty(t, c.settled())
+
+	if err := c.Categorize(context.Background(), userID); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Len(t, topics.topics, 2)
+	for _, topic := range topics.topics {
+		assert.Equal(t, userID, topic.UserID)
+		assert.False(t, topic.ID.IsZero())
+	}
+	assert.Len(t, highlights.suggested, len(hs))
+
+	// Only the changed suggestions are written
+	for _, h := range hs {
+		h.SuggestedTags = highlights.suggested[h.ID]
+	}
+	hs[2].SuggestedTags = nil
+
+	if err := c.Categorize(context.Background(), userID); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Len(t, highlights.suggested, 1)
+	assert.Contains(t, highlights.suggested, hs[2].ID)
+}
+
+// Only the methods used by the categorizer are implemented
+type mockHighlightStore struct {
+	types.HighlightStore
+	highlights []*types.Highlight
+	suggested  map[primitive.ObjectID][]string
+}
+
+func (m *mockHighlightStore) GetUserHighlights(context.Context, primitive.ObjectID, *types.HighlightFilter) (*types.HighlightPage, error) {
+	return &types.HighlightPage{Highlights: m.highlights, Total: int64(len(m.highlights))}, nil
+}
+
+func (m *mockHighlightStore) SetSuggestedTags(_ context.Context, _ primitive.ObjectID, suggestions map[primitive.ObjectID][]string) error {
+	m.suggested = suggestions
+	return nil
+}
+
+type mockTopicStore struct {
+	topics []*types.Topic
+}
+
+func (m *mockTopicStore) GetUserTopics(context.Context, primitive.ObjectID) ([]*types.Topic, error) {
+	return m.topics, nil
+}
+
+func (m *mockTopicStore) ReplaceUserTopics(_ context.Context, _ primitive.ObjectID, topics []*types.Topic) error {
+	m.topics = topics
+	return nil
+}
@@ -0,0 +1,116 @@
+package categorize
+
+import (
+	"context"
+	"log"
+	"slices"
+	"sync"
+	"time"
+
+	t "github.com/sikozonpc/notebase/types"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Categorizer re-categorizes a user's library in the background after it
+// changes. It's registered as a highlight hook and waits for changes to
+// settle, so an import of hundreds of highlights triggers a single run.
+type Categorizer struct {
+	highlights t.HighlightStore
+	topics     t.TopicStore
+	delay      time.Duration
+
+	mu sync.Mutex
+	// Users with changes, and when the last one happened
+	pending map[primitive.ObjectID]time.Time
+}
+
+func NewCategorizer(highlights t.HighlightStore, topics t.TopicStore, delay time.Duration) *Categorizer {
+	return &Categorizer{
+		highlights: highlights,
+		topics:     topics,
+		delay:      delay,
+		pending:    make(map[primitive.ObjectID]time.Time),
+	}
+}
+
+func (c *Categorizer) HighlightSaved(_ context.Context, h *t.Highlight) {
+	c.schedule(h.UserID)
+}
+
+func (c *Categorizer) HighlightDeleted(_ context.Context, userID, _ primitive.ObjectID) {
+	c.schedule(userID)
+}
+
+func (c *Categorizer) schedule(userID primitive.ObjectID) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.pending[userID] = time.Now()
+}
+
+// Run categorizes the libraries of users with settled changes until ctx is
+// done
+func (c *Categorizer) Run(ctx context.Context) {
+	ticker := time.NewTicker(max(c.delay/2, time.Second))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		for _, userID := range c.settled() {
+			if err := c.Categorize(ctx, userID); err != nil {
+				log.Printf("failed to categorize highlights of user %s: %v", userID.Hex(), err)
+			}
+		}
+	}
+}
+
+func (c *Categorizer) settled() []primitive.ObjectID {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	users := make([]primitive.ObjectID, 0)
+	for userID, changed := range c.pending {
+		if time.Since(changed) >= c.delay {
+			users = append(users, userID)
+			delete(c.pending, userID)
+		}
+	}
+
+	return users
+}
+
+// Categorize recomputes the user's topics and suggested tags. Only the
+// highlights whose suggestions changed are written.
+func (c *Categorizer) Categorize(ctx context.Context, userID primitive.ObjectID) error {
+	page, err := c.highlights.GetUserHighlights(ctx, userID, nil)
+	if err != nil {
+		return err
+	}
+
+	result := Categorize(page.Highlights)
+
+	changed := make(map[primitive.ObjectID][]string)
+	for _, h := range page.Highlights {
+		if tags := result.Suggestions[h.ID]; !slices.Equal(tags, h.SuggestedTags) && (len(tags) > 0 || len(h.SuggestedTags) > 0) {
+			changed[h.ID] = tags
+		}
+	}
+
+	if err := c.highlights.SetSuggestedTags(ctx, userID, changed); err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	for _, topic := range result.Topics {
+		topic.ID = primitive.NewObjectID()
+		topic.UserID = userID
+		topic.CreatedAt = now
+	}
+
+	return c.topics.ReplaceUserTopics(ctx, userID, result.Topics)
+}
@@ -0,0 +1,212 @@
+package categorize
+
+import (
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/sikozonpc/notebase/analysis"
+	"github.com/sikozonpc/notebase/suggest"
+	t "github.com/sikozonpc/notebase/types"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	MaxTopics = 20
+	// Smaller groups of highlights are not considered a topic
+	MinTopicSize = 2
+	// Keywords describing a topic
+	TopicKeywords = 5
+	// Tags suggested for each highlight, the topic label and its own keywords
+	SuggestedTagsLimit = 3
+
+	maxIterations = 25
+	// Shorter keywords are rarely meaningful tags
+	minKeywordLength = 3
+)
+
+// Result is the outcome of categorizing a user's library
+type Result struct {
+	Topics []*t.Topic
+	// Suggested tags for every highlight, empty when there are none
+	Suggestions map[primitive.ObjectID][]string
+}
+
+// Categorize extracts keywords from the highlights and clusters them into
+// topics with spherical k-means over their TF-IDF vectors. Results only
+// depend on the highlights, so running it again on an unchanged library
+// suggests the same tags.
+func Categorize(hs []*t.Highlight) *Result {
+	corpus := suggest.NewCorpus(hs)
+	forms := surfaceForms(hs)
+
+	// Highlights without any meaningful term can't be clustered
+	docs := make([]*t.Highlight, 0, len(hs))
+	for _, h := range hs {
+		if len(corpus.Vector(h.ID)) > 0 {
+			docs = append(docs, h)
+		}
+	}
+
+	sort.Slice(docs, func(i, j int) bool {
+		return docs[i].ID.Hex() < docs[j].ID.Hex()
+	})
+
+	result := &Result{
+		Topics:      make([]*t.Topic, 0),
+		Suggestions: make(map[primitive.ObjectID][]string, len(hs)),
+	}
+
+	labels := make(map[primitive.ObjectID]string)
+	for _, cluster := range kMeans(corpus, docs, numTopics(len(docs))) {
+		if len(cluster.members) < MinTopicSize {
+			continue
+		}
+
+		keywords := topTerms(cluster.centroid, TopicKeywords, forms, func(string) bool { return true })
+		if len(keywords) == 0 {
+			continue
+		}
+
+		topic := &t.Topic{
+			UserID:       cluster.members[0].UserID,
+			Label:        keywords[0],
+			Keywords:     keywords,
+			HighlightIDs: make([]primitive.ObjectID, len(cluster.members)),
+		}
+		for i, h := range cluster.members {
+			topic.HighlightIDs[i] = h.ID
+			labels[h.ID] = topic.Label
+		}
+
+		result.Topics = append(result.Topics, topic)
+	}
+
+	sort.Slice(result.Topics, func(i, j int) bool {
+		if len(result.Topics[i].HighlightIDs) != len(result.Topics[j].HighlightIDs) {
+			return len(result.Topics[i].HighlightIDs) > len(result.Topics[j].HighlightIDs)
+		}
+
+		return result.Topics[i].Label < result.Topics[j].Label
+	})
+
+	for _, h := range hs {
+		// Keywords only found in this highlight wouldn't group it with others
+		shared := func(term string) bool { return corpus.DocumentFrequency(term) > 1 }
+
+		candidates := make([]string, 0, SuggestedTagsLimit+1)
+		if label, ok := labels[h.ID]; ok {
+			candidates = append(candidates, label)
+		}
+		candidates = append(candidates, topTerms(corpus.Vector(h.ID), SuggestedTagsLimit, forms, shared)...)
+
+		result.Suggestions[h.ID] = suggestedTags(h, candidates)
+	}
+
+	return result
+}
+
+// numTopics grows with the library, following the sqrt(n/2) rule of thumb
+func numTopics(n int) int {
+	if n < 2*MinTopicSize {
+		return 0
+	}
+
+	k := int(math.Round(math.Sqrt(float64(n) / 2)))
+	return max(1, min(k, MaxTopics))
+}
+
+// suggestedTags drops the candidates the highlight already has or which the
+// user rejected, keeping their order
+func suggestedTags(h *t.Highlight, candidates []string) []string {
+	skip := make(map[string]bool, len(h.Tags)+len(h.RejectedTags))
+	for _, tag := range h.Tags {
+		skip[tag] = true
+	}
+	for _, tag := range h.RejectedTags {
+		skip[tag] = true
+	}
+
+	tags := make([]string, 0, SuggestedTagsLimit)
+	for _, c := range candidates {
+		if skip[c] || len(tags) == SuggestedTagsLimit {
+			continue
+		}
+
+		skip[c] = true
+		tags = append(tags, c)
+	}
+
+	return tags
+}
+
+// topTerms returns the n heaviest terms of a vector that pass keep, as the
+// words they were written as
+func topTerms(v map[string]float64, n int, forms map[string]string, keep func(string) bool) []string {
+	terms := make([]string, 0, len(v))
+	for term := range v {
+		form := forms[term]
+		if len(form) < minKeywordLength || isNumber(form) || !keep(term) {
+			continue
+		}
+		terms = append(terms, term)
+	}
+
+	sort.Slice(terms, func(i, j int) bool {
+		if v[terms[i]] != v[terms[j]] {
+			return v[terms[i]] > v[terms[j]]
+		}
+
+		return terms[i] < terms[j]
+	})
+
+	if len(terms) > n {
+		terms = terms[:n]
+	}
+
+	keywords := make([]string, len(terms))
+	for i, term := range terms {
+		keywords[i] = forms[term]
+	}
+
+	return keywords
+}
+
+// surfaceForms maps each stem to the word it's most often written as, since
+// stems like "habit" or "effect" don't always read well as tags
+func surfaceForms(hs []*t.Highlight) map[string]string {
+	counts := make(map[string]map[string]int)
+	for _, h := range hs {
+		for _, text := range append([]string{h.Text, h.Note}, h.Tags...) {
+			for _, tok := range analysis.Analyze(text) {
+				if counts[tok.Term] == nil {
+					counts[tok.Term] = make(map[string]int)
+				}
+				counts[tok.Term][strings.ToLower(text[tok.Start:tok.End])]++
+			}
+		}
+	}
+
+	forms := make(map[string]string, len(counts))
+	for stem, words := range counts {
+		best := ""
+		for word, n := range words {
+			if best == "" || n > words[best] || (n == words[best] && (len(word) < len(best) || (len(word) == len(best) && word < best))) {
+				best = word
+			}
+		}
+		forms[stem] = best
+	}
+
+	return forms
+}
+
+func isNumber(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,68 @@
+package categorize
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/sikozonpc/notebase/auth"
+	t "github.com/sikozonpc/notebase/types"
+	u "github.com/sikozonpc/notebase/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type Handler struct {
+	categorizer *Categorizer
+	store       t.TopicStore
+	userStore   t.UserStore
+}
+
+func NewHandler(categorizer *Categorizer, store t.TopicStore, userStore t.UserStore) *Handler {
+	return &Handler{
+		categorizer: categorizer,
+		store:       store,
+		userStore:   userStore,
+	}
+}
+
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc(
+		"/user/{userID}/topics",
+		auth.WithJWTAuth(u.MakeHTTPHandler(h.handleGetUserTopics), h.userStore),
+	).Methods("GET")
+
+	router.HandleFunc(
+		"/user/{userID}/topics/refresh",
+		auth.WithJWTAuth(u.MakeHTTPHandler(h.handleRefreshTopics), h.userStore),
+	).Methods("POST")
+}
+
+func (h *Handler) handleGetUserTopics(w http.ResponseWriter, r *http.Request) error {
+	userID, err := u.GetStringParamFromRequest(r, "userID")
+	if err != nil {
+		return err
+	}
+	oUserID, _ := primitive.ObjectIDFromHex(userID)
+
+	topics, err := h.store.GetUserTopics(r.Context(), oUserID)
+	if err != nil {
+		return err
+	}
+
+	return u.WriteJSON(w, http.StatusOK, topics)
+}
+
+// handleRefreshTopics categorizes the library right away instead of waiting
+// for the background run
+func (h *Handler) handleRefreshTopics(w http.ResponseWriter, r *http.Request) error {
+	userID, err := u.GetStringParamFromRequest(r, "userID")
+	if err != nil {
+		return err
+	}
+	oUserID, _ := primitive.ObjectIDFromHex(userID)
+
+	if err := h.categorizer.Categorize(r.Context(), oUserID); err != nil {
+		return err
+	}
+
+	return h.handleGetUserTopics(w, r)
+}
@@ -0,0 +1,162 @@
+package categorize
+
+import (
+	"math"
+
+	"github.com/sikozonpc/notebase/suggest"
+	t "github.com/sikozonpc/notebase/types"
+)
+
+type cluster struct {
+	centroid map[string]float64
+	members  []*t.Highlight
+}
+
+// kMeans groups the highlights into k clusters by cosine similarity. Initial
+// centroids are picked farthest-first so the result is deterministic.
+func kMeans(corpus *suggest.Corpus, docs []*t.Highlight, k int) []*cluster {
+	if k == 0 || len(docs) == 0 {
+		return nil
+	}
+
+	clusters := make([]*cluster, 0, k)
+	for _, i := range farthestFirst(corpus, docs, k) {
+		clusters = append(clusters, &cluster{centroid: copyVector(corpus.Vector(docs[i].ID))})
+	}
+
+	assignment := make([]int, len(docs))
+	for i := range assignment {
+		assignment[i] = -1
+	}
+
+	for iter := 0; iter < maxIterations; iter++ {
+		changed := false
+		for i, h := range docs {
+			best := nearest(clusters, corpus.Vector(h.ID))
+			if best != assignment[i] {
+				assignment[i] = best
+				changed = true
+			}
+		}
+
+		if !changed {
+			break
+		}
+
+		for c, cl := range clusters {
+			sum := make(map[string]float64)
+			for i, h := range docs {
+				if assignment[i] != c {
+					continue
+				}
+				for term, w := range corpus.Vector(h.ID) {
+					sum[term] += w
+				}
+			}
+
+			// An emptied cluster keeps its centroid and may get members back
+			if len(sum) > 0 {
+				cl.centroid = normalize(sum)
+			}
+		}
+	}
+
+	for i, h := range docs {
+		clusters[assignment[i]].members = append(clusters[assignment[i]].members, h)
+	}
+
+	return clusters
+}
+
+// farthestFirst starts from the highlight with the most terms and then
+// repeatedly picks the one least similar to those already picked
+func farthestFirst(corpus *suggest.Corpus, docs []*t.Highlight, k int) []int {
+	first := 0
+	for i, h := range docs {
+		if len(corpus.Vector(h.ID)) > len(corpus.Vector(docs[first].ID)) {
+			first = i
+		}
+	}
+
+	picked := []int{first}
+	// Similarity of each highlight to its most similar picked one
+	closest := make([]float64, len(docs))
+	for i, h := range docs {
+		closest[i] = corpus.Similarity(h.ID, docs[first].ID)
+	}
+
+	for len(picked) < k && len(picked) < len(docs) {
+		next := -1
+		for i := range docs {
+			if contains(picked, i) {
+				continue
+			}
+			if next == -1 || closest[i] < closest[next] {
+				next = i
+			}
+		}
+
+		picked = append(picked, next)
+		for i, h := range docs {
+			closest[i] = math.Max(closest[i], corpus.Similarity(h.ID, docs[next].ID))
+		}
+	}
+
+	return picked
+}
+
+func nearest(clusters []*cluster, v map[string]float64) int {
+	best, bestSim := 0, -1.0
+	for c, cl := range clusters {
+		if sim := dot(v, cl.centroid); sim > bestSim {
+			best, bestSim = c, sim
+		}
+	}
+
+	return best
+}
+
+func dot(a, b map[string]float64) float64 {
+	if len(b) < len(a) {
+		a, b = b, a
+	}
+
+	sum := 0.0
+	for term, w := range a {
+		sum += w * b[term]
+	}
+
+	return sum
+}
+
+func normalize(v map[string]float64) map[string]float64 {
+	norm := math.Sqrt(dot(v, v))
+	if norm == 0 {
+		return v
+	}
+
+	for term := range v {
+		v[term] /= norm
+	}
+
+	return v
+}
+
+func copyVector(v map[string]float64) map[string]float64 {
+	c := make(map[string]float64, len(v))
+	for term, w := range v {
+		c[term] = w
+	}
+
+	return c
+}
+
+func contains(values []int, value int) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
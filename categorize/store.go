@@ -0,0 +1,59 @@
+package categorize
+
+import (
+	"context"
+
+	t "github.com/sikozonpc/notebase/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const CollName = "topics"
+
+type Store struct {
+	db *mongo.Database
+}
+
+func NewStore(db *mongo.Database) *Store {
+	return &Store{db: db}
+}
+
+// GetUserTopics returns the user's topics, largest first
+func (s *Store) GetUserTopics(ctx context.Context, userID primitive.ObjectID) ([]*t.Topic, error) {
+	col := s.db.Collection(CollName)
+
+	cursor, err := col.Aggregate(ctx, mongo.Pipeline{
+		bson.D{{Key: "$match", Value: bson.M{"userId": userID}}},
+		bson.D{{Key: "$addFields", Value: bson.M{"size": bson.M{"$size": "$highlightIds"}}}},
+		bson.D{{Key: "$sort", Value: bson.D{{Key: "size", Value: -1}, {Key: "label", Value: 1}}}},
+		bson.D{{Key: "$project", Value: bson.M{"size": 0}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	topics := make([]*t.Topic, 0)
+	if err = cursor.All(ctx, &topics); err != nil {
+		return nil, err
+	}
+
+	return topics, nil
+}
+
+// ReplaceUserTopics replaces all of the user's topics, they are recomputed
+// as a whole every time
+func (s *Store) ReplaceUserTopics(ctx context.Context, userID primitive.ObjectID, topics []*t.Topic) error {
+	col := s.db.Collection(CollName)
+
+	models := []mongo.WriteModel{
+		mongo.NewDeleteManyModel().SetFilter(bson.M{"userId": userID}),
+	}
+	for _, topic := range topics {
+		models = append(models, mongo.NewInsertOneModel().SetDocument(topic))
+	}
+
+	_, err := col.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(true))
+	return err
+}
@@ -0,0 +1,410 @@
+package collection
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/sikozonpc/notebase/auth"
+	t "github.com/sikozonpc/notebase/types"
+	u "github.com/sikozonpc/notebase/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type Handler struct {
+	store          t.CollectionStore
+	highlightStore t.HighlightStore
+	userStore      t.UserStore
+}
+
+func NewHandler(store t.CollectionStore, highlightStore t.HighlightStore, userStore t.UserStore) *Handler {
+	return &Handler{
+		store:          store,
+		highlightStore: highlightStore,
+		userStore:      userStore,
+	}
+}
+
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc(
+		"/user/{userID}/collections",
+		auth.WithJWTAuth(u.MakeHTTPHandler(h.handleGetUserCollections), h.userStore),
+	).Methods("GET")
+
+	router.HandleFunc(
+		"/user/{userID}/collections",
+		auth.WithJWTAuth(u.MakeHTTPHandler(h.handleCreateCollection), h.userStore),
+	).Methods("POST")
+
+	router.HandleFunc(
+		"/user/{userID}/collections/{id}",
+		auth.WithJWTAuth(u.MakeHTTPHandler(h.handleGetCollection), h.userStore),
+	).Methods("GET")
+
+	router.HandleFunc(
+		"/user/{userID}/collections/{id}",
+		auth.WithJWTAuth(u.MakeHTTPHandler(h.handleUpdateCollection), h.userStore),
+	).Methods("PATCH")
+
+	router.HandleFunc(
+		"/user/{userID}/collections/{id}",
+		auth.WithJWTAuth(u.MakeHTTPHandler(h.handleDeleteCollection), h.userStore),
+	).Methods("DELETE")
+
+	router.HandleFunc(
+		"/user/{userID}/collections/{id}/highlights",
+		auth.WithJWTAuth(u.MakeHTTPHandler(h.handleAddHighlights), h.userStore),
+	).Methods("POST")
+
+	router.HandleFunc(
+		"/user/{userID}/collections/{id}/highlights",
+		auth.WithJWTAuth(u.MakeHTTPHandler(h.handleReorderHighlights), h.userStore),
+	).Methods("PUT")
+
+	router.HandleFunc(
+		"/user/{userID}/collections/{id}/highlights/{highlightID}",
+		auth.WithJWTAuth(u.MakeHTTPHandler(h.handleRemoveHighlight), h.userStore),
+	).Methods("DELETE")
+
+	router.HandleFunc(
+		"/user/{userID}/collections/{id}/daily-insights",
+		auth.WithJWTAuth(u.MakeHTTPHandler(h.handleUseForDailyInsights), h.userStore),
+	).Methods("PUT")
+
+	router.HandleFunc(
+		"/user/{userID}/collections/{id}/daily-insights",
+		auth.WithJWTAuth(u.MakeHTTPHandler(h.handleStopUsingForDailyInsights), h.userStore),
+	).Methods("DELETE")
+}
+
+func (h *Handler) handleGetUserCollections(w http.ResponseWriter, r *http.Request) error {
+	userID, err := u.GetStringParamFromRequest(r, "userID")
+	if err != nil {
+		return err
+	}
+	oUserID, _ := primitive.ObjectIDFromHex(userID)
+
+	cs, err := h.store.GetUserCollections(r.Context(), oUserID)
+	if err != nil {
+		return err
+	}
+
+	return u.WriteJSON(w, http.StatusOK, cs)
+}
+
+func (h *Handler) handleCreateCollection(w http.ResponseWriter, r *http.Request) error {
+	userID, err := u.GetStringParamFromRequest(r, "userID")
+	if err != nil {
+		return err
+	}
+	oUserID, _ := primitive.ObjectIDFromHex(userID)
+
+	payload := new(CreateCollectionRequest)
+	if err := json.NewDecoder(r.Body).Decode(payload); err != nil {
+		return u.WriteJSON(w, http.StatusBadRequest, t.APIError{Error: err.Error()})
+	}
+
+	if strings.TrimSpace(payload.Name) == "" {
+		return u.WriteJSON(w, http.StatusBadRequest, t.APIError{Error: fmt.Errorf("name is required").Error()})
+	}
+
+	c, err := h.store.CreateCollection(r.Context(), &t.CreateCollectionRequest{
+		Name:        strings.TrimSpace(payload.Name),
+		Description: payload.Description,
+		UserID:      oUserID,
+	})
+	if err != nil {
+		return err
+	}
+
+	return u.WriteJSON(w, http.StatusCreated, c)
+}
+
+func (h *Handler) handleGetCollection(w http.ResponseWriter, r *http.Request) error {
+	oUserID, oID, err := getIDsFromRequest(r)
+	if err != nil {
+		return err
+	}
+
+	c, err := h.store.GetCollectionByID(r.Context(), oID, oUserID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return notFound(w, oID)
+	}
+	if err != nil {
+		return err
+	}
+
+	page, err := h.highlightStore.GetUserHighlights(r.Context(), oUserID, &t.HighlightFilter{IDs: c.HighlightIDs})
+	if err != nil {
+		return err
+	}
+
+	return u.WriteJSON(w, http.StatusOK, CollectionResponse{
+		Collection: c,
+		Highlights: orderHighlights(c.HighlightIDs, page.Highlights),
+	})
+}
+
+func (h *Handler) handleUpdateCollection(w http.ResponseWriter, r *http.Request) error {
+	oUserID, oID, err := getIDsFromRequest(r)
+	if err != nil {
+		return err
+	}
+
+	payload := new(t.UpdateCollectionRequest)
+	if err := json.NewDecoder(r.Body).Decode(payload); err != nil {
+		return u.WriteJSON(w, http.StatusBadRequest, t.APIError{Error: err.Error()})
+	}
+
+	if payload.Name != nil && strings.TrimSpace(*payload.Name) == "" {
+		return u.WriteJSON(w, http.StatusBadRequest, t.APIError{Error: fmt.Errorf("name cannot be empty").Error()})
+	}
+
+	c, err := h.store.UpdateCollection(r.Context(), oID, oUserID, payload)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return notFound(w, oID)
+	}
+	if err != nil {
+		return err
+	}
+
+	return u.WriteJSON(w, http.StatusOK, c)
+}
+
+func (h *Handler) handleDeleteCollection(w http.ResponseWriter, r *http.Request) error {
+	oUserID, oID, err := getIDsFromRequest(r)
+	if err != nil {
+		return err
+	}
+
+	err = h.store.DeleteCollection(r.Context(), oID, oUserID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return notFound(w, oID)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Fall back to the whole library if this was the daily insights source
+	user, err := h.userStore.GetUserByID(r.Context(), oUserID.Hex())
+	if err != nil {
+		return err
+	}
+
+	if user.InsightsCollectionID != nil && *user.InsightsCollectionID == oID {
+		user.InsightsCollectionID = nil
+		if err := h.userStore.UpdateUser(r.Context(), *user); err != nil {
+			return err
+		}
+	}
+
+	return u.WriteJSON(w, http.StatusOK, nil)
+}
+
+func (h *Handler) handleAddHighlights(w http.ResponseWriter, r *http.Request) error {
+	oUserID, oID, err := getIDsFromRequest(r)
+	if err != nil {
+		return err
+	}
+
+	payload := new(AddHighlightsRequest)
+	if err := json.NewDecoder(r.Body).Decode(payload); err != nil {
+		return u.WriteJSON(w, http.StatusBadRequest, t.APIError{Error: err.Error()})
+	}
+
+	ids, err := u.ParseObjectIDs(payload.HighlightIDs)
+	if err != nil {
+		return u.WriteJSON(w, http.StatusBadRequest, t.APIError{Error: err.Error()})
+	}
+
+	if len(ids) == 0 {
+		return u.WriteJSON(w, http.StatusBadRequest, t.APIError{Error: fmt.Errorf("highlightIds are required").Error()})
+	}
+
+	// Only the user's own highlights can be added
+	page, err := h.highlightStore.GetUserHighlights(r.Context(), oUserID, &t.HighlightFilter{IDs: ids})
+	if err != nil {
+		return err
+	}
+
+	if len(page.Highlights) != len(missingIDs(nil, ids)) {
+		return u.WriteJSON(w, http.StatusBadRequest, t.APIError{Error: fmt.Errorf("some highlights do not exist").Error()})
+	}
+
+	position := -1
+	if payload.Position != nil {
+		position = *payload.Position
+	}
+
+	c, err := h.store.AddHighlights(r.Context(), oID, oUserID, ids, position)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return notFound(w, oID)
+	}
+	if err != nil {
+		return err
+	}
+
+	return u.WriteJSON(w, http.StatusOK, c)
+}
+
+func (h *Handler) handleReorderHighlights(w http.ResponseWriter, r *http.Request) error {
+	oUserID, oID, err := getIDsFromRequest(r)
+	if err != nil {
+		return err
+	}
+
+	payload := new(ReorderHighlightsRequest)
+	if err := json.NewDecoder(r.Body).Decode(payload); err != nil {
+		return u.WriteJSON(w, http.StatusBadRequest, t.APIError{Error: err.Error()})
+	}
+
+	ids, err := u.ParseObjectIDs(payload.HighlightIDs)
+	if err != nil {
+		return u.WriteJSON(w, http.StatusBadRequest, t.APIError{Error: err.Error()})
+	}
+
+	c, err := h.store.ReorderHighlights(r.Context(), oID, oUserID, ids)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return notFound(w, oID)
+	}
+	if errors.Is(err, ErrInvalidOrder) {
+		return u.WriteJSON(w, http.StatusBadRequest, t.APIError{Error: err.Error()})
+	}
+	if err != nil {
+		return err
+	}
+
+	return u.WriteJSON(w, http.StatusOK, c)
+}
+
+func (h *Handler) handleRemoveHighlight(w http.ResponseWriter, r *http.Request) error {
+	oUserID, oID, err := getIDsFromRequest(r)
+	if err != nil {
+		return err
+	}
+
+	highlightID, err := u.GetStringParamFromRequest(r, "highlightID")
+	if err != nil {
+		return err
+	}
+	oHighlightID, _ := primitive.ObjectIDFromHex(highlightID)
+
+	c, err := h.store.RemoveHighlight(r.Context(), oID, oUserID, oHighlightID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return notFound(w, oID)
+	}
+	if err != nil {
+		return err
+	}
+
+	return u.WriteJSON(w, http.StatusOK, c)
+}
+
+func (h *Handler) handleUseForDailyInsights(w http.ResponseWriter, r *http.Request) error {
+	oUserID, oID, err := getIDsFromRequest(r)
+	if err != nil {
+		return err
+	}
+
+	_, err = h.store.GetCollectionByID(r.Context(), oID, oUserID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return notFound(w, oID)
+	}
+	if err != nil {
+		return err
+	}
+
+	user, err := h.userStore.GetUserByID(r.Context(), oUserID.Hex())
+	if err != nil {
+		return err
+	}
+
+	user.InsightsCollectionID = &oID
+	if err := h.userStore.UpdateUser(r.Context(), *user); err != nil {
+		return err
+	}
+
+	return u.WriteJSON(w, http.StatusOK, user)
+}
+
+func (h *Handler) handleStopUsingForDailyInsights(w http.ResponseWriter, r *http.Request) error {
+	oUserID, oID, err := getIDsFromRequest(r)
+	if err != nil {
+		return err
+	}
+
+	user, err := h.userStore.GetUserByID(r.Context(), oUserID.Hex())
+	if err != nil {
+		return err
+	}
+
+	if user.InsightsCollectionID != nil && *user.InsightsCollectionID == oID {
+		user.InsightsCollectionID = nil
+		if err := h.userStore.UpdateUser(r.Context(), *user); err != nil {
+			return err
+		}
+	}
+
+	return u.WriteJSON(w, http.StatusOK, user)
+}
+
+func getIDsFromRequest(r *http.Request) (primitive.ObjectID, primitive.ObjectID, error) {
+	userID, err := u.GetStringParamFromRequest(r, "userID")
+	if err != nil {
+		return primitive.NilObjectID, primitive.NilObjectID, err
+	}
+	oUserID, _ := primitive.ObjectIDFromHex(userID)
+
+	id, err := u.GetStringParamFromRequest(r, "id")
+	if err != nil {
+		return primitive.NilObjectID, primitive.NilObjectID, err
+	}
+	oID, _ := primitive.ObjectIDFromHex(id)
+
+	return oUserID, oID, nil
+}
+
+func notFound(w http.ResponseWriter, id primitive.ObjectID) error {
+	return u.WriteJSON(w, http.StatusNotFound, t.APIError{Error: fmt.Errorf("collection with id %v not found", id.Hex()).Error()})
+}
+
+// orderHighlights returns the highlights in the collection order, skipping
+// ids that no longer exist
+func orderHighlights(order []primitive.ObjectID, hs []*t.Highlight) []*t.Highlight {
+	byID := make(map[primitive.ObjectID]*t.Highlight, len(hs))
+	for _, h := range hs {
+		byID[h.ID] = h
+	}
+
+	ordered := make([]*t.Highlight, 0, len(hs))
+	for _, id := range order {
+		if h, ok := byID[id]; ok {
+			ordered = append(ordered, h)
+		}
+	}
+
+	return ordered
+}
+
+type CreateCollectionRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+type AddHighlightsRequest struct {
+	HighlightIDs []string `json:"highlightIds"`
+	Position     *int     `json:"position"` // Appended at the end when omitted
+}
+
+type ReorderHighlightsRequest struct {
+	HighlightIDs []string `json:"highlightIds"`
+}
+
+type CollectionResponse struct {
+	*t.Collection
+	Highlights []*t.Highlight `json:"highlights"`
+}
@@ -0,0 +1,272 @@
+package collection
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	types "github.com/sikozonpc/notebase/types"
+	u "github.com/sikozonpc/notebase/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestCollectionHandler(t *testing.T) {
+	userID := primitive.NewObjectID()
+	h1 := &types.Highlight{ID: primitive.NewObjectID(), UserID: userID, Text: "first"}
+	h2 := &types.Highlight{ID: primitive.NewObjectID(), UserID: userID, Text: "second"}
+
+	collection := &types.Collection{
+		ID:           primitive.NewObjectID(),
+		UserID:       userID,
+		Name:         "Leadership",
+		HighlightIDs: []primitive.ObjectID{h2.ID, h1.ID},
+	}
+
+	store := &mockCollectionStore{collections: map[primitive.ObjectID]*types.Collection{collection.ID: collection}}
+	highlightStore := &mockHighlightStore{highlights: []*types.Highlight{h1, h2}}
+	userStore := &mockUserStore{user: &types.User{ID: userID}}
+	handler := NewHandler(store, highlightStore, userStore)
+
+	collectionURL := "/user/" + userID.Hex() + "/collections/" + collection.ID.Hex()
+
+	t.Run("should handle create collection", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, "/user/"+userID.Hex()+"/collections", bytes.NewBufferString(`{"name": "Go concurrency"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		router := mux.NewRouter()
+
+		router.HandleFunc("/user/{userID}/collections", u.MakeHTTPHandler(handler.handleCreateCollection)).Methods(http.MethodPost)
+
+		router.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusCreated {
+			t.Errorf("expected status code %d, got %d", http.StatusCreated, rr.Code)
+		}
+	})
+
+	t.Run("should fail to create a collection without a name", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, "/user/"+userID.Hex()+"/collections", bytes.NewBufferString(`{"name": " "}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		router := mux.NewRouter()
+
+		router.HandleFunc("/user/{userID}/collections", u.MakeHTTPHandler(handler.handleCreateCollection)).Methods(http.MethodPost)
+
+		router.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+
+	t.Run("should return the collection highlights in order", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, collectionURL, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		router := mux.NewRouter()
+
+		router.HandleFunc("/user/{userID}/collections/{id}", u.MakeHTTPHandler(handler.handleGetCollection)).Methods(http.MethodGet)
+
+		router.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		var response struct {
+			Highlights []*types.Highlight `json:"highlights"`
+		}
+		if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(response.Highlights) != 2 || response.Highlights[0].Text != "second" {
+			t.Errorf("expected highlights in collection order, got %+v", response.Highlights)
+		}
+	})
+
+	t.Run("should fail to add highlights the user does not own", func(t *testing.T) {
+		body := `{"highlightIds": ["` + primitive.NewObjectID().Hex() + `"]}`
+		req, err := http.NewRequest(http.MethodPost, collectionURL+"/highlights", bytes.NewBufferString(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		router := mux.NewRouter()
+
+		router.HandleFunc("/user/{userID}/collections/{id}/highlights", u.MakeHTTPHandler(handler.handleAddHighlights)).Methods(http.MethodPost)
+
+		router.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+
+	t.Run("should fail to reorder with a different set of highlights", func(t *testing.T) {
+		body := `{"highlightIds": ["` + h1.ID.Hex() + `"]}`
+		req, err := http.NewRequest(http.MethodPut, collectionURL+"/highlights", bytes.NewBufferString(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		router := mux.NewRouter()
+
+		router.HandleFunc("/user/{userID}/collections/{id}/highlights", u.MakeHTTPHandler(handler.handleReorderHighlights)).Methods(http.MethodPut)
+
+		router.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+
+	t.Run("should use the collection for daily insights", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPut, collectionURL+"/daily-insights", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		router := mux.NewRouter()
+
+		router.HandleFunc("/user/{userID}/collections/{id}/daily-insights", u.MakeHTTPHandler(handler.handleUseForDailyInsights)).Methods(http.MethodPut)
+
+		router.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Errorf("expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		if userStore.user.InsightsCollectionID == nil || *userStore.user.InsightsCollectionID != collection.ID {
+			t.Errorf("expected insights collection to be %s", collection.ID.Hex())
+		}
+	})
+
+	t.Run("should return 404 for a collection that does not exist", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/user/"+userID.Hex()+"/collections/"+primitive.NewObjectID().Hex(), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		router := mux.NewRouter()
+
+		router.HandleFunc("/user/{userID}/collections/{id}", u.MakeHTTPHandler(handler.handleGetCollection)).Methods(http.MethodGet)
+
+		router.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("expected status code %d, got %d", http.StatusNotFound, rr.Code)
+		}
+	})
+}
+
+type mockCollectionStore struct {
+	collections map[primitive.ObjectID]*types.Collection
+}
+
+func (m *mockCollectionStore) CreateCollection(_ context.Context, req *types.CreateCollectionRequest) (*types.Collection, error) {
+	c := &types.Collection{ID: primitive.NewObjectID(), UserID: req.UserID, Name: req.Name}
+	m.collections[c.ID] = c
+	return c, nil
+}
+
+func (m *mockCollectionStore) GetUserCollections(context.Context, primitive.ObjectID) ([]*types.Collection, error) {
+	return []*types.Collection{}, nil
+}
+
+func (m *mockCollectionStore) GetCollectionByID(_ context.Context, id primitive.ObjectID, _ primitive.ObjectID) (*types.Collection, error) {
+	c, ok := m.collections[id]
+	if !ok {
+		return nil, mongo.ErrNoDocuments
+	}
+
+	return c, nil
+}
+
+func (m *mockCollectionStore) UpdateCollection(ctx context.Context, id primitive.ObjectID, userID primitive.ObjectID, _ *types.UpdateCollectionRequest) (*types.Collection, error) {
+	return m.GetCollectionByID(ctx, id, userID)
+}
+
+func (m *mockCollectionStore) DeleteCollection(context.Context, primitive.ObjectID, primitive.ObjectID) error {
+	return nil
+}
+
+func (m *mockCollectionStore) AddHighlights(ctx context.Context, id primitive.ObjectID, userID primitive.ObjectID, _ []primitive.ObjectID, _ int) (*types.Collection, error) {
+	return m.GetCollectionByID(ctx, id, userID)
+}
+
+func (m *mockCollectionStore) RemoveHighlight(ctx context.Context, id primitive.ObjectID, userID primitive.ObjectID, _ primitive.ObjectID) (*types.Collection, error) {
+	return m.GetCollectionByID(ctx, id, userID)
+}
+
+func (m *mockCollectionStore) PurgeHighlights(context.Context, []primitive.ObjectID) error {
+	return nil
+}
+
+func (m *mockCollectionStore) ReplaceHighlights(context.Context, primitive.ObjectID, []primitive.ObjectID, primitive.ObjectID) error {
+	return nil
+}
+
+func (m *mockCollectionStore) ReorderHighlights(ctx context.Context, id primitive.ObjectID, userID primitive.ObjectID, ids []primitive.ObjectID) (*types.Collection, error) {
+	c, err := m.GetCollectionByID(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !sameIDs(c.HighlightIDs, ids) {
+		return nil, ErrInvalidOrder
+	}
+
+	return c, nil
+}
+
+// Only the methods used by the collection handler are implemented
+type mockHighlightStore struct {
+	types.HighlightStore
+	highlights []*types.Highlight
+}
+
+func (m *mockHighlightStore) GetUserHighlights(_ context.Context, _ primitive.ObjectID, filter *types.HighlightFilter) (*types.HighlightPage, error) {
+	hs := make([]*types.Highlight, 0)
+	for _, h := range m.highlights {
+		for _, id := range filter.IDs {
+			if h.ID == id {
+				hs = append(hs, h)
+			}
+		}
+	}
+
+	return &types.HighlightPage{Highlights: hs, Total: int64(len(hs))}, nil
+}
+
+type mockUserStore struct {
+	types.UserStore
+	user *types.User
+}
+
+func (m *mockUserStore) GetUserByID(context.Context, string) (*types.User, error) {
+	return m.user, nil
+}
+
+func (m *mockUserStore) UpdateUser(_ context.Context, user types.User) error {
+	*m.user = user
+	return nil
+}
@@ -0,0 +1,287 @@
+package collection
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+
+	t "github.com/sikozonpc/notebase/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const CollName = "collections"
+
+var ErrInvalidOrder = errors.New("new order must contain exactly the highlights in the collection")
+
+type Store struct {
+	db *mongo.Database
+}
+
+func NewStore(db *mongo.Database) *Store {
+	return &Store{db: db}
+}
+
+func (s *Store) CreateCollection(ctx context.Context, c *t.CreateCollectionRequest) (*t.Collection, error) {
+	col := s.db.Collection(CollName)
+
+	now := time.Now().UTC()
+	newCollection := &t.Collection{
+		ID:           primitive.NewObjectID(),
+		UserID:       c.UserID,
+		Name:         c.Name,
+		Description:  c.Description,
+		HighlightIDs: []primitive.ObjectID{},
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+
+	if _, err := col.InsertOne(ctx, newCollection); err != nil {
+		return nil, err
+	}
+
+	return newCollection, nil
+}
+
+func (s *Store) GetUserCollections(ctx context.Context, userID primitive.ObjectID) ([]*t.Collection, error) {
+	col := s.db.Collection(CollName)
+
+	cursor, err := col.Find(ctx, bson.M{
+		"userId": userID,
+	}, options.Find().SetSort(bson.D{{Key: "name", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+
+	collections := make([]*t.Collection, 0)
+	if err = cursor.All(ctx, &collections); err != nil {
+		return nil, err
+	}
+
+	return collections, nil
+}
+
+func (s *Store) GetCollectionByID(ctx context.Context, id primitive.ObjectID, userID primitive.ObjectID) (*t.Collection, error) {
+	col := s.db.Collection(CollName)
+
+	var c t.Collection
+	err := col.FindOne(ctx, bson.M{
+		"_id":    id,
+		"userId": userID,
+	}).Decode(&c)
+	if err != nil {
+		return nil, err
+	}
+
+	return &c, nil
+}
+
+func (s *Store) UpdateCollection(ctx context.Context, id primitive.ObjectID, userID primitive.ObjectID, req *t.UpdateCollectionRequest) (*t.Collection, error) {
+	set := bson.M{
+		"updatedAt": time.Now().UTC(),
+	}
+	if req.Name != nil {
+		set["name"] = *req.Name
+	}
+	if req.Description != nil {
+		set["description"] = *req.Description
+	}
+
+	return s.update(ctx, id, userID, bson.M{"$set": set})
+}
+
+func (s *Store) DeleteCollection(ctx context.Context, id primitive.ObjectID, userID primitive.ObjectID) error {
+	col := s.db.Collection(CollName)
+
+	res, err := col.DeleteOne(ctx, bson.M{
+		"_id":    id,
+		"userId": userID,
+	})
+	if err != nil {
+		return err
+	}
+
+	if res.DeletedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+
+	return nil
+}
+
+// AddHighlights inserts the highlights at position, or at the end when
+// position is negative. Highlights already in the collection keep their place.
+func (s *Store) AddHighlights(ctx context.Context, id primitive.ObjectID, userID primitive.ObjectID, highlightIDs []primitive.ObjectID, position int) (*t.Collection, error) {
+	c, err := s.GetCollectionByID(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	newIDs := missingIDs(c.HighlightIDs, highlightIDs)
+	if len(newIDs) == 0 {
+		return c, nil
+	}
+
+	each := bson.M{"$each": newIDs}
+	if position >= 0 {
+		each["$position"] = position
+	}
+
+	return s.update(ctx, id, userID, bson.M{
+		"$push": bson.M{"highlightIds": each},
+		"$set":  bson.M{"updatedAt": time.Now().UTC()},
+	})
+}
+
+func (s *Store) RemoveHighlight(ctx context.Context, id primitive.ObjectID, userID primitive.ObjectID, highlightID primitive.ObjectID) (*t.Collection, error) {
+	return s.update(ctx, id, userID, bson.M{
+		"$pull": bson.M{"highlightIds": highlightID},
+		"$set":  bson.M{"updatedAt": time.Now().UTC()},
+	})
+}
+
+// PurgeHighlights removes the highlights from every collection they're in,
+// once they are deleted for good
+func (s *Store) PurgeHighlights(ctx context.Context, highlightIDs []primitive.ObjectID) error {
+	col := s.db.Collection(CollName)
+
+	_, err := col.UpdateMany(ctx, bson.M{
+		"highlightIds": bson.M{"$in": highlightIDs},
+	}, bson.M{
+		"$pull": bson.M{"highlightIds": bson.M{"$in": highlightIDs}},
+		"$set":  bson.M{"updatedAt": time.Now().UTC()},
+	})
+
+	return err
+}
+
+// ReplaceHighlights puts the highlight in place of the others in the user's
+// collections, after they were merged into it
+func (s *Store) ReplaceHighlights(ctx context.Context, userID primitive.ObjectID, from []primitive.ObjectID, to primitive.ObjectID) error {
+	col := s.db.Collection(CollName)
+
+	cursor, err := col.Find(ctx, bson.M{
+		"userId":       userID,
+		"highlightIds": bson.M{"$in": from},
+	})
+	if err != nil {
+		return err
+	}
+
+	collections := make([]*t.Collection, 0)
+	if err = cursor.All(ctx, &collections); err != nil {
+		return err
+	}
+
+	for _, c := range collections {
+		_, err := s.update(ctx, c.ID, userID, bson.M{
+			"$set": bson.M{
+				"highlightIds": replaceIDs(c.HighlightIDs, from, to),
+				"updatedAt":    time.Now().UTC(),
+			},
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ReorderHighlights replaces the order of the highlights in the collection.
+// The new order must contain exactly the highlights already in it.
+func (s *Store) ReorderHighlights(ctx context.Context, id primitive.ObjectID, userID primitive.ObjectID, highlightIDs []primitive.ObjectID) (*t.Collection, error) {
+	c, err := s.GetCollectionByID(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !sameIDs(c.HighlightIDs, highlightIDs) {
+		return nil, ErrInvalidOrder
+	}
+
+	return s.update(ctx, id, userID, bson.M{
+		"$set": bson.M{
+			"highlightIds": highlightIDs,
+			"updatedAt":    time.Now().UTC(),
+		},
+	})
+}
+
+func (s *Store) update(ctx context.Context, id primitive.ObjectID, userID primitive.ObjectID, update bson.M) (*t.Collection, error) {
+	col := s.db.Collection(CollName)
+
+	var c t.Collection
+	err := col.FindOneAndUpdate(ctx, bson.M{
+		"_id":    id,
+		"userId": userID,
+	}, update, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&c)
+	if err != nil {
+		return nil, err
+	}
+
+	return &c, nil
+}
+
+// missingIDs returns the ids in add that are not in existing, without duplicates
+func missingIDs(existing, add []primitive.ObjectID) []primitive.ObjectID {
+	seen := make(map[primitive.ObjectID]bool, len(existing)+len(add))
+	for _, id := range existing {
+		seen[id] = true
+	}
+
+	missing := make([]primitive.ObjectID, 0, len(add))
+	for _, id := range add {
+		if seen[id] {
+			continue
+		}
+
+		seen[id] = true
+		missing = append(missing, id)
+	}
+
+	return missing
+}
+
+// replaceIDs replaces the ids in from with to, which takes the place of the
+// first of them or keeps its own if it comes before
+func replaceIDs(ids, from []primitive.ObjectID, to primitive.ObjectID) []primitive.ObjectID {
+	replaced := make([]primitive.ObjectID, 0, len(ids))
+	placed := false
+
+	for _, id := range ids {
+		if id != to && !slices.Contains(from, id) {
+			replaced = append(replaced, id)
+			continue
+		}
+
+		if !placed {
+			placed = true
+			replaced = append(replaced, to)
+		}
+	}
+
+	return replaced
+}
+
+func sameIDs(a, b []primitive.ObjectID) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	counts := make(map[primitive.ObjectID]int, len(a))
+	for _, id := range a {
+		counts[id]++
+	}
+
+	for _, id := range b {
+		counts[id]--
+		if counts[id] < 0 {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,16 @@
+package collection
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestReplaceIDs(t *testing.T) {
+	a, b, c, keep := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+
+	assert.Equal(t, []primitive.ObjectID{a, keep, c}, replaceIDs([]primitive.ObjectID{a, b, c}, []primitive.ObjectID{b}, keep))
+	assert.Equal(t, []primitive.ObjectID{keep, a}, replaceIDs([]primitive.ObjectID{keep, a, b}, []primitive.ObjectID{b}, keep), "the kept highlight shouldn't be added twice")
+	assert.Equal(t, []primitive.ObjectID{keep, a}, replaceIDs([]primitive.ObjectID{b, a, c, keep}, []primitive.ObjectID{b, c}, keep))
+}
@@ -2,6 +2,8 @@ package config
 
 import (
 	"os"
+	"strconv"
+	"time"
 
 	t "github.com/sikozonpc/notebase/types"
 )
@@ -10,14 +12,41 @@ var Envs = initConfig()
 
 func initConfig() t.Config {
 	return t.Config{
-		Env:       getEnv("ENV", "development"),
-		Port:      getEnv("PORT", "8080"),
-		MongoURI:  getEnv("MONGODB_URI", "mongodb://localhost:27017"),
-		PublicURL: getEnv("PUBLIC_URL", "http://localhost:3000"),
-		JWTSecret: getEnv("JWT_SECRET", "JWT secret is required"),
-		SendGridAPIKey:    getEnv("SENDGRID_API_KEY", "SendGrid API KEY is required"),
-		SendGridFromEmail: getEnv("SENDGRID_FROM_EMAIL", "SendGrid From email is required"),
-		APIKey:            getEnv("API_KEY", "API Key is required"),
+		Env:                         getEnv("ENV", "development"),
+		Port:                        getEnv("PORT", "8080"),
+		MongoURI:                    getEnv("MONGODB_URI", "mongodb://localhost:27017"),
+		MongoDBName:                 getEnv("MONGODB_DB_NAME", "notebase"),
+		MongoMaxPoolSize:            getEnvAsUint("MONGODB_MAX_POOL_SIZE", 100),
+		MongoMinPoolSize:            getEnvAsUint("MONGODB_MIN_POOL_SIZE", 0),
+		MongoConnectTimeout:         getEnvAsDuration("MONGODB_CONNECT_TIMEOUT", 20*time.Second),
+		MongoServerSelectionTimeout: getEnvAsDuration("MONGODB_SERVER_SELECTION_TIMEOUT", 30*time.Second),
+		MongoSocketTimeout:          getEnvAsDuration("MONGODB_SOCKET_TIMEOUT", 0),
+		MongoReadConcern:            getEnv("MONGODB_READ_CONCERN", ""),
+		MongoWriteConcern:           getEnv("MONGODB_WRITE_CONCERN", ""),
+		MongoTLS:                    getEnvAsBool("MONGODB_TLS", false),
+		MongoTLSCAFile:              getEnv("MONGODB_TLS_CA_FILE", ""),
+		MongoTLSCertKeyFile:         getEnv("MONGODB_TLS_CERT_KEY_FILE", ""),
+		MongoTLSInsecure:            getEnvAsBool("MONGODB_TLS_INSECURE", false),
+		PublicURL:                   getEnv("PUBLIC_URL", "http://localhost:3000"),
+		GCPCoversBucketName:         getEnv("GCP_COVERS_BUCKET_NAME", "notebase-covers"),
+		JWTSecret:                   getEnv("JWT_SECRET", "JWT secret is required"),
+		SendGridAPIKey:              getEnv("SENDGRID_API_KEY", "SendGrid API KEY is required"),
+		SendGridFromEmail:           getEnv("SENDGRID_FROM_EMAIL", "SendGrid From email is required"),
+		APIKey:                      getEnv("API_KEY", "API Key is required"),
+		SearchBackend:               getEnv("SEARCH_BACKEND", "mongo"),
+		SearchIndexPath:             getEnv("SEARCH_INDEX_PATH", "data/search.idx"),
+		SearchIndexSaveInterval:     getEnvAsDuration("SEARCH_INDEX_SAVE_INTERVAL", time.Minute),
+		CategorizeDelay:             getEnvAsDuration("CATEGORIZE_DELAY", 30*time.Second),
+		TrashRetention:              getEnvAsDuration("TRASH_RETENTION", 30*24*time.Hour),
+		TrashPurgeInterval:          getEnvAsDuration("TRASH_PURGE_INTERVAL", time.Hour),
+		WeightInsightsByRating:      getEnvAsBool("WEIGHT_INSIGHTS_BY_RATING", false),
+		MetadataProviders:           getEnv("METADATA_PROVIDERS", ""),
+		MetadataCatalogPath:         getEnv("METADATA_CATALOG_PATH", ""),
+		MetadataEnrichInterval:      getEnvAsDuration("METADATA_ENRICH_INTERVAL", 10*time.Minute),
+		MetadataCacheTTL:            getEnvAsDuration("METADATA_CACHE_TTL", 30*24*time.Hour),
+		OpenLibraryURL:              getEnv("OPEN_LIBRARY_URL", "https://openlibrary.org"),
+		GoogleBooksURL:              getEnv("GOOGLE_BOOKS_URL", "https://www.googleapis.com/books/v1"),
+		GoogleBooksAPIKey:           getEnv("GOOGLE_BOOKS_API_KEY", ""),
 	}
 }
 
@@ -28,3 +57,43 @@ func getEnv(key, fallback string) string {
 
 	return fallback
 }
+
+func getEnvAsUint(key string, fallback uint64) uint64 {
+	if value, ok := os.LookupEnv(key); ok {
+		i, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return fallback
+		}
+
+		return i
+	}
+
+	return fallback
+}
+
+func getEnvAsBool(key string, fallback bool) bool {
+	if value, ok := os.LookupEnv(key); ok {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fallback
+		}
+
+		return b
+	}
+
+	return fallback
+}
+
+// Durations are written like "30s" or "1m30s"
+func getEnvAsDuration(key string, fallback time.Duration) time.Duration {
+	if value, ok := os.LookupEnv(key); ok {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fallback
+		}
+
+		return d
+	}
+
+	return fallback
+}
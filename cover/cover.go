@@ -0,0 +1,232 @@
+package cover
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/sikozonpc/notebase/storage"
+	t "github.com/sikozonpc/notebase/types"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ErrInvalidImage is returned for a cover that is not a JPEG, PNG or GIF, or
+// is too large
+var ErrInvalidImage = errors.New("invalid cover image")
+
+const (
+	MaxSize   = 1200 // Longest side of a stored cover, larger ones are scaled down
+	ThumbSize = 240  // Longest side of a thumbnail
+
+	MaxUploadBytes = 10 << 20
+	// Larger images would take too much memory to decode
+	maxPixels = 40_000_000
+
+	jpegQuality = 85
+)
+
+// Covers stores a full size and a thumbnail JPEG of each book's cover
+type Covers struct {
+	storage storage.Storage
+	books   t.BookStore
+	client  *http.Client
+}
+
+func NewCovers(storage storage.Storage, books t.BookStore) *Covers {
+	return &Covers{
+		storage: storage,
+		books:   books,
+		client:  &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Save replaces the cover of the book with the image
+func (c *Covers) Save(ctx context.Context, bookID primitive.ObjectID, r io.Reader) error {
+	data, err := io.ReadAll(io.LimitReader(r, MaxUploadBytes+1))
+	if err != nil {
+		return err
+	}
+	if len(data) > MaxUploadBytes {
+		return fmt.Errorf("%w: larger than %d bytes", ErrInvalidImage, MaxUploadBytes)
+	}
+
+	full, thumb, err := process(data)
+	if err != nil {
+		return err
+	}
+
+	if err := c.storage.Write(Path(bookID, false), full); err != nil {
+		return err
+	}
+	if err := c.storage.Write(Path(bookID, true), thumb); err != nil {
+		return err
+	}
+
+	return c.books.SetCover(ctx, bookID, time.Now().UTC())
+}
+
+// Fetch downloads the cover at the URL and saves it as the book's cover
+func (c *Covers) Fetch(ctx context.Context, bookID primitive.ObjectID, url string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("cover %s responded with status %d", url, res.StatusCode)
+	}
+
+	return c.Save(ctx, bookID, res.Body)
+}
+
+// Read returns the stored JPEG of the book's cover or its thumbnail
+func (c *Covers) Read(bookID primitive.ObjectID, thumb bool) ([]byte, error) {
+	data, err := c.storage.Read(Path(bookID, thumb))
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(data), nil
+}
+
+// Delete removes the stored cover of the book and its thumbnail
+func (c *Covers) Delete(bookID primitive.ObjectID) error {
+	if err := c.storage.Delete(Path(bookID, false)); err != nil {
+		return err
+	}
+
+	return c.storage.Delete(Path(bookID, true))
+}
+
+// Path is where the cover is kept in storage
+func Path(bookID primitive.ObjectID, thumb bool) string {
+	if thumb {
+		return fmt.Sprintf("covers/%s_thumb.jpg", bookID.Hex())
+	}
+
+	return fmt.Sprintf("covers/%s.jpg", bookID.Hex())
+}
+
+// URL is where the API serves the book's cover, empty if it has none. It
+// changes with the cover, so clients can cache it for good.
+func URL(b *t.Book, thumb bool) string {
+	if b.CoverAt == nil {
+		return ""
+	}
+
+	url := fmt.Sprintf("/api/v1/books/%s/cover?v=%d", b.ID.Hex(), b.CoverAt.Unix())
+	if thumb {
+		url += "&size=thumb"
+	}
+
+	return url
+}
+
+// process decodes the image and encodes the cover and its thumbnail
+func process(data []byte) ([]byte, []byte, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrInvalidImage, err)
+	}
+	if cfg.Width*cfg.Height > maxPixels {
+		return nil, nil, fmt.Errorf("%w: %dx%d is too large", ErrInvalidImage, cfg.Width, cfg.Height)
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrInvalidImage, err)
+	}
+
+	flat := flatten(img)
+
+	full, err := encode(resize(flat, MaxSize))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	thumb, err := encode(resize(flat, ThumbSize))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return full, thumb, nil
+}
+
+// flatten draws the image over white, JPEGs have no transparency
+func flatten(img image.Image) *image.RGBA {
+	b := img.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(dst, dst.Bounds(), img, b.Min, draw.Over)
+
+	return dst
+}
+
+// resize scales the image down so its longest side fits in size, averaging
+// the pixels each one covers
+func resize(src *image.RGBA, size int) *image.RGBA {
+	w, h := src.Bounds().Dx(), src.Bounds().Dy()
+	if w <= size && h <= size {
+		return src
+	}
+
+	dw, dh := size, size
+	if w > h {
+		dh = max(1, h*size/w)
+	} else {
+		dw = max(1, w*size/h)
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		y0, y1 := y*h/dh, max((y+1)*h/dh, y*h/dh+1)
+
+		for x := 0; x < dw; x++ {
+			x0, x1 := x*w/dw, max((x+1)*w/dw, x*w/dw+1)
+
+			var r, g, b, n int
+			for sy := y0; sy < y1; sy++ {
+				row := src.Pix[sy*src.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					r += int(row[sx*4])
+					g += int(row[sx*4+1])
+					b += int(row[sx*4+2])
+					n++
+				}
+			}
+
+			i := dst.PixOffset(x, y)
+			dst.Pix[i] = uint8(r / n)
+			dst.Pix[i+1] = uint8(g / n)
+			dst.Pix[i+2] = uint8(b / n)
+			dst.Pix[i+3] = 0xff
+		}
+	}
+
+	return dst
+}
+
+func encode(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpegQuality}); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,91 @@
+package cover
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+	"time"
+
+	"github.com/sikozonpc/notebase/storage"
+	types "github.com/sikozonpc/notebase/types"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestSave(t *testing.T) {
+	bookID := primitive.NewObjectID()
+	books := &mockBookStore{books: []*types.Book{{ID: bookID}}}
+	covers := NewCovers(storage.NewMemoryStorage(), books)
+
+	t.Run("should store a cover and its thumbnail", func(t *testing.T) {
+		if err := covers.Save(context.Background(), bookID, bytes.NewReader(pngCover(t, 1600, 2400))); err != nil {
+			t.Fatal(err)
+		}
+
+		assert.NotNil(t, books.books[0].CoverAt)
+
+		for thumb, size := range map[bool][2]int{false: {800, 1200}, true: {160, 240}} {
+			data, err := covers.Read(bookID, thumb)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			img, err := jpeg.Decode(bytes.NewReader(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, size[0], img.Bounds().Dx())
+			assert.Equal(t, size[1], img.Bounds().Dy())
+		}
+	})
+
+	t.Run("should flatten transparency onto white", func(t *testing.T) {
+		img := resize(flatten(image.NewNRGBA(image.Rect(0, 0, 10, 10))), ThumbSize)
+
+		assert.Equal(t, color.RGBA{255, 255, 255, 255}, img.At(5, 5))
+	})
+
+	t.Run("should reject something that isn't an image", func(t *testing.T) {
+		err := covers.Save(context.Background(), bookID, bytes.NewReader([]byte("not an image")))
+		if !errors.Is(err, ErrInvalidImage) {
+			t.Errorf("expected ErrInvalidImage, got %v", err)
+		}
+	})
+}
+
+func TestURL(t *testing.T) {
+	b := &types.Book{ID: primitive.NewObjectID()}
+	assert.Empty(t, URL(b, false))
+
+	at := time.Unix(1700000000, 0)
+	b.CoverAt = &at
+	assert.Equal(t, "/api/v1/books/"+b.ID.Hex()+"/cover?v=1700000000", URL(b, false))
+	assert.Equal(t, "/api/v1/books/"+b.ID.Hex()+"/cover?v=1700000000&size=thumb", URL(b, true))
+}
+
+// pngCover makes a cover with a red top half and a blue bottom half
+func pngCover(t *testing.T, w, h int) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if y < h/2 {
+				img.Set(x, y, color.RGBA{200, 0, 0, 255})
+			} else {
+				img.Set(x, y, color.RGBA{0, 0, 200, 255})
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
@@ -0,0 +1,249 @@
+package cover
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/sikozonpc/notebase/auth"
+	"github.com/sikozonpc/notebase/storage"
+	t "github.com/sikozonpc/notebase/types"
+	u "github.com/sikozonpc/notebase/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Enricher finds the metadata of a book, which has the URL of its cover
+type Enricher interface {
+	Enrich(ctx context.Context, b *t.Book) (*t.BookMetadata, error)
+}
+
+type Handler struct {
+	covers       *Covers
+	store        t.BookStore
+	libraryStore t.LibraryStore
+	enricher     Enricher
+	userStore    t.UserStore
+}
+
+// NewHandler creates the cover handler. The enricher can be nil when no
+// metadata provider is configured, then only covers already known are
+// fetched.
+func NewHandler(covers *Covers, store t.BookStore, libraryStore t.LibraryStore, enricher Enricher, userStore t.UserStore) *Handler {
+	return &Handler{
+		covers:       covers,
+		store:        store,
+		libraryStore: libraryStore,
+		enricher:     enricher,
+		userStore:    userStore,
+	}
+}
+
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	// Public so emails and img tags can load it
+	router.HandleFunc(
+		"/books/{id}/cover",
+		u.MakeHTTPHandler(h.handleGetCover),
+	).Methods("GET")
+
+	router.HandleFunc(
+		"/user/{userID}/book/{id}/cover",
+		auth.WithJWTAuth(u.MakeHTTPHandler(h.handleUploadCover), h.userStore),
+	).Methods("PUT")
+
+	router.HandleFunc(
+		"/user/{userID}/book/{id}/cover/fetch",
+		auth.WithJWTAuth(u.MakeHTTPHandler(h.handleFetchCover), h.userStore),
+	).Methods("POST")
+}
+
+// handleGetCover serves the cover, or its thumbnail with ?size=thumb.
+// Versioned URLs never change and are cached for good.
+func (h *Handler) handleGetCover(w http.ResponseWriter, r *http.Request) error {
+	id, err := u.GetStringParamFromRequest(r, "id")
+	if err != nil {
+		return err
+	}
+	oID, _ := primitive.ObjectIDFromHex(id)
+
+	book, err := h.store.GetByID(r.Context(), oID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return notFound(w, oID)
+	}
+	if err != nil {
+		return err
+	}
+
+	if book.CoverAt == nil {
+		return u.WriteJSON(w, http.StatusNotFound, t.APIError{Error: fmt.Errorf("book with id %v has no cover", oID.Hex()).Error()})
+	}
+
+	thumb := r.URL.Query().Get("size") == "thumb"
+
+	etag := fmt.Sprintf(`"%s-%d"`, oID.Hex(), book.CoverAt.Unix())
+	if thumb {
+		etag = fmt.Sprintf(`"%s-%d-thumb"`, oID.Hex(), book.CoverAt.Unix())
+	}
+
+	if r.URL.Query().Get("v") != "" {
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	} else {
+		w.Header().Set("Cache-Control", "public, max-age=86400")
+	}
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Last-Modified", book.CoverAt.UTC().Format(http.TimeFormat))
+
+	if match := r.Header.Get("If-None-Match"); match != "" && strings.Contains(match, etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+
+	data, err := h.covers.Read(oID, thumb)
+	if errors.Is(err, storage.ErrNotFound) {
+		return u.WriteJSON(w, http.StatusNotFound, t.APIError{Error: fmt.Errorf("book with id %v has no cover", oID.Hex()).Error()})
+	}
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "image/jpeg")
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(data)
+
+	return err
+}
+
+// handleUploadCover sets the cover to the image in the "file" form field.
+// Books are shared, so a user can give a book its first cover but never
+// replace the one every other user sees.
+func (h *Handler) handleUploadCover(w http.ResponseWriter, r *http.Request) error {
+	oUserID, oID, err := getIDsFromRequest(r)
+	if err != nil {
+		return err
+	}
+
+	book, err := h.getUserBook(r.Context(), oUserID, oID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return notFound(w, oID)
+	}
+	if err != nil {
+		return err
+	}
+
+	if book.CoverAt != nil {
+		return u.WriteJSON(w, http.StatusConflict, t.APIError{Error: fmt.Errorf("book with id %v already has a cover", oID.Hex()).Error()})
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, MaxUploadBytes+1<<20)
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		return u.WriteJSON(w, http.StatusBadRequest, t.APIError{Error: err.Error()})
+	}
+	defer file.Close()
+
+	err = h.covers.Save(r.Context(), oID, file)
+	if errors.Is(err, ErrInvalidImage) {
+		return u.WriteJSON(w, http.StatusBadRequest, t.APIError{Error: err.Error()})
+	}
+	if err != nil {
+		return err
+	}
+
+	return h.writeBook(w, r.Context(), oID)
+}
+
+// handleFetchCover downloads the cover the metadata providers know of,
+// looking the book up first if it wasn't yet. A book that already has a
+// cover keeps it, like with an upload.
+func (h *Handler) handleFetchCover(w http.ResponseWriter, r *http.Request) error {
+	oUserID, oID, err := getIDsFromRequest(r)
+	if err != nil {
+		return err
+	}
+
+	book, err := h.getUserBook(r.Context(), oUserID, oID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return notFound(w, oID)
+	}
+	if err != nil {
+		return err
+	}
+
+	if book.CoverAt != nil {
+		return h.writeBook(w, r.Context(), oID)
+	}
+
+	md := book.Metadata
+	if (md == nil || md.CoverURL == "") && h.enricher != nil {
+		md, err = h.enricher.Enrich(r.Context(), book)
+		if err != nil {
+			return err
+		}
+
+		// Enriching a book without a cover already fetches it
+		if enriched, err := h.store.GetByID(r.Context(), oID); err == nil && enriched.CoverAt != nil {
+			return h.writeBook(w, r.Context(), oID)
+		}
+	}
+
+	if md == nil || md.CoverURL == "" {
+		return u.WriteJSON(w, http.StatusNotFound, t.APIError{Error: fmt.Errorf("no cover found for book with id %v", oID.Hex()).Error()})
+	}
+
+	if err := h.covers.Fetch(r.Context(), oID, md.CoverURL); err != nil {
+		return u.WriteJSON(w, http.StatusBadGateway, t.APIError{Error: err.Error()})
+	}
+
+	return h.writeBook(w, r.Context(), oID)
+}
+
+// getUserBook returns the book if it's in the user's library, or
+// mongo.ErrNoDocuments
+func (h *Handler) getUserBook(ctx context.Context, userID, id primitive.ObjectID) (*t.Book, error) {
+	if _, err := h.libraryStore.GetLibraryBook(ctx, userID, id); err != nil {
+		return nil, err
+	}
+
+	return h.store.GetByID(ctx, id)
+}
+
+// writeBook responds with the URLs of the book's new cover
+func (h *Handler) writeBook(w http.ResponseWriter, ctx context.Context, id primitive.ObjectID) error {
+	book, err := h.store.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	return u.WriteJSON(w, http.StatusOK, CoverResponse{
+		CoverURL: URL(book, false),
+		ThumbURL: URL(book, true),
+	})
+}
+
+func getIDsFromRequest(r *http.Request) (primitive.ObjectID, primitive.ObjectID, error) {
+	userID, err := u.GetStringParamFromRequest(r, "userID")
+	if err != nil {
+		return primitive.NilObjectID, primitive.NilObjectID, err
+	}
+	oUserID, _ := primitive.ObjectIDFromHex(userID)
+
+	id, err := u.GetStringParamFromRequest(r, "id")
+	if err != nil {
+		return primitive.NilObjectID, primitive.NilObjectID, err
+	}
+	oID, _ := primitive.ObjectIDFromHex(id)
+
+	return oUserID, oID, nil
+}
+
+func notFound(w http.ResponseWriter, id primitive.ObjectID) error {
+	return u.WriteJSON(w, http.StatusNotFound, t.APIError{Error: fmt.Errorf("book with id %v not found", id.Hex()).Error()})
+}
+
+type CoverResponse struct {
+	CoverURL string `json:"coverUrl"`
+	ThumbURL string `json:"thumbUrl"`
+}
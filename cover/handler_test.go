@@ -0,0 +1,224 @@
+package cover
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/sikozonpc/notebase/storage"
+	types "github.com/sikozonpc/notebase/types"
+	u "github.com/sikozonpc/notebase/utils"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestCoverHandler(t *testing.T) {
+	userID := primitive.NewObjectID()
+	book := &types.Book{ID: primitive.NewObjectID(), Title: "Deep Work"}
+	other := &types.Book{ID: primitive.NewObjectID(), Title: "Not in the library"}
+
+	remote := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(pngCover(t, 300, 450))
+	}))
+	defer remote.Close()
+
+	books := &mockBookStore{books: []*types.Book{book, other}}
+	libraryStore := &mockLibraryStore{entries: []*types.LibraryBook{{UserID: userID, BookID: book.ID}}}
+	enricher := &mockEnricher{coverURL: remote.URL + "/cover.png"}
+	handler := NewHandler(NewCovers(storage.NewMemoryStorage(), books), books, libraryStore, enricher, &mockUserStore{})
+
+	serve := func(t *testing.T, req *http.Request) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		router := mux.NewRouter()
+
+		router.HandleFunc("/books/{id}/cover", u.MakeHTTPHandler(handler.handleGetCover)).Methods(http.MethodGet)
+		router.HandleFunc("/user/{userID}/book/{id}/cover", u.MakeHTTPHandler(handler.handleUploadCover)).Methods(http.MethodPut)
+		router.HandleFunc("/user/{userID}/book/{id}/cover/fetch", u.MakeHTTPHandler(handler.handleFetchCover)).Methods(http.MethodPost)
+
+		router.ServeHTTP(rr, req)
+
+		return rr
+	}
+
+	upload := func(t *testing.T, id primitive.ObjectID, data []byte) *httptest.ResponseRecorder {
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		fw, err := mw.CreateFormFile("file", "cover.png")
+		if err != nil {
+			t.Fatal(err)
+		}
+		fw.Write(data)
+		mw.Close()
+
+		req, err := http.NewRequest(http.MethodPut, "/user/"+userID.Hex()+"/book/"+id.Hex()+"/cover", &body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+
+		return serve(t, req)
+	}
+
+	t.Run("should not serve a cover that doesn't exist", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, "/books/"+book.ID.Hex()+"/cover", nil)
+
+		rr := serve(t, req)
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("expected status code %d, got %d", http.StatusNotFound, rr.Code)
+		}
+	})
+
+	t.Run("should not serve a cover missing from storage", func(t *testing.T) {
+		at := time.Now()
+		other.CoverAt = &at
+		defer func() { other.CoverAt = nil }()
+
+		req, _ := http.NewRequest(http.MethodGet, "/books/"+other.ID.Hex()+"/cover", nil)
+
+		rr := serve(t, req)
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("expected status code %d, got %d", http.StatusNotFound, rr.Code)
+		}
+	})
+
+	t.Run("should upload and serve a cover", func(t *testing.T) {
+		rr := upload(t, book.ID, pngCover(t, 300, 450))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body)
+		}
+
+		var res CoverResponse
+		if err := json.NewDecoder(rr.Body).Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+		assert.Contains(t, res.ThumbURL, "size=thumb")
+
+		req, _ := http.NewRequest(http.MethodGet, "/books/"+book.ID.Hex()+"/cover?size=thumb", nil)
+		rr = serve(t, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		assert.Equal(t, "image/jpeg", rr.Header().Get("Content-Type"))
+		assert.Equal(t, "public, max-age=86400", rr.Header().Get("Cache-Control"))
+		assert.NotEmpty(t, rr.Body.Bytes())
+
+		// The browser already has it
+		req, _ = http.NewRequest(http.MethodGet, "/books/"+book.ID.Hex()+"/cover?size=thumb", nil)
+		req.Header.Set("If-None-Match", rr.Header().Get("ETag"))
+		rr = serve(t, req)
+		if rr.Code != http.StatusNotModified {
+			t.Errorf("expected status code %d, got %d", http.StatusNotModified, rr.Code)
+		}
+	})
+
+	t.Run("should not replace the cover other users see", func(t *testing.T) {
+		coverAt := book.CoverAt
+
+		rr := upload(t, book.ID, pngCover(t, 30, 45))
+		if rr.Code != http.StatusConflict {
+			t.Errorf("expected status code %d, got %d", http.StatusConflict, rr.Code)
+		}
+
+		req, _ := http.NewRequest(http.MethodPost, "/user/"+userID.Hex()+"/book/"+book.ID.Hex()+"/cover/fetch", nil)
+		rr = serve(t, req)
+		if rr.Code != http.StatusOK {
+			t.Errorf("expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		assert.False(t, enricher.called)
+		assert.Equal(t, coverAt, book.CoverAt)
+	})
+
+	t.Run("should fail to upload something that isn't an image", func(t *testing.T) {
+		book.CoverAt = nil
+
+		rr := upload(t, book.ID, []byte("not an image"))
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+
+	t.Run("should not upload a cover of a book that isn't in the user's library", func(t *testing.T) {
+		rr := upload(t, other.ID, pngCover(t, 30, 45))
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("expected status code %d, got %d", http.StatusNotFound, rr.Code)
+		}
+	})
+
+	t.Run("should fetch the cover the metadata providers know of", func(t *testing.T) {
+		book.CoverAt = nil
+
+		req, _ := http.NewRequest(http.MethodPost, "/user/"+userID.Hex()+"/book/"+book.ID.Hex()+"/cover/fetch", nil)
+		rr := serve(t, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body)
+		}
+
+		assert.True(t, enricher.called)
+		assert.NotNil(t, book.CoverAt)
+	})
+}
+
+type mockBookStore struct {
+	types.BookStore
+	books []*types.Book
+}
+
+func (m *mockBookStore) GetByID(_ context.Context, id primitive.ObjectID) (*types.Book, error) {
+	for _, b := range m.books {
+		if b.ID == id {
+			return b, nil
+		}
+	}
+
+	return nil, mongo.ErrNoDocuments
+}
+
+func (m *mockBookStore) SetCover(ctx context.Context, id primitive.ObjectID, at time.Time) error {
+	b, err := m.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	b.CoverAt = &at
+
+	return nil
+}
+
+type mockLibraryStore struct {
+	types.LibraryStore
+	entries []*types.LibraryBook
+}
+
+func (m *mockLibraryStore) GetLibraryBook(_ context.Context, userID primitive.ObjectID, bookID primitive.ObjectID) (*types.LibraryBook, error) {
+	for _, e := range m.entries {
+		if e.UserID == userID && e.BookID == bookID {
+			return e, nil
+		}
+	}
+
+	return nil, mongo.ErrNoDocuments
+}
+
+type mockEnricher struct {
+	coverURL string
+	called   bool
+}
+
+func (m *mockEnricher) Enrich(context.Context, *types.Book) (*types.BookMetadata, error) {
+	m.called = true
+
+	return &types.BookMetadata{CoverURL: m.coverURL}, nil
+}
+
+type mockUserStore struct {
+	types.UserStore
+}
@@ -0,0 +1,112 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const MigrationsCollName = "migrations"
+
+// Migration is a single versioned change to the database, either to its
+// schema (indexes) or to the data already stored in it.
+type Migration struct {
+	Version     int
+	Description string
+	Up          func(ctx context.Context, db *mongo.Database) error
+}
+
+// migrationRecord is what gets stored in the migrations collection once a
+// migration has been applied.
+type migrationRecord struct {
+	Version     int       `bson:"_id"`
+	Description string    `bson:"description"`
+	AppliedAt   time.Time `bson:"appliedAt"`
+}
+
+// Migrate applies, in version order, every migration that has not been
+// recorded in the migrations collection yet.
+func Migrate(ctx context.Context, db *mongo.Database, migrations []Migration) error {
+	col := db.Collection(MigrationsCollName)
+
+	applied, err := appliedVersions(ctx, col)
+	if err != nil {
+		return err
+	}
+
+	pending, err := pendingMigrations(migrations, applied)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range pending {
+		log.Printf("Applying migration %d: %s", m.Version, m.Description)
+
+		if err := m.Up(ctx, db); err != nil {
+			return fmt.Errorf("migration %d (%s) failed: %w", m.Version, m.Description, err)
+		}
+
+		_, err := col.InsertOne(ctx, migrationRecord{
+			Version:     m.Version,
+			Description: m.Description,
+			AppliedAt:   time.Now().UTC(),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func appliedVersions(ctx context.Context, col *mongo.Collection) (map[int]bool, error) {
+	cursor, err := col.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+
+	var records []migrationRecord
+	if err = cursor.All(ctx, &records); err != nil {
+		return nil, err
+	}
+
+	applied := make(map[int]bool, len(records))
+	for _, r := range records {
+		applied[r.Version] = true
+	}
+
+	return applied, nil
+}
+
+// pendingMigrations returns the migrations that have not been applied yet,
+// sorted by version. Versions must be positive and unique.
+func pendingMigrations(migrations []Migration, applied map[int]bool) ([]Migration, error) {
+	seen := make(map[int]bool, len(migrations))
+	pending := make([]Migration, 0, len(migrations))
+
+	for _, m := range migrations {
+		if m.Version <= 0 {
+			return nil, fmt.Errorf("migration %q has invalid version %d", m.Description, m.Version)
+		}
+
+		if seen[m.Version] {
+			return nil, fmt.Errorf("duplicate migration version %d", m.Version)
+		}
+		seen[m.Version] = true
+
+		if !applied[m.Version] {
+			pending = append(pending, m)
+		}
+	}
+
+	sort.Slice(pending, func(i, j int) bool {
+		return pending[i].Version < pending[j].Version
+	})
+
+	return pending, nil
+}
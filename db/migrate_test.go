@@ -0,0 +1,46 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPendingMigrations(t *testing.T) {
+	t.Run("should return unapplied migrations sorted by version", func(t *testing.T) {
+		ms := []Migration{
+			{Version: 3, Description: "third"},
+			{Version: 1, Description: "first"},
+			{Version: 2, Description: "second"},
+		}
+
+		pending, err := pendingMigrations(ms, map[int]bool{2: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Len(t, pending, 2)
+		assert.Equal(t, 1, pending[0].Version)
+		assert.Equal(t, 3, pending[1].Version)
+	})
+
+	t.Run("should fail on duplicate versions", func(t *testing.T) {
+		ms := []Migration{
+			{Version: 1, Description: "first"},
+			{Version: 1, Description: "also first"},
+		}
+
+		_, err := pendingMigrations(ms, map[int]bool{})
+		assert.Error(t, err)
+	})
+
+	t.Run("should fail on non positive versions", func(t *testing.T) {
+		_, err := pendingMigrations([]Migration{{Version: 0}}, map[int]bool{})
+		assert.Error(t, err)
+	})
+
+	t.Run("should have valid and unique versions for the registered migrations", func(t *testing.T) {
+		_, err := pendingMigrations(Migrations, map[int]bool{})
+		assert.NoError(t, err)
+	})
+}
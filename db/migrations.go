@@ -0,0 +1,526 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/sikozonpc/notebase/author"
+	"github.com/sikozonpc/notebase/book"
+	"github.com/sikozonpc/notebase/categorize"
+	"github.com/sikozonpc/notebase/collection"
+	"github.com/sikozonpc/notebase/highlight"
+	"github.com/sikozonpc/notebase/library"
+	"github.com/sikozonpc/notebase/link"
+	"github.com/sikozonpc/notebase/note"
+	"github.com/sikozonpc/notebase/reading"
+	t "github.com/sikozonpc/notebase/types"
+	"github.com/sikozonpc/notebase/user"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Migrations is the ordered list of every migration the application knows
+// about. Never edit or reorder an entry that has already shipped, add a new
+// one with the next version instead.
+var Migrations = []Migration{
+	{
+		Version:     1,
+		Description: "unique index on users.email",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			return createIndex(ctx, db.Collection(user.CollName), bson.D{{Key: "email", Value: 1}}, true)
+		},
+	},
+	{
+		Version:     2,
+		Description: "unique index on books.isbn",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			// Books imported twice would fail the index. Not recorded until
+			// it succeeds, so databases that have them still run this.
+			if err := dedupeBooks(ctx, db); err != nil {
+				return err
+			}
+
+			return createISBNIndex(ctx, db.Collection(book.CollName))
+		},
+	},
+	{
+		Version:     3,
+		Description: "index on highlights.userId",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			return createIndex(ctx, db.Collection(highlight.CollName), bson.D{{Key: "userId", Value: 1}}, false)
+		},
+	},
+	{
+		Version:     4,
+		Description: "backfill missing createdAt from the document _id",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			for _, name := range []string{user.CollName, book.CollName, highlight.CollName} {
+				if err := backfillCreatedAt(ctx, db.Collection(name)); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		},
+	},
+	{
+		Version:     5,
+		Description: "unique index on highlight_revisions.highlightId and version",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			return createIndex(ctx, db.Collection(highlight.RevisionsCollName), bson.D{{Key: "highlightId", Value: 1}, {Key: "version", Value: 1}}, true)
+		},
+	},
+	{
+		Version:     6,
+		Description: "index on highlights.userId and tags",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			return createIndex(ctx, db.Collection(highlight.CollName), bson.D{{Key: "userId", Value: 1}, {Key: "tags", Value: 1}}, false)
+		},
+	},
+	{
+		Version:     7,
+		Description: "index on collections.userId",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			return createIndex(ctx, db.Collection(collection.CollName), bson.D{{Key: "userId", Value: 1}}, false)
+		},
+	},
+	{
+		Version:     8,
+		Description: "backfill highlights updatedAt and position",
+		Up:          backfillHighlightPositions,
+	},
+	{
+		Version:     9,
+		Description: "indexes for sorting highlight listings",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			col := db.Collection(highlight.CollName)
+
+			_, err := col.Indexes().CreateMany(ctx, []mongo.IndexModel{
+				{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "createdAt", Value: 1}, {Key: "_id", Value: 1}}},
+				{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "updatedAt", Value: 1}, {Key: "_id", Value: 1}}},
+				{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "position", Value: 1}, {Key: "_id", Value: 1}}},
+				{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "bookId", Value: 1}, {Key: "position", Value: 1}, {Key: "_id", Value: 1}}},
+			})
+
+			return err
+		},
+	},
+	{
+		Version:     10,
+		Description: "text indexes on highlights and books for search",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			_, err := db.Collection(highlight.CollName).Indexes().CreateOne(ctx, mongo.IndexModel{
+				Keys:    bson.D{{Key: "text", Value: "text"}, {Key: "note", Value: "text"}, {Key: "tags", Value: "text"}},
+				Options: options.Index().SetWeights(bson.M{"text": 3, "note": 2, "tags": 1}).SetName("highlights_text"),
+			})
+			if err != nil {
+				return err
+			}
+
+			_, err = db.Collection(book.CollName).Indexes().CreateOne(ctx, mongo.IndexModel{
+				Keys:    bson.D{{Key: "title", Value: "text"}, {Key: "authors", Value: "text"}},
+				Options: options.Index().SetWeights(bson.M{"title": 3, "authors": 2}).SetName("books_text"),
+			})
+
+			return err
+		},
+	},
+	{
+		Version:     11,
+		Description: "index on topics.userId",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			return createIndex(ctx, db.Collection(categorize.CollName), bson.D{{Key: "userId", Value: 1}}, false)
+		},
+	},
+	{
+		Version:     12,
+		Description: "user libraries from existing highlights",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			err := createIndex(ctx, db.Collection(library.CollName), bson.D{{Key: "userId", Value: 1}, {Key: "bookId", Value: 1}}, true)
+			if err != nil {
+				return err
+			}
+
+			return backfillLibraries(ctx, db)
+		},
+	},
+	{
+		Version:     13,
+		Description: "authors parsed from the books' authors",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			if err := createIndex(ctx, db.Collection(author.CollName), bson.D{{Key: "keys", Value: 1}}, true); err != nil {
+				return err
+			}
+
+			if err := createIndex(ctx, db.Collection(book.CollName), bson.D{{Key: "authorIds", Value: 1}}, false); err != nil {
+				return err
+			}
+
+			return backfillAuthors(ctx, db)
+		},
+	},
+	{
+		Version:     14,
+		Description: "index on books.identifiers",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			return createIndex(ctx, db.Collection(book.CollName), bson.D{{Key: "identifiers", Value: 1}}, false)
+		},
+	},
+	{
+		Version:     15,
+		Description: "indexes on reading_sessions",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			col := db.Collection(reading.CollName)
+			if err := createIndex(ctx, col, bson.D{{Key: "userId", Value: 1}, {Key: "startedAt", Value: -1}}, false); err != nil {
+				return err
+			}
+
+			return createIndex(ctx, col, bson.D{{Key: "bookId", Value: 1}}, false)
+		},
+	},
+	{
+		Version:     16,
+		Description: "indexes on notes",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			col := db.Collection(note.CollName)
+			if err := createIndex(ctx, col, bson.D{{Key: "userId", Value: 1}, {Key: "updatedAt", Value: -1}}, false); err != nil {
+				return err
+			}
+
+			return createIndex(ctx, col, bson.D{{Key: "bookId", Value: 1}}, false)
+		},
+	},
+	{
+		Version:     17,
+		Description: "wiki-links from existing highlights and notes",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			col := db.Collection(link.CollName)
+			if err := createIndex(ctx, col, bson.D{{Key: "userId", Value: 1}, {Key: "target", Value: 1}}, false); err != nil {
+				return err
+			}
+
+			if err := createIndex(ctx, col, bson.D{{Key: "userId", Value: 1}, {Key: "sourceType", Value: 1}, {Key: "sourceId", Value: 1}}, false); err != nil {
+				return err
+			}
+
+			return backfillLinks(ctx, db)
+		},
+	},
+	{
+		Version:     18,
+		Description: "backfill highlights revision counters",
+		Up:          backfillRevisionCounters,
+	},
+	{
+		Version:     19,
+		Description: "unique index on books.isbn only for books that have one",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			col := db.Collection(book.CollName)
+
+			// Built by migration 2 on every book, before books without an
+			// ISBN were left out of it
+			_, err := col.Indexes().DropOne(ctx, "isbn_1")
+			var cmdErr mongo.CommandError
+			if err != nil && !(errors.As(err, &cmdErr) && cmdErr.Code == indexNotFoundCode) {
+				return err
+			}
+
+			return createISBNIndex(ctx, col)
+		},
+	},
+}
+
+// indexNotFoundCode is returned by Mongo when dropping an index that
+// doesn't exist
+const indexNotFoundCode = 27
+
+func createIndex(ctx context.Context, col *mongo.Collection, keys bson.D, unique bool) error {
+	_, err := col.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    keys,
+		Options: options.Index().SetUnique(unique),
+	})
+
+	return err
+}
+
+// createISBNIndex makes ISBNs unique among the books that have one. Books
+// without one are unrelated, however many there are.
+func createISBNIndex(ctx context.Context, col *mongo.Collection) error {
+	_, err := col.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "isbn", Value: 1}},
+		Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{
+			"isbn": bson.M{"$gt": ""},
+		}),
+	})
+
+	return err
+}
+
+// dedupeBooks keeps the first book imported with each ISBN and deletes the
+// others. Highlights point to books by ISBN, so they move to the kept one.
+// Books without an ISBN are left alone.
+func dedupeBooks(ctx context.Context, db *mongo.Database) error {
+	col := db.Collection(book.CollName)
+
+	cursor, err := col.Aggregate(ctx, mongo.Pipeline{
+		bson.D{{Key: "$match", Value: bson.M{"isbn": bson.M{"$nin": bson.A{"", nil}}}}},
+		bson.D{{Key: "$sort", Value: bson.M{"_id": 1}}},
+		bson.D{{Key: "$group", Value: bson.M{
+			"_id":   "$isbn",
+			"ids":   bson.M{"$push": "$_id"},
+			"count": bson.M{"$sum": 1},
+		}}},
+		bson.D{{Key: "$match", Value: bson.M{"count": bson.M{"$gt": 1}}}},
+	})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var dup struct {
+			IDs []primitive.ObjectID `bson:"ids"`
+		}
+		if err := cursor.Decode(&dup); err != nil {
+			return err
+		}
+
+		if _, err := col.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": dup.IDs[1:]}}); err != nil {
+			return err
+		}
+	}
+
+	return cursor.Err()
+}
+
+// backfillCreatedAt sets createdAt on documents that were inserted without
+// one, using the creation time embedded in their ObjectID.
+func backfillCreatedAt(ctx context.Context, col *mongo.Collection) error {
+	_, err := col.UpdateMany(ctx, bson.M{
+		"createdAt": bson.M{"$exists": false},
+	}, mongo.Pipeline{
+		bson.D{
+			{Key: "$set", Value: bson.M{
+				"createdAt": bson.M{"$toDate": "$_id"},
+			}},
+		},
+	})
+
+	return err
+}
+
+// backfillHighlightPositions fills in the fields keyset pagination sorts on,
+// since documents missing them would never match a cursor
+func backfillHighlightPositions(ctx context.Context, db *mongo.Database) error {
+	col := db.Collection(highlight.CollName)
+
+	_, err := col.UpdateMany(ctx, bson.M{
+		"updatedAt": bson.M{"$exists": false},
+	}, mongo.Pipeline{
+		bson.D{
+			{Key: "$set", Value: bson.M{
+				"updatedAt": "$createdAt",
+			}},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	cursor, err := col.Find(ctx, bson.M{
+		"position": bson.M{"$exists": false},
+	})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var h struct {
+			ID       primitive.ObjectID `bson:"_id"`
+			Location string             `bson:"location"`
+		}
+		if err := cursor.Decode(&h); err != nil {
+			return err
+		}
+
+		_, err := col.UpdateByID(ctx, h.ID, bson.M{
+			"$set": bson.M{"position": highlight.ParsePosition(h.Location)},
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return cursor.Err()
+}
+
+// backfillRevisionCounters counts the edits already recorded for each
+// highlight, so new ones carry on from their latest version
+func backfillRevisionCounters(ctx context.Context, db *mongo.Database) error {
+	cursor, err := db.Collection(highlight.RevisionsCollName).Aggregate(ctx, mongo.Pipeline{
+		bson.D{{Key: "$group", Value: bson.M{
+			"_id":     "$highlightId",
+			"version": bson.M{"$max": "$version"},
+		}}},
+	})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	col := db.Collection(highlight.CollName)
+	for cursor.Next(ctx) {
+		var r struct {
+			HighlightID primitive.ObjectID `bson:"_id"`
+			Version     int                `bson:"version"`
+		}
+		if err := cursor.Decode(&r); err != nil {
+			return err
+		}
+
+		_, err := col.UpdateByID(ctx, r.HighlightID, bson.M{
+			"$max": bson.M{"revision": r.Version - 1},
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return cursor.Err()
+}
+
+// backfillLibraries adds every book a user has highlighted to their library,
+// as of their first highlight of it
+func backfillLibraries(ctx context.Context, db *mongo.Database) error {
+	cursor, err := db.Collection(highlight.CollName).Aggregate(ctx, mongo.Pipeline{
+		bson.D{{Key: "$group", Value: bson.M{
+			"_id":       bson.M{"userId": "$userId", "isbn": "$bookId"},
+			"createdAt": bson.M{"$min": "$createdAt"},
+		}}},
+		bson.D{{Key: "$lookup", Value: bson.M{
+			"from":         book.CollName,
+			"localField":   "_id.isbn",
+			"foreignField": "isbn",
+			"as":           "book",
+		}}},
+		bson.D{{Key: "$unwind", Value: "$book"}},
+	})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	col := db.Collection(library.CollName)
+	for cursor.Next(ctx) {
+		var entry struct {
+			ID struct {
+				UserID primitive.ObjectID `bson:"userId"`
+			} `bson:"_id"`
+			CreatedAt time.Time `bson:"createdAt"`
+			Book      struct {
+				ID primitive.ObjectID `bson:"_id"`
+			} `bson:"book"`
+		}
+		if err := cursor.Decode(&entry); err != nil {
+			return err
+		}
+
+		_, err := col.UpdateOne(ctx, bson.M{
+			"userId": entry.ID.UserID,
+			"bookId": entry.Book.ID,
+		}, bson.M{
+			"$setOnInsert": bson.M{
+				"_id":       primitive.NewObjectID(),
+				"createdAt": entry.CreatedAt,
+				"updatedAt": entry.CreatedAt,
+			},
+		}, options.Update().SetUpsert(true))
+		if err != nil {
+			return err
+		}
+	}
+
+	return cursor.Err()
+}
+
+// backfillAuthors links every book to the authors parsed from its authors
+// string
+func backfillAuthors(ctx context.Context, db *mongo.Database) error {
+	authors := author.NewStore(db)
+	books := book.NewStore(db)
+
+	cursor, err := db.Collection(book.CollName).Find(ctx, bson.M{
+		"authorIds": bson.M{"$exists": false},
+	})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		b := new(t.Book)
+		if err := cursor.Decode(b); err != nil {
+			return err
+		}
+
+		if err := author.LinkBook(ctx, authors, books, b); err != nil {
+			return err
+		}
+	}
+
+	return cursor.Err()
+}
+
+// backfillLinks parses the wiki-links already written in highlights' notes
+// and in standalone notes
+func backfillLinks(ctx context.Context, db *mongo.Database) error {
+	links := link.NewStore(db)
+
+	cursor, err := db.Collection(highlight.CollName).Find(ctx, bson.M{
+		"note":      bson.M{"$regex": `\[\[`},
+		"deletedAt": nil,
+	})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		h := new(t.Highlight)
+		if err := cursor.Decode(h); err != nil {
+			return err
+		}
+
+		if err := links.SetLinks(ctx, h.UserID, t.LinkNodeHighlight, h.ID, link.Parse(h.Note)); err != nil {
+			return err
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return err
+	}
+
+	cursor, err = db.Collection(note.CollName).Find(ctx, bson.M{
+		"body": bson.M{"$regex": `\[\[`},
+	})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		n := new(t.Note)
+		if err := cursor.Decode(n); err != nil {
+			return err
+		}
+
+		if err := links.SetLinks(ctx, n.UserID, t.LinkNodeNote, n.ID, link.Parse(n.Body)); err != nil {
+			return err
+		}
+	}
+
+	return cursor.Err()
+}
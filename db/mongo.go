@@ -2,21 +2,110 @@ package db
 
 import (
 	"context"
-	"time"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strconv"
 
+	t "github.com/sikozonpc/notebase/types"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readconcern"
 	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
 )
 
-func ConnectToMongo(uri string) (*mongo.Client, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+func ConnectToMongo(cfg t.Config) (*mongo.Client, error) {
+	opts, err := clientOptions(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.MongoConnectTimeout)
 	defer cancel()
-	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	client, err := mongo.Connect(ctx, opts)
 	if err != nil {
 		return nil, err
 	}
 
 	err = client.Ping(ctx, readpref.Primary())
 	return client, err
-}
\ No newline at end of file
+}
+
+func clientOptions(cfg t.Config) (*options.ClientOptions, error) {
+	opts := options.Client().
+		ApplyURI(cfg.MongoURI).
+		SetMaxPoolSize(cfg.MongoMaxPoolSize).
+		SetMinPoolSize(cfg.MongoMinPoolSize).
+		SetConnectTimeout(cfg.MongoConnectTimeout).
+		SetServerSelectionTimeout(cfg.MongoServerSelectionTimeout)
+
+	if cfg.MongoSocketTimeout > 0 {
+		opts.SetSocketTimeout(cfg.MongoSocketTimeout)
+	}
+
+	if cfg.MongoReadConcern != "" {
+		opts.SetReadConcern(readconcern.New(readconcern.Level(cfg.MongoReadConcern)))
+	}
+
+	if cfg.MongoWriteConcern != "" {
+		opts.SetWriteConcern(parseWriteConcern(cfg.MongoWriteConcern))
+	}
+
+	if cfg.MongoTLS {
+		tlsConfig, err := tlsConfig(cfg)
+		if err != nil {
+			return nil, err
+		}
+
+		opts.SetTLSConfig(tlsConfig)
+	}
+
+	return opts, nil
+}
+
+// parseWriteConcern accepts "majority", a number of nodes or the name of a
+// custom tag set configured on the replica set.
+func parseWriteConcern(w string) *writeconcern.WriteConcern {
+	if w == "majority" {
+		return writeconcern.Majority()
+	}
+
+	if n, err := strconv.Atoi(w); err == nil {
+		return &writeconcern.WriteConcern{W: n}
+	}
+
+	return writeconcern.Custom(w)
+}
+
+func tlsConfig(cfg t.Config) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: cfg.MongoTLSInsecure,
+	}
+
+	if cfg.MongoTLSCAFile != "" {
+		ca, err := os.ReadFile(cfg.MongoTLSCAFile)
+		if err != nil {
+			return nil, err
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificates found in %s", cfg.MongoTLSCAFile)
+		}
+
+		tlsConfig.RootCAs = pool
+	}
+
+	if cfg.MongoTLSCertKeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.MongoTLSCertKeyFile, cfg.MongoTLSCertKeyFile)
+		if err != nil {
+			return nil, err
+		}
+
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
@@ -0,0 +1,50 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sikozonpc/notebase/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientOptions(t *testing.T) {
+	t.Run("should apply pool sizes, timeouts and concerns from the config", func(t *testing.T) {
+		opts, err := clientOptions(types.Config{
+			MongoURI:                    "mongodb://localhost:27017",
+			MongoMaxPoolSize:            50,
+			MongoMinPoolSize:            5,
+			MongoConnectTimeout:         5 * time.Second,
+			MongoServerSelectionTimeout: 10 * time.Second,
+			MongoReadConcern:            "majority",
+			MongoWriteConcern:           "2",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, uint64(50), *opts.MaxPoolSize)
+		assert.Equal(t, uint64(5), *opts.MinPoolSize)
+		assert.Equal(t, 5*time.Second, *opts.ConnectTimeout)
+		assert.Equal(t, 10*time.Second, *opts.ServerSelectionTimeout)
+		assert.Equal(t, "majority", opts.ReadConcern.Level)
+		assert.Equal(t, 2, opts.WriteConcern.W)
+		assert.Nil(t, opts.TLSConfig)
+	})
+
+	t.Run("should fail when the TLS CA file does not exist", func(t *testing.T) {
+		_, err := clientOptions(types.Config{
+			MongoURI:       "mongodb://localhost:27017",
+			MongoTLS:       true,
+			MongoTLSCAFile: "does-not-exist.pem",
+		})
+
+		assert.Error(t, err)
+	})
+}
+
+func TestParseWriteConcern(t *testing.T) {
+	assert.Equal(t, "majority", parseWriteConcern("majority").W)
+	assert.Equal(t, 1, parseWriteConcern("1").W)
+	assert.Equal(t, "dc-east", parseWriteConcern("dc-east").W)
+}
@@ -0,0 +1,252 @@
+package dedupe
+
+import (
+	"hash/fnv"
+	"sort"
+	"strings"
+
+	"github.com/sikozonpc/notebase/analysis"
+	t "github.com/sikozonpc/notebase/types"
+)
+
+const (
+	// Highlights at least this similar are considered duplicates by default
+	DefaultThreshold = 0.7
+
+	// Shingles are runs of this many characters of the normalized text, so
+	// a changed word or punctuation only affects a few of them
+	shingleSize = 5
+
+	// The signature is split into bands of rows, highlights sharing a band
+	// are compared. 32 bands of 4 rows find most pairs above a similarity of
+	// about 0.5.
+	bands        = 32
+	rowsPerBand  = 4
+	numHashes    = bands * rowsPerBand
+	hashSeedBase = 0x9e3779b97f4a7c15
+)
+
+// FindDuplicates groups the highlights whose texts have a Jaccard similarity
+// of their shingles of at least threshold. Highlights are grouped
+// transitively, and groups are returned largest first.
+func FindDuplicates(hs []*t.Highlight, threshold float64) []*t.DuplicateGroup {
+	docs := make([]*document, 0, len(hs))
+	for _, h := range hs {
+		if sh := shingles(h.Text); len(sh) > 0 {
+			docs = append(docs, &document{highlight: h, shingles: sh, signature: minHash(sh)})
+		}
+	}
+
+	// Candidate pairs are the ones sharing at least one band
+	buckets := make(map[uint64][]int)
+	for i, d := range docs {
+		for b := 0; b < bands; b++ {
+			key := bandKey(b, d.signature[b*rowsPerBand:(b+1)*rowsPerBand])
+			buckets[key] = append(buckets[key], i)
+		}
+	}
+
+	uf := newUnionFind(len(docs))
+	lowest := make(map[int]float64)
+	compared := make(map[[2]int]bool)
+	for _, bucket := range buckets {
+		for x := 0; x < len(bucket); x++ {
+			for y := x + 1; y < len(bucket); y++ {
+				pair := [2]int{bucket[x], bucket[y]}
+				if compared[pair] {
+					continue
+				}
+				compared[pair] = true
+
+				// The signatures only estimate it, the exact similarity decides
+				sim := jaccard(docs[pair[0]].shingles, docs[pair[1]].shingles)
+				if sim < threshold {
+					continue
+				}
+
+				uf.union(pair[0], pair[1])
+				for _, i := range pair {
+					if s, ok := lowest[i]; !ok || sim < s {
+						lowest[i] = sim
+					}
+				}
+			}
+		}
+	}
+
+	members := make(map[int][]int)
+	for i := range docs {
+		root := uf.find(i)
+		members[root] = append(members[root], i)
+	}
+
+	groups := make([]*t.DuplicateGroup, 0)
+	for _, m := range members {
+		if len(m) < 2 {
+			continue
+		}
+
+		group := &t.DuplicateGroup{Similarity: 1}
+		for _, i := range m {
+			group.Highlights = append(group.Highlights, docs[i].highlight)
+			if lowest[i] < group.Similarity {
+				group.Similarity = lowest[i]
+			}
+		}
+
+		// Oldest first, it's usually the one to keep
+		sort.Slice(group.Highlights, func(i, j int) bool {
+			a, b := group.Highlights[i], group.Highlights[j]
+			if !a.CreatedAt.Equal(b.CreatedAt) {
+				return a.CreatedAt.Before(b.CreatedAt)
+			}
+			return a.ID.Hex() < b.ID.Hex()
+		})
+
+		groups = append(groups, group)
+	}
+
+	sort.Slice(groups, func(i, j int) bool {
+		if len(groups[i].Highlights) != len(groups[j].Highlights) {
+			return len(groups[i].Highlights) > len(groups[j].Highlights)
+		}
+		return groups[i].Highlights[0].ID.Hex() < groups[j].Highlights[0].ID.Hex()
+	})
+
+	return groups
+}
+
+type document struct {
+	highlight *t.Highlight
+	shingles  map[uint64]struct{}
+	signature []uint64
+}
+
+// shingles hashes the character shingles of the text with case,
+// punctuation and spacing normalized
+func shingles(text string) map[uint64]struct{} {
+	normalized := []rune(strings.Join(analysis.Tokenize(text), " "))
+
+	set := make(map[uint64]struct{})
+	if len(normalized) == 0 {
+		return set
+	}
+
+	// Texts shorter than a shingle are a single shingle
+	if len(normalized) < shingleSize {
+		set[hash(string(normalized))] = struct{}{}
+		return set
+	}
+
+	for i := 0; i+shingleSize <= len(normalized); i++ {
+		set[hash(string(normalized[i:i+shingleSize]))] = struct{}{}
+	}
+
+	return set
+}
+
+func hash(s string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	return h.Sum64()
+}
+
+// minHash keeps, for each of the hash functions, the smallest hash of the
+// shingles. Two sets agree on a position with a probability equal to their
+// Jaccard similarity.
+func minHash(set map[uint64]struct{}) []uint64 {
+	sig := make([]uint64, numHashes)
+	for i := range sig {
+		sig[i] = ^uint64(0)
+	}
+
+	for sh := range set {
+		for i := range sig {
+			if v := mix(sh ^ (hashSeedBase * uint64(i+1))); v < sig[i] {
+				sig[i] = v
+			}
+		}
+	}
+
+	return sig
+}
+
+// mix is the splitmix64 finalizer, turning the seeded shingle hash into an
+// independent hash function per seed
+func mix(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+func bandKey(band int, rows []uint64) uint64 {
+	key := mix(uint64(band) + 1)
+	for _, r := range rows {
+		key = mix(key ^ r)
+	}
+
+	return key
+}
+
+func jaccard(a, b map[uint64]struct{}) float64 {
+	if len(b) < len(a) {
+		a, b = b, a
+	}
+
+	shared := 0
+	for sh := range a {
+		if _, ok := b[sh]; ok {
+			shared++
+		}
+	}
+
+	union := len(a) + len(b) - shared
+	if union == 0 {
+		return 0
+	}
+
+	return float64(shared) / float64(union)
+}
+
+type unionFind struct {
+	parent []int
+	rank   []int
+}
+
+func newUnionFind(n int) *unionFind {
+	uf := &unionFind{parent: make([]int, n), rank: make([]int, n)}
+	for i := range uf.parent {
+		uf.parent[i] = i
+	}
+
+	return uf
+}
+
+func (uf *unionFind) find(x int) int {
+	for uf.parent[x] != x {
+		uf.parent[x] = uf.parent[uf.parent[x]]
+		x = uf.parent[x]
+	}
+
+	return x
+}
+
+func (uf *unionFind) union(a, b int) {
+	ra, rb := uf.find(a), uf.find(b)
+	if ra == rb {
+		return
+	}
+
+	switch {
+	case uf.rank[ra] < uf.rank[rb]:
+		uf.parent[ra] = rb
+	case uf.rank[ra] > uf.rank[rb]:
+		uf.parent[rb] = ra
+	default:
+		uf.parent[rb] = ra
+		uf.rank[ra]++
+	}
+}
@@ -0,0 +1,66 @@
+package dedupe
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sikozonpc/notebase/types"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestFindDuplicates(t *testing.T) {
+	now := time.Now()
+	highlight := func(text string, age time.Duration) *types.Highlight {
+		return &types.Highlight{ID: primitive.NewObjectID(), Text: text, CreatedAt: now.Add(-age)}
+	}
+
+	kindle := highlight("The obstacle is the way. What stands in the way becomes the way.", 3*time.Hour)
+	readwise := highlight("The Obstacle is the way; what stands in the way, becomes the way", 2*time.Hour)
+	manual := highlight("the obstacle is the way what stands in the way becomes the way!", time.Hour)
+	other := highlight("Waste no more time arguing what a good man should be. Be one.", time.Hour)
+	edited := highlight("Waste no more time arguing about what a good man should be. Be one.", 0)
+	unrelated := highlight("All warfare is based on deception.", 0)
+	empty := highlight("", 0)
+
+	groups := FindDuplicates([]*types.Highlight{manual, other, unrelated, kindle, edited, readwise, empty}, DefaultThreshold)
+
+	assert.Len(t, groups, 2)
+
+	assert.Equal(t, []*types.Highlight{kindle, readwise, manual}, groups[0].Highlights)
+	assert.Equal(t, 1.0, groups[0].Similarity)
+
+	assert.Equal(t, []*types.Highlight{other, edited}, groups[1].Highlights)
+	assert.Less(t, groups[1].Similarity, 1.0)
+	assert.GreaterOrEqual(t, groups[1].Similarity, DefaultThreshold)
+
+	// A stricter threshold leaves the edited highlight out
+	groups = FindDuplicates([]*types.Highlight{other, edited}, 0.95)
+	assert.Empty(t, groups)
+}
+
+func TestMinHashEstimatesJaccard(t *testing.T) {
+	a := shingles("It is not that we have a short time to live, but that we waste a lot of it.")
+	b := shingles("It is not that we have a short time to live, but that we waste much of it.")
+
+	sigA, sigB := minHash(a), minHash(b)
+
+	agree := 0
+	for i := range sigA {
+		if sigA[i] == sigB[i] {
+			agree++
+		}
+	}
+
+	assert.InDelta(t, jaccard(a, b), float64(agree)/numHashes, 0.15)
+}
+
+func TestUnionFind(t *testing.T) {
+	uf := newUnionFind(5)
+	uf.union(0, 1)
+	uf.union(3, 4)
+	uf.union(1, 4)
+
+	assert.Equal(t, uf.find(0), uf.find(3))
+	assert.NotEqual(t, uf.find(0), uf.find(2))
+}
@@ -0,0 +1,180 @@
+package highlight
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	t "github.com/sikozonpc/notebase/types"
+	u "github.com/sikozonpc/notebase/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrInvalidBulkOperation is returned for an unknown action or one missing
+// what it needs
+var ErrInvalidBulkOperation = errors.New("invalid bulk operation")
+
+// BulkUpdate applies the operation to each of the user's highlights with one
+// unordered bulk write, so a failing highlight doesn't stop the others. The
+// results are in the order of ids. Moves are the exception, see bulkMove.
+func (s *Store) BulkUpdate(ctx context.Context, userID primitive.ObjectID, ids []primitive.ObjectID, op *t.BulkOperation) ([]*t.BulkItemResult, error) {
+	col := s.db.Collection(CollName)
+
+	update, err := bulkUpdateDocument(op)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]*t.BulkItemResult, len(ids))
+	for i, id := range ids {
+		results[i] = &t.BulkItemResult{ID: id, Status: t.BulkStatusNotFound}
+	}
+
+	// Only the user's highlights outside the trash are written, the others
+	// are reported as not found
+	found, err := s.findIDs(ctx, bson.M{
+		"_id":       bson.M{"$in": ids},
+		"userId":    userID,
+		"deletedAt": nil,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	owned := make(map[primitive.ObjectID]bool, len(found))
+	for _, id := range found {
+		owned[id] = true
+	}
+
+	if op.Action == t.BulkActionMove {
+		return s.bulkMove(ctx, userID, ids, owned, op.BookID, results)
+	}
+
+	models := make([]mongo.WriteModel, 0, len(found))
+	// Index of the result each model writes
+	items := make([]int, 0, len(found))
+	first := make(map[primitive.ObjectID]int, len(found))
+	for i, id := range ids {
+		if !owned[id] {
+			continue
+		}
+
+		// Listed twice, it's written once and reported the same
+		if j, ok := first[id]; ok {
+			results[i] = results[j]
+			continue
+		}
+		first[id] = i
+
+		models = append(models, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"_id": id, "userId": userID, "deletedAt": nil}).
+			SetUpdate(update))
+		items = append(items, i)
+		results[i].Status = t.BulkStatusOK
+	}
+
+	if len(models) == 0 {
+		return results, nil
+	}
+
+	_, err = col.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+
+	var bulkErr mongo.BulkWriteException
+	if errors.As(err, &bulkErr) {
+		for _, we := range bulkErr.WriteErrors {
+			results[items[we.Index]].Status = t.BulkStatusFailed
+			results[items[we.Index]].Error = we.Message
+		}
+	} else if err != nil {
+		return nil, err
+	}
+
+	written := make([]primitive.ObjectID, 0, len(items))
+	for _, i := range items {
+		if results[i].Status == t.BulkStatusOK {
+			written = append(written, results[i].ID)
+		}
+	}
+
+	if op.Action == t.BulkActionDelete {
+		for _, id := range written {
+			s.notifyDeleted(ctx, userID, id)
+		}
+	} else {
+		s.notifyUpdated(ctx, bson.M{"_id": bson.M{"$in": written}})
+	}
+
+	return results, nil
+}
+
+// bulkMove moves the owned highlights one by one, so each move is recorded as
+// a revision like any other edit of the book
+func (s *Store) bulkMove(ctx context.Context, userID primitive.ObjectID, ids []primitive.ObjectID, owned map[primitive.ObjectID]bool, bookID string, results []*t.BulkItemResult) ([]*t.BulkItemResult, error) {
+	first := make(map[primitive.ObjectID]int, len(owned))
+	for i, id := range ids {
+		if !owned[id] {
+			continue
+		}
+
+		if j, ok := first[id]; ok {
+			results[i] = results[j]
+			continue
+		}
+		first[id] = i
+
+		_, err := s.UpdateHighlight(ctx, id, userID, &t.UpdateHighlightRequest{BookID: &bookID})
+		switch {
+		case errors.Is(err, mongo.ErrNoDocuments):
+			// Deleted since it was found
+		case err != nil:
+			results[i].Status = t.BulkStatusFailed
+			results[i].Error = err.Error()
+		default:
+			results[i].Status = t.BulkStatusOK
+		}
+	}
+
+	return results, nil
+}
+
+func bulkUpdateDocument(op *t.BulkOperation) (bson.M, error) {
+	now := time.Now().UTC()
+
+	switch op.Action {
+	case t.BulkActionDelete:
+		return bson.M{"$set": bson.M{"deletedAt": now}}, nil
+	case t.BulkActionTag, t.BulkActionUntag:
+		tags := u.NormalizeTags(op.Tags)
+		if len(tags) == 0 {
+			return nil, fmt.Errorf("%w: tags are required", ErrInvalidBulkOperation)
+		}
+
+		if op.Action == t.BulkActionTag {
+			return bson.M{
+				"$addToSet": bson.M{"tags": bson.M{"$each": tags}},
+				"$set":      bson.M{"updatedAt": now},
+			}, nil
+		}
+
+		return bson.M{
+			"$pull": bson.M{"tags": bson.M{"$in": tags}},
+			"$set":  bson.M{"updatedAt": now},
+		}, nil
+	case t.BulkActionMove:
+		if op.BookID == "" {
+			return nil, fmt.Errorf("%w: book id is required", ErrInvalidBulkOperation)
+		}
+
+		return bson.M{"$set": bson.M{"bookId": op.BookID, "updatedAt": now}}, nil
+	case t.BulkActionFavorite:
+		return bson.M{"$set": bson.M{"favorite": true}}, nil
+	case t.BulkActionUnfavorite:
+		return bson.M{"$unset": bson.M{"favorite": ""}}, nil
+	default:
+		return nil, fmt.Errorf("%w: unknown action %q", ErrInvalidBulkOperation, op.Action)
+	}
+}
@@ -0,0 +1,143 @@
+package highlight
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/sikozonpc/notebase/storage"
+	types "github.com/sikozonpc/notebase/types"
+	u "github.com/sikozonpc/notebase/utils"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestBulkUpdateDocument(t *testing.T) {
+	t.Run("should build the update of each action", func(t *testing.T) {
+		for _, op := range []*types.BulkOperation{
+			{Action: types.BulkActionDelete},
+			{Action: types.BulkActionTag, Tags: []string{"go"}},
+			{Action: types.BulkActionUntag, Tags: []string{"go"}},
+			{Action: types.BulkActionMove, BookID: "B01"},
+			{Action: types.BulkActionFavorite},
+			{Action: types.BulkActionUnfavorite},
+		} {
+			update, err := bulkUpdateDocument(op)
+			assert.NoError(t, err, op.Action)
+			assert.NotEmpty(t, update, op.Action)
+		}
+	})
+
+	t.Run("should reject incomplete or unknown actions", func(t *testing.T) {
+		for _, op := range []*types.BulkOperation{
+			{Action: types.BulkActionTag, Tags: []string{" "}},
+			{Action: types.BulkActionMove},
+			{Action: "archive"},
+		} {
+			_, err := bulkUpdateDocument(op)
+			assert.ErrorIs(t, err, ErrInvalidBulkOperation, op.Action)
+		}
+	})
+}
+
+func TestHandleBulkHighlights(t *testing.T) {
+	handler := NewHandler(&mockHighlightStore{}, &mockUserStore{}, storage.NewMemoryStorage(), &mockBookStore{}, &mockLibraryStore{}, &mockAuthorStore{}, &mockCollectionStore{}, &mockNoteStore{}, &mockLinkStore{}, &mockMailer{})
+
+	h1 := &types.Highlight{ID: primitive.NewObjectID(), Text: "first"}
+	h2 := &types.Highlight{ID: primitive.NewObjectID(), Text: "second"}
+
+	fakeLibrary = []*types.Highlight{h1, h2}
+	defer func() { fakeLibrary = nil }()
+
+	post := func(t *testing.T, body string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(http.MethodPost, "/user/1/highlight/bulk", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		router := mux.NewRouter()
+
+		router.HandleFunc("/user/{userID}/highlight/bulk", u.MakeHTTPHandler(handler.handleBulkHighlights)).Methods(http.MethodPost)
+
+		router.ServeHTTP(rr, req)
+
+		return rr
+	}
+
+	decode := func(t *testing.T, rr *httptest.ResponseRecorder) *BulkHighlightsResponse {
+		var res BulkHighlightsResponse
+		if err := json.NewDecoder(rr.Body).Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+
+		return &res
+	}
+
+	t.Run("should report the result of each highlight", func(t *testing.T) {
+		missing := primitive.NewObjectID()
+		rr := post(t, `{"action": "tag", "tags": ["go"], "highlightIds": ["`+h1.ID.Hex()+`", "`+missing.Hex()+`"]}`)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		res := decode(t, rr)
+		assert.Equal(t, 1, res.Succeeded)
+		assert.Equal(t, 1, res.Failed)
+		assert.Equal(t, types.BulkStatusOK, res.Results[0].Status)
+		assert.Equal(t, types.BulkStatusNotFound, res.Results[1].Status)
+	})
+
+	t.Run("should apply the action to the highlights matching a filter", func(t *testing.T) {
+		rr := post(t, `{"action": "favorite", "filter": {"tag": "go"}}`)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		assert.Equal(t, 2, decode(t, rr).Succeeded)
+	})
+
+	t.Run("should add highlights to a collection", func(t *testing.T) {
+		rr := post(t, `{"action": "collect", "collectionId": "`+primitive.NewObjectID().Hex()+`", "highlightIds": ["`+h2.ID.Hex()+`"]}`)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		assert.Equal(t, 1, decode(t, rr).Succeeded)
+	})
+
+	t.Run("should move highlights to another book", func(t *testing.T) {
+		rr := post(t, `{"action": "move", "bookId": "B02", "highlightIds": ["`+h1.ID.Hex()+`"]}`)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		assert.Equal(t, 1, decode(t, rr).Succeeded)
+	})
+
+	t.Run("should fail to move highlights to a book that does not exist", func(t *testing.T) {
+		rr := post(t, `{"action": "move", "bookId": "missing", "highlightIds": ["`+h1.ID.Hex()+`"]}`)
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("expected status code %d, got %d", http.StatusNotFound, rr.Code)
+		}
+	})
+
+	t.Run("should fail with invalid requests", func(t *testing.T) {
+		for _, body := range []string{
+			`{"action": "delete"}`,
+			`{"action": "delete", "highlightIds": ["` + h1.ID.Hex() + `"], "filter": {}}`,
+			`{"action": "delete", "highlightIds": ["nope"]}`,
+			`{"action": "archive", "highlightIds": ["` + h1.ID.Hex() + `"]}`,
+			`{"action": "move", "highlightIds": ["` + h1.ID.Hex() + `"]}`,
+			`{"action": "collect", "highlightIds": ["` + h1.ID.Hex() + `"]}`,
+		} {
+			rr := post(t, body)
+			if rr.Code != http.StatusBadRequest {
+				t.Errorf("expected status code %d for %s, got %d", http.StatusBadRequest, body, rr.Code)
+			}
+		}
+	})
+}
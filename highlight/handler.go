@@ -3,26 +3,44 @@ package highlight
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"mime/multipart"
 	"net/http"
+	"slices"
+	"strconv"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/sikozonpc/notebase/auth"
+	"github.com/sikozonpc/notebase/author"
+	"github.com/sikozonpc/notebase/config"
+	"github.com/sikozonpc/notebase/cover"
+	"github.com/sikozonpc/notebase/dedupe"
+	"github.com/sikozonpc/notebase/link"
 	"github.com/sikozonpc/notebase/medium"
+	"github.com/sikozonpc/notebase/reading"
 	"github.com/sikozonpc/notebase/storage"
+	"github.com/sikozonpc/notebase/suggest"
 	t "github.com/sikozonpc/notebase/types"
 	u "github.com/sikozonpc/notebase/utils"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
 type Handler struct {
-	store     t.HighlightStore
-	userStore t.UserStore
-	storage   storage.Storage
-	bookStore t.BookStore
-	mailer    medium.Medium
+	store           t.HighlightStore
+	userStore       t.UserStore
+	storage         storage.Storage
+	bookStore       t.BookStore
+	libraryStore    t.LibraryStore
+	authorStore     t.AuthorStore
+	collectionStore t.CollectionStore
+	noteStore       t.NoteStore
+	linkStore       t.LinkStore
+	mailer          medium.Medium
 }
 
 func NewHandler(
@@ -30,14 +48,24 @@ func NewHandler(
 	userStore t.UserStore,
 	storage storage.Storage,
 	bookStore t.BookStore,
+	libraryStore t.LibraryStore,
+	authorStore t.AuthorStore,
+	collectionStore t.CollectionStore,
+	noteStore t.NoteStore,
+	linkStore t.LinkStore,
 	mailer medium.Medium,
 ) *Handler {
 	return &Handler{
-		store:     store,
-		userStore: userStore,
-		storage:   storage,
-		bookStore: bookStore,
-		mailer:    mailer,
+		store:           store,
+		userStore:       userStore,
+		storage:         storage,
+		bookStore:       bookStore,
+		libraryStore:    libraryStore,
+		authorStore:     authorStore,
+		collectionStore: collectionStore,
+		noteStore:       noteStore,
+		linkStore:       linkStore,
+		mailer:          mailer,
 	}
 }
 
@@ -52,16 +80,112 @@ func (h *Handler) RegisterRoutes(router *mux.Router) {
 		auth.WithJWTAuth(u.MakeHTTPHandler(h.handleCreateHighlight), h.userStore),
 	).Methods("POST")
 
+	// Registered before {id}, which would match them
+	router.HandleFunc(
+		"/user/{userID}/highlight/duplicates",
+		auth.WithJWTAuth(u.MakeHTTPHandler(h.handleGetDuplicateHighlights), h.userStore),
+	).Methods("GET")
+
+	router.HandleFunc(
+		"/user/{userID}/highlight/bulk",
+		auth.WithJWTAuth(u.MakeHTTPHandler(h.handleBulkHighlights), h.userStore),
+	).Methods("POST")
+
 	router.HandleFunc(
 		"/user/{userID}/highlight/{id}",
 		auth.WithJWTAuth(u.MakeHTTPHandler(h.handleGetHighlightByID), h.userStore),
 	).Methods("GET")
 
+	router.HandleFunc(
+		"/user/{userID}/highlight/{id}",
+		auth.WithJWTAuth(u.MakeHTTPHandler(h.handleUpdateHighlight), h.userStore),
+	).Methods("PATCH")
+
 	router.HandleFunc(
 		"/user/{userID}/highlight/{id}",
 		auth.WithJWTAuth(u.MakeHTTPHandler(h.handleDeleteHighlight), h.userStore),
 	).Methods("DELETE")
 
+	router.HandleFunc(
+		"/user/{userID}/highlight/{id}/revisions",
+		auth.WithJWTAuth(u.MakeHTTPHandler(h.handleGetHighlightRevisions), h.userStore),
+	).Methods("GET")
+
+	router.HandleFunc(
+		"/user/{userID}/highlight/{id}/revisions/{version}/revert",
+		auth.WithJWTAuth(u.MakeHTTPHandler(h.handleRevertHighlight), h.userStore),
+	).Methods("POST")
+
+	router.HandleFunc(
+		"/user/{userID}/highlight/{id}/merge",
+		auth.WithJWTAuth(u.MakeHTTPHandler(h.handleMergeHighlights), h.userStore),
+	).Methods("POST")
+
+	router.HandleFunc(
+		"/user/{userID}/highlight/{id}/related",
+		auth.WithJWTAuth(u.MakeHTTPHandler(h.handleGetRelatedHighlights), h.userStore),
+	).Methods("GET")
+
+	router.HandleFunc(
+		"/user/{userID}/highlight/{id}/favorite",
+		auth.WithJWTAuth(u.MakeHTTPHandler(h.handleFavoriteHighlight), h.userStore),
+	).Methods("PUT")
+
+	router.HandleFunc(
+		"/user/{userID}/highlight/{id}/favorite",
+		auth.WithJWTAuth(u.MakeHTTPHandler(h.handleUnfavoriteHighlight), h.userStore),
+	).Methods("DELETE")
+
+	router.HandleFunc(
+		"/user/{userID}/highlight/{id}/rating",
+		auth.WithJWTAuth(u.MakeHTTPHandler(h.handleRateHighlight), h.userStore),
+	).Methods("PUT")
+
+	router.HandleFunc(
+		"/user/{userID}/highlight/{id}/rating",
+		auth.WithJWTAuth(u.MakeHTTPHandler(h.handleClearHighlightRating), h.userStore),
+	).Methods("DELETE")
+
+	router.HandleFunc(
+		"/user/{userID}/highlight/{id}/tags",
+		auth.WithJWTAuth(u.MakeHTTPHandler(h.handleAddHighlightTags), h.userStore),
+	).Methods("POST")
+
+	router.HandleFunc(
+		"/user/{userID}/highlight/{id}/tags/{tag}",
+		auth.WithJWTAuth(u.MakeHTTPHandler(h.handleRemoveHighlightTag), h.userStore),
+	).Methods("DELETE")
+
+	router.HandleFunc(
+		"/user/{userID}/highlight/{id}/suggested-tags/accept",
+		auth.WithJWTAuth(u.MakeHTTPHandler(h.handleAcceptSuggestedTags), h.userStore),
+	).Methods("POST")
+
+	router.HandleFunc(
+		"/user/{userID}/highlight/{id}/suggested-tags/reject",
+		auth.WithJWTAuth(u.MakeHTTPHandler(h.handleRejectSuggestedTags), h.userStore),
+	).Methods("POST")
+
+	router.HandleFunc(
+		"/user/{userID}/tags",
+		auth.WithJWTAuth(u.MakeHTTPHandler(h.handleGetUserTags), h.userStore),
+	).Methods("GET")
+
+	router.HandleFunc(
+		"/user/{userID}/tags/bulk",
+		auth.WithJWTAuth(u.MakeHTTPHandler(h.handleBulkTags), h.userStore),
+	).Methods("POST")
+
+	router.HandleFunc(
+		"/user/{userID}/tags/merge",
+		auth.WithJWTAuth(u.MakeHTTPHandler(h.handleMergeTags), h.userStore),
+	).Methods("POST")
+
+	router.HandleFunc(
+		"/user/{userID}/tags/{tag}",
+		auth.WithJWTAuth(u.MakeHTTPHandler(h.handleRenameTag), h.userStore),
+	).Methods("PUT")
+
 	router.HandleFunc(
 		"/user/{userID}/parse-kindle-extract",
 		u.MakeHTTPHandler(h.handleParseKindleFile),
@@ -124,14 +248,24 @@ func (s *Handler) handleSendDailyInsights(w http.ResponseWriter, r *http.Request
 			return fmt.Errorf("user with id %d not found", u.ID)
 		}
 
-		hs, err := s.store.GetRandomHighlights(r.Context(), u.ID, 3)
+		filter, err := s.insightsFilter(r.Context(), user)
 		if err != nil {
 			return err
 		}
+		// Notes aren't part of collections, so a user who picked one only
+		// gets its highlights
+		withNotes := filter == nil
+
+		if config.Envs.WeightInsightsByRating {
+			if filter == nil {
+				filter = &t.HighlightFilter{}
+			}
+			filter.WeightByRating = true
+		}
 
-		// Don't send daily insights if there are none
-		if len(hs) == 0 {
-			continue
+		hs, err := s.store.GetRandomHighlights(r.Context(), u.ID, 3, filter)
+		if err != nil {
+			return err
 		}
 
 		insights, err := buildInsights(hs, s.bookStore)
@@ -139,6 +273,28 @@ func (s *Handler) handleSendDailyInsights(w http.ResponseWriter, r *http.Request
 			return err
 		}
 
+		if err := s.addRelatedInsights(r.Context(), u.ID, hs, insights); err != nil {
+			return err
+		}
+
+		if withNotes {
+			notes, err := s.noteStore.GetRandomNotes(r.Context(), u.ID, NoteInsightsLimit)
+			if err != nil {
+				return err
+			}
+
+			noteInsights, err := buildNoteInsights(r.Context(), notes, s.bookStore)
+			if err != nil {
+				return err
+			}
+			insights = append(insights, noteInsights...)
+		}
+
+		// Don't send daily insights if there are none
+		if len(insights) == 0 {
+			continue
+		}
+
 		if err = s.mailer.SendInsights(user, insights, authToken); err != nil {
 			return err
 		}
@@ -147,6 +303,81 @@ func (s *Handler) handleSendDailyInsights(w http.ResponseWriter, r *http.Request
 	return u.WriteJSON(w, http.StatusOK, nil)
 }
 
+// addRelatedInsights suggests, under each insight, the highlights from the
+// user's library most similar to it. A highlight is suggested once.
+func (s *Handler) addRelatedInsights(ctx context.Context, userID primitive.ObjectID, hs []*t.Highlight, insights []*t.DailyInsight) error {
+	candidates, err := s.getCorpusHighlights(ctx, userID, hs...)
+	if err != nil {
+		return err
+	}
+
+	corpus := suggest.NewCorpus(candidates)
+
+	seen := make([]primitive.ObjectID, len(hs))
+	for i, h := range hs {
+		seen[i] = h.ID
+	}
+
+	for i, h := range hs {
+		related := corpus.Related(h.ID, RelatedInsightsLimit, seen...)
+		if len(related) == 0 {
+			continue
+		}
+
+		rhs := make([]*t.Highlight, len(related))
+		for j, r := range related {
+			rhs[j] = r.Highlight
+			seen = append(seen, r.Highlight.ID)
+		}
+
+		insights[i].Related, err = buildInsights(rhs, s.bookStore)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// getCorpusHighlights returns the user's latest highlights, up to
+// MaxCorpusHighlights, along with the given ones if they're older
+func (s *Handler) getCorpusHighlights(ctx context.Context, userID primitive.ObjectID, include ...*t.Highlight) ([]*t.Highlight, error) {
+	page, err := s.store.GetUserHighlights(ctx, userID, &t.HighlightFilter{
+		Desc:  true,
+		Limit: MaxCorpusHighlights,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	hs := page.Highlights
+	for _, h := range include {
+		if !slices.ContainsFunc(hs, func(other *t.Highlight) bool { return other.ID == h.ID }) {
+			hs = append(hs, h)
+		}
+	}
+
+	return hs, nil
+}
+
+// insightsFilter restricts daily insights to the user's chosen collection, if
+// it still exists
+func (s *Handler) insightsFilter(ctx context.Context, user *t.User) (*t.HighlightFilter, error) {
+	if user.InsightsCollectionID == nil {
+		return nil, nil
+	}
+
+	c, err := s.collectionStore.GetCollectionByID(ctx, *user.InsightsCollectionID, user.ID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &t.HighlightFilter{IDs: c.HighlightIDs}, nil
+}
+
 func (s *Handler) handleCloudKindleParse(w http.ResponseWriter, r *http.Request) error {
 	userID, err := u.GetStringParamFromRequest(r, "userID")
 	if err != nil {
@@ -209,22 +440,125 @@ func (s *Handler) handleGetUserHighlights(w http.ResponseWriter, r *http.Request
 
 	oID, _ := primitive.ObjectIDFromHex(string(userID))
 
-	hs, err := s.store.GetUserHighlights(r.Context(), oID)
+	filter, err := parseHighlightFilter(r)
+	if err != nil {
+		return u.WriteJSON(w, http.StatusBadRequest, t.APIError{Error: err.Error()})
+	}
+
+	page, err := s.store.GetUserHighlights(r.Context(), oID, filter)
+	if errors.Is(err, ErrInvalidFilter) {
+		return u.WriteJSON(w, http.StatusBadRequest, t.APIError{Error: err.Error()})
+	}
 	if err != nil {
 		return err
 	}
 
-	return u.WriteJSON(w, http.StatusOK, hs)
+	w.Header().Set("X-Total-Count", strconv.FormatInt(page.Total, 10))
+	if page.NextCursor != "" {
+		w.Header().Set("X-Next-Cursor", page.NextCursor)
+	}
+
+	return u.WriteJSON(w, http.StatusOK, page.Highlights)
+}
+
+const (
+	DefaultPageSize = 50
+	MaxPageSize     = 200
+
+	DefaultRelatedLimit = 5
+	MaxRelatedLimit     = 20
+	// Related highlights suggested under each daily insight
+	RelatedInsightsLimit = 2
+	// Latest highlights related and duplicate ones are looked for among, so
+	// a large library doesn't make every request load and compare all of it
+	MaxCorpusHighlights = 5000
+	// Standalone notes sent along the daily highlights
+	NoteInsightsLimit = 1
+
+	// Highlights that can be listed by id in a bulk request, filters aren't
+	// limited
+	MaxBulkHighlights = 1000
+)
+
+// parseHighlightFilter reads the listing query parameters: book, tag, from,
+// to, hasNote, favorite, minRating, sort, order, limit and cursor
+func parseHighlightFilter(r *http.Request) (*t.HighlightFilter, error) {
+	q := r.URL.Query()
+
+	filter := &t.HighlightFilter{
+		BookID: q.Get("book"),
+		Tag:    q.Get("tag"),
+		Sort:   q.Get("sort"),
+		Cursor: q.Get("cursor"),
+		Limit:  DefaultPageSize,
+	}
+
+	switch q.Get("order") {
+	case "", "asc":
+	case "desc":
+		filter.Desc = true
+	default:
+		return nil, fmt.Errorf("order must be asc or desc")
+	}
+
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > MaxPageSize {
+			return nil, fmt.Errorf("limit must be between 1 and %d", MaxPageSize)
+		}
+		filter.Limit = limit
+	}
+
+	if v := q.Get("hasNote"); v != "" {
+		hasNote, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("hasNote must be true or false")
+		}
+		filter.HasNote = &hasNote
+	}
+
+	if v := q.Get("favorite"); v != "" {
+		favorite, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("favorite must be true or false")
+		}
+		filter.Favorite = &favorite
+	}
+
+	if v := q.Get("minRating"); v != "" {
+		rating, err := strconv.Atoi(v)
+		if err != nil || rating < MinRating || rating > MaxRating {
+			return nil, fmt.Errorf("minRating must be between %d and %d", MinRating, MaxRating)
+		}
+		filter.MinRating = rating
+	}
+
+	from, to, err := u.ParseDateRange(q.Get("from"), q.Get("to"))
+	if err != nil {
+		return nil, err
+	}
+	filter.CreatedAfter, filter.CreatedBefore = from, to
+
+	return filter, nil
 }
 
 func (s *Handler) handleDeleteHighlight(w http.ResponseWriter, r *http.Request) error {
-	id, err := u.GetStringParamFromRequest(r, "id")
+	userID, err := u.GetStringParamFromRequest(r, "userID")
 	if err != nil {
 		return err
 	}
+	oUserID, _ := primitive.ObjectIDFromHex(string(userID))
 
+	id, err := u.GetStringParamFromRequest(r, "id")
+	if err != nil {
+		return err
+	}
 	oID, _ := primitive.ObjectIDFromHex(string(id))
-	err = s.store.DeleteHighlight(r.Context(), oID)
+
+	err = s.store.DeleteHighlight(r.Context(), oID, oUserID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return u.WriteJSON(w, http.StatusNotFound, t.APIError{Error: fmt.Errorf("highlight with id %v not found", id).Error()})
+	}
 	if err != nil {
 		return err
 	}
@@ -258,91 +592,985 @@ func (s *Handler) handleGetHighlightByID(w http.ResponseWriter, r *http.Request)
 
 }
 
-func (s *Handler) handleCreateHighlight(w http.ResponseWriter, r *http.Request) error {
-	payload := new(CreateHighlightRequest)
-	if err := json.NewDecoder(r.Body).Decode(payload); err != nil {
+func (s *Handler) handleGetRelatedHighlights(w http.ResponseWriter, r *http.Request) error {
+	userID, err := u.GetStringParamFromRequest(r, "userID")
+	if err != nil {
 		return err
 	}
+	oUserID, _ := primitive.ObjectIDFromHex(userID)
 
-	oID, _ := primitive.ObjectIDFromHex(string(payload.UserId))
+	id, err := u.GetStringParamFromRequest(r, "id")
+	if err != nil {
+		return err
+	}
+	oID, _ := primitive.ObjectIDFromHex(id)
 
-	highlight := &t.CreateHighlightRequest{
-		Text:     payload.Text,
-		Location: payload.Location,
-		Note:     payload.Note,
-		UserID:   oID,
-		BookID:   payload.BookId,
+	limit := DefaultRelatedLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > MaxRelatedLimit {
+			return u.WriteJSON(w, http.StatusBadRequest, t.APIError{Error: fmt.Errorf("limit must be between 1 and %d", MaxRelatedLimit).Error()})
+		}
 	}
 
-	if _, err := s.store.CreateHighlight(r.Context(), highlight); err != nil {
+	h, err := s.store.GetHighlightByID(r.Context(), oID, oUserID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return u.WriteJSON(w, http.StatusNotFound, t.APIError{Error: fmt.Errorf("highlight with id %v not found", id).Error()})
+	}
+	if err != nil {
 		return err
 	}
 
-	return u.WriteJSON(w, http.StatusOK, highlight)
+	// Similarity is relative to the rest of the library, so as much of it
+	// as allowed is needed
+	candidates, err := s.getCorpusHighlights(r.Context(), oUserID, h)
+	if err != nil {
+		return err
+	}
 
-}
+	related := suggest.NewCorpus(candidates).Related(h.ID, limit)
 
-type CreateHighlightRequest struct {
-	Text     string `json:"text"`
-	Location string `json:"location"`
-	Note     string `json:"note"`
-	UserId   string `json:"userId"`
-	BookId   string `json:"bookId"`
+	return u.WriteJSON(w, http.StatusOK, related)
 }
 
-type ParseKindleFileRequest struct {
-	File multipart.File `json:"file"`
+func (s *Handler) handleGetDuplicateHighlights(w http.ResponseWriter, r *http.Request) error {
+	userID, err := u.GetStringParamFromRequest(r, "userID")
+	if err != nil {
+		return err
+	}
+	oUserID, _ := primitive.ObjectIDFromHex(userID)
+
+	threshold := dedupe.DefaultThreshold
+	if v := r.URL.Query().Get("threshold"); v != "" {
+		threshold, err = strconv.ParseFloat(v, 64)
+		if err != nil || threshold <= 0 || threshold > 1 {
+			return u.WriteJSON(w, http.StatusBadRequest, t.APIError{Error: fmt.Errorf("threshold must be between 0 and 1").Error()})
+		}
+	}
+
+	candidates, err := s.getCorpusHighlights(r.Context(), oUserID)
+	if err != nil {
+		return err
+	}
+
+	return u.WriteJSON(w, http.StatusOK, dedupe.FindDuplicates(candidates, threshold))
 }
 
-func buildInsights(hs []*t.Highlight, bookStore t.BookStore) ([]*t.DailyInsight, error) {
-	var insights []*t.DailyInsight
+// handleMergeHighlights keeps the highlight in the path and merges the ones
+// in the body into it
+func (s *Handler) handleMergeHighlights(w http.ResponseWriter, r *http.Request) error {
+	userID, err := u.GetStringParamFromRequest(r, "userID")
+	if err != nil {
+		return err
+	}
+	oUserID, _ := primitive.ObjectIDFromHex(userID)
 
-	for _, h := range hs {
-		book, err := bookStore.GetByISBN(context.Background(), h.BookID)
-		if err != nil {
-			log.Println("Error getting book: ", err)
-			return nil, err
+	id, err := u.GetStringParamFromRequest(r, "id")
+	if err != nil {
+		return err
+	}
+	oID, _ := primitive.ObjectIDFromHex(id)
+
+	payload := new(MergeHighlightsRequest)
+	if err := json.NewDecoder(r.Body).Decode(payload); err != nil {
+		return u.WriteJSON(w, http.StatusBadRequest, t.APIError{Error: err.Error()})
+	}
+
+	ids, err := u.ParseObjectIDs(payload.HighlightIDs)
+	if err != nil {
+		return u.WriteJSON(w, http.StatusBadRequest, t.APIError{Error: err.Error()})
+	}
+
+	others := make([]primitive.ObjectID, 0, len(ids))
+	for _, other := range ids {
+		if other == oID {
+			return u.WriteJSON(w, http.StatusBadRequest, t.APIError{Error: fmt.Errorf("can't merge a highlight into itself").Error()})
 		}
+		if !slices.Contains(others, other) {
+			others = append(others, other)
+		}
+	}
 
-		insights = append(insights, &t.DailyInsight{
-			Text:        h.Text,
-			Note:        h.Note,
-			BookAuthors: book.Authors,
-			BookTitle:   book.Title,
-		})
+	if len(others) == 0 {
+		return u.WriteJSON(w, http.StatusBadRequest, t.APIError{Error: fmt.Errorf("highlight ids to merge are required").Error()})
 	}
 
-	return insights, nil
+	h, err := s.store.MergeHighlights(r.Context(), oID, oUserID, others)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return u.WriteJSON(w, http.StatusNotFound, t.APIError{Error: fmt.Errorf("highlights not found").Error()})
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := s.collectionStore.ReplaceHighlights(r.Context(), oUserID, others, oID); err != nil {
+		return err
+	}
+
+	if err := s.repointLinks(r.Context(), oUserID, others, oID); err != nil {
+		return err
+	}
+
+	return u.WriteJSON(w, http.StatusOK, h)
 }
 
-func (s *Handler) createDataFromRawBook(raw *t.RawExtractBook, userID string) error {
-	// Create book
-	_, err := s.bookStore.GetByISBN(context.Background(), raw.ASIN)
-	if err != nil {
-		s.bookStore.Create(context.Background(), &t.CreateBookRequest{
-			ISBN:    raw.ASIN,
-			Title:   raw.Title,
-			Authors: raw.Authors,
-		})
+// repointLinks rewrites the user's wiki-links to the merged highlights in
+// the notes they're written in, so they point to the kept one
+func (s *Handler) repointLinks(ctx context.Context, userID primitive.ObjectID, from []primitive.ObjectID, to primitive.ObjectID) error {
+	targets := make([]string, len(from))
+	for i, id := range from {
+		targets[i] = id.Hex()
 	}
 
-	oID, _ := primitive.ObjectIDFromHex(string(userID))
+	links, err := s.linkStore.GetLinksTo(ctx, userID, targets)
+	if err != nil {
+		return err
+	}
 
-	// Create highlights
-	hs := make([]*t.CreateHighlightRequest, len(raw.Highlights))
-	for i, h := range raw.Highlights {
-		hs[i] = &t.CreateHighlightRequest{
-			Text:     h.Text,
-			Location: h.Location.URL,
-			Note:     h.Note,
-			UserID:   oID,
-			BookID:   raw.ASIN,
+	seen := make(map[primitive.ObjectID]bool)
+	for _, l := range links {
+		if seen[l.SourceID] {
+			continue
+		}
+		seen[l.SourceID] = true
+
+		switch l.SourceType {
+		case t.LinkNodeHighlight:
+			h, err := s.store.GetHighlightByID(ctx, l.SourceID, userID)
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+
+			// The links are set again by the hook once it's updated
+			note := link.Replace(h.Note, targets, to.Hex())
+			if _, err := s.store.UpdateHighlight(ctx, h.ID, userID, &t.UpdateHighlightRequest{Note: &note}); err != nil {
+				return err
+			}
+		case t.LinkNodeNote:
+			n, err := s.noteStore.GetNoteByID(ctx, l.SourceID, userID)
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+
+			body := link.Replace(n.Body, targets, to.Hex())
+			if _, err := s.noteStore.UpdateNote(ctx, n.ID, userID, &t.UpdateNoteRequest{Body: &body}); err != nil {
+				return err
+			}
+
+			if err := s.linkStore.SetLinks(ctx, userID, t.LinkNodeNote, n.ID, link.Parse(body)); err != nil {
+				return err
+			}
 		}
 	}
 
-	for _, h := range hs {
-		_, err := s.store.CreateHighlight(context.Background(), h)
-		if err != nil {
+	return nil
+}
+
+// handleBulkHighlights applies one action to the highlights listed by id or
+// matching a filter, and reports the outcome for each of them
+func (s *Handler) handleBulkHighlights(w http.ResponseWriter, r *http.Request) error {
+	userID, err := u.GetStringParamFromRequest(r, "userID")
+	if err != nil {
+		return err
+	}
+	oUserID, _ := primitive.ObjectIDFromHex(userID)
+
+	payload := new(BulkHighlightsRequest)
+	if err := json.NewDecoder(r.Body).Decode(payload); err != nil {
+		return u.WriteJSON(w, http.StatusBadRequest, t.APIError{Error: err.Error()})
+	}
+
+	if (len(payload.HighlightIDs) == 0) == (payload.Filter == nil) {
+		return u.WriteJSON(w, http.StatusBadRequest, t.APIError{Error: fmt.Errorf("either highlight ids or a filter is required").Error()})
+	}
+
+	if len(payload.HighlightIDs) > MaxBulkHighlights {
+		return u.WriteJSON(w, http.StatusBadRequest, t.APIError{Error: fmt.Errorf("at most %d highlight ids are allowed", MaxBulkHighlights).Error()})
+	}
+
+	ids, err := u.ParseObjectIDs(payload.HighlightIDs)
+	if err != nil {
+		return u.WriteJSON(w, http.StatusBadRequest, t.APIError{Error: err.Error()})
+	}
+
+	if payload.Filter != nil {
+		page, err := s.store.GetUserHighlights(r.Context(), oUserID, payload.Filter.highlightFilter())
+		if err != nil {
+			return err
+		}
+
+		ids = make([]primitive.ObjectID, len(page.Highlights))
+		for i, h := range page.Highlights {
+			ids[i] = h.ID
+		}
+	}
+
+	if payload.Action == t.BulkActionMove && payload.BookID != "" {
+		isbn, err := s.getMoveTarget(r.Context(), oUserID, payload.BookID)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return u.WriteJSON(w, http.StatusNotFound, t.APIError{Error: fmt.Errorf("book with id %v not found", payload.BookID).Error()})
+		}
+		if err != nil {
+			return err
+		}
+		payload.BookID = isbn
+	}
+
+	var results []*t.BulkItemResult
+	if payload.Action == t.BulkActionCollect {
+		results, err = s.bulkCollect(r.Context(), oUserID, ids, payload.CollectionID)
+	} else {
+		results, err = s.store.BulkUpdate(r.Context(), oUserID, ids, &t.BulkOperation{
+			Action: payload.Action,
+			Tags:   payload.Tags,
+			BookID: payload.BookID,
+		})
+	}
+	if errors.Is(err, ErrInvalidBulkOperation) {
+		return u.WriteJSON(w, http.StatusBadRequest, t.APIError{Error: err.Error()})
+	}
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return u.WriteJSON(w, http.StatusNotFound, t.APIError{Error: fmt.Errorf("collection with id %v not found", payload.CollectionID).Error()})
+	}
+	if err != nil {
+		return err
+	}
+
+	response := BulkHighlightsResponse{Results: results}
+	for _, res := range results {
+		if res.Status == t.BulkStatusOK {
+			response.Succeeded++
+		} else {
+			response.Failed++
+		}
+	}
+
+	return u.WriteJSON(w, http.StatusOK, response)
+}
+
+// bulkCollect appends the user's highlights to a collection. It updates the
+// collection rather than the highlights, so it's not a bulk write.
+func (s *Handler) bulkCollect(ctx context.Context, userID primitive.ObjectID, ids []primitive.ObjectID, collectionID string) ([]*t.BulkItemResult, error) {
+	oCollectionID, err := primitive.ObjectIDFromHex(collectionID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: a valid collection id is required", ErrInvalidBulkOperation)
+	}
+
+	page, err := s.store.GetUserHighlights(ctx, userID, &t.HighlightFilter{IDs: ids})
+	if err != nil {
+		return nil, err
+	}
+
+	owned := make(map[primitive.ObjectID]bool, len(page.Highlights))
+	for _, h := range page.Highlights {
+		owned[h.ID] = true
+	}
+
+	results := make([]*t.BulkItemResult, len(ids))
+	found := make([]primitive.ObjectID, 0, len(owned))
+	for i, id := range ids {
+		results[i] = &t.BulkItemResult{ID: id, Status: t.BulkStatusNotFound}
+		if owned[id] {
+			results[i].Status = t.BulkStatusOK
+			found = append(found, id)
+		}
+	}
+
+	if _, err := s.collectionStore.AddHighlights(ctx, oCollectionID, userID, found, -1); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+func (s *Handler) handleUpdateHighlight(w http.ResponseWriter, r *http.Request) error {
+	userID, err := u.GetStringParamFromRequest(r, "userID")
+	if err != nil {
+		return err
+	}
+	oUserID, _ := primitive.ObjectIDFromHex(string(userID))
+
+	id, err := u.GetStringParamFromRequest(r, "id")
+	if err != nil {
+		return err
+	}
+	oID, _ := primitive.ObjectIDFromHex(string(id))
+
+	payload := new(t.UpdateHighlightRequest)
+	if err := json.NewDecoder(r.Body).Decode(payload); err != nil {
+		return u.WriteJSON(w, http.StatusBadRequest, t.APIError{Error: err.Error()})
+	}
+
+	if err := validateUpdateHighlightRequest(payload); err != nil {
+		return u.WriteJSON(w, http.StatusBadRequest, t.APIError{Error: err.Error()})
+	}
+
+	if payload.BookID != nil {
+		isbn, err := s.getMoveTarget(r.Context(), oUserID, *payload.BookID)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return u.WriteJSON(w, http.StatusNotFound, t.APIError{Error: fmt.Errorf("book with id %v not found", *payload.BookID).Error()})
+		}
+		if err != nil {
+			return err
+		}
+		payload.BookID = &isbn
+	}
+
+	// The store only matches highlights owned by the user, so another user's
+	// highlight is reported as not found
+	h, err := s.store.UpdateHighlight(r.Context(), oID, oUserID, payload)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return u.WriteJSON(w, http.StatusNotFound, t.APIError{Error: fmt.Errorf("highlight with id %v not found", id).Error()})
+	}
+	if err != nil {
+		return err
+	}
+
+	return u.WriteJSON(w, http.StatusOK, h)
+}
+
+// getMoveTarget finds the book highlights are moved to by any of its
+// identifiers, and adds it to the user's library. Highlights refer to it by
+// the ISBN it returns.
+func (s *Handler) getMoveTarget(ctx context.Context, userID primitive.ObjectID, identifier string) (string, error) {
+	book, err := s.bookStore.GetByIdentifier(ctx, identifier)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := s.libraryStore.AddBook(ctx, userID, book.ID); err != nil {
+		return "", err
+	}
+
+	return book.ISBN, nil
+}
+
+func (s *Handler) handleGetHighlightRevisions(w http.ResponseWriter, r *http.Request) error {
+	userID, err := u.GetStringParamFromRequest(r, "userID")
+	if err != nil {
+		return err
+	}
+	oUserID, _ := primitive.ObjectIDFromHex(string(userID))
+
+	id, err := u.GetStringParamFromRequest(r, "id")
+	if err != nil {
+		return err
+	}
+	oID, _ := primitive.ObjectIDFromHex(string(id))
+
+	h, err := s.store.GetHighlightByID(r.Context(), oID, oUserID)
+	if errors.Is(err, mongo.ErrNoDocuments) || (err == nil && h == nil) {
+		return u.WriteJSON(w, http.StatusNotFound, t.APIError{Error: fmt.Errorf("highlight with id %v not found", id).Error()})
+	}
+	if err != nil {
+		return err
+	}
+
+	revisions, err := s.store.GetHighlightRevisions(r.Context(), oID, oUserID)
+	if err != nil {
+		return err
+	}
+
+	return u.WriteJSON(w, http.StatusOK, revisions)
+}
+
+func (s *Handler) handleRevertHighlight(w http.ResponseWriter, r *http.Request) error {
+	userID, err := u.GetStringParamFromRequest(r, "userID")
+	if err != nil {
+		return err
+	}
+	oUserID, _ := primitive.ObjectIDFromHex(string(userID))
+
+	id, err := u.GetStringParamFromRequest(r, "id")
+	if err != nil {
+		return err
+	}
+	oID, _ := primitive.ObjectIDFromHex(string(id))
+
+	v, err := u.GetStringParamFromRequest(r, "version")
+	if err != nil {
+		return err
+	}
+	version, err := strconv.Atoi(v)
+	if err != nil {
+		return u.WriteJSON(w, http.StatusBadRequest, t.APIError{Error: fmt.Errorf("invalid revision %v", v).Error()})
+	}
+
+	rev, err := s.store.GetHighlightRevision(r.Context(), oID, oUserID, version)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return u.WriteJSON(w, http.StatusNotFound, t.APIError{Error: fmt.Errorf("revision %v of highlight %v not found", version, id).Error()})
+	}
+	if err != nil {
+		return err
+	}
+
+	// Reverting is an edit itself, so it shows up in the revision log
+	h, err := s.store.UpdateHighlight(r.Context(), oID, oUserID, &t.UpdateHighlightRequest{
+		Text:     &rev.Snapshot.Text,
+		Location: &rev.Snapshot.Location,
+		Note:     &rev.Snapshot.Note,
+		BookID:   &rev.Snapshot.BookID,
+	})
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return u.WriteJSON(w, http.StatusNotFound, t.APIError{Error: fmt.Errorf("highlight with id %v not found", id).Error()})
+	}
+	if err != nil {
+		return err
+	}
+
+	return u.WriteJSON(w, http.StatusOK, h)
+}
+
+func (s *Handler) handleAddHighlightTags(w http.ResponseWriter, r *http.Request) error {
+	userID, err := u.GetStringParamFromRequest(r, "userID")
+	if err != nil {
+		return err
+	}
+	oUserID, _ := primitive.ObjectIDFromHex(string(userID))
+
+	id, err := u.GetStringParamFromRequest(r, "id")
+	if err != nil {
+		return err
+	}
+	oID, _ := primitive.ObjectIDFromHex(string(id))
+
+	payload := new(TagsRequest)
+	if err := json.NewDecoder(r.Body).Decode(payload); err != nil {
+		return u.WriteJSON(w, http.StatusBadRequest, t.APIError{Error: err.Error()})
+	}
+
+	if len(u.NormalizeTags(payload.Tags)) == 0 {
+		return u.WriteJSON(w, http.StatusBadRequest, t.APIError{Error: fmt.Errorf("at least one tag is required").Error()})
+	}
+
+	if _, err := s.store.AddTags(r.Context(), oUserID, []primitive.ObjectID{oID}, payload.Tags); err != nil {
+		return err
+	}
+
+	return s.writeHighlight(w, r, oID, oUserID)
+}
+
+func (s *Handler) handleRemoveHighlightTag(w http.ResponseWriter, r *http.Request) error {
+	userID, err := u.GetStringParamFromRequest(r, "userID")
+	if err != nil {
+		return err
+	}
+	oUserID, _ := primitive.ObjectIDFromHex(string(userID))
+
+	id, err := u.GetStringParamFromRequest(r, "id")
+	if err != nil {
+		return err
+	}
+	oID, _ := primitive.ObjectIDFromHex(string(id))
+
+	tag, err := u.GetStringParamFromRequest(r, "tag")
+	if err != nil {
+		return err
+	}
+
+	if _, err := s.store.RemoveTags(r.Context(), oUserID, []primitive.ObjectID{oID}, []string{tag}); err != nil {
+		return err
+	}
+
+	return s.writeHighlight(w, r, oID, oUserID)
+}
+
+func (s *Handler) handleAcceptSuggestedTags(w http.ResponseWriter, r *http.Request) error {
+	return s.resolveSuggestedTags(w, r, s.store.AcceptSuggestedTags)
+}
+
+func (s *Handler) handleRejectSuggestedTags(w http.ResponseWriter, r *http.Request) error {
+	return s.resolveSuggestedTags(w, r, s.store.RejectSuggestedTags)
+}
+
+// resolveSuggestedTags accepts or rejects the tags in the body, or all of the
+// suggested tags without a body
+func (s *Handler) resolveSuggestedTags(
+	w http.ResponseWriter,
+	r *http.Request,
+	resolve func(context.Context, primitive.ObjectID, primitive.ObjectID, []string) (*t.Highlight, error),
+) error {
+	userID, err := u.GetStringParamFromRequest(r, "userID")
+	if err != nil {
+		return err
+	}
+	oUserID, _ := primitive.ObjectIDFromHex(userID)
+
+	id, err := u.GetStringParamFromRequest(r, "id")
+	if err != nil {
+		return err
+	}
+	oID, _ := primitive.ObjectIDFromHex(id)
+
+	payload := new(TagsRequest)
+	if err := json.NewDecoder(r.Body).Decode(payload); err != nil && !errors.Is(err, io.EOF) {
+		return u.WriteJSON(w, http.StatusBadRequest, t.APIError{Error: err.Error()})
+	}
+
+	h, err := resolve(r.Context(), oID, oUserID, payload.Tags)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return u.WriteJSON(w, http.StatusNotFound, t.APIError{Error: fmt.Errorf("highlight with id %v not found", id).Error()})
+	}
+	if err != nil {
+		return err
+	}
+
+	return u.WriteJSON(w, http.StatusOK, h)
+}
+
+func (s *Handler) handleFavoriteHighlight(w http.ResponseWriter, r *http.Request) error {
+	return s.setFavorite(w, r, true)
+}
+
+func (s *Handler) handleUnfavoriteHighlight(w http.ResponseWriter, r *http.Request) error {
+	return s.setFavorite(w, r, false)
+}
+
+func (s *Handler) setFavorite(w http.ResponseWriter, r *http.Request, favorite bool) error {
+	userID, err := u.GetStringParamFromRequest(r, "userID")
+	if err != nil {
+		return err
+	}
+	oUserID, _ := primitive.ObjectIDFromHex(userID)
+
+	id, err := u.GetStringParamFromRequest(r, "id")
+	if err != nil {
+		return err
+	}
+	oID, _ := primitive.ObjectIDFromHex(id)
+
+	h, err := s.store.SetFavorite(r.Context(), oID, oUserID, favorite)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return u.WriteJSON(w, http.StatusNotFound, t.APIError{Error: fmt.Errorf("highlight with id %v not found", id).Error()})
+	}
+	if err != nil {
+		return err
+	}
+
+	return u.WriteJSON(w, http.StatusOK, h)
+}
+
+func (s *Handler) handleRateHighlight(w http.ResponseWriter, r *http.Request) error {
+	payload := new(RatingRequest)
+	if err := json.NewDecoder(r.Body).Decode(payload); err != nil {
+		return u.WriteJSON(w, http.StatusBadRequest, t.APIError{Error: err.Error()})
+	}
+
+	if payload.Rating < MinRating || payload.Rating > MaxRating {
+		return u.WriteJSON(w, http.StatusBadRequest, t.APIError{Error: fmt.Errorf("rating must be between %d and %d", MinRating, MaxRating).Error()})
+	}
+
+	return s.setRating(w, r, payload.Rating)
+}
+
+func (s *Handler) handleClearHighlightRating(w http.ResponseWriter, r *http.Request) error {
+	return s.setRating(w, r, 0)
+}
+
+func (s *Handler) setRating(w http.ResponseWriter, r *http.Request, rating int) error {
+	userID, err := u.GetStringParamFromRequest(r, "userID")
+	if err != nil {
+		return err
+	}
+	oUserID, _ := primitive.ObjectIDFromHex(userID)
+
+	id, err := u.GetStringParamFromRequest(r, "id")
+	if err != nil {
+		return err
+	}
+	oID, _ := primitive.ObjectIDFromHex(id)
+
+	h, err := s.store.SetRating(r.Context(), oID, oUserID, rating)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return u.WriteJSON(w, http.StatusNotFound, t.APIError{Error: fmt.Errorf("highlight with id %v not found", id).Error()})
+	}
+	if err != nil {
+		return err
+	}
+
+	return u.WriteJSON(w, http.StatusOK, h)
+}
+
+func (s *Handler) handleGetUserTags(w http.ResponseWriter, r *http.Request) error {
+	userID, err := u.GetStringParamFromRequest(r, "userID")
+	if err != nil {
+		return err
+	}
+	oUserID, _ := primitive.ObjectIDFromHex(string(userID))
+
+	tags, err := s.store.GetUserTags(r.Context(), oUserID)
+	if err != nil {
+		return err
+	}
+
+	return u.WriteJSON(w, http.StatusOK, tags)
+}
+
+func (s *Handler) handleBulkTags(w http.ResponseWriter, r *http.Request) error {
+	userID, err := u.GetStringParamFromRequest(r, "userID")
+	if err != nil {
+		return err
+	}
+	oUserID, _ := primitive.ObjectIDFromHex(string(userID))
+
+	payload := new(BulkTagsRequest)
+	if err := json.NewDecoder(r.Body).Decode(payload); err != nil {
+		return u.WriteJSON(w, http.StatusBadRequest, t.APIError{Error: err.Error()})
+	}
+
+	ids, err := u.ParseObjectIDs(payload.HighlightIDs)
+	if err != nil {
+		return u.WriteJSON(w, http.StatusBadRequest, t.APIError{Error: err.Error()})
+	}
+
+	if len(ids) == 0 || (len(payload.Add) == 0 && len(payload.Remove) == 0) {
+		return u.WriteJSON(w, http.StatusBadRequest, t.APIError{Error: fmt.Errorf("highlight ids and tags to add or remove are required").Error()})
+	}
+
+	var modified int64
+	if len(payload.Add) > 0 {
+		n, err := s.store.AddTags(r.Context(), oUserID, ids, payload.Add)
+		if err != nil {
+			return err
+		}
+		modified += n
+	}
+
+	if len(payload.Remove) > 0 {
+		n, err := s.store.RemoveTags(r.Context(), oUserID, ids, payload.Remove)
+		if err != nil {
+			return err
+		}
+		modified += n
+	}
+
+	return u.WriteJSON(w, http.StatusOK, TagsUpdatedResponse{Modified: modified})
+}
+
+func (s *Handler) handleRenameTag(w http.ResponseWriter, r *http.Request) error {
+	userID, err := u.GetStringParamFromRequest(r, "userID")
+	if err != nil {
+		return err
+	}
+	oUserID, _ := primitive.ObjectIDFromHex(string(userID))
+
+	tag, err := u.GetStringParamFromRequest(r, "tag")
+	if err != nil {
+		return err
+	}
+
+	payload := new(RenameTagRequest)
+	if err := json.NewDecoder(r.Body).Decode(payload); err != nil {
+		return u.WriteJSON(w, http.StatusBadRequest, t.APIError{Error: err.Error()})
+	}
+
+	if u.NormalizeTag(payload.Name) == "" {
+		return u.WriteJSON(w, http.StatusBadRequest, t.APIError{Error: fmt.Errorf("name is required").Error()})
+	}
+
+	// Renaming to a tag that already exists merges both
+	modified, err := s.store.MergeTags(r.Context(), oUserID, []string{tag}, payload.Name)
+	if err != nil {
+		return err
+	}
+
+	return u.WriteJSON(w, http.StatusOK, TagsUpdatedResponse{Modified: modified})
+}
+
+func (s *Handler) handleMergeTags(w http.ResponseWriter, r *http.Request) error {
+	userID, err := u.GetStringParamFromRequest(r, "userID")
+	if err != nil {
+		return err
+	}
+	oUserID, _ := primitive.ObjectIDFromHex(string(userID))
+
+	payload := new(MergeTagsRequest)
+	if err := json.NewDecoder(r.Body).Decode(payload); err != nil {
+		return u.WriteJSON(w, http.StatusBadRequest, t.APIError{Error: err.Error()})
+	}
+
+	if len(u.NormalizeTags(payload.Tags)) == 0 || u.NormalizeTag(payload.Into) == "" {
+		return u.WriteJSON(w, http.StatusBadRequest, t.APIError{Error: fmt.Errorf("tags and into are required").Error()})
+	}
+
+	modified, err := s.store.MergeTags(r.Context(), oUserID, payload.Tags, payload.Into)
+	if err != nil {
+		return err
+	}
+
+	return u.WriteJSON(w, http.StatusOK, TagsUpdatedResponse{Modified: modified})
+}
+
+// writeHighlight responds with the current state of a highlight, or a 404 if
+// the user does not own it
+func (s *Handler) writeHighlight(w http.ResponseWriter, r *http.Request, id, userID primitive.ObjectID) error {
+	h, err := s.store.GetHighlightByID(r.Context(), id, userID)
+	if errors.Is(err, mongo.ErrNoDocuments) || (err == nil && h == nil) {
+		return u.WriteJSON(w, http.StatusNotFound, t.APIError{Error: fmt.Errorf("highlight with id %v not found", id.Hex()).Error()})
+	}
+	if err != nil {
+		return err
+	}
+
+	return u.WriteJSON(w, http.StatusOK, h)
+}
+
+func (s *Handler) handleCreateHighlight(w http.ResponseWriter, r *http.Request) error {
+	payload := new(CreateHighlightRequest)
+	if err := json.NewDecoder(r.Body).Decode(payload); err != nil {
+		return err
+	}
+
+	oID, _ := primitive.ObjectIDFromHex(string(payload.UserId))
+
+	highlight := &t.CreateHighlightRequest{
+		Text:     payload.Text,
+		Location: payload.Location,
+		Note:     payload.Note,
+		UserID:   oID,
+		BookID:   payload.BookId,
+		Tags:     payload.Tags,
+	}
+
+	if _, err := s.store.CreateHighlight(r.Context(), highlight); err != nil {
+		return err
+	}
+
+	// Highlighting a known book puts it in the user's library
+	books, err := s.bookStore.GetByISBNs(r.Context(), []string{highlight.BookID})
+	if err != nil {
+		return err
+	}
+	for _, b := range books {
+		if _, err := s.libraryStore.AddBook(r.Context(), oID, b.ID); err != nil {
+			return err
+		}
+	}
+
+	return u.WriteJSON(w, http.StatusOK, highlight)
+
+}
+
+type CreateHighlightRequest struct {
+	Text     string   `json:"text"`
+	Location string   `json:"location"`
+	Note     string   `json:"note"`
+	UserId   string   `json:"userId"`
+	BookId   string   `json:"bookId"`
+	Tags     []string `json:"tags"`
+}
+
+type TagsRequest struct {
+	Tags []string `json:"tags"`
+}
+
+type BulkHighlightsRequest struct {
+	HighlightIDs []string    `json:"highlightIds"`
+	Filter       *BulkFilter `json:"filter"`
+	Action       string      `json:"action"`
+	Tags         []string    `json:"tags"`
+	BookID       string      `json:"bookId"`
+	CollectionID string      `json:"collectionId"`
+}
+
+// BulkFilter selects highlights like the listing query parameters do
+type BulkFilter struct {
+	BookID    string     `json:"book"`
+	Tag       string     `json:"tag"`
+	From      *time.Time `json:"from"`
+	To        *time.Time `json:"to"`
+	HasNote   *bool      `json:"hasNote"`
+	Favorite  *bool      `json:"favorite"`
+	MinRating int        `json:"minRating"`
+}
+
+func (f *BulkFilter) highlightFilter() *t.HighlightFilter {
+	return &t.HighlightFilter{
+		BookID:        f.BookID,
+		Tag:           f.Tag,
+		CreatedAfter:  f.From,
+		CreatedBefore: f.To,
+		HasNote:       f.HasNote,
+		Favorite:      f.Favorite,
+		MinRating:     f.MinRating,
+	}
+}
+
+type BulkHighlightsResponse struct {
+	Succeeded int                 `json:"succeeded"`
+	Failed    int                 `json:"failed"`
+	Results   []*t.BulkItemResult `json:"results"`
+}
+
+type RatingRequest struct {
+	Rating int `json:"rating"`
+}
+
+type MergeHighlightsRequest struct {
+	HighlightIDs []string `json:"highlightIds"`
+}
+
+type BulkTagsRequest struct {
+	HighlightIDs []string `json:"highlightIds"`
+	Add          []string `json:"add"`
+	Remove       []string `json:"remove"`
+}
+
+type RenameTagRequest struct {
+	Name string `json:"name"`
+}
+
+type MergeTagsRequest struct {
+	Tags []string `json:"tags"`
+	Into string   `json:"into"`
+}
+
+type TagsUpdatedResponse struct {
+	Modified int64 `json:"modified"`
+}
+
+func validateUpdateHighlightRequest(req *t.UpdateHighlightRequest) error {
+	if req.Text == nil && req.Location == nil && req.Note == nil && req.BookID == nil {
+		return fmt.Errorf("nothing to update")
+	}
+
+	if req.Text != nil && *req.Text == "" {
+		return fmt.Errorf("text cannot be empty")
+	}
+
+	if req.BookID != nil && *req.BookID == "" {
+		return fmt.Errorf("bookId cannot be empty")
+	}
+
+	return nil
+}
+
+type ParseKindleFileRequest struct {
+	File multipart.File `json:"file"`
+}
+
+func buildInsights(hs []*t.Highlight, bookStore t.BookStore) ([]*t.DailyInsight, error) {
+	var insights []*t.DailyInsight
+
+	for _, h := range hs {
+		insight := &t.DailyInsight{
+			Text: h.Text,
+			Note: h.Note,
+		}
+
+		// A highlight whose book is gone is still worth sending
+		book, err := bookStore.GetByIdentifier(context.Background(), h.BookID)
+		var notFound *t.BookNotFoundError
+		switch {
+		case errors.As(err, &notFound):
+			log.Println("Sending insight without its book: ", err)
+		case err != nil:
+			log.Println("Error getting book: ", err)
+			return nil, err
+		default:
+			insight.BookAuthors = book.Authors
+			insight.BookTitle = book.Title
+			if url := cover.URL(book, true); url != "" {
+				insight.BookCoverURL = config.Envs.PublicURL + url
+			}
+		}
+
+		insights = append(insights, insight)
+	}
+
+	return insights, nil
+}
+
+// buildNoteInsights sends a note's body on its own, it isn't quoting the book
+func buildNoteInsights(ctx context.Context, notes []*t.Note, bookStore t.BookStore) ([]*t.DailyInsight, error) {
+	var insights []*t.DailyInsight
+
+	for _, n := range notes {
+		insight := &t.DailyInsight{Note: n.Body}
+
+		if n.BookID != nil {
+			book, err := bookStore.GetByID(ctx, *n.BookID)
+			switch {
+			case errors.Is(err, mongo.ErrNoDocuments):
+				log.Println("Sending note insight without its book: ", err)
+			case err != nil:
+				return nil, err
+			default:
+				insight.BookAuthors = book.Authors
+				insight.BookTitle = book.Title
+				if url := cover.URL(book, true); url != "" {
+					insight.BookCoverURL = config.Envs.PublicURL + url
+				}
+			}
+		}
+
+		insights = append(insights, insight)
+	}
+
+	return insights, nil
+}
+
+func (s *Handler) createDataFromRawBook(raw *t.RawExtractBook, userID string) error {
+	oID, _ := primitive.ObjectIDFromHex(string(userID))
+
+	// Create book, or use the one another user already imported
+	book, err := s.bookStore.GetOrCreate(context.Background(), &t.CreateBookRequest{
+		ISBN:    raw.ASIN,
+		Title:   raw.Title,
+		Authors: raw.Authors,
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := author.LinkBook(context.Background(), s.authorStore, s.bookStore, book); err != nil {
+		return err
+	}
+
+	entry, err := s.libraryStore.AddBook(context.Background(), oID, book.ID)
+	if err != nil {
+		return err
+	}
+
+	// Create highlights
+	hs := make([]*t.CreateHighlightRequest, len(raw.Highlights))
+	for i, h := range raw.Highlights {
+		hs[i] = &t.CreateHighlightRequest{
+			Text:     h.Text,
+			Location: h.Location.URL,
+			Position: h.Location.Value,
+			Note:     h.Note,
+			UserID:   oID,
+			BookID:   book.ISBN, // The ASIN may have matched the book under another form
+		}
+	}
+
+	for _, h := range hs {
+		_, err := s.store.CreateHighlight(context.Background(), h)
+		if err != nil {
+			return err
+		}
+	}
+
+	// The furthest highlight is as far as the user got, at least
+	furthest := 0
+	for _, h := range hs {
+		furthest = max(furthest, h.Position)
+	}
+
+	if furthest == 0 {
+		return nil
+	}
+
+	progress := reading.FromLocation(book, furthest, t.ProgressSourceHighlights)
+	if reading.Ahead(entry.Progress, progress) {
+		if _, err := s.libraryStore.SetProgress(context.Background(), oID, book.ID, progress); err != nil {
 			return err
 		}
 	}
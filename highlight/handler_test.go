@@ -7,24 +7,31 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/sikozonpc/notebase/storage"
 	types "github.com/sikozonpc/notebase/types"
 	u "github.com/sikozonpc/notebase/utils"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
 var fakeHighlight *types.Highlight
 
+// The highlights returned when listing, for the handlers needing the library
+var fakeLibrary []*types.Highlight
+
 func TestHandleUserHighlights(t *testing.T) {
 	memStore := storage.NewMemoryStorage()
+	memStore.Write("file.json", []byte(storage.SampleKindleExtract))
 	bookStore := &mockBookStore{}
 	mockMailer := &mockMailer{}
 
 	store := &mockHighlightStore{}
 	userStore := &mockUserStore{}
-	handler := NewHandler(store, userStore, memStore, bookStore, mockMailer)
+	collectionStore := &mockCollectionStore{}
+	handler := NewHandler(store, userStore, memStore, bookStore, &mockLibraryStore{}, &mockAuthorStore{}, collectionStore, &mockNoteStore{}, &mockLinkStore{}, mockMailer)
 
 	t.Run("should handle get user highlights", func(t *testing.T) {
 		req, err := http.NewRequest(http.MethodGet, "/user/1/highlight", nil)
@@ -42,6 +49,34 @@ func TestHandleUserHighlights(t *testing.T) {
 		if rr.Code != http.StatusOK {
 			t.Errorf("expected status code %d, got %d", http.StatusOK, rr.Code)
 		}
+
+		if rr.Header().Get("X-Total-Count") != "42" {
+			t.Errorf("expected total count header to be %s, got %s", "42", rr.Header().Get("X-Total-Count"))
+		}
+
+		if rr.Header().Get("X-Next-Cursor") != "next" {
+			t.Errorf("expected next cursor header to be %s, got %s", "next", rr.Header().Get("X-Next-Cursor"))
+		}
+	})
+
+	t.Run("should fail to list highlights with invalid filters", func(t *testing.T) {
+		for _, query := range []string{"limit=0", "limit=1000", "hasNote=maybe", "from=yesterday", "order=up", "sort=random"} {
+			req, err := http.NewRequest(http.MethodGet, "/user/1/highlight?"+query, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rr := httptest.NewRecorder()
+			router := mux.NewRouter()
+
+			router.HandleFunc("/user/{userID}/highlight", u.MakeHTTPHandler(handler.handleGetUserHighlights))
+
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusBadRequest {
+				t.Errorf("%s: expected status code %d, got %d", query, http.StatusBadRequest, rr.Code)
+			}
+		}
 	})
 
 	t.Run("should fail to handle get highlight by ID if highlight does not exist", func(t *testing.T) {
@@ -139,6 +174,309 @@ func TestHandleUserHighlights(t *testing.T) {
 		}
 	})
 
+	t.Run("should handle update highlight", func(t *testing.T) {
+		fakeHighlight = &types.Highlight{
+			ID:     primitive.NewObjectID(),
+			Text:   "test",
+			Note:   "test",
+			UserID: primitive.NewObjectID(),
+		}
+
+		req, err := http.NewRequest(http.MethodPatch, "/user/"+fakeHighlight.UserID.Hex()+"/highlight/"+fakeHighlight.ID.Hex(), bytes.NewBufferString(`{"note": "updated note"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		router := mux.NewRouter()
+
+		router.HandleFunc("/user/{userID}/highlight/{id}", u.MakeHTTPHandler(handler.handleUpdateHighlight)).Methods(http.MethodPatch)
+
+		router.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Errorf("expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		var response types.Highlight
+		if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+			t.Fatal(err)
+		}
+
+		if response.Note != "updated note" {
+			t.Errorf("expected note to be %s, got %s", "updated note", response.Note)
+		}
+
+		if response.Text != "test" {
+			t.Errorf("expected text to be unchanged, got %s", response.Text)
+		}
+	})
+
+	t.Run("should move a highlight only to a book that exists", func(t *testing.T) {
+		fakeHighlight = &types.Highlight{ID: primitive.NewObjectID(), Text: "test", BookID: "B01"}
+
+		for body, code := range map[string]int{
+			`{"bookId": "B02"}`:     http.StatusOK,
+			`{"bookId": "missing"}`: http.StatusNotFound,
+			`{"bookId": ""}`:        http.StatusBadRequest,
+		} {
+			req, err := http.NewRequest(http.MethodPatch, "/user/1/highlight/1", bytes.NewBufferString(body))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rr := httptest.NewRecorder()
+			router := mux.NewRouter()
+
+			router.HandleFunc("/user/{userID}/highlight/{id}", u.MakeHTTPHandler(handler.handleUpdateHighlight)).Methods(http.MethodPatch)
+
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != code {
+				t.Errorf("expected status code %d for %s, got %d", code, body, rr.Code)
+			}
+		}
+	})
+
+	t.Run("should fail to update a highlight with an empty payload", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPatch, "/user/1/highlight/1", bytes.NewBufferString(`{}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		router := mux.NewRouter()
+
+		router.HandleFunc("/user/{userID}/highlight/{id}", u.MakeHTTPHandler(handler.handleUpdateHighlight)).Methods(http.MethodPatch)
+
+		router.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+
+	t.Run("should fail to update a highlight that is not owned by the user", func(t *testing.T) {
+		owner := primitive.NewObjectID()
+		fakeHighlight = &types.Highlight{ID: primitive.NewObjectID(), UserID: owner, Text: "mine"}
+
+		patch := func(userID primitive.ObjectID) *httptest.ResponseRecorder {
+			req, err := http.NewRequest(http.MethodPatch, "/user/"+userID.Hex()+"/highlight/"+fakeHighlight.ID.Hex(), bytes.NewBufferString(`{"text": "hijacked"}`))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rr := httptest.NewRecorder()
+			router := mux.NewRouter()
+
+			router.HandleFunc("/user/{userID}/highlight/{id}", u.MakeHTTPHandler(handler.handleUpdateHighlight)).Methods(http.MethodPatch)
+
+			router.ServeHTTP(rr, req)
+
+			return rr
+		}
+
+		if rr := patch(primitive.NewObjectID()); rr.Code != http.StatusNotFound {
+			t.Errorf("expected status code %d for another user, got %d", http.StatusNotFound, rr.Code)
+		}
+
+		if rr := patch(owner); rr.Code != http.StatusOK {
+			t.Errorf("expected status code %d for the owner, got %d", http.StatusOK, rr.Code)
+		}
+	})
+
+	t.Run("should find the related highlights of one older than the latest ones", func(t *testing.T) {
+		fakeHighlight = &types.Highlight{ID: primitive.NewObjectID(), Text: "deep work needs long hours of focus"}
+		similar := &types.Highlight{ID: primitive.NewObjectID(), Text: "focus on deep work for hours"}
+		other := &types.Highlight{ID: primitive.NewObjectID(), Text: "a recipe for bread"}
+		fakeLibrary = []*types.Highlight{similar, other}
+		defer func() { fakeLibrary = nil }()
+
+		req, err := http.NewRequest(http.MethodGet, "/user/1/highlight/1/related", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		router := mux.NewRouter()
+
+		router.HandleFunc("/user/{userID}/highlight/{id}/related", u.MakeHTTPHandler(handler.handleGetRelatedHighlights)).Methods(http.MethodGet)
+
+		router.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		var related []*types.RelatedHighlight
+		if err := json.NewDecoder(rr.Body).Decode(&related); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(related) != 1 || related[0].Highlight.ID != similar.ID {
+			t.Errorf("expected the similar highlight to be related, got %d highlights", len(related))
+		}
+	})
+
+	t.Run("should handle get highlight revisions", func(t *testing.T) {
+		fakeHighlight = &types.Highlight{ID: primitive.NewObjectID(), Text: "test"}
+
+		req, err := http.NewRequest(http.MethodGet, "/user/1/highlight/1/revisions", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		router := mux.NewRouter()
+
+		router.HandleFunc("/user/{userID}/highlight/{id}/revisions", u.MakeHTTPHandler(handler.handleGetHighlightRevisions)).Methods(http.MethodGet)
+
+		router.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Errorf("expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+	})
+
+	t.Run("should revert a highlight to a previous revision", func(t *testing.T) {
+		fakeHighlight = &types.Highlight{ID: primitive.NewObjectID(), Text: "edited text", Note: "edited note"}
+
+		req, err := http.NewRequest(http.MethodPost, "/user/1/highlight/1/revisions/1/revert", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		router := mux.NewRouter()
+
+		router.HandleFunc("/user/{userID}/highlight/{id}/revisions/{version}/revert", u.MakeHTTPHandler(handler.handleRevertHighlight)).Methods(http.MethodPost)
+
+		router.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Errorf("expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		var response types.Highlight
+		if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+			t.Fatal(err)
+		}
+
+		if response.Text != "original text" || response.Note != "original note" {
+			t.Errorf("expected highlight to be reverted, got %+v", response)
+		}
+	})
+
+	t.Run("should fail to revert to a revision that does not exist", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, "/user/1/highlight/1/revisions/7/revert", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		router := mux.NewRouter()
+
+		router.HandleFunc("/user/{userID}/highlight/{id}/revisions/{version}/revert", u.MakeHTTPHandler(handler.handleRevertHighlight)).Methods(http.MethodPost)
+
+		router.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("expected status code %d, got %d", http.StatusNotFound, rr.Code)
+		}
+	})
+
+	t.Run("should handle add tags to a highlight", func(t *testing.T) {
+		fakeHighlight = &types.Highlight{ID: primitive.NewObjectID(), Text: "test", Tags: []string{"go"}}
+
+		req, err := http.NewRequest(http.MethodPost, "/user/1/highlight/1/tags", bytes.NewBufferString(`{"tags": ["Go"]}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		router := mux.NewRouter()
+
+		router.HandleFunc("/user/{userID}/highlight/{id}/tags", u.MakeHTTPHandler(handler.handleAddHighlightTags)).Methods(http.MethodPost)
+
+		router.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Errorf("expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+	})
+
+	t.Run("should fail to add empty tags to a highlight", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, "/user/1/highlight/1/tags", bytes.NewBufferString(`{"tags": ["  "]}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		router := mux.NewRouter()
+
+		router.HandleFunc("/user/{userID}/highlight/{id}/tags", u.MakeHTTPHandler(handler.handleAddHighlightTags)).Methods(http.MethodPost)
+
+		router.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+
+	t.Run("should handle get user tags", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/user/1/tags", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		router := mux.NewRouter()
+
+		router.HandleFunc("/user/{userID}/tags", u.MakeHTTPHandler(handler.handleGetUserTags)).Methods(http.MethodGet)
+
+		router.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Errorf("expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+	})
+
+	t.Run("should handle rename tag", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPut, "/user/1/tags/golang", bytes.NewBufferString(`{"name": "go"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		router := mux.NewRouter()
+
+		router.HandleFunc("/user/{userID}/tags/{tag}", u.MakeHTTPHandler(handler.handleRenameTag)).Methods(http.MethodPut)
+
+		router.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Errorf("expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+	})
+
+	t.Run("should fail bulk tagging with an invalid highlight id", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, "/user/1/tags/bulk", bytes.NewBufferString(`{"highlightIds": ["nope"], "add": ["go"]}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		router := mux.NewRouter()
+
+		router.HandleFunc("/user/{userID}/tags/bulk", u.MakeHTTPHandler(handler.handleBulkTags)).Methods(http.MethodPost)
+
+		router.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+
 	t.Run("should handle delete highlight", func(t *testing.T) {
 		req, err := http.NewRequest(http.MethodDelete, "/user/1/highlight/1", nil)
 		if err != nil {
@@ -186,18 +524,177 @@ func (m *mockHighlightStore) GetHighlightByID(context.Context, primitive.ObjectI
 	return fakeHighlight, nil
 }
 
-func (m *mockHighlightStore) GetUserHighlights(context.Context, primitive.ObjectID) ([]*types.Highlight, error) {
-	return []*types.Highlight{}, nil
+func (m *mockHighlightStore) GetUserHighlights(_ context.Context, _ primitive.ObjectID, filter *types.HighlightFilter) (*types.HighlightPage, error) {
+	if filter != nil && filter.Sort != "" && filter.Sort != types.HighlightSortCreated {
+		return nil, ErrInvalidFilter
+	}
+
+	return &types.HighlightPage{Highlights: fakeLibrary, Total: 42, NextCursor: "next"}, nil
 }
 
-func (m *mockHighlightStore) DeleteHighlight(context.Context, primitive.ObjectID) error {
+func (m *mockHighlightStore) UpdateHighlight(_ context.Context, _ primitive.ObjectID, userID primitive.ObjectID, req *types.UpdateHighlightRequest) (*types.Highlight, error) {
+	// Like the store, only the owner's highlight matches
+	if fakeHighlight == nil || fakeHighlight.UserID != userID {
+		return nil, mongo.ErrNoDocuments
+	}
+
+	h := *fakeHighlight
+	if req.Text != nil {
+		h.Text = *req.Text
+	}
+	if req.Note != nil {
+		h.Note = *req.Note
+	}
+
+	return &h, nil
+}
+
+func (m *mockHighlightStore) GetHighlightRevisions(context.Context, primitive.ObjectID, primitive.ObjectID) ([]*types.HighlightRevision, error) {
+	return []*types.HighlightRevision{}, nil
+}
+
+func (m *mockHighlightStore) GetHighlightRevision(_ context.Context, id primitive.ObjectID, _ primitive.ObjectID, version int) (*types.HighlightRevision, error) {
+	if version != 1 {
+		return nil, mongo.ErrNoDocuments
+	}
+
+	return &types.HighlightRevision{
+		HighlightID: id,
+		Version:     version,
+		Snapshot: types.HighlightSnapshot{
+			Text: "original text",
+			Note: "original note",
+		},
+	}, nil
+}
+
+func (m *mockHighlightStore) DeleteHighlight(context.Context, primitive.ObjectID, primitive.ObjectID) error {
 	return nil
 }
 
-func (m *mockHighlightStore) GetRandomHighlights(context.Context, primitive.ObjectID, int) ([]*types.Highlight, error) {
+func (m *mockHighlightStore) DeleteBookHighlights(context.Context, primitive.ObjectID, string) (int64, error) {
+	return 0, nil
+}
+
+func (m *mockHighlightStore) GetDeletedHighlights(context.Context, primitive.ObjectID) ([]*types.Highlight, error) {
 	return []*types.Highlight{}, nil
 }
 
+func (m *mockHighlightStore) RestoreHighlight(context.Context, primitive.ObjectID, primitive.ObjectID) (*types.Highlight, error) {
+	return fakeHighlight, nil
+}
+
+func (m *mockHighlightStore) RestoreBookHighlights(context.Context, primitive.ObjectID, string) (int64, error) {
+	return 0, nil
+}
+
+func (m *mockHighlightStore) PurgeDeletedHighlights(context.Context, time.Time) ([]primitive.ObjectID, error) {
+	return []primitive.ObjectID{}, nil
+}
+
+func (m *mockHighlightStore) GetRandomHighlights(context.Context, primitive.ObjectID, int, *types.HighlightFilter) ([]*types.Highlight, error) {
+	return []*types.Highlight{}, nil
+}
+
+func (m *mockHighlightStore) SetFavorite(_ context.Context, _ primitive.ObjectID, _ primitive.ObjectID, favorite bool) (*types.Highlight, error) {
+	if fakeHighlight == nil {
+		return nil, mongo.ErrNoDocuments
+	}
+
+	h := *fakeHighlight
+	h.Favorite = favorite
+
+	return &h, nil
+}
+
+func (m *mockHighlightStore) SetRating(_ context.Context, _ primitive.ObjectID, _ primitive.ObjectID, rating int) (*types.Highlight, error) {
+	if fakeHighlight == nil {
+		return nil, mongo.ErrNoDocuments
+	}
+
+	h := *fakeHighlight
+	h.Rating = rating
+
+	return &h, nil
+}
+
+func (m *mockHighlightStore) BulkUpdate(_ context.Context, _ primitive.ObjectID, ids []primitive.ObjectID, op *types.BulkOperation) ([]*types.BulkItemResult, error) {
+	if _, err := bulkUpdateDocument(op); err != nil {
+		return nil, err
+	}
+
+	results := make([]*types.BulkItemResult, len(ids))
+	for i, id := range ids {
+		results[i] = &types.BulkItemResult{ID: id, Status: types.BulkStatusNotFound}
+		for _, h := range fakeLibrary {
+			if h.ID == id {
+				results[i].Status = types.BulkStatusOK
+			}
+		}
+	}
+
+	return results, nil
+}
+
+func (m *mockHighlightStore) GetUserBookStats(context.Context, primitive.ObjectID) ([]*types.BookHighlightStats, error) {
+	return []*types.BookHighlightStats{}, nil
+}
+
+func (m *mockHighlightStore) AddTags(context.Context, primitive.ObjectID, []primitive.ObjectID, []string) (int64, error) {
+	return 1, nil
+}
+
+func (m *mockHighlightStore) RemoveTags(context.Context, primitive.ObjectID, []primitive.ObjectID, []string) (int64, error) {
+	return 1, nil
+}
+
+func (m *mockHighlightStore) GetUserTags(context.Context, primitive.ObjectID) ([]*types.TagCount, error) {
+	return []*types.TagCount{{Tag: "go", Count: 2}}, nil
+}
+
+func (m *mockHighlightStore) MergeTags(context.Context, primitive.ObjectID, []string, string) (int64, error) {
+	return 2, nil
+}
+
+func (m *mockHighlightStore) SetSuggestedTags(context.Context, primitive.ObjectID, map[primitive.ObjectID][]string) error {
+	return nil
+}
+
+func (m *mockHighlightStore) AcceptSuggestedTags(_ context.Context, _ primitive.ObjectID, _ primitive.ObjectID, tags []string) (*types.Highlight, error) {
+	if fakeHighlight == nil {
+		return nil, mongo.ErrNoDocuments
+	}
+
+	h := *fakeHighlight
+	if len(tags) == 0 {
+		tags = h.SuggestedTags
+	}
+	h.Tags = append(h.Tags, tags...)
+	h.SuggestedTags = nil
+
+	return &h, nil
+}
+
+func (m *mockHighlightStore) RejectSuggestedTags(context.Context, primitive.ObjectID, primitive.ObjectID, []string) (*types.Highlight, error) {
+	if fakeHighlight == nil {
+		return nil, mongo.ErrNoDocuments
+	}
+
+	return fakeHighlight, nil
+}
+
+func (m *mockHighlightStore) MergeHighlights(_ context.Context, _ primitive.ObjectID, _ primitive.ObjectID, others []primitive.ObjectID) (*types.Highlight, error) {
+	if fakeHighlight == nil || len(others) > len(fakeLibrary) {
+		return nil, mongo.ErrNoDocuments
+	}
+
+	return fakeHighlight, nil
+}
+
+func (m *mockHighlightStore) MoveBookHighlights(context.Context, *primitive.ObjectID, []string, string) (int64, error) {
+	return 0, nil
+}
+
 type mockUserStore struct{}
 
 func (m *mockUserStore) Create(context.Context, types.RegisterRequest) (primitive.ObjectID, error) {
@@ -222,14 +719,178 @@ func (m *mockUserStore) UpdateUser(context.Context, types.User) error {
 
 type mockBookStore struct{}
 
-func (m *mockBookStore) GetByISBN(context.Context, string) (*types.Book, error) {
-	return &types.Book{}, nil
+func (m *mockBookStore) GetByIdentifier(_ context.Context, identifier string) (*types.Book, error) {
+	if identifier == "missing" {
+		return nil, &types.BookNotFoundError{Identifier: identifier}
+	}
+
+	return &types.Book{ISBN: identifier}, nil
 }
 
 func (m *mockBookStore) Create(context.Context, *types.CreateBookRequest) (primitive.ObjectID, error) {
 	return primitive.NilObjectID, nil
 }
 
+func (m *mockBookStore) GetByID(context.Context, primitive.ObjectID) (*types.Book, error) {
+	return &types.Book{}, nil
+}
+
+func (m *mockBookStore) GetByISBNs(context.Context, []string) ([]*types.Book, error) {
+	return []*types.Book{}, nil
+}
+
+func (m *mockBookStore) GetByIDs(context.Context, []primitive.ObjectID) ([]*types.Book, error) {
+	return []*types.Book{}, nil
+}
+
+func (m *mockBookStore) GetOrCreate(_ context.Context, req *types.CreateBookRequest) (*types.Book, error) {
+	return &types.Book{ID: primitive.NewObjectID(), ISBN: req.ISBN, Title: req.Title, Authors: req.Authors}, nil
+}
+
+func (m *mockBookStore) Delete(context.Context, primitive.ObjectID) error {
+	return nil
+}
+
+func (m *mockBookStore) GetDeletedByISBNs(context.Context, []string) ([]*types.Book, error) {
+	return []*types.Book{}, nil
+}
+
+func (m *mockBookStore) Restore(context.Context, primitive.ObjectID) (*types.Book, error) {
+	return &types.Book{}, nil
+}
+
+func (m *mockBookStore) PurgeDeleted(context.Context, time.Time) ([]*types.Book, error) {
+	return []*types.Book{}, nil
+}
+
+func (m *mockBookStore) GetUnenriched(context.Context, int) ([]*types.Book, error) {
+	return nil, nil
+}
+
+func (m *mockBookStore) SetMetadata(context.Context, primitive.ObjectID, *types.BookMetadata) error {
+	return nil
+}
+
+func (m *mockBookStore) SetCover(context.Context, primitive.ObjectID, time.Time) error {
+	return nil
+}
+
+func (m *mockBookStore) SetAuthors(context.Context, primitive.ObjectID, []primitive.ObjectID) error {
+	return nil
+}
+
+func (m *mockBookStore) ReplaceAuthors(context.Context, []primitive.ObjectID, []primitive.ObjectID, primitive.ObjectID) error {
+	return nil
+}
+
+func (m *mockBookStore) CountAuthorBooks(context.Context, primitive.ObjectID) (int64, error) {
+	return 0, nil
+}
+
+func (m *mockBookStore) Merge(context.Context, primitive.ObjectID, []primitive.ObjectID) (*types.Book, error) {
+	return nil, mongo.ErrNoDocuments
+}
+
+type mockLibraryStore struct {
+	types.LibraryStore
+}
+
+func (m *mockLibraryStore) AddBook(_ context.Context, userID primitive.ObjectID, bookID primitive.ObjectID) (*types.LibraryBook, error) {
+	return &types.LibraryBook{ID: primitive.NewObjectID(), UserID: userID, BookID: bookID}, nil
+}
+
+func (m *mockLibraryStore) SetProgress(_ context.Context, userID primitive.ObjectID, bookID primitive.ObjectID, progress *types.ReadingProgress) (*types.LibraryBook, error) {
+	return &types.LibraryBook{ID: primitive.NewObjectID(), UserID: userID, BookID: bookID, Progress: progress}, nil
+}
+
+type mockAuthorStore struct {
+	types.AuthorStore
+}
+
+func (m *mockAuthorStore) GetOrCreateAll(context.Context, string) ([]*types.Author, error) {
+	return []*types.Author{{ID: primitive.NewObjectID()}}, nil
+}
+
+type mockCollectionStore struct {
+	replaced []primitive.ObjectID
+}
+
+func (m *mockCollectionStore) CreateCollection(context.Context, *types.CreateCollectionRequest) (*types.Collection, error) {
+	return &types.Collection{}, nil
+}
+
+func (m *mockCollectionStore) GetUserCollections(context.Context, primitive.ObjectID) ([]*types.Collection, error) {
+	return []*types.Collection{}, nil
+}
+
+func (m *mockCollectionStore) GetCollectionByID(context.Context, primitive.ObjectID, primitive.ObjectID) (*types.Collection, error) {
+	return &types.Collection{}, nil
+}
+
+func (m *mockCollectionStore) UpdateCollection(context.Context, primitive.ObjectID, primitive.ObjectID, *types.UpdateCollectionRequest) (*types.Collection, error) {
+	return &types.Collection{}, nil
+}
+
+func (m *mockCollectionStore) DeleteCollection(context.Context, primitive.ObjectID, primitive.ObjectID) error {
+	return nil
+}
+
+func (m *mockCollectionStore) AddHighlights(context.Context, primitive.ObjectID, primitive.ObjectID, []primitive.ObjectID, int) (*types.Collection, error) {
+	return &types.Collection{}, nil
+}
+
+func (m *mockCollectionStore) RemoveHighlight(context.Context, primitive.ObjectID, primitive.ObjectID, primitive.ObjectID) (*types.Collection, error) {
+	return &types.Collection{}, nil
+}
+
+func (m *mockCollectionStore) ReorderHighlights(context.Context, primitive.ObjectID, primitive.ObjectID, []primitive.ObjectID) (*types.Collection, error) {
+	return &types.Collection{}, nil
+}
+
+func (m *mockCollectionStore) PurgeHighlights(context.Context, []primitive.ObjectID) error {
+	return nil
+}
+
+func (m *mockCollectionStore) ReplaceHighlights(_ context.Context, _ primitive.ObjectID, from []primitive.ObjectID, _ primitive.ObjectID) error {
+	m.replaced = append(m.replaced, from...)
+	return nil
+}
+
+type mockNoteStore struct {
+	types.NoteStore
+	note *types.Note
+}
+
+func (m *mockNoteStore) GetNoteByID(context.Context, primitive.ObjectID, primitive.ObjectID) (*types.Note, error) {
+	if m.note == nil {
+		return nil, mongo.ErrNoDocuments
+	}
+
+	return m.note, nil
+}
+
+func (m *mockNoteStore) UpdateNote(_ context.Context, _ primitive.ObjectID, _ primitive.ObjectID, req *types.UpdateNoteRequest) (*types.Note, error) {
+	m.note.Body = *req.Body
+	return m.note, nil
+}
+
+func (m *mockNoteStore) GetRandomNotes(context.Context, primitive.ObjectID, int) ([]*types.Note, error) {
+	return []*types.Note{}, nil
+}
+
+type mockLinkStore struct {
+	types.LinkStore
+	links []*types.Link
+}
+
+func (m *mockLinkStore) GetLinksTo(context.Context, primitive.ObjectID, []string) ([]*types.Link, error) {
+	return m.links, nil
+}
+
+func (m *mockLinkStore) SetLinks(context.Context, primitive.ObjectID, types.LinkNodeType, primitive.ObjectID, []string) error {
+	return nil
+}
+
 type mockMailer struct{}
 
 func (m *mockMailer) SendMail(string, string, string) error {
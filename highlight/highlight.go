@@ -4,6 +4,9 @@ import (
 	"encoding/json"
 	"log"
 	"mime/multipart"
+	"net/url"
+	"strconv"
+	"strings"
 
 	t "github.com/sikozonpc/notebase/types"
 )
@@ -32,3 +35,26 @@ func parseKindleExtractFromFile(file multipart.File) (*t.RawExtractBook, error)
 
 	return raw, nil
 }
+
+// ParsePosition extracts the numeric position from a highlight location. It
+// understands Kindle links like "kindle://book?action=open&location=307" and
+// plain numbers, anything else is position 0.
+func ParsePosition(location string) int {
+	location = strings.TrimSpace(location)
+
+	if n, err := strconv.Atoi(location); err == nil {
+		return n
+	}
+
+	u, err := url.Parse(location)
+	if err != nil {
+		return 0
+	}
+
+	n, err := strconv.Atoi(u.Query().Get("location"))
+	if err != nil {
+		return 0
+	}
+
+	return n
+}
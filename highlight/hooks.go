@@ -0,0 +1,52 @@
+package highlight
+
+import (
+	"context"
+	"log"
+
+	t "github.com/sikozonpc/notebase/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// RegisterHook adds a hook notified after every write made through the store
+func (s *Store) RegisterHook(hook t.HighlightHook) {
+	s.hooks = append(s.hooks, hook)
+}
+
+func (s *Store) notifySaved(ctx context.Context, hs ...*t.Highlight) {
+	for _, hook := range s.hooks {
+		for _, h := range hs {
+			hook.HighlightSaved(ctx, h)
+		}
+	}
+}
+
+func (s *Store) notifyDeleted(ctx context.Context, userID, id primitive.ObjectID) {
+	for _, hook := range s.hooks {
+		hook.HighlightDeleted(ctx, userID, id)
+	}
+}
+
+// notifyUpdated re-reads the highlights changed by a multi-document update,
+// which doesn't return them. The write already succeeded, so a failure here
+// is only logged.
+func (s *Store) notifyUpdated(ctx context.Context, query bson.M) {
+	if len(s.hooks) == 0 {
+		return
+	}
+
+	cursor, err := s.db.Collection(CollName).Find(ctx, query)
+	if err != nil {
+		log.Printf("failed to notify highlight hooks: %v", err)
+		return
+	}
+
+	var hs []*t.Highlight
+	if err := cursor.All(ctx, &hs); err != nil {
+		log.Printf("failed to notify highlight hooks: %v", err)
+		return
+	}
+
+	s.notifySaved(ctx, hs...)
+}
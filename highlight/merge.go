@@ -0,0 +1,128 @@
+package highlight
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	t "github.com/sikozonpc/notebase/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// MergeHighlights keeps the highlight with id, adds the notes and tags of the
+// others to it, along with the best rating and the favorite flag, and moves
+// them to the trash. It fails with mongo.ErrNoDocuments unless
+// the user owns all of them.
+func (s *Store) MergeHighlights(ctx context.Context, id primitive.ObjectID, userID primitive.ObjectID, others []primitive.ObjectID) (*t.Highlight, error) {
+	keep, err := s.GetHighlightByID(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	page, err := s.GetUserHighlights(ctx, userID, &t.HighlightFilter{IDs: others})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(page.Highlights) != len(others) {
+		return nil, mongo.ErrNoDocuments
+	}
+
+	// Notes are kept in the order they were written
+	merged := page.Highlights
+	sort.SliceStable(merged, func(i, j int) bool {
+		return merged[i].CreatedAt.Before(merged[j].CreatedAt)
+	})
+
+	if note := mergeNotes(keep, merged); note != keep.Note {
+		if _, err := s.UpdateHighlight(ctx, id, userID, &t.UpdateHighlightRequest{Note: &note}); err != nil {
+			return nil, err
+		}
+	}
+
+	tags := make([]string, 0)
+	for _, h := range merged {
+		tags = append(tags, h.Tags...)
+	}
+	if len(tags) > 0 {
+		if _, err := s.AddTags(ctx, userID, []primitive.ObjectID{id}, tags); err != nil {
+			return nil, err
+		}
+	}
+
+	favorite, rating := keep.Favorite, keep.Rating
+	for _, h := range merged {
+		favorite = favorite || h.Favorite
+		rating = max(rating, h.Rating)
+	}
+	if favorite != keep.Favorite {
+		if _, err := s.SetFavorite(ctx, id, userID, favorite); err != nil {
+			return nil, err
+		}
+	}
+	if rating != keep.Rating {
+		if _, err := s.SetRating(ctx, id, userID, rating); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, h := range merged {
+		if err := s.DeleteHighlight(ctx, h.ID, userID); err != nil {
+			return nil, err
+		}
+	}
+
+	return s.GetHighlightByID(ctx, id, userID)
+}
+
+// MoveBookHighlights moves the user's highlights of the books to another
+// one, after the books were merged into it, or every user's when userID is
+// nil. Highlights in the trash move too so they can still be restored.
+func (s *Store) MoveBookHighlights(ctx context.Context, userID *primitive.ObjectID, from []string, to string) (int64, error) {
+	col := s.db.Collection(CollName)
+
+	query := bson.M{"bookId": bson.M{"$in": from}}
+	if userID != nil {
+		query["userId"] = *userID
+	}
+
+	// The ids are needed to notify the hooks once moved
+	ids, err := s.findIDs(ctx, query)
+	if err != nil || len(ids) == 0 {
+		return 0, err
+	}
+
+	res, err := col.UpdateMany(ctx, bson.M{
+		"_id": bson.M{"$in": ids},
+	}, bson.M{
+		"$set": bson.M{"bookId": to},
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	s.notifyUpdated(ctx, bson.M{"_id": bson.M{"$in": ids}, "deletedAt": nil})
+
+	return res.ModifiedCount, nil
+}
+
+// mergeNotes joins the distinct notes of the highlights as paragraphs,
+// starting with the kept highlight's note
+func mergeNotes(keep *t.Highlight, others []*t.Highlight) string {
+	notes := make([]string, 0, len(others)+1)
+	seen := make(map[string]bool)
+
+	for _, h := range append([]*t.Highlight{keep}, others...) {
+		note := strings.TrimSpace(h.Note)
+		if note == "" || seen[note] {
+			continue
+		}
+
+		seen[note] = true
+		notes = append(notes, note)
+	}
+
+	return strings.Join(notes, "\n\n")
+}
@@ -0,0 +1,117 @@
+package highlight
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/sikozonpc/notebase/storage"
+	types "github.com/sikozonpc/notebase/types"
+	u "github.com/sikozonpc/notebase/utils"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestMergeNotes(t *testing.T) {
+	keep := &types.Highlight{Note: "first thought"}
+	others := []*types.Highlight{{Note: ""}, {Note: " second thought "}, {Note: "first thought"}}
+
+	assert.Equal(t, "first thought\n\nsecond thought", mergeNotes(keep, others))
+	assert.Equal(t, "", mergeNotes(&types.Highlight{}, nil))
+}
+
+func TestHandleDuplicateHighlights(t *testing.T) {
+	keep := &types.Highlight{ID: primitive.NewObjectID(), Text: "Simplicity is prerequisite for reliability."}
+	duplicate := &types.Highlight{ID: primitive.NewObjectID(), Text: "Simplicity is a prerequisite for reliability"}
+
+	note := &types.Note{ID: primitive.NewObjectID(), Body: "See [[" + duplicate.ID.Hex() + "|Dijkstra]]"}
+	collectionStore := &mockCollectionStore{}
+	noteStore := &mockNoteStore{note: note}
+	linkStore := &mockLinkStore{links: []*types.Link{{SourceType: types.LinkNodeNote, SourceID: note.ID, Target: duplicate.ID.Hex()}}}
+	handler := NewHandler(&mockHighlightStore{}, &mockUserStore{}, storage.NewMemoryStorage(), &mockBookStore{}, &mockLibraryStore{}, &mockAuthorStore{}, collectionStore, noteStore, linkStore, &mockMailer{})
+
+	fakeHighlight = keep
+	fakeLibrary = []*types.Highlight{keep, duplicate, {ID: primitive.NewObjectID(), Text: "Premature optimization is the root of all evil."}}
+	defer func() { fakeLibrary = nil }()
+
+	serve := func(t *testing.T, method, path, body string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(method, path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		router := mux.NewRouter()
+
+		router.HandleFunc("/user/{userID}/highlight/duplicates", u.MakeHTTPHandler(handler.handleGetDuplicateHighlights)).Methods(http.MethodGet)
+		router.HandleFunc("/user/{userID}/highlight/{id}/merge", u.MakeHTTPHandler(handler.handleMergeHighlights)).Methods(http.MethodPost)
+
+		router.ServeHTTP(rr, req)
+
+		return rr
+	}
+
+	t.Run("should list duplicate groups", func(t *testing.T) {
+		rr := serve(t, http.MethodGet, "/user/1/highlight/duplicates", "")
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		var groups []*types.DuplicateGroup
+		if err := json.NewDecoder(rr.Body).Decode(&groups); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(groups) != 1 || len(groups[0].Highlights) != 2 {
+			t.Errorf("expected one group of two highlights, got %d groups", len(groups))
+		}
+	})
+
+	t.Run("should fail with an invalid threshold", func(t *testing.T) {
+		rr := serve(t, http.MethodGet, "/user/1/highlight/duplicates?threshold=2", "")
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+
+	t.Run("should merge highlights", func(t *testing.T) {
+		rr := serve(t, http.MethodPost, "/user/1/highlight/"+keep.ID.Hex()+"/merge", `{"highlightIds": ["`+duplicate.ID.Hex()+`"]}`)
+
+		if rr.Code != http.StatusOK {
+			t.Errorf("expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		assert.Equal(t, []primitive.ObjectID{duplicate.ID}, collectionStore.replaced, "the kept highlight should take its place in collections")
+		assert.Equal(t, "See [["+keep.ID.Hex()+"|Dijkstra]]", note.Body, "links should point to the kept highlight")
+	})
+
+	t.Run("should fail to merge a highlight into itself", func(t *testing.T) {
+		rr := serve(t, http.MethodPost, "/user/1/highlight/"+keep.ID.Hex()+"/merge", `{"highlightIds": ["`+keep.ID.Hex()+`"]}`)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+
+	t.Run("should fail without highlights to merge", func(t *testing.T) {
+		rr := serve(t, http.MethodPost, "/user/1/highlight/"+keep.ID.Hex()+"/merge", `{"highlightIds": []}`)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+
+	t.Run("should fail if a highlight doesn't exist", func(t *testing.T) {
+		ids := `"` + primitive.NewObjectID().Hex() + `", "` + primitive.NewObjectID().Hex() + `", "` + primitive.NewObjectID().Hex() + `", "` + primitive.NewObjectID().Hex() + `"`
+		rr := serve(t, http.MethodPost, "/user/1/highlight/"+keep.ID.Hex()+"/merge", `{"highlightIds": [`+ids+`]}`)
+
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("expected status code %d, got %d", http.StatusNotFound, rr.Code)
+		}
+	})
+}
@@ -0,0 +1,126 @@
+package highlight
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+
+	t "github.com/sikozonpc/notebase/types"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// ErrInvalidFilter is returned when a listing is requested with an unknown
+// sort or a cursor that does not belong to it
+var ErrInvalidFilter = errors.New("invalid filter")
+
+// Fields each sort orders by. The _id always comes last to break ties, so
+// every highlight has a unique place and pages never overlap.
+var sortKeys = map[string][]string{
+	t.HighlightSortCreated:  {"createdAt", "_id"},
+	t.HighlightSortUpdated:  {"updatedAt", "_id"},
+	t.HighlightSortLocation: {"position", "_id"},
+	t.HighlightSortBook:     {"bookId", "position", "_id"},
+}
+
+func sortFields(sort string) (string, []string, error) {
+	if sort == "" {
+		sort = t.HighlightSortCreated
+	}
+
+	keys, ok := sortKeys[sort]
+	if !ok {
+		return "", nil, fmt.Errorf("%w: unknown sort %q", ErrInvalidFilter, sort)
+	}
+
+	return sort, keys, nil
+}
+
+func sortDocument(keys []string, desc bool) bson.D {
+	direction := 1
+	if desc {
+		direction = -1
+	}
+
+	doc := make(bson.D, len(keys))
+	for i, k := range keys {
+		doc[i] = bson.E{Key: k, Value: direction}
+	}
+
+	return doc
+}
+
+// sortValues returns the values of the sort keys for a highlight, which is
+// what a cursor pointing right after it stores
+func sortValues(h *t.Highlight, keys []string) bson.A {
+	values := make(bson.A, len(keys))
+	for i, k := range keys {
+		switch k {
+		case "createdAt":
+			values[i] = h.CreatedAt
+		case "updatedAt":
+			values[i] = h.UpdatedAt
+		case "position":
+			values[i] = h.Position
+		case "bookId":
+			values[i] = h.BookID
+		case "_id":
+			values[i] = h.ID
+		}
+	}
+
+	return values
+}
+
+type cursor struct {
+	Sort   string `bson:"s"`
+	Values bson.A `bson:"v"`
+}
+
+func encodeCursor(sort string, values bson.A) (string, error) {
+	b, err := bson.Marshal(cursor{Sort: sort, Values: values})
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func decodeCursor(s string, sort string, keys []string) (bson.A, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed cursor", ErrInvalidFilter)
+	}
+
+	var c cursor
+	if err := bson.Unmarshal(b, &c); err != nil {
+		return nil, fmt.Errorf("%w: malformed cursor", ErrInvalidFilter)
+	}
+
+	if c.Sort != sort || len(c.Values) != len(keys) {
+		return nil, fmt.Errorf("%w: cursor does not match sort %q", ErrInvalidFilter, sort)
+	}
+
+	return c.Values, nil
+}
+
+// afterCursor matches the documents that come after the cursor values in the
+// sort order, i.e. (k1 > v1) or (k1 = v1 and k2 > v2) or ...
+func afterCursor(keys []string, values bson.A, desc bool) bson.M {
+	op := "$gt"
+	if desc {
+		op = "$lt"
+	}
+
+	or := make(bson.A, len(keys))
+	for i := range keys {
+		cond := bson.M{}
+		for j := 0; j < i; j++ {
+			cond[keys[j]] = values[j]
+		}
+		cond[keys[i]] = bson.M{op: values[i]}
+
+		or[i] = cond
+	}
+
+	return bson.M{"$or": or}
+}
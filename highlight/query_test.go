@@ -0,0 +1,80 @@
+package highlight
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	types "github.com/sikozonpc/notebase/types"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestCursor(t *testing.T) {
+	h := &types.Highlight{
+		ID:        primitive.NewObjectID(),
+		BookID:    "B004XCFJ3E",
+		Position:  307,
+		CreatedAt: time.Date(2024, 1, 31, 10, 0, 0, 0, time.UTC),
+	}
+
+	t.Run("should round trip the sort values", func(t *testing.T) {
+		sort, keys, err := sortFields(types.HighlightSortBook)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		c, err := encodeCursor(sort, sortValues(h, keys))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		values, err := decodeCursor(c, sort, keys)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, "B004XCFJ3E", values[0])
+		assert.EqualValues(t, 307, values[1])
+		assert.Equal(t, h.ID, values[2])
+	})
+
+	t.Run("should reject a cursor from another sort", func(t *testing.T) {
+		_, createdKeys, _ := sortFields("")
+		c, err := encodeCursor(types.HighlightSortCreated, sortValues(h, createdKeys))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, keys, _ := sortFields(types.HighlightSortUpdated)
+		_, err = decodeCursor(c, types.HighlightSortUpdated, keys)
+		assert.True(t, errors.Is(err, ErrInvalidFilter))
+
+		_, err = decodeCursor("not a cursor", types.HighlightSortCreated, createdKeys)
+		assert.True(t, errors.Is(err, ErrInvalidFilter))
+	})
+
+	t.Run("should reject unknown sorts", func(t *testing.T) {
+		_, _, err := sortFields("random")
+		assert.True(t, errors.Is(err, ErrInvalidFilter))
+	})
+}
+
+func TestAfterCursor(t *testing.T) {
+	id := primitive.NewObjectID()
+
+	cond := afterCursor([]string{"position", "_id"}, bson.A{307, id}, true)
+
+	assert.Equal(t, bson.M{"$or": bson.A{
+		bson.M{"position": bson.M{"$lt": 307}},
+		bson.M{"position": 307, "_id": bson.M{"$lt": id}},
+	}}, cond)
+}
+
+func TestParsePosition(t *testing.T) {
+	assert.Equal(t, 307, ParsePosition("kindle://book?action=open&asin=SOMERANDOMASIN&location=307"))
+	assert.Equal(t, 42, ParsePosition(" 42 "))
+	assert.Equal(t, 0, ParsePosition("chapter 3"))
+	assert.Equal(t, 0, ParsePosition(""))
+}
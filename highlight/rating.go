@@ -0,0 +1,91 @@
+package highlight
+
+import (
+	"context"
+
+	t "github.com/sikozonpc/notebase/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	MinRating = 1
+	MaxRating = 5
+
+	// Weight of unrated highlights when sampling by rating, as if rated in
+	// the middle of the scale
+	unratedWeight = 3
+	// Favorites are picked as often as if their rating was doubled
+	favoriteFactor = 2
+)
+
+// SetFavorite marks or unmarks the user's highlight as a favorite
+func (s *Store) SetFavorite(ctx context.Context, id primitive.ObjectID, userID primitive.ObjectID, favorite bool) (*t.Highlight, error) {
+	update := bson.M{"$set": bson.M{"favorite": true}}
+	if !favorite {
+		update = bson.M{"$unset": bson.M{"favorite": ""}}
+	}
+
+	return s.updateOne(ctx, id, userID, update)
+}
+
+// SetRating rates the user's highlight from MinRating to MaxRating, a rating
+// of zero clears it
+func (s *Store) SetRating(ctx context.Context, id primitive.ObjectID, userID primitive.ObjectID, rating int) (*t.Highlight, error) {
+	update := bson.M{"$set": bson.M{"rating": rating}}
+	if rating == 0 {
+		update = bson.M{"$unset": bson.M{"rating": ""}}
+	}
+
+	return s.updateOne(ctx, id, userID, update)
+}
+
+func (s *Store) updateOne(ctx context.Context, id, userID primitive.ObjectID, update bson.M) (*t.Highlight, error) {
+	col := s.db.Collection(CollName)
+
+	var h t.Highlight
+	err := col.FindOneAndUpdate(ctx, bson.M{
+		"_id":       id,
+		"userId":    userID,
+		"deletedAt": nil,
+	}, update, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&h)
+	if err != nil {
+		return nil, err
+	}
+
+	s.notifySaved(ctx, &h)
+
+	return &h, nil
+}
+
+// weightedSample picks limit highlights with a probability proportional to
+// their weight, by keeping the highest random keys u^(1/weight)
+// (Efraimidis-Spirakis)
+func weightedSample(limit int) mongo.Pipeline {
+	weight := bson.M{"$multiply": bson.A{
+		bson.M{"$ifNull": bson.A{"$rating", unratedWeight}},
+		bson.M{"$cond": bson.A{bson.M{"$eq": bson.A{"$favorite", true}}, favoriteFactor, 1}},
+	}}
+
+	return mongo.Pipeline{
+		bson.D{
+			{Key: "$set", Value: bson.M{
+				"_sampleKey": bson.M{"$pow": bson.A{
+					bson.M{"$rand": bson.M{}},
+					bson.M{"$divide": bson.A{1, weight}},
+				}},
+			}},
+		},
+		bson.D{
+			{Key: "$sort", Value: bson.D{{Key: "_sampleKey", Value: -1}}},
+		},
+		bson.D{
+			{Key: "$limit", Value: limit},
+		},
+		bson.D{
+			{Key: "$unset", Value: "_sampleKey"},
+		},
+	}
+}
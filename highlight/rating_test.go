@@ -0,0 +1,130 @@
+package highlight
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/sikozonpc/notebase/storage"
+	types "github.com/sikozonpc/notebase/types"
+	u "github.com/sikozonpc/notebase/utils"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestFilterQueryFavoritesAndRatings(t *testing.T) {
+	favorite := true
+	query := filterQuery(primitive.NewObjectID(), &types.HighlightFilter{Favorite: &favorite, MinRating: 4})
+
+	assert.Equal(t, true, query["favorite"])
+	assert.Equal(t, bson.M{"$gte": 4}, query["rating"])
+
+	favorite = false
+	query = filterQuery(primitive.NewObjectID(), &types.HighlightFilter{Favorite: &favorite})
+
+	assert.Equal(t, bson.M{"$ne": true}, query["favorite"])
+	assert.NotContains(t, query, "rating")
+}
+
+func TestHandleFavoritesAndRatings(t *testing.T) {
+	handler := NewHandler(&mockHighlightStore{}, &mockUserStore{}, storage.NewMemoryStorage(), &mockBookStore{}, &mockLibraryStore{}, &mockAuthorStore{}, &mockCollectionStore{}, &mockNoteStore{}, &mockLinkStore{}, &mockMailer{})
+
+	serve := func(t *testing.T, method, path, body string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(method, path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		router := mux.NewRouter()
+
+		router.HandleFunc("/user/{userID}/highlight", u.MakeHTTPHandler(handler.handleGetUserHighlights)).Methods(http.MethodGet)
+		router.HandleFunc("/user/{userID}/highlight/{id}/favorite", u.MakeHTTPHandler(handler.handleFavoriteHighlight)).Methods(http.MethodPut)
+		router.HandleFunc("/user/{userID}/highlight/{id}/favorite", u.MakeHTTPHandler(handler.handleUnfavoriteHighlight)).Methods(http.MethodDelete)
+		router.HandleFunc("/user/{userID}/highlight/{id}/rating", u.MakeHTTPHandler(handler.handleRateHighlight)).Methods(http.MethodPut)
+		router.HandleFunc("/user/{userID}/highlight/{id}/rating", u.MakeHTTPHandler(handler.handleClearHighlightRating)).Methods(http.MethodDelete)
+
+		router.ServeHTTP(rr, req)
+
+		return rr
+	}
+
+	decode := func(t *testing.T, rr *httptest.ResponseRecorder) *types.Highlight {
+		var h types.Highlight
+		if err := json.NewDecoder(rr.Body).Decode(&h); err != nil {
+			t.Fatal(err)
+		}
+
+		return &h
+	}
+
+	fakeHighlight = &types.Highlight{ID: primitive.NewObjectID(), Text: "Make it work, make it right, make it fast."}
+	path := "/user/1/highlight/" + fakeHighlight.ID.Hex()
+
+	t.Run("should mark a highlight as favorite", func(t *testing.T) {
+		rr := serve(t, http.MethodPut, path+"/favorite", "")
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		assert.True(t, decode(t, rr).Favorite)
+	})
+
+	t.Run("should unmark a favorite highlight", func(t *testing.T) {
+		rr := serve(t, http.MethodDelete, path+"/favorite", "")
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		assert.False(t, decode(t, rr).Favorite)
+	})
+
+	t.Run("should rate a highlight", func(t *testing.T) {
+		rr := serve(t, http.MethodPut, path+"/rating", `{"rating": 4}`)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		assert.Equal(t, 4, decode(t, rr).Rating)
+	})
+
+	t.Run("should fail with a rating out of range", func(t *testing.T) {
+		for _, body := range []string{`{"rating": 0}`, `{"rating": 6}`, `{`} {
+			rr := serve(t, http.MethodPut, path+"/rating", body)
+			if rr.Code != http.StatusBadRequest {
+				t.Errorf("expected status code %d for %s, got %d", http.StatusBadRequest, body, rr.Code)
+			}
+		}
+	})
+
+	t.Run("should clear a rating", func(t *testing.T) {
+		rr := serve(t, http.MethodDelete, path+"/rating", "")
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		assert.Zero(t, decode(t, rr).Rating)
+	})
+
+	t.Run("should fail to list with an invalid rating filter", func(t *testing.T) {
+		for _, q := range []string{"minRating=9", "favorite=maybe"} {
+			rr := serve(t, http.MethodGet, "/user/1/highlight?"+q, "")
+			if rr.Code != http.StatusBadRequest {
+				t.Errorf("expected status code %d for %s, got %d", http.StatusBadRequest, q, rr.Code)
+			}
+		}
+	})
+
+	t.Run("should fail if the highlight doesn't exist", func(t *testing.T) {
+		fakeHighlight = nil
+
+		rr := serve(t, http.MethodPut, path+"/favorite", "")
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("expected status code %d, got %d", http.StatusNotFound, rr.Code)
+		}
+	})
+}
@@ -0,0 +1,67 @@
+package highlight
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/sikozonpc/notebase/storage"
+	types "github.com/sikozonpc/notebase/types"
+	u "github.com/sikozonpc/notebase/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestHandleGetRelatedHighlights(t *testing.T) {
+	handler := NewHandler(&mockHighlightStore{}, &mockUserStore{}, storage.NewMemoryStorage(), &mockBookStore{}, &mockLibraryStore{}, &mockAuthorStore{}, &mockCollectionStore{}, &mockNoteStore{}, &mockLinkStore{}, &mockMailer{})
+
+	goroutines := &types.Highlight{ID: primitive.NewObjectID(), Text: "Goroutines communicate by sharing channels, not memory."}
+	channels := &types.Highlight{ID: primitive.NewObjectID(), Text: "Buffered channels let goroutines run ahead of each other."}
+	leadership := &types.Highlight{ID: primitive.NewObjectID(), Text: "Leadership is the capacity to translate vision into reality."}
+
+	fakeHighlight = goroutines
+	fakeLibrary = []*types.Highlight{goroutines, channels, leadership}
+	defer func() { fakeLibrary = nil }()
+
+	get := func(t *testing.T, query string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(http.MethodGet, "/user/1/highlight/"+goroutines.ID.Hex()+"/related"+query, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		router := mux.NewRouter()
+
+		router.HandleFunc("/user/{userID}/highlight/{id}/related", u.MakeHTTPHandler(handler.handleGetRelatedHighlights))
+
+		router.ServeHTTP(rr, req)
+
+		return rr
+	}
+
+	t.Run("should return the similar highlights only", func(t *testing.T) {
+		rr := get(t, "")
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		var related []*types.RelatedHighlight
+		if err := json.NewDecoder(rr.Body).Decode(&related); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(related) != 1 || related[0].Highlight.ID != channels.ID {
+			t.Errorf("expected only the highlight about channels, got %d", len(related))
+		}
+	})
+
+	t.Run("should fail with an invalid limit", func(t *testing.T) {
+		rr := get(t, "?limit=100")
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+}
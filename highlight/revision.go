@@ -0,0 +1,125 @@
+package highlight
+
+import (
+	"context"
+	"time"
+
+	t "github.com/sikozonpc/notebase/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const RevisionsCollName = "highlight_revisions"
+
+func (s *Store) GetHighlightRevisions(ctx context.Context, highlightID primitive.ObjectID, userID primitive.ObjectID) ([]*t.HighlightRevision, error) {
+	col := s.db.Collection(RevisionsCollName)
+
+	cursor, err := col.Find(ctx, bson.M{
+		"highlightId": highlightID,
+		"userId":      userID,
+	}, options.Find().SetSort(bson.D{{Key: "version", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+
+	revisions := make([]*t.HighlightRevision, 0)
+	if err = cursor.All(ctx, &revisions); err != nil {
+		return nil, err
+	}
+
+	return revisions, nil
+}
+
+func (s *Store) GetHighlightRevision(ctx context.Context, highlightID primitive.ObjectID, userID primitive.ObjectID, version int) (*t.HighlightRevision, error) {
+	col := s.db.Collection(RevisionsCollName)
+
+	var r t.HighlightRevision
+	err := col.FindOne(ctx, bson.M{
+		"highlightId": highlightID,
+		"userId":      userID,
+		"version":     version,
+	}).Decode(&r)
+	if err != nil {
+		return nil, err
+	}
+
+	return &r, nil
+}
+
+// recordRevision stores the change from before to after. The first time a
+// highlight is edited its original state is stored as version 1, so it can
+// always be reverted back to how it was created. Edits follow from version 2,
+// after.Revision being how many there were.
+func (s *Store) recordRevision(ctx context.Context, userID primitive.ObjectID, before, after *t.Highlight) error {
+	changes := diffHighlight(before, after)
+	if len(changes) == 0 {
+		return nil
+	}
+
+	col := s.db.Collection(RevisionsCollName)
+
+	revisions := make([]interface{}, 0, 2)
+	if before.Revision == 0 {
+		revisions = append(revisions, t.HighlightRevision{
+			ID:          primitive.NewObjectID(),
+			HighlightID: before.ID,
+			UserID:      before.UserID,
+			Version:     1,
+			Changes:     []t.FieldChange{},
+			Snapshot:    snapshot(before),
+			CreatedAt:   before.CreatedAt,
+		})
+	}
+
+	revisions = append(revisions, t.HighlightRevision{
+		ID:          primitive.NewObjectID(),
+		HighlightID: before.ID,
+		UserID:      userID,
+		Version:     after.Revision + 1,
+		Changes:     changes,
+		Snapshot:    snapshot(after),
+		CreatedAt:   time.Now().UTC(),
+	})
+
+	_, err := col.InsertMany(ctx, revisions)
+	return err
+}
+
+func snapshot(h *t.Highlight) t.HighlightSnapshot {
+	return t.HighlightSnapshot{
+		Text:     h.Text,
+		Location: h.Location,
+		Note:     h.Note,
+		BookID:   h.BookID,
+	}
+}
+
+// diffHighlight returns the editable fields that differ between two versions
+// of a highlight
+func diffHighlight(before, after *t.Highlight) []t.FieldChange {
+	b, a := snapshot(before), snapshot(after)
+
+	fields := []struct {
+		name     string
+		from, to string
+	}{
+		{"text", b.Text, a.Text},
+		{"location", b.Location, a.Location},
+		{"note", b.Note, a.Note},
+		{"bookId", b.BookID, a.BookID},
+	}
+
+	changes := make([]t.FieldChange, 0)
+	for _, f := range fields {
+		if f.from != f.to {
+			changes = append(changes, t.FieldChange{
+				Field: f.name,
+				From:  f.from,
+				To:    f.to,
+			})
+		}
+	}
+
+	return changes
+}
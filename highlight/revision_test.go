@@ -0,0 +1,22 @@
+package highlight
+
+import (
+	"testing"
+
+	types "github.com/sikozonpc/notebase/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffHighlight(t *testing.T) {
+	before := &types.Highlight{Text: "text", Note: "old note", Location: "307", BookID: "B004XCFJ3E"}
+	after := &types.Highlight{Text: "text", Note: "new note", Location: "307", BookID: "B00ABCDEFG"}
+
+	changes := diffHighlight(before, after)
+
+	assert.Equal(t, []types.FieldChange{
+		{Field: "note", From: "old note", To: "new note"},
+		{Field: "bookId", From: "B004XCFJ3E", To: "B00ABCDEFG"},
+	}, changes)
+
+	assert.Empty(t, diffHighlight(before, before))
+}
@@ -2,63 +2,132 @@ package highlight
 
 import (
 	"context"
+	"errors"
+	"time"
 
 	t "github.com/sikozonpc/notebase/types"
+	u "github.com/sikozonpc/notebase/utils"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-const (
-	DbName   = "notebase"
-	CollName = "highlights"
-)
+const CollName = "highlights"
 
 type Store struct {
-	db *mongo.Client
+	db    *mongo.Database
+	hooks []t.HighlightHook
 }
 
-func NewStore(db *mongo.Client) *Store {
+func NewStore(db *mongo.Database) *Store {
 	return &Store{db: db}
 }
 
-func (s *Store) GetUserHighlights(ctx context.Context, userID primitive.ObjectID) ([]*t.Highlight, error) {
-	col := s.db.Database(DbName).Collection(CollName)
+func (s *Store) GetUserHighlights(ctx context.Context, userID primitive.ObjectID, filter *t.HighlightFilter) (*t.HighlightPage, error) {
+	col := s.db.Collection(CollName)
 
-	cursor, err := col.Find(ctx, bson.M{
-		"userId": userID,
-	})
+	if filter == nil {
+		filter = &t.HighlightFilter{}
+	}
+
+	sort, keys, err := sortFields(filter.Sort)
 	if err != nil {
 		return nil, err
 	}
 
-	var highlights []*t.Highlight
+	query := filterQuery(userID, filter)
+
+	page := &t.HighlightPage{}
+	if filter.Limit > 0 {
+		page.Total, err = col.CountDocuments(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if filter.Cursor != "" {
+		values, err := decodeCursor(filter.Cursor, sort, keys)
+		if err != nil {
+			return nil, err
+		}
+
+		query["$and"] = bson.A{afterCursor(keys, values, filter.Desc)}
+	}
+
+	opts := options.Find().SetSort(sortDocument(keys, filter.Desc))
+	if filter.Limit > 0 {
+		// One extra to know if there is a next page
+		opts.SetLimit(int64(filter.Limit) + 1)
+	}
+
+	cursor, err := col.Find(ctx, query, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	highlights := make([]*t.Highlight, 0)
 	if err = cursor.All(ctx, &highlights); err != nil {
 		return nil, err
 	}
 
-	return highlights, nil
+	if filter.Limit == 0 {
+		page.Total = int64(len(highlights))
+	}
+
+	if filter.Limit > 0 && len(highlights) > filter.Limit {
+		highlights = highlights[:filter.Limit]
+
+		page.NextCursor, err = encodeCursor(sort, sortValues(highlights[len(highlights)-1], keys))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	page.Highlights = highlights
+
+	return page, nil
 }
 
 func (s *Store) CreateHighlight(ctx context.Context, h *t.CreateHighlightRequest) (primitive.ObjectID, error) {
-	col := s.db.Database(DbName).Collection(CollName)
+	col := s.db.Collection(CollName)
 
-	newHighlight, err := col.InsertOne(ctx, h)
-	if err != nil {
+	position := h.Position
+	if position == 0 {
+		position = ParsePosition(h.Location)
+	}
+
+	now := time.Now().UTC()
+	highlight := &t.Highlight{
+		ID:        primitive.NewObjectID(),
+		Text:      h.Text,
+		Location:  h.Location,
+		Position:  position,
+		Note:      h.Note,
+		UserID:    h.UserID,
+		BookID:    h.BookID,
+		Tags:      u.NormalizeTags(h.Tags),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	if _, err := col.InsertOne(ctx, highlight); err != nil {
 		return primitive.NilObjectID, err
 	}
 
-	id := newHighlight.InsertedID.(primitive.ObjectID)
-	return id, nil
+	s.notifySaved(ctx, highlight)
+
+	return highlight.ID, nil
 }
 
 func (s *Store) GetHighlightByID(ctx context.Context, id primitive.ObjectID, userID primitive.ObjectID) (*t.Highlight, error) {
-	col := s.db.Database(DbName).Collection(CollName)
+	col := s.db.Collection(CollName)
 
 	var h t.Highlight
 	err := col.FindOne(ctx, bson.M{
-		"_id":    id,
-		"userId": userID,
+		"_id":       id,
+		"userId":    userID,
+		"deletedAt": nil,
 	}).Decode(&h)
 
 	if err != nil {
@@ -68,34 +137,134 @@ func (s *Store) GetHighlightByID(ctx context.Context, id primitive.ObjectID, use
 	return &h, nil
 }
 
-func (s *Store) DeleteHighlight(ctx context.Context, id primitive.ObjectID) error {
-	col := s.db.Database(DbName).Collection(CollName)
+func (s *Store) UpdateHighlight(ctx context.Context, id primitive.ObjectID, userID primitive.ObjectID, req *t.UpdateHighlightRequest) (*t.Highlight, error) {
+	col := s.db.Collection(CollName)
+
+	set := bson.M{
+		"updatedAt": time.Now().UTC(),
+	}
+	changed := bson.A{}
+	if req.Text != nil {
+		set["text"] = *req.Text
+		changed = append(changed, bson.M{"text": bson.M{"$ne": *req.Text}})
+	}
+	if req.Location != nil {
+		set["location"] = *req.Location
+		set["position"] = ParsePosition(*req.Location)
+		changed = append(changed, bson.M{"location": bson.M{"$ne": *req.Location}})
+	}
+	if req.Note != nil {
+		set["note"] = *req.Note
+		changed = append(changed, bson.M{"note": bson.M{"$ne": *req.Note}})
+	}
+	if req.BookID != nil {
+		set["bookId"] = *req.BookID
+		changed = append(changed, bson.M{"bookId": bson.M{"$ne": *req.BookID}})
+	}
+
+	filter := bson.M{
+		"_id":       id,
+		"userId":    userID,
+		"deletedAt": nil,
+	}
+	update := bson.M{"$set": set}
+
+	// Each edit that changes something gets the next revision version,
+	// counted along with the edit so concurrent ones never share it
+	if len(changed) > 0 {
+		filter["$or"] = changed
+		update["$inc"] = bson.M{"revision": 1}
+	}
+
+	// The previous version is needed to record what changed
+	var before t.Highlight
+	err := col.FindOneAndUpdate(ctx, filter, update, options.FindOneAndUpdate().SetReturnDocument(options.Before)).Decode(&before)
+	if errors.Is(err, mongo.ErrNoDocuments) && len(changed) > 0 {
+		// Either not found, or already as requested
+		return s.GetHighlightByID(ctx, id, userID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	after := applyUpdate(before, req)
+	after.UpdatedAt = set["updatedAt"].(time.Time)
+	if len(changed) > 0 {
+		after.Revision++
+	}
+
+	if err := s.recordRevision(ctx, userID, &before, &after); err != nil {
+		return nil, err
+	}
+
+	s.notifySaved(ctx, &after)
+
+	return &after, nil
+}
 
-	_, err := col.DeleteOne(ctx, bson.M{
-		"_id": id,
+func applyUpdate(h t.Highlight, req *t.UpdateHighlightRequest) t.Highlight {
+	if req.Text != nil {
+		h.Text = *req.Text
+	}
+	if req.Location != nil {
+		h.Location = *req.Location
+		h.Position = ParsePosition(*req.Location)
+	}
+	if req.Note != nil {
+		h.Note = *req.Note
+	}
+	if req.BookID != nil {
+		h.BookID = *req.BookID
+	}
+
+	return h
+}
+
+// DeleteHighlight moves the user's highlight to the trash, it's purged
+// after the retention period unless restored
+func (s *Store) DeleteHighlight(ctx context.Context, id primitive.ObjectID, userID primitive.ObjectID) error {
+	col := s.db.Collection(CollName)
+
+	res, err := col.UpdateOne(ctx, bson.M{
+		"_id":       id,
+		"userId":    userID,
+		"deletedAt": nil,
+	}, bson.M{
+		"$set": bson.M{"deletedAt": time.Now().UTC()},
 	})
 	if err != nil {
 		return err
 	}
 
+	if res.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+
+	s.notifyDeleted(ctx, userID, id)
+
 	return nil
 }
 
-func (s *Store) GetRandomHighlights(ctx context.Context, userID primitive.ObjectID, limit int) ([]*t.Highlight, error) {
-	col := s.db.Database(DbName).Collection(CollName)
+func (s *Store) GetRandomHighlights(ctx context.Context, userID primitive.ObjectID, limit int, filter *t.HighlightFilter) ([]*t.Highlight, error) {
+	col := s.db.Collection(CollName)
 
-	cursor, err := col.Aggregate(ctx, mongo.Pipeline{
+	pipeline := mongo.Pipeline{
 		bson.D{
-			{Key: `$match`, Value: bson.M{
-				"userId": userID,
-			}},
+			{Key: `$match`, Value: filterQuery(userID, filter)},
 		},
-		bson.D{
+	}
+
+	if filter != nil && filter.WeightByRating {
+		pipeline = append(pipeline, weightedSample(limit)...)
+	} else {
+		pipeline = append(pipeline, bson.D{
 			{Key: "$sample", Value: bson.M{
 				"size": limit,
 			}},
-		},
-	})
+		})
+	}
+
+	cursor, err := col.Aggregate(ctx, pipeline)
 	if err != nil {
 		return nil, err
 	}
@@ -107,3 +276,85 @@ func (s *Store) GetRandomHighlights(ctx context.Context, userID primitive.Object
 
 	return highlights, nil
 }
+
+// GetUserBookStats counts the user's highlights of each book they have
+// highlights of, most recently highlighted first
+func (s *Store) GetUserBookStats(ctx context.Context, userID primitive.ObjectID) ([]*t.BookHighlightStats, error) {
+	col := s.db.Collection(CollName)
+
+	cursor, err := col.Aggregate(ctx, mongo.Pipeline{
+		bson.D{{Key: "$match", Value: bson.M{"userId": userID, "deletedAt": nil}}},
+		bson.D{{Key: "$group", Value: bson.M{
+			"_id":               "$bookId",
+			"count":             bson.M{"$sum": 1},
+			"lastHighlightedAt": bson.M{"$max": "$createdAt"},
+		}}},
+		bson.D{{Key: "$sort", Value: bson.D{{Key: "lastHighlightedAt", Value: -1}, {Key: "_id", Value: 1}}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	stats := make([]*t.BookHighlightStats, 0)
+	if err = cursor.All(ctx, &stats); err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+func filterQuery(userID primitive.ObjectID, filter *t.HighlightFilter) bson.M {
+	query := bson.M{
+		"userId":    userID,
+		"deletedAt": nil,
+	}
+
+	if filter == nil {
+		return query
+	}
+
+	if filter.IDs != nil {
+		query["_id"] = bson.M{"$in": filter.IDs}
+	}
+
+	if filter.BookID != "" {
+		query["bookId"] = filter.BookID
+	}
+
+	if filter.Tag != "" {
+		query["tags"] = u.NormalizeTag(filter.Tag)
+	}
+
+	if filter.CreatedAfter != nil || filter.CreatedBefore != nil {
+		createdAt := bson.M{}
+		if filter.CreatedAfter != nil {
+			createdAt["$gte"] = *filter.CreatedAfter
+		}
+		if filter.CreatedBefore != nil {
+			createdAt["$lt"] = *filter.CreatedBefore
+		}
+		query["createdAt"] = createdAt
+	}
+
+	if filter.Favorite != nil {
+		if *filter.Favorite {
+			query["favorite"] = true
+		} else {
+			query["favorite"] = bson.M{"$ne": true}
+		}
+	}
+
+	if filter.MinRating > 0 {
+		query["rating"] = bson.M{"$gte": filter.MinRating}
+	}
+
+	if filter.HasNote != nil {
+		if *filter.HasNote {
+			query["note"] = bson.M{"$nin": bson.A{"", nil}}
+		} else {
+			query["note"] = bson.M{"$in": bson.A{"", nil}}
+		}
+	}
+
+	return query
+}
@@ -0,0 +1,95 @@
+package highlight
+
+import (
+	"context"
+	"time"
+
+	t "github.com/sikozonpc/notebase/types"
+	u "github.com/sikozonpc/notebase/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// SetSuggestedTags replaces the suggested tags of the given highlights. The
+// highlights aren't edited by the user, so hooks aren't notified and
+// updatedAt is kept.
+func (s *Store) SetSuggestedTags(ctx context.Context, userID primitive.ObjectID, suggestions map[primitive.ObjectID][]string) error {
+	if len(suggestions) == 0 {
+		return nil
+	}
+
+	col := s.db.Collection(CollName)
+
+	models := make([]mongo.WriteModel, 0, len(suggestions))
+	for id, tags := range suggestions {
+		update := bson.M{"$set": bson.M{"suggestedTags": tags}}
+		if len(tags) == 0 {
+			update = bson.M{"$unset": bson.M{"suggestedTags": ""}}
+		}
+
+		models = append(models, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"_id": id, "userId": userID}).
+			SetUpdate(update))
+	}
+
+	_, err := col.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+	return err
+}
+
+// AcceptSuggestedTags moves suggested tags to the highlight's tags, all of
+// them when tags is empty
+func (s *Store) AcceptSuggestedTags(ctx context.Context, id primitive.ObjectID, userID primitive.ObjectID, tags []string) (*t.Highlight, error) {
+	h, err := s.resolveSuggestedTags(ctx, id, userID, tags, "tags", bson.M{"updatedAt": time.Now().UTC()})
+	if err != nil {
+		return nil, err
+	}
+
+	s.notifySaved(ctx, h)
+
+	return h, nil
+}
+
+// RejectSuggestedTags dismisses suggested tags, all of them when tags is
+// empty, so they are not suggested again
+func (s *Store) RejectSuggestedTags(ctx context.Context, id primitive.ObjectID, userID primitive.ObjectID, tags []string) (*t.Highlight, error) {
+	return s.resolveSuggestedTags(ctx, id, userID, tags, "rejectedTags", nil)
+}
+
+func (s *Store) resolveSuggestedTags(ctx context.Context, id, userID primitive.ObjectID, tags []string, into string, set bson.M) (*t.Highlight, error) {
+	col := s.db.Collection(CollName)
+
+	tags = u.NormalizeTags(tags)
+	if len(tags) == 0 {
+		h, err := s.GetHighlightByID(ctx, id, userID)
+		if err != nil {
+			return nil, err
+		}
+
+		tags = h.SuggestedTags
+		if tags == nil {
+			tags = []string{}
+		}
+	}
+
+	update := bson.M{
+		"$addToSet": bson.M{into: bson.M{"$each": tags}},
+		"$pull":     bson.M{"suggestedTags": bson.M{"$in": tags}},
+	}
+	if set != nil {
+		update["$set"] = set
+	}
+
+	var h t.Highlight
+	err := col.FindOneAndUpdate(ctx, bson.M{
+		"_id":       id,
+		"userId":    userID,
+		"deletedAt": nil,
+	}, update, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&h)
+	if err != nil {
+		return nil, err
+	}
+
+	return &h, nil
+}
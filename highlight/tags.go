@@ -0,0 +1,118 @@
+package highlight
+
+import (
+	"context"
+	"time"
+
+	t "github.com/sikozonpc/notebase/types"
+	u "github.com/sikozonpc/notebase/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func (s *Store) AddTags(ctx context.Context, userID primitive.ObjectID, ids []primitive.ObjectID, tags []string) (int64, error) {
+	col := s.db.Collection(CollName)
+
+	query := bson.M{
+		"_id":       bson.M{"$in": ids},
+		"userId":    userID,
+		"deletedAt": nil,
+	}
+
+	res, err := col.UpdateMany(ctx, query, bson.M{
+		"$addToSet": bson.M{"tags": bson.M{"$each": u.NormalizeTags(tags)}},
+		"$set":      bson.M{"updatedAt": time.Now().UTC()},
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	s.notifyUpdated(ctx, query)
+
+	return res.ModifiedCount, nil
+}
+
+func (s *Store) RemoveTags(ctx context.Context, userID primitive.ObjectID, ids []primitive.ObjectID, tags []string) (int64, error) {
+	col := s.db.Collection(CollName)
+
+	query := bson.M{
+		"_id":       bson.M{"$in": ids},
+		"userId":    userID,
+		"deletedAt": nil,
+	}
+
+	res, err := col.UpdateMany(ctx, query, bson.M{
+		"$pull": bson.M{"tags": bson.M{"$in": u.NormalizeTags(tags)}},
+		"$set":  bson.M{"updatedAt": time.Now().UTC()},
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	s.notifyUpdated(ctx, query)
+
+	return res.ModifiedCount, nil
+}
+
+// GetUserTags returns every tag the user has, most used first
+func (s *Store) GetUserTags(ctx context.Context, userID primitive.ObjectID) ([]*t.TagCount, error) {
+	col := s.db.Collection(CollName)
+
+	cursor, err := col.Aggregate(ctx, mongo.Pipeline{
+		bson.D{{Key: "$match", Value: bson.M{"userId": userID, "deletedAt": nil}}},
+		bson.D{{Key: "$unwind", Value: "$tags"}},
+		bson.D{{Key: "$group", Value: bson.M{
+			"_id":   "$tags",
+			"count": bson.M{"$sum": 1},
+		}}},
+		bson.D{{Key: "$sort", Value: bson.D{{Key: "count", Value: -1}, {Key: "_id", Value: 1}}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	tags := make([]*t.TagCount, 0)
+	if err = cursor.All(ctx, &tags); err != nil {
+		return nil, err
+	}
+
+	return tags, nil
+}
+
+// MergeTags replaces the tags in from with into on all of the user's
+// highlights. Renaming a tag is merging it into the new name.
+func (s *Store) MergeTags(ctx context.Context, userID primitive.ObjectID, from []string, into string) (int64, error) {
+	col := s.db.Collection(CollName)
+
+	from = u.NormalizeTags(from)
+	into = u.NormalizeTag(into)
+	// Mongo stores milliseconds, so the merged highlights can be found by it
+	now := time.Now().UTC().Truncate(time.Millisecond)
+
+	res, err := col.UpdateMany(ctx, bson.M{
+		"userId":    userID,
+		"tags":      bson.M{"$in": from},
+		"deletedAt": nil,
+	}, mongo.Pipeline{
+		bson.D{{Key: "$set", Value: bson.M{
+			"tags": bson.M{"$setUnion": bson.A{
+				bson.M{"$setDifference": bson.A{"$tags", from}},
+				bson.A{into},
+			}},
+			"updatedAt": now,
+		}}},
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	// The merged highlights are the ones stamped with this update
+	s.notifyUpdated(ctx, bson.M{
+		"userId":    userID,
+		"tags":      into,
+		"updatedAt": now,
+	})
+
+	return res.ModifiedCount, nil
+}
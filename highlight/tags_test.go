@@ -0,0 +1,71 @@
+package highlight
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/sikozonpc/notebase/storage"
+	types "github.com/sikozonpc/notebase/types"
+	u "github.com/sikozonpc/notebase/utils"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestHandleSuggestedTags(t *testing.T) {
+	handler := NewHandler(&mockHighlightStore{}, &mockUserStore{}, storage.NewMemoryStorage(), &mockBookStore{}, &mockLibraryStore{}, &mockAuthorStore{}, &mockCollectionStore{}, &mockNoteStore{}, &mockLinkStore{}, &mockMailer{})
+
+	post := func(t *testing.T, action string, body string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(http.MethodPost, "/user/1/highlight/1/suggested-tags/"+action, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		router := mux.NewRouter()
+
+		router.HandleFunc("/user/{userID}/highlight/{id}/suggested-tags/accept", u.MakeHTTPHandler(handler.handleAcceptSuggestedTags))
+		router.HandleFunc("/user/{userID}/highlight/{id}/suggested-tags/reject", u.MakeHTTPHandler(handler.handleRejectSuggestedTags))
+
+		router.ServeHTTP(rr, req)
+
+		return rr
+	}
+
+	t.Run("should accept all suggested tags without a body", func(t *testing.T) {
+		fakeHighlight = &types.Highlight{ID: primitive.NewObjectID(), Tags: []string{"go"}, SuggestedTags: []string{"channels", "goroutines"}}
+
+		rr := post(t, "accept", "")
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		var h types.Highlight
+		if err := json.NewDecoder(rr.Body).Decode(&h); err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, []string{"go", "channels", "goroutines"}, h.Tags)
+		assert.Empty(t, h.SuggestedTags)
+	})
+
+	t.Run("should fail with an invalid body", func(t *testing.T) {
+		rr := post(t, "reject", "{")
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+
+	t.Run("should fail if the highlight doesn't exist", func(t *testing.T) {
+		fakeHighlight = nil
+
+		rr := post(t, "reject", `{"tags": ["go"]}`)
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("expected status code %d, got %d", http.StatusNotFound, rr.Code)
+		}
+	})
+}
@@ -0,0 +1,182 @@
+package highlight
+
+import (
+	"context"
+	"time"
+
+	t "github.com/sikozonpc/notebase/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Highlights are purged in batches so a large trash doesn't build one huge
+// query
+const purgeBatchSize = 500
+
+// DeleteBookHighlights moves all of the user's highlights of a book to the
+// trash
+func (s *Store) DeleteBookHighlights(ctx context.Context, userID primitive.ObjectID, bookID string) (int64, error) {
+	col := s.db.Collection(CollName)
+
+	query := bson.M{
+		"userId":    userID,
+		"bookId":    bookID,
+		"deletedAt": nil,
+	}
+
+	// The ids are needed to notify the hooks once deleted
+	ids, err := s.findIDs(ctx, query)
+	if err != nil || len(ids) == 0 {
+		return 0, err
+	}
+
+	res, err := col.UpdateMany(ctx, bson.M{
+		"_id":       bson.M{"$in": ids},
+		"deletedAt": nil,
+	}, bson.M{
+		"$set": bson.M{"deletedAt": time.Now().UTC()},
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	for _, id := range ids {
+		s.notifyDeleted(ctx, userID, id)
+	}
+
+	return res.ModifiedCount, nil
+}
+
+// GetDeletedHighlights returns the user's highlights in the trash, most
+// recently deleted first
+func (s *Store) GetDeletedHighlights(ctx context.Context, userID primitive.ObjectID) ([]*t.Highlight, error) {
+	col := s.db.Collection(CollName)
+
+	cursor, err := col.Find(ctx, bson.M{
+		"userId":    userID,
+		"deletedAt": bson.M{"$ne": nil},
+	}, options.Find().SetSort(bson.D{{Key: "deletedAt", Value: -1}, {Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+
+	highlights := make([]*t.Highlight, 0)
+	if err = cursor.All(ctx, &highlights); err != nil {
+		return nil, err
+	}
+
+	return highlights, nil
+}
+
+// RestoreHighlight takes the user's highlight out of the trash
+func (s *Store) RestoreHighlight(ctx context.Context, id primitive.ObjectID, userID primitive.ObjectID) (*t.Highlight, error) {
+	col := s.db.Collection(CollName)
+
+	var h t.Highlight
+	err := col.FindOneAndUpdate(ctx, bson.M{
+		"_id":       id,
+		"userId":    userID,
+		"deletedAt": bson.M{"$ne": nil},
+	}, bson.M{
+		"$unset": bson.M{"deletedAt": ""},
+	}, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&h)
+	if err != nil {
+		return nil, err
+	}
+
+	s.notifySaved(ctx, &h)
+
+	return &h, nil
+}
+
+// RestoreBookHighlights takes all of the user's highlights of a book out of
+// the trash
+func (s *Store) RestoreBookHighlights(ctx context.Context, userID primitive.ObjectID, bookID string) (int64, error) {
+	col := s.db.Collection(CollName)
+
+	query := bson.M{
+		"userId":    userID,
+		"bookId":    bookID,
+		"deletedAt": bson.M{"$ne": nil},
+	}
+
+	ids, err := s.findIDs(ctx, query)
+	if err != nil || len(ids) == 0 {
+		return 0, err
+	}
+
+	res, err := col.UpdateMany(ctx, bson.M{
+		"_id": bson.M{"$in": ids},
+	}, bson.M{
+		"$unset": bson.M{"deletedAt": ""},
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	s.notifyUpdated(ctx, bson.M{"_id": bson.M{"$in": ids}})
+
+	return res.ModifiedCount, nil
+}
+
+// PurgeDeletedHighlights permanently deletes the highlights that went to the
+// trash before the given time, with their revisions. It returns the ids of
+// the purged ones, even when it stops early.
+func (s *Store) PurgeDeletedHighlights(ctx context.Context, before time.Time) ([]primitive.ObjectID, error) {
+	col := s.db.Collection(CollName)
+
+	purged := make([]primitive.ObjectID, 0)
+	for {
+		ids, err := s.findIDs(ctx, bson.M{
+			"deletedAt": bson.M{"$lt": before},
+		}, options.Find().SetLimit(purgeBatchSize))
+		if err != nil {
+			return purged, err
+		}
+
+		if len(ids) == 0 {
+			return purged, nil
+		}
+
+		_, err = s.db.Collection(RevisionsCollName).DeleteMany(ctx, bson.M{
+			"highlightId": bson.M{"$in": ids},
+		})
+		if err != nil {
+			return purged, err
+		}
+
+		_, err = col.DeleteMany(ctx, bson.M{
+			"_id": bson.M{"$in": ids},
+		})
+		if err != nil {
+			return purged, err
+		}
+
+		purged = append(purged, ids...)
+	}
+}
+
+func (s *Store) findIDs(ctx context.Context, query bson.M, opts ...*options.FindOptions) ([]primitive.ObjectID, error) {
+	col := s.db.Collection(CollName)
+
+	opts = append(opts, options.Find().SetProjection(bson.M{"_id": 1}))
+	cursor, err := col.Find(ctx, query, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	var docs []struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+
+	ids := make([]primitive.ObjectID, len(docs))
+	for i, d := range docs {
+		ids[i] = d.ID
+	}
+
+	return ids, nil
+}
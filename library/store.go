@@ -0,0 +1,260 @@
+package library
+
+import (
+	"context"
+	"time"
+
+	t "github.com/sikozonpc/notebase/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// CollName holds which books are in each user's library. Books are shared
+// between users, what a user changes about a book is kept here.
+const CollName = "user_books"
+
+type Store struct {
+	db *mongo.Database
+}
+
+func NewStore(db *mongo.Database) *Store {
+	return &Store{db: db}
+}
+
+// AddBook adds the book to the user's library. Adding a book that is already
+// in it, or in the trash, keeps what the user changed about it.
+func (s *Store) AddBook(ctx context.Context, userID primitive.ObjectID, bookID primitive.ObjectID) (*t.LibraryBook, error) {
+	col := s.db.Collection(CollName)
+
+	now := time.Now().UTC()
+
+	var lb t.LibraryBook
+	err := col.FindOneAndUpdate(ctx, bson.M{
+		"userId": userID,
+		"bookId": bookID,
+	}, bson.M{
+		"$setOnInsert": bson.M{
+			"_id":       primitive.NewObjectID(),
+			"createdAt": now,
+			"updatedAt": now,
+		},
+		"$unset": bson.M{"deletedAt": ""},
+	}, options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)).Decode(&lb)
+	if err != nil {
+		return nil, err
+	}
+
+	return &lb, nil
+}
+
+// GetLibrary returns the books in the user's library, most recently added
+// first
+func (s *Store) GetLibrary(ctx context.Context, userID primitive.ObjectID) ([]*t.LibraryBook, error) {
+	col := s.db.Collection(CollName)
+
+	cursor, err := col.Find(ctx, bson.M{
+		"userId":    userID,
+		"deletedAt": nil,
+	}, options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}, {Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+
+	books := make([]*t.LibraryBook, 0)
+	if err = cursor.All(ctx, &books); err != nil {
+		return nil, err
+	}
+
+	return books, nil
+}
+
+func (s *Store) GetLibraryBook(ctx context.Context, userID primitive.ObjectID, bookID primitive.ObjectID) (*t.LibraryBook, error) {
+	col := s.db.Collection(CollName)
+
+	var lb t.LibraryBook
+	err := col.FindOne(ctx, bson.M{
+		"userId":    userID,
+		"bookId":    bookID,
+		"deletedAt": nil,
+	}).Decode(&lb)
+	if err != nil {
+		return nil, err
+	}
+
+	return &lb, nil
+}
+
+// UpdateLibraryBook changes the book for the user only. An empty title or
+// authors goes back to the book's own.
+func (s *Store) UpdateLibraryBook(ctx context.Context, userID primitive.ObjectID, bookID primitive.ObjectID, req *t.UpdateLibraryBookRequest) (*t.LibraryBook, error) {
+	col := s.db.Collection(CollName)
+
+	set := bson.M{
+		"updatedAt": time.Now().UTC(),
+	}
+	unset := bson.M{}
+
+	overrides := map[string]*string{
+		"title":   req.Title,
+		"authors": req.Authors,
+		"status":  req.Status,
+	}
+	for field, v := range overrides {
+		if v == nil {
+			continue
+		}
+
+		if *v == "" {
+			unset[field] = ""
+		} else {
+			set[field] = *v
+		}
+	}
+
+	if req.StartedAt != nil {
+		set["startedAt"] = *req.StartedAt
+	}
+	if req.FinishedAt != nil {
+		set["finishedAt"] = *req.FinishedAt
+	}
+
+	update := bson.M{"$set": set}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+
+	var lb t.LibraryBook
+	err := col.FindOneAndUpdate(ctx, bson.M{
+		"userId":    userID,
+		"bookId":    bookID,
+		"deletedAt": nil,
+	}, update, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&lb)
+	if err != nil {
+		return nil, err
+	}
+
+	return &lb, nil
+}
+
+// SetProgress records how far the user got in the book
+func (s *Store) SetProgress(ctx context.Context, userID primitive.ObjectID, bookID primitive.ObjectID, progress *t.ReadingProgress) (*t.LibraryBook, error) {
+	col := s.db.Collection(CollName)
+
+	var lb t.LibraryBook
+	err := col.FindOneAndUpdate(ctx, bson.M{
+		"userId":    userID,
+		"bookId":    bookID,
+		"deletedAt": nil,
+	}, bson.M{
+		"$set": bson.M{
+			"progress":  progress,
+			"updatedAt": time.Now().UTC(),
+		},
+	}, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&lb)
+	if err != nil {
+		return nil, err
+	}
+
+	return &lb, nil
+}
+
+// RemoveBook moves the book out of the user's library to the trash
+func (s *Store) RemoveBook(ctx context.Context, userID primitive.ObjectID, bookID primitive.ObjectID) error {
+	col := s.db.Collection(CollName)
+
+	res, err := col.UpdateOne(ctx, bson.M{
+		"userId":    userID,
+		"bookId":    bookID,
+		"deletedAt": nil,
+	}, bson.M{
+		"$set": bson.M{"deletedAt": time.Now().UTC()},
+	})
+	if err != nil {
+		return err
+	}
+
+	if res.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+
+	return nil
+}
+
+// CountBookReaders counts the users with the book in their library
+func (s *Store) CountBookReaders(ctx context.Context, bookID primitive.ObjectID) (int64, error) {
+	col := s.db.Collection(CollName)
+
+	return col.CountDocuments(ctx, bson.M{
+		"bookId":    bookID,
+		"deletedAt": nil,
+	})
+}
+
+// MoveBooks moves the books in the user's library to another one, after they
+// were merged into it, or in every library when userID is nil. A user who
+// already has the other book keeps their entry of it, which comes out of the
+// trash if the moved book was in their library.
+func (s *Store) MoveBooks(ctx context.Context, userID *primitive.ObjectID, from []primitive.ObjectID, to primitive.ObjectID) error {
+	col := s.db.Collection(CollName)
+
+	query := bson.M{"bookId": bson.M{"$in": from}}
+	if userID != nil {
+		query["userId"] = *userID
+	}
+
+	cursor, err := col.Find(ctx, query)
+	if err != nil {
+		return err
+	}
+
+	entries := make([]*t.LibraryBook, 0)
+	if err = cursor.All(ctx, &entries); err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		_, err := col.UpdateByID(ctx, e.ID, bson.M{
+			"$set": bson.M{"bookId": to},
+		})
+		if err == nil {
+			continue
+		}
+		if !mongo.IsDuplicateKeyError(err) {
+			return err
+		}
+
+		if e.DeletedAt == nil {
+			if _, err := col.UpdateOne(ctx, bson.M{
+				"userId": e.UserID,
+				"bookId": to,
+			}, bson.M{
+				"$unset": bson.M{"deletedAt": ""},
+			}); err != nil {
+				return err
+			}
+		}
+
+		if _, err := col.DeleteOne(ctx, bson.M{"_id": e.ID}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// PurgeDeleted permanently removes the books that went to the trash before
+// the given time from the libraries
+func (s *Store) PurgeDeleted(ctx context.Context, before time.Time) (int64, error) {
+	col := s.db.Collection(CollName)
+
+	res, err := col.DeleteMany(ctx, bson.M{
+		"deletedAt": bson.M{"$lt": before},
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return res.DeletedCount, nil
+}
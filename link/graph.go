@@ -0,0 +1,71 @@
+package link
+
+import (
+	t "github.com/sikozonpc/notebase/types"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// BuildGraph resolves the links between the highlights and notes. Every note
+// is a node, highlights only when linked, so the graph isn't buried under
+// the unlinked ones. A title shared by several notes links to all of them,
+// and links to nothing the user has, or to themselves, are left out.
+func BuildGraph(links []*t.Link, highlights []*t.Highlight, notes []*t.Note) *t.LinkGraph {
+	graph := &t.LinkGraph{
+		Nodes: make([]*t.GraphNode, 0, len(notes)),
+		Edges: make([]*t.GraphEdge, 0),
+	}
+
+	byID := make(map[primitive.ObjectID]*t.GraphNode)
+	byTarget := make(map[string][]*t.GraphNode)
+
+	for _, n := range notes {
+		title := Title(n.Body)
+		node := &t.GraphNode{ID: n.ID, Type: t.LinkNodeNote, Label: title}
+
+		byID[n.ID] = node
+		byTarget[n.ID.Hex()] = append(byTarget[n.ID.Hex()], node)
+		if key := Normalize(title); key != "" {
+			byTarget[key] = append(byTarget[key], node)
+		}
+
+		graph.Nodes = append(graph.Nodes, node)
+	}
+
+	for _, h := range highlights {
+		node := &t.GraphNode{ID: h.ID, Type: t.LinkNodeHighlight, Label: label(h.Text)}
+
+		byID[h.ID] = node
+		byTarget[h.ID.Hex()] = append(byTarget[h.ID.Hex()], node)
+	}
+
+	type edge struct{ source, target primitive.ObjectID }
+	seen := make(map[edge]bool)
+	linked := make(map[primitive.ObjectID]bool)
+
+	for _, l := range links {
+		source, ok := byID[l.SourceID]
+		if !ok || source.Type != l.SourceType {
+			continue
+		}
+
+		for _, target := range byTarget[l.Target] {
+			e := edge{source.ID, target.ID}
+			if source.ID == target.ID || seen[e] {
+				continue
+			}
+
+			seen[e] = true
+			linked[source.ID] = true
+			linked[target.ID] = true
+			graph.Edges = append(graph.Edges, &t.GraphEdge{Source: source.ID, Target: target.ID})
+		}
+	}
+
+	for _, h := range highlights {
+		if linked[h.ID] {
+			graph.Nodes = append(graph.Nodes, byID[h.ID])
+		}
+	}
+
+	return graph
+}
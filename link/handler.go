@@ -0,0 +1,206 @@
+package link
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/sikozonpc/notebase/auth"
+	t "github.com/sikozonpc/notebase/types"
+	u "github.com/sikozonpc/notebase/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type Handler struct {
+	store          t.LinkStore
+	highlightStore t.HighlightStore
+	noteStore      t.NoteStore
+	userStore      t.UserStore
+}
+
+func NewHandler(store t.LinkStore, highlightStore t.HighlightStore, noteStore t.NoteStore, userStore t.UserStore) *Handler {
+	return &Handler{
+		store:          store,
+		highlightStore: highlightStore,
+		noteStore:      noteStore,
+		userStore:      userStore,
+	}
+}
+
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc(
+		"/user/{userID}/highlight/{id}/backlinks",
+		auth.WithJWTAuth(u.MakeHTTPHandler(h.handleGetHighlightBacklinks), h.userStore),
+	).Methods("GET")
+
+	router.HandleFunc(
+		"/user/{userID}/notes/{id}/backlinks",
+		auth.WithJWTAuth(u.MakeHTTPHandler(h.handleGetNoteBacklinks), h.userStore),
+	).Methods("GET")
+
+	router.HandleFunc(
+		"/user/{userID}/graph",
+		auth.WithJWTAuth(u.MakeHTTPHandler(h.handleGetGraph), h.userStore),
+	).Methods("GET")
+}
+
+func (h *Handler) handleGetHighlightBacklinks(w http.ResponseWriter, r *http.Request) error {
+	oUserID, oID, err := getIDsFromRequest(r)
+	if err != nil {
+		return err
+	}
+
+	_, err = h.highlightStore.GetHighlightByID(r.Context(), oID, oUserID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return notFound(w, t.LinkNodeHighlight, oID)
+	}
+	if err != nil {
+		return err
+	}
+
+	backlinks, err := h.backlinks(r.Context(), oUserID, oID, []string{oID.Hex()})
+	if err != nil {
+		return err
+	}
+
+	return u.WriteJSON(w, http.StatusOK, backlinks)
+}
+
+func (h *Handler) handleGetNoteBacklinks(w http.ResponseWriter, r *http.Request) error {
+	oUserID, oID, err := getIDsFromRequest(r)
+	if err != nil {
+		return err
+	}
+
+	n, err := h.noteStore.GetNoteByID(r.Context(), oID, oUserID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return notFound(w, t.LinkNodeNote, oID)
+	}
+	if err != nil {
+		return err
+	}
+
+	targets := []string{oID.Hex()}
+	if title := Normalize(Title(n.Body)); title != "" {
+		targets = append(targets, title)
+	}
+
+	backlinks, err := h.backlinks(r.Context(), oUserID, oID, targets)
+	if err != nil {
+		return err
+	}
+
+	return u.WriteJSON(w, http.StatusOK, backlinks)
+}
+
+// handleGetGraph returns the user's notes and linked highlights with the
+// links between them
+func (h *Handler) handleGetGraph(w http.ResponseWriter, r *http.Request) error {
+	userID, err := u.GetStringParamFromRequest(r, "userID")
+	if err != nil {
+		return err
+	}
+	oUserID, _ := primitive.ObjectIDFromHex(userID)
+
+	links, err := h.store.GetUserLinks(r.Context(), oUserID)
+	if err != nil {
+		return err
+	}
+
+	notes, err := h.noteStore.GetUserNotes(r.Context(), oUserID, nil)
+	if err != nil {
+		return err
+	}
+
+	// Only the highlights written in or pointed to by a link can be in the
+	// graph
+	ids := make([]primitive.ObjectID, 0)
+	for _, l := range links {
+		if l.SourceType == t.LinkNodeHighlight {
+			ids = append(ids, l.SourceID)
+		}
+		if oID, err := primitive.ObjectIDFromHex(l.Target); err == nil {
+			ids = append(ids, oID)
+		}
+	}
+
+	page, err := h.highlightStore.GetUserHighlights(r.Context(), oUserID, &t.HighlightFilter{IDs: ids})
+	if err != nil {
+		return err
+	}
+
+	return u.WriteJSON(w, http.StatusOK, BuildGraph(links, page.Highlights, notes))
+}
+
+// backlinks returns the highlights and notes linking to any of the targets,
+// except the one they all name
+func (h *Handler) backlinks(ctx context.Context, userID, id primitive.ObjectID, targets []string) ([]*t.Backlink, error) {
+	links, err := h.store.GetLinksTo(ctx, userID, targets)
+	if err != nil {
+		return nil, err
+	}
+
+	highlightIDs := make([]primitive.ObjectID, 0)
+	noteIDs := make([]primitive.ObjectID, 0)
+	for _, l := range links {
+		if l.SourceID == id {
+			continue
+		}
+
+		switch l.SourceType {
+		case t.LinkNodeHighlight:
+			highlightIDs = append(highlightIDs, l.SourceID)
+		case t.LinkNodeNote:
+			noteIDs = append(noteIDs, l.SourceID)
+		}
+	}
+
+	backlinks := make([]*t.Backlink, 0, len(highlightIDs)+len(noteIDs))
+
+	if len(highlightIDs) > 0 {
+		page, err := h.highlightStore.GetUserHighlights(ctx, userID, &t.HighlightFilter{IDs: highlightIDs})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, hl := range page.Highlights {
+			backlinks = append(backlinks, &t.Backlink{Type: t.LinkNodeHighlight, Highlight: hl})
+		}
+	}
+
+	if len(noteIDs) > 0 {
+		notes, err := h.noteStore.GetUserNotes(ctx, userID, &t.NoteFilter{IDs: noteIDs})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, n := range notes {
+			backlinks = append(backlinks, &t.Backlink{Type: t.LinkNodeNote, Note: n})
+		}
+	}
+
+	return backlinks, nil
+}
+
+func getIDsFromRequest(r *http.Request) (primitive.ObjectID, primitive.ObjectID, error) {
+	userID, err := u.GetStringParamFromRequest(r, "userID")
+	if err != nil {
+		return primitive.NilObjectID, primitive.NilObjectID, err
+	}
+	oUserID, _ := primitive.ObjectIDFromHex(userID)
+
+	id, err := u.GetStringParamFromRequest(r, "id")
+	if err != nil {
+		return primitive.NilObjectID, primitive.NilObjectID, err
+	}
+	oID, _ := primitive.ObjectIDFromHex(id)
+
+	return oUserID, oID, nil
+}
+
+func notFound(w http.ResponseWriter, nodeType t.LinkNodeType, id primitive.ObjectID) error {
+	return u.WriteJSON(w, http.StatusNotFound, t.APIError{Error: fmt.Errorf("%s with id %v not found", nodeType, id.Hex()).Error()})
+}
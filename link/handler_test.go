@@ -0,0 +1,256 @@
+package link
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	types "github.com/sikozonpc/notebase/types"
+	u "github.com/sikozonpc/notebase/utils"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestLinkHandler(t *testing.T) {
+	userID := primitive.NewObjectID()
+
+	deepWork := &types.Note{ID: primitive.NewObjectID(), UserID: userID, Body: "# Deep Work\n\nFocus is a skill."}
+	index := &types.Note{ID: primitive.NewObjectID(), UserID: userID, Body: "Index\n\n[[deep work]] and [[Deep Work]]"}
+	unlinked := &types.Note{ID: primitive.NewObjectID(), UserID: userID, Body: "Nothing here [[missing]]"}
+
+	focus := &types.Highlight{ID: primitive.NewObjectID(), UserID: userID, Text: "Clarity about what matters provides clarity about what does not.", Note: "[[Deep Work]]"}
+	quoted := &types.Highlight{ID: primitive.NewObjectID(), UserID: userID, Text: "Who you are is what you focus on."}
+	alone := &types.Highlight{ID: primitive.NewObjectID(), UserID: userID, Text: "Not linked"}
+
+	linkStore := &mockLinkStore{}
+	linker := NewLinker(linkStore)
+	for _, n := range []*types.Note{deepWork, index, unlinked} {
+		linkStore.SetLinks(context.Background(), userID, types.LinkNodeNote, n.ID, Parse(n.Body))
+	}
+	for _, h := range []*types.Highlight{focus, quoted, alone} {
+		linker.HighlightSaved(context.Background(), h)
+	}
+
+	// A note quoting a highlight by its id
+	linkStore.SetLinks(context.Background(), userID, types.LinkNodeNote, deepWork.ID, []string{quoted.ID.Hex(), deepWork.ID.Hex()})
+
+	handler := NewHandler(
+		linkStore,
+		&mockHighlightStore{highlights: []*types.Highlight{focus, quoted, alone}},
+		&mockNoteStore{notes: []*types.Note{deepWork, index, unlinked}},
+		&mockUserStore{},
+	)
+
+	get := func(t *testing.T, path string, v any) int {
+		req, err := http.NewRequest(http.MethodGet, "/user/"+userID.Hex()+path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		router := mux.NewRouter()
+
+		router.HandleFunc("/user/{userID}/highlight/{id}/backlinks", u.MakeHTTPHandler(handler.handleGetHighlightBacklinks)).Methods(http.MethodGet)
+		router.HandleFunc("/user/{userID}/notes/{id}/backlinks", u.MakeHTTPHandler(handler.handleGetNoteBacklinks)).Methods(http.MethodGet)
+		router.HandleFunc("/user/{userID}/graph", u.MakeHTTPHandler(handler.handleGetGraph)).Methods(http.MethodGet)
+
+		router.ServeHTTP(rr, req)
+
+		if rr.Code == http.StatusOK {
+			if err := json.NewDecoder(rr.Body).Decode(v); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		return rr.Code
+	}
+
+	t.Run("should find the backlinks of a note by title", func(t *testing.T) {
+		var backlinks []*types.Backlink
+		if code := get(t, "/notes/"+deepWork.ID.Hex()+"/backlinks", &backlinks); code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d", http.StatusOK, code)
+		}
+
+		if assert.Len(t, backlinks, 2) {
+			assert.Equal(t, focus.ID, backlinks[0].Highlight.ID)
+			assert.Equal(t, index.ID, backlinks[1].Note.ID)
+		}
+	})
+
+	t.Run("should find the backlinks of a highlight by id", func(t *testing.T) {
+		var backlinks []*types.Backlink
+		if code := get(t, "/highlight/"+quoted.ID.Hex()+"/backlinks", &backlinks); code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d", http.StatusOK, code)
+		}
+
+		if assert.Len(t, backlinks, 1) {
+			assert.Equal(t, types.LinkNodeNote, backlinks[0].Type)
+			assert.Equal(t, deepWork.ID, backlinks[0].Note.ID)
+		}
+	})
+
+	t.Run("should not find the backlinks of a missing note", func(t *testing.T) {
+		var backlinks []*types.Backlink
+		if code := get(t, "/notes/"+primitive.NewObjectID().Hex()+"/backlinks", &backlinks); code != http.StatusNotFound {
+			t.Fatalf("expected status code %d, got %d", http.StatusNotFound, code)
+		}
+	})
+
+	t.Run("should return the graph of linked highlights and every note", func(t *testing.T) {
+		var graph types.LinkGraph
+		if code := get(t, "/graph", &graph); code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d", http.StatusOK, code)
+		}
+
+		nodes := make(map[primitive.ObjectID]*types.GraphNode)
+		for _, n := range graph.Nodes {
+			nodes[n.ID] = n
+		}
+
+		assert.Len(t, graph.Nodes, 5)
+		assert.Equal(t, "Deep Work", nodes[deepWork.ID].Label)
+		assert.Contains(t, nodes, unlinked.ID)
+		assert.NotContains(t, nodes, alone.ID)
+
+		// Links to itself, twice to the same note or to nothing are left out
+		assert.ElementsMatch(t, []*types.GraphEdge{
+			{Source: index.ID, Target: deepWork.ID},
+			{Source: focus.ID, Target: deepWork.ID},
+			{Source: deepWork.ID, Target: quoted.ID},
+		}, graph.Edges)
+	})
+
+	t.Run("should drop a trashed highlight's links", func(t *testing.T) {
+		trashed := *focus
+		trashed.DeletedAt = &trashed.CreatedAt
+		linker.HighlightSaved(context.Background(), &trashed)
+
+		links, _ := linkStore.GetLinksTo(context.Background(), userID, []string{"deep work"})
+		for _, l := range links {
+			assert.NotEqual(t, focus.ID, l.SourceID)
+		}
+
+		linker.HighlightSaved(context.Background(), focus)
+	})
+}
+
+type mockLinkStore struct {
+	types.LinkStore
+	links []*types.Link
+}
+
+func (m *mockLinkStore) SetLinks(ctx context.Context, userID primitive.ObjectID, sourceType types.LinkNodeType, sourceID primitive.ObjectID, targets []string) error {
+	m.DeleteLinks(ctx, userID, sourceType, sourceID)
+
+	for _, target := range targets {
+		m.links = append(m.links, &types.Link{ID: primitive.NewObjectID(), UserID: userID, SourceType: sourceType, SourceID: sourceID, Target: target})
+	}
+
+	return nil
+}
+
+func (m *mockLinkStore) DeleteLinks(_ context.Context, userID primitive.ObjectID, sourceType types.LinkNodeType, sourceID primitive.ObjectID) error {
+	kept := make([]*types.Link, 0, len(m.links))
+	for _, l := range m.links {
+		if l.UserID != userID || l.SourceType != sourceType || l.SourceID != sourceID {
+			kept = append(kept, l)
+		}
+	}
+	m.links = kept
+
+	return nil
+}
+
+func (m *mockLinkStore) GetLinksTo(_ context.Context, userID primitive.ObjectID, targets []string) ([]*types.Link, error) {
+	links := make([]*types.Link, 0)
+	for _, l := range m.links {
+		for _, target := range targets {
+			if l.UserID == userID && l.Target == target {
+				links = append(links, l)
+			}
+		}
+	}
+
+	return links, nil
+}
+
+func (m *mockLinkStore) GetUserLinks(_ context.Context, userID primitive.ObjectID) ([]*types.Link, error) {
+	links := make([]*types.Link, 0)
+	for _, l := range m.links {
+		if l.UserID == userID {
+			links = append(links, l)
+		}
+	}
+
+	return links, nil
+}
+
+type mockHighlightStore struct {
+	types.HighlightStore
+	highlights []*types.Highlight
+}
+
+func (m *mockHighlightStore) GetHighlightByID(_ context.Context, id primitive.ObjectID, userID primitive.ObjectID) (*types.Highlight, error) {
+	for _, h := range m.highlights {
+		if h.ID == id && h.UserID == userID {
+			return h, nil
+		}
+	}
+
+	return nil, mongo.ErrNoDocuments
+}
+
+func (m *mockHighlightStore) GetUserHighlights(_ context.Context, userID primitive.ObjectID, filter *types.HighlightFilter) (*types.HighlightPage, error) {
+	hs := make([]*types.Highlight, 0)
+	for _, h := range m.highlights {
+		if h.UserID == userID && (filter == nil || filter.IDs == nil || contains(filter.IDs, h.ID)) {
+			hs = append(hs, h)
+		}
+	}
+
+	return &types.HighlightPage{Highlights: hs, Total: int64(len(hs))}, nil
+}
+
+type mockNoteStore struct {
+	types.NoteStore
+	notes []*types.Note
+}
+
+func (m *mockNoteStore) GetNoteByID(_ context.Context, id primitive.ObjectID, userID primitive.ObjectID) (*types.Note, error) {
+	for _, n := range m.notes {
+		if n.ID == id && n.UserID == userID {
+			return n, nil
+		}
+	}
+
+	return nil, mongo.ErrNoDocuments
+}
+
+func (m *mockNoteStore) GetUserNotes(_ context.Context, userID primitive.ObjectID, filter *types.NoteFilter) ([]*types.Note, error) {
+	notes := make([]*types.Note, 0)
+	for _, n := range m.notes {
+		if n.UserID == userID && (filter == nil || filter.IDs == nil || contains(filter.IDs, n.ID)) {
+			notes = append(notes, n)
+		}
+	}
+
+	return notes, nil
+}
+
+type mockUserStore struct {
+	types.UserStore
+}
+
+func contains(ids []primitive.ObjectID, id primitive.ObjectID) bool {
+	for _, i := range ids {
+		if i == id {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,41 @@
+package link
+
+import (
+	"context"
+	"log"
+
+	t "github.com/sikozonpc/notebase/types"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Linker keeps the links written in highlights' notes up to date as a hook
+// on the highlight store. The highlight is already written when it's
+// notified, so a failure is only logged.
+type Linker struct {
+	store t.LinkStore
+}
+
+func NewLinker(store t.LinkStore) *Linker {
+	return &Linker{store: store}
+}
+
+func (l *Linker) HighlightSaved(ctx context.Context, h *t.Highlight) {
+	var err error
+	if h.DeletedAt != nil {
+		err = l.store.DeleteLinks(ctx, h.UserID, t.LinkNodeHighlight, h.ID)
+	} else {
+		err = l.store.SetLinks(ctx, h.UserID, t.LinkNodeHighlight, h.ID, Parse(h.Note))
+	}
+
+	if err != nil {
+		log.Printf("failed to update the links of highlight %s: %v", h.ID.Hex(), err)
+	}
+}
+
+// HighlightDeleted drops the links written in a highlight, the ones to it
+// are kept in case it's restored from the trash
+func (l *Linker) HighlightDeleted(ctx context.Context, userID, id primitive.ObjectID) {
+	if err := l.store.DeleteLinks(ctx, userID, t.LinkNodeHighlight, id); err != nil {
+		log.Printf("failed to delete the links of highlight %s: %v", id.Hex(), err)
+	}
+}
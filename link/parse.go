@@ -0,0 +1,78 @@
+package link
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// LabelLength is how much of a highlight's text labels it in the graph
+const LabelLength = 80
+
+// Wiki-links are written [[target]] or [[target|label]], the label is only
+// for display. A target is the id of a highlight or note, or a note's title.
+var wikiLink = regexp.MustCompile(`\[\[([^\[\]]+)\]\]`)
+
+// Parse returns the normalized targets of the wiki-links in a text, once each
+func Parse(text string) []string {
+	targets := make([]string, 0)
+	seen := make(map[string]bool)
+
+	for _, m := range wikiLink.FindAllStringSubmatch(text, -1) {
+		target, _, _ := strings.Cut(m[1], "|")
+		target = Normalize(target)
+		if target == "" || seen[target] {
+			continue
+		}
+
+		seen[target] = true
+		targets = append(targets, target)
+	}
+
+	return targets
+}
+
+// Replace points the wiki-links to any of the targets in from to another
+// target instead, keeping their labels
+func Replace(text string, from []string, to string) string {
+	return wikiLink.ReplaceAllStringFunc(text, func(m string) string {
+		target, label, hasLabel := strings.Cut(m[2:len(m)-2], "|")
+		if !slices.Contains(from, Normalize(target)) {
+			return m
+		}
+
+		if hasLabel {
+			return "[[" + to + "|" + label + "]]"
+		}
+		return "[[" + to + "]]"
+	})
+}
+
+// Normalize makes a link match its target whatever the case and spacing it
+// was written with
+func Normalize(target string) string {
+	return strings.ToLower(strings.Join(strings.Fields(target), " "))
+}
+
+// Title is what a note can be linked by besides its id: its first line,
+// without the Markdown heading marks
+func Title(body string) string {
+	for _, line := range strings.Split(body, "\n") {
+		line = strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(line), "#"))
+		if line != "" {
+			return line
+		}
+	}
+
+	return ""
+}
+
+func label(text string) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if utf8.RuneCountInString(text) <= LabelLength {
+		return text
+	}
+
+	return string([]rune(text)[:LabelLength]) + "…"
+}
@@ -0,0 +1,38 @@
+package link
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	t.Run("should find every link once", func(t *testing.T) {
+		targets := Parse("Like [[Deep Work]], see [[deep  work|the book]] and [[65f1c0a2b3c4d5e6f7a8b9c0]]")
+
+		assert.Equal(t, []string{"deep work", "65f1c0a2b3c4d5e6f7a8b9c0"}, targets)
+	})
+
+	t.Run("should skip empty and unclosed links", func(t *testing.T) {
+		assert.Empty(t, Parse("[[ ]] [[|label]] [[unclosed and [single]"))
+	})
+
+	t.Run("should replace the links to the targets", func(t *testing.T) {
+		text := Replace("See [[65F1C0A2B3C4D5E6F7A8B9C0|this]], [[ 65f1c0a2b3c4d5e6f7a8b9c0 ]] and [[Deep Work]]", []string{"65f1c0a2b3c4d5e6f7a8b9c0"}, "65f1c0a2b3c4d5e6f7a8b9c1")
+
+		assert.Equal(t, "See [[65f1c0a2b3c4d5e6f7a8b9c1|this]], [[65f1c0a2b3c4d5e6f7a8b9c1]] and [[Deep Work]]", text)
+	})
+
+	t.Run("should title a note by its first line", func(t *testing.T) {
+		assert.Equal(t, "Deep Work", Title("\n## Deep Work\n\nFocus is a skill."))
+		assert.Equal(t, "", Title("  \n"))
+	})
+
+	t.Run("should shorten long labels", func(t *testing.T) {
+		long := strings.Repeat("é", LabelLength+10)
+
+		assert.Equal(t, "short text", label("short\n\ntext"))
+		assert.Equal(t, strings.Repeat("é", LabelLength)+"…", label(long))
+	})
+}
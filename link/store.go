@@ -0,0 +1,101 @@
+package link
+
+import (
+	"context"
+
+	t "github.com/sikozonpc/notebase/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const CollName = "links"
+
+type Store struct {
+	db *mongo.Database
+}
+
+func NewStore(db *mongo.Database) *Store {
+	return &Store{db: db}
+}
+
+// SetLinks replaces the links written in a highlight or note with the ones
+// to the targets
+func (s *Store) SetLinks(ctx context.Context, userID primitive.ObjectID, sourceType t.LinkNodeType, sourceID primitive.ObjectID, targets []string) error {
+	if err := s.DeleteLinks(ctx, userID, sourceType, sourceID); err != nil {
+		return err
+	}
+
+	if len(targets) == 0 {
+		return nil
+	}
+
+	docs := make([]interface{}, len(targets))
+	for i, target := range targets {
+		docs[i] = &t.Link{
+			ID:         primitive.NewObjectID(),
+			UserID:     userID,
+			SourceType: sourceType,
+			SourceID:   sourceID,
+			Target:     target,
+		}
+	}
+
+	_, err := s.db.Collection(CollName).InsertMany(ctx, docs)
+
+	return err
+}
+
+func (s *Store) DeleteLinks(ctx context.Context, userID primitive.ObjectID, sourceType t.LinkNodeType, sourceID primitive.ObjectID) error {
+	col := s.db.Collection(CollName)
+
+	_, err := col.DeleteMany(ctx, bson.M{
+		"userId":     userID,
+		"sourceType": sourceType,
+		"sourceId":   sourceID,
+	})
+
+	return err
+}
+
+// DeleteLinksTo removes every user's links to the targets, once what they
+// point to is deleted for good
+func (s *Store) DeleteLinksTo(ctx context.Context, targets []string) error {
+	col := s.db.Collection(CollName)
+
+	_, err := col.DeleteMany(ctx, bson.M{
+		"target": bson.M{"$in": targets},
+	})
+
+	return err
+}
+
+// GetLinksTo returns the user's links to any of the targets, in the order
+// they were written
+func (s *Store) GetLinksTo(ctx context.Context, userID primitive.ObjectID, targets []string) ([]*t.Link, error) {
+	return s.find(ctx, bson.M{
+		"userId": userID,
+		"target": bson.M{"$in": targets},
+	})
+}
+
+func (s *Store) GetUserLinks(ctx context.Context, userID primitive.ObjectID) ([]*t.Link, error) {
+	return s.find(ctx, bson.M{"userId": userID})
+}
+
+func (s *Store) find(ctx context.Context, query bson.M) ([]*t.Link, error) {
+	col := s.db.Collection(CollName)
+
+	cursor, err := col.Find(ctx, query, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+
+	links := make([]*t.Link, 0)
+	if err = cursor.All(ctx, &links); err != nil {
+		return nil, err
+	}
+
+	return links, nil
+}
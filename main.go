@@ -1,20 +1,50 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"os"
 
+	"github.com/sikozonpc/notebase/book"
 	"github.com/sikozonpc/notebase/config"
 	"github.com/sikozonpc/notebase/db"
+	"github.com/sikozonpc/notebase/search"
 )
 
 func main() {
-	mongoClient, err := db.ConnectToMongo(config.Envs.MongoURI)
+	mongoClient, err := db.ConnectToMongo(config.Envs)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	server := NewAPIServer(fmt.Sprintf(":%s", config.Envs.Port), mongoClient)
+	// Migrations always run on startup, `notebase migrate` runs them and exits
+	database := mongoClient.Database(config.Envs.MongoDBName)
+	if err := db.Migrate(context.Background(), database, db.Migrations); err != nil {
+		log.Fatal(err)
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		log.Println("Migrations applied")
+		return
+	}
+
+	// `notebase reindex` rebuilds the embedded search index and exits
+	if len(os.Args) > 1 && os.Args[1] == "reindex" {
+		index := search.NewIndex(book.NewStore(database))
+		if err := index.Rebuild(context.Background(), database); err != nil {
+			log.Fatal(err)
+		}
+
+		if err := index.Save(config.Envs.SearchIndexPath); err != nil {
+			log.Fatal(err)
+		}
+
+		log.Printf("Indexed %d highlights", index.Len())
+		return
+	}
+
+	server := NewAPIServer(fmt.Sprintf(":%s", config.Envs.Port), database)
 	if err := server.Run(); err != nil {
 		log.Fatal(err)
 	}
@@ -14,10 +14,14 @@ func TestBuildInsightsMailTemplate(t *testing.T) {
 		t.Run("BuildInsightsMailTemplate should return a list with insights", func(t *testing.T) {
 			insights := []*types.DailyInsight{
 				{
-					Text:        "This is an insight",
-					Note:        "This is a note",
-					BookAuthors: "John Doe",
-					BookTitle:   "Gopher",
+					Text:         "This is an insight",
+					Note:         "This is a note",
+					BookAuthors:  "John Doe",
+					BookTitle:    "Gopher",
+					BookCoverURL: "https://notebase.example/api/v1/books/1/cover?size=thumb",
+					Related: []*types.DailyInsight{
+						{Text: "This is a related insight", BookTitle: "Gopher"},
+					},
 				},
 			}
 
@@ -50,6 +54,32 @@ func TestBuildInsightsMailTemplate(t *testing.T) {
 			if !bytes.Contains([]byte(html), []byte("Gopher")) {
 				t.Errorf("BuildInsightsMailTemplate() = %v; want %v", html, "html")
 			}
+
+			if !bytes.Contains([]byte(html), []byte("https://notebase.example/api/v1/books/1/cover?size=thumb")) {
+				t.Errorf("BuildInsightsMailTemplate() = %v; want %v", html, "html")
+			}
+
+			if !bytes.Contains([]byte(html), []byte("This is a related insight")) {
+				t.Errorf("BuildInsightsMailTemplate() = %v; want %v", html, "html")
+			}
+		})
+
+		t.Run("BuildInsightsMailTemplate should send a standalone note without quotes", func(t *testing.T) {
+			insights := []*types.DailyInsight{
+				{Note: "A thought of my own"},
+			}
+
+			u := &types.User{FirstName: "Test", ID: primitive.NewObjectID()}
+
+			html := BuildInsightsMailTemplate("../template", u, insights, "some-random-token")
+
+			if !bytes.Contains([]byte(html), []byte("A thought of my own")) {
+				t.Errorf("BuildInsightsMailTemplate() = %v; want %v", html, "the note")
+			}
+
+			if bytes.Contains([]byte(html), []byte("<em>")) {
+				t.Errorf("BuildInsightsMailTemplate() = %v; want no empty quote or book", html)
+			}
 		})
 	}
 }
@@ -0,0 +1,122 @@
+package metadata
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	t "github.com/sikozonpc/notebase/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Cache keeps the merged answers of the providers, so a book shared by many
+// users or enriched again is not looked up twice. Not knowing a book is
+// cached too.
+type Cache interface {
+	Get(ctx context.Context, key string) (*t.BookMetadata, bool, error)
+	Set(ctx context.Context, key string, md *t.BookMetadata) error
+	Delete(ctx context.Context, key string) error
+}
+
+type cacheEntry struct {
+	Metadata *t.BookMetadata `bson:"metadata"`
+	CachedAt time.Time       `bson:"cachedAt"`
+}
+
+type MemoryCache struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+}
+
+func NewMemoryCache(ttl time.Duration) *MemoryCache {
+	return &MemoryCache{
+		ttl:     ttl,
+		entries: make(map[string]cacheEntry),
+	}
+}
+
+func (c *MemoryCache) Get(_ context.Context, key string) (*t.BookMetadata, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok || time.Since(e.CachedAt) > c.ttl {
+		return nil, false, nil
+	}
+
+	return e.Metadata, true, nil
+}
+
+func (c *MemoryCache) Set(_ context.Context, key string, md *t.BookMetadata) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cached := *md
+	c.entries[key] = cacheEntry{Metadata: &cached, CachedAt: time.Now()}
+
+	return nil
+}
+
+func (c *MemoryCache) Delete(_ context.Context, key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.entries, key)
+
+	return nil
+}
+
+// CollName holds the cached provider answers, keyed by query
+const CollName = "book_metadata"
+
+// Store is a Cache kept in Mongo, so it survives restarts
+type Store struct {
+	db  *mongo.Database
+	ttl time.Duration
+}
+
+func NewStore(db *mongo.Database, ttl time.Duration) *Store {
+	return &Store{db: db, ttl: ttl}
+}
+
+func (s *Store) Get(ctx context.Context, key string) (*t.BookMetadata, bool, error) {
+	col := s.db.Collection(CollName)
+
+	var e cacheEntry
+	err := col.FindOne(ctx, bson.M{
+		"_id":      key,
+		"cachedAt": bson.M{"$gt": time.Now().UTC().Add(-s.ttl)},
+	}).Decode(&e)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	return e.Metadata, true, nil
+}
+
+func (s *Store) Set(ctx context.Context, key string, md *t.BookMetadata) error {
+	col := s.db.Collection(CollName)
+
+	_, err := col.ReplaceOne(ctx, bson.M{"_id": key}, cacheEntry{
+		Metadata: md,
+		CachedAt: time.Now().UTC(),
+	}, options.Replace().SetUpsert(true))
+
+	return err
+}
+
+func (s *Store) Delete(ctx context.Context, key string) error {
+	col := s.db.Collection(CollName)
+
+	_, err := col.DeleteOne(ctx, bson.M{"_id": key})
+
+	return err
+}
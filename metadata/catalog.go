@@ -0,0 +1,238 @@
+package metadata
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/sikozonpc/notebase/book"
+	t "github.com/sikozonpc/notebase/types"
+)
+
+// Catalog answers from a local catalog loaded in memory, like a publisher's
+// ONIX feed or a CSV export. It needs no network.
+type Catalog struct {
+	byISBN  map[string]*t.BookMetadata
+	byASIN  map[string]*t.BookMetadata
+	byTitle map[string]*t.BookMetadata
+}
+
+// CatalogEntry is one book of a local catalog
+type CatalogEntry struct {
+	ISBN     string // ISBN-10 or ISBN-13
+	ASIN     string
+	Title    string
+	Metadata t.BookMetadata
+}
+
+func NewCatalog(entries []*CatalogEntry) *Catalog {
+	c := &Catalog{
+		byISBN:  make(map[string]*t.BookMetadata),
+		byASIN:  make(map[string]*t.BookMetadata),
+		byTitle: make(map[string]*t.BookMetadata),
+	}
+
+	for _, e := range entries {
+		md := e.Metadata
+		if id, err := book.ParseIdentifier(e.ISBN); err == nil {
+			switch id.Type {
+			case book.IdentifierISBN10:
+				md.ISBN13 = book.ISBN10To13(id.Value)
+			case book.IdentifierISBN13:
+				md.ISBN13 = id.Value
+			}
+		}
+
+		if md.ISBN13 != "" {
+			c.byISBN[md.ISBN13] = &md
+		}
+		if e.ASIN != "" {
+			c.byASIN[strings.ToUpper(e.ASIN)] = &md
+		}
+		if title := normalize(e.Title); title != "" {
+			c.byTitle[title] = &md
+		}
+	}
+
+	return c
+}
+
+// LoadCatalog reads a catalog file, ONIX if it ends in .xml or .onix and CSV
+// otherwise
+func LoadCatalog(path string) (*Catalog, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []*CatalogEntry
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".xml", ".onix":
+		entries, err = ParseONIX(f)
+	default:
+		entries, err = ParseCSV(f)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load catalog %s: %w", path, err)
+	}
+
+	return NewCatalog(entries), nil
+}
+
+func (c *Catalog) Name() string {
+	return "catalog"
+}
+
+func (c *Catalog) Lookup(_ context.Context, q Query) (*t.BookMetadata, error) {
+	md, ok := c.byISBN[q.ISBN13]
+	if !ok {
+		md, ok = c.byASIN[q.ASIN]
+	}
+	if !ok {
+		md, ok = c.byTitle[normalize(q.Title)]
+	}
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	found := *md
+	return &found, nil
+}
+
+// ParseCSV reads a catalog with a header row. Columns are matched by name,
+// any of isbn, isbn13, isbn10, asin, title, publisher, year, pages, subjects
+// and cover. Subjects are separated by semicolons.
+func ParseCSV(r io.Reader) ([]*CatalogEntry, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+
+	entries := make([]*CatalogEntry, 0)
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		field := func(names ...string) string {
+			for _, name := range names {
+				if i, ok := columns[name]; ok && i < len(record) && strings.TrimSpace(record[i]) != "" {
+					return strings.TrimSpace(record[i])
+				}
+			}
+			return ""
+		}
+
+		year, _ := strconv.Atoi(field("year"))
+		pages, _ := strconv.Atoi(field("pages"))
+
+		entries = append(entries, &CatalogEntry{
+			ISBN:  field("isbn13", "isbn", "isbn10"),
+			ASIN:  field("asin"),
+			Title: field("title"),
+			Metadata: t.BookMetadata{
+				Publisher:     field("publisher"),
+				PublishedYear: year,
+				PageCount:     pages,
+				Subjects:      uniqueSubjects(strings.Split(field("subjects"), ";")),
+				CoverURL:      field("cover"),
+			},
+		})
+	}
+
+	return entries, nil
+}
+
+// The parts of an ONIX 3.0 product record the catalog uses
+type onixProduct struct {
+	Identifiers []struct {
+		Type  string `xml:"ProductIDType"`
+		Value string `xml:"IDValue"`
+	} `xml:"ProductIdentifier"`
+	Titles  []string `xml:"DescriptiveDetail>TitleDetail>TitleElement>TitleText"`
+	Extents []struct {
+		Value int    `xml:"ExtentValue"`
+		Unit  string `xml:"ExtentUnit"`
+	} `xml:"DescriptiveDetail>Extent"`
+	Subjects   []string `xml:"DescriptiveDetail>Subject>SubjectHeadingText"`
+	Covers     []string `xml:"CollateralDetail>SupportingResource>ResourceVersion>ResourceLink"`
+	Publishers []string `xml:"PublishingDetail>Publisher>PublisherName"`
+	Dates      []string `xml:"PublishingDetail>PublishingDate>Date"`
+}
+
+// ONIX code list 5 product identifier types
+const (
+	onixISBN10 = "02"
+	onixISBN13 = "15"
+)
+
+// ONIX code list 24 unit for pages
+const onixPages = "03"
+
+// ParseONIX reads the products of an ONIX 3.0 message
+func ParseONIX(r io.Reader) ([]*CatalogEntry, error) {
+	var msg struct {
+		Products []onixProduct `xml:"Product"`
+	}
+	if err := xml.NewDecoder(r).Decode(&msg); err != nil {
+		return nil, err
+	}
+
+	entries := make([]*CatalogEntry, 0, len(msg.Products))
+	for _, p := range msg.Products {
+		e := &CatalogEntry{
+			Metadata: t.BookMetadata{
+				Subjects: uniqueSubjects(p.Subjects),
+			},
+		}
+
+		for _, id := range p.Identifiers {
+			if id.Type == onixISBN13 || (id.Type == onixISBN10 && e.ISBN == "") {
+				e.ISBN = strings.TrimSpace(id.Value)
+			}
+		}
+		if len(p.Titles) > 0 {
+			e.Title = strings.TrimSpace(p.Titles[0])
+		}
+		for _, extent := range p.Extents {
+			if extent.Unit == onixPages {
+				e.Metadata.PageCount = extent.Value
+				break
+			}
+		}
+		if len(p.Covers) > 0 {
+			e.Metadata.CoverURL = strings.TrimSpace(p.Covers[0])
+		}
+		if len(p.Publishers) > 0 {
+			e.Metadata.Publisher = strings.TrimSpace(p.Publishers[0])
+		}
+		// Dates are YYYYMMDD unless the record says otherwise
+		if len(p.Dates) > 0 && len(strings.TrimSpace(p.Dates[0])) >= 4 {
+			e.Metadata.PublishedYear, _ = strconv.Atoi(strings.TrimSpace(p.Dates[0])[:4])
+		}
+
+		entries = append(entries, e)
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,85 @@
+package metadata
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCatalog(t *testing.T) {
+	t.Run("should read a CSV catalog", func(t *testing.T) {
+		entries, err := ParseCSV(strings.NewReader(`isbn,asin,title,publisher,year,pages,subjects,cover
+1455586692,B0189PVAWY,Deep Work,Grand Central Publishing,2016,296,Work; Attention,https://example.com/deep-work.jpg
+`))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		catalog := NewCatalog(entries)
+
+		// ISBN-10 in the catalog, ISBN-13 in the query
+		md, err := catalog.Lookup(context.Background(), Query{ISBN13: "9781455586691"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, "9781455586691", md.ISBN13)
+		assert.Equal(t, "Grand Central Publishing", md.Publisher)
+		assert.Equal(t, 2016, md.PublishedYear)
+		assert.Equal(t, 296, md.PageCount)
+		assert.Equal(t, []string{"Work", "Attention"}, md.Subjects)
+
+		md, err = catalog.Lookup(context.Background(), Query{ASIN: "B0189PVAWY"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, "https://example.com/deep-work.jpg", md.CoverURL)
+
+		_, err = catalog.Lookup(context.Background(), Query{Title: "deep work!"})
+		assert.NoError(t, err)
+
+		_, err = catalog.Lookup(context.Background(), Query{Title: "Shallow Work"})
+		if !errors.Is(err, ErrNotFound) {
+			t.Errorf("expected ErrNotFound, got %v", err)
+		}
+	})
+
+	t.Run("should read an ONIX catalog", func(t *testing.T) {
+		entries, err := ParseONIX(strings.NewReader(`<?xml version="1.0" encoding="UTF-8"?>
+<ONIXMessage release="3.0">
+  <Product>
+    <ProductIdentifier><ProductIDType>02</ProductIDType><IDValue>1455586692</IDValue></ProductIdentifier>
+    <ProductIdentifier><ProductIDType>15</ProductIDType><IDValue>9781455586691</IDValue></ProductIdentifier>
+    <DescriptiveDetail>
+      <TitleDetail><TitleType>01</TitleType><TitleElement><TitleText>Deep Work</TitleText></TitleElement></TitleDetail>
+      <Extent><ExtentType>00</ExtentType><ExtentValue>296</ExtentValue><ExtentUnit>03</ExtentUnit></Extent>
+      <Subject><SubjectHeadingText>Time Management</SubjectHeadingText></Subject>
+    </DescriptiveDetail>
+    <CollateralDetail>
+      <SupportingResource><ResourceVersion><ResourceLink>https://example.com/deep-work.jpg</ResourceLink></ResourceVersion></SupportingResource>
+    </CollateralDetail>
+    <PublishingDetail>
+      <Publisher><PublisherName>Grand Central Publishing</PublisherName></Publisher>
+      <PublishingDate><PublishingDateRole>01</PublishingDateRole><Date>20160105</Date></PublishingDate>
+    </PublishingDetail>
+  </Product>
+</ONIXMessage>`))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		md, err := NewCatalog(entries).Lookup(context.Background(), Query{ISBN13: "9781455586691"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, "Grand Central Publishing", md.Publisher)
+		assert.Equal(t, 2016, md.PublishedYear)
+		assert.Equal(t, 296, md.PageCount)
+		assert.Equal(t, []string{"Time Management"}, md.Subjects)
+		assert.Equal(t, "https://example.com/deep-work.jpg", md.CoverURL)
+	})
+}
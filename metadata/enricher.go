@@ -0,0 +1,157 @@
+package metadata
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	t "github.com/sikozonpc/notebase/types"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// How many books are enriched per run, providers are rate limited
+const batchSize = 50
+
+// CoverFetcher stores the cover found at a URL as the book's cover
+type CoverFetcher interface {
+	Fetch(ctx context.Context, bookID primitive.ObjectID, url string) error
+}
+
+// Enricher fills in the metadata of books in the background. Providers are
+// asked in order, later ones only fill in what earlier ones didn't know.
+type Enricher struct {
+	books     t.BookStore
+	cache     Cache
+	covers    CoverFetcher
+	interval  time.Duration
+	providers []Provider
+}
+
+// NewEnricher creates the enricher. Covers are fetched for books without
+// one, unless covers is nil.
+func NewEnricher(books t.BookStore, cache Cache, covers CoverFetcher, interval time.Duration, providers ...Provider) *Enricher {
+	return &Enricher{
+		books:     books,
+		cache:     cache,
+		covers:    covers,
+		interval:  interval,
+		providers: providers,
+	}
+}
+
+// Run enriches new books every interval until ctx is done
+func (e *Enricher) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	for {
+		n, err := e.EnrichPending(ctx)
+		if err != nil {
+			log.Printf("failed to enrich books: %v", err)
+		} else if n > 0 {
+			log.Printf("enriched %d books", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// EnrichPending enriches a batch of the books that weren't yet. A book a
+// provider failed on is left for the next run.
+func (e *Enricher) EnrichPending(ctx context.Context) (int, error) {
+	books, err := e.books.GetUnenriched(ctx, batchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	enriched := 0
+	for _, b := range books {
+		if _, err := e.Enrich(ctx, b); err != nil {
+			log.Printf("failed to enrich book %s: %v", b.ID.Hex(), err)
+			continue
+		}
+
+		enriched++
+	}
+
+	return enriched, nil
+}
+
+// Enrich looks the book up and saves what was found. A book no provider
+// knows is saved with empty metadata, so it isn't looked up again.
+func (e *Enricher) Enrich(ctx context.Context, b *t.Book) (*t.BookMetadata, error) {
+	md, err := e.Lookup(ctx, QueryFor(b))
+	if err != nil {
+		return nil, err
+	}
+
+	md.EnrichedAt = time.Now().UTC()
+	if err := e.books.SetMetadata(ctx, b.ID, md); err != nil {
+		return nil, err
+	}
+
+	// A missing cover doesn't make the metadata any less useful
+	if e.covers != nil && md.CoverURL != "" && b.CoverAt == nil {
+		if err := e.covers.Fetch(ctx, b.ID, md.CoverURL); err != nil {
+			log.Printf("failed to fetch cover of book %s: %v", b.ID.Hex(), err)
+		}
+	}
+
+	return md, nil
+}
+
+// Lookup merges the answers of the providers, or returns the cached ones.
+// It only fails if a provider failed and none knew the book, since another
+// try could find it.
+func (e *Enricher) Lookup(ctx context.Context, q Query) (*t.BookMetadata, error) {
+	key := q.key()
+
+	cached, ok, err := e.cache.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		md := *cached
+		return &md, nil
+	}
+
+	md := new(t.BookMetadata)
+	var lookupErr error
+	for _, p := range e.providers {
+		found, err := p.Lookup(ctx, q)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			lookupErr = errors.Join(lookupErr, err)
+			continue
+		}
+
+		merge(md, found, p.Name())
+		if complete(md) {
+			break
+		}
+	}
+
+	if len(md.Sources) == 0 && lookupErr != nil {
+		return nil, lookupErr
+	}
+
+	if md.ISBN13 == "" {
+		md.ISBN13 = q.ISBN13
+	}
+
+	// Answers missing a failed provider are not worth keeping
+	if lookupErr == nil {
+		if err := e.cache.Set(ctx, key, md); err != nil {
+			return nil, err
+		}
+	}
+
+	return md, nil
+}
@@ -0,0 +1,119 @@
+package metadata
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	types "github.com/sikozonpc/notebase/types"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestEnricher(t *testing.T) {
+	deepWork := &types.Book{ID: primitive.NewObjectID(), ISBN: "1455586692", Title: "Deep Work"}
+	unknown := &types.Book{ID: primitive.NewObjectID(), ISBN: "B004XCFJ3E", Title: "Unknown"}
+
+	first := &mockProvider{name: "first", books: map[string]*types.BookMetadata{
+		"9781455586691": {Publisher: "Grand Central Publishing", PageCount: 296},
+	}}
+	second := &mockProvider{name: "second", books: map[string]*types.BookMetadata{
+		"9781455586691": {Publisher: "Piatkus", PublishedYear: 2016, Subjects: []string{"Work"}},
+	}}
+
+	t.Run("should merge what providers know in order", func(t *testing.T) {
+		books := &mockBookStore{books: []*types.Book{deepWork, unknown}}
+		enricher := NewEnricher(books, NewMemoryCache(time.Hour), nil, time.Minute, first, second)
+
+		n, err := enricher.EnrichPending(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, 2, n)
+
+		md := books.metadata[deepWork.ID]
+		assert.Equal(t, "9781455586691", md.ISBN13)
+		assert.Equal(t, "Grand Central Publishing", md.Publisher)
+		assert.Equal(t, 2016, md.PublishedYear)
+		assert.Equal(t, 296, md.PageCount)
+		assert.Equal(t, []string{"first", "second"}, md.Sources)
+		assert.False(t, md.EnrichedAt.IsZero())
+
+		// Saved so it's not looked up again
+		assert.Empty(t, books.metadata[unknown.ID].Sources)
+	})
+
+	t.Run("should reuse cached answers", func(t *testing.T) {
+		first.calls = 0
+		cache := NewMemoryCache(time.Hour)
+		enricher := NewEnricher(&mockBookStore{}, cache, nil, time.Minute, first)
+
+		for i := 0; i < 3; i++ {
+			if _, err := enricher.Lookup(context.Background(), Query{ISBN13: "9781455586691"}); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		assert.Equal(t, 1, first.calls)
+	})
+
+	t.Run("should retry a book when a provider failed", func(t *testing.T) {
+		books := &mockBookStore{books: []*types.Book{unknown}}
+		failing := &mockProvider{name: "failing", err: errors.New("connection refused")}
+		enricher := NewEnricher(books, NewMemoryCache(time.Hour), nil, time.Minute, first, failing)
+
+		n, err := enricher.EnrichPending(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Zero(t, n)
+		assert.NotContains(t, books.metadata, unknown.ID)
+	})
+}
+
+type mockProvider struct {
+	name  string
+	books map[string]*types.BookMetadata
+	err   error
+	calls int
+}
+
+func (m *mockProvider) Name() string {
+	return m.name
+}
+
+func (m *mockProvider) Lookup(_ context.Context, q Query) (*types.BookMetadata, error) {
+	m.calls++
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	md, ok := m.books[q.ISBN13]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	found := *md
+	return &found, nil
+}
+
+type mockBookStore struct {
+	types.BookStore
+	books    []*types.Book
+	metadata map[primitive.ObjectID]*types.BookMetadata
+}
+
+func (m *mockBookStore) GetUnenriched(context.Context, int) ([]*types.Book, error) {
+	return m.books, nil
+}
+
+func (m *mockBookStore) SetMetadata(_ context.Context, id primitive.ObjectID, md *types.BookMetadata) error {
+	if m.metadata == nil {
+		m.metadata = make(map[primitive.ObjectID]*types.BookMetadata)
+	}
+	m.metadata[id] = md
+
+	return nil
+}
@@ -0,0 +1,114 @@
+package metadata
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	t "github.com/sikozonpc/notebase/types"
+)
+
+// GoogleBooks looks books up in the Google Books volumes API
+type GoogleBooks struct {
+	baseURL string
+	apiKey  string
+	client  *http.Client
+}
+
+func NewGoogleBooks(baseURL, apiKey string) *GoogleBooks {
+	return &GoogleBooks{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		apiKey:  apiKey,
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (g *GoogleBooks) Name() string {
+	return "googlebooks"
+}
+
+type googleBooksVolumes struct {
+	Items []struct {
+		VolumeInfo struct {
+			Publisher           string   `json:"publisher"`
+			PublishedDate       string   `json:"publishedDate"`
+			PageCount           int      `json:"pageCount"`
+			Categories          []string `json:"categories"`
+			IndustryIdentifiers []struct {
+				Type       string `json:"type"`
+				Identifier string `json:"identifier"`
+			} `json:"industryIdentifiers"`
+			ImageLinks struct {
+				Thumbnail string `json:"thumbnail"`
+			} `json:"imageLinks"`
+		} `json:"volumeInfo"`
+	} `json:"items"`
+}
+
+func (g *GoogleBooks) Lookup(ctx context.Context, q Query) (*t.BookMetadata, error) {
+	var search string
+	switch {
+	case q.ISBN13 != "":
+		search = "isbn:" + q.ISBN13
+	case q.Title != "":
+		search = "intitle:" + q.Title
+		if q.Authors != "" {
+			search += " inauthor:" + q.Authors
+		}
+	default:
+		return nil, ErrNotFound
+	}
+
+	params := url.Values{
+		"q":          {search},
+		"maxResults": {"1"},
+	}
+	if g.apiKey != "" {
+		params.Set("key", g.apiKey)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, g.baseURL+"/volumes?"+params.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := g.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("google books responded with status %d", res.StatusCode)
+	}
+
+	var volumes googleBooksVolumes
+	if err := json.NewDecoder(res.Body).Decode(&volumes); err != nil {
+		return nil, err
+	}
+
+	if len(volumes.Items) == 0 {
+		return nil, ErrNotFound
+	}
+	info := volumes.Items[0].VolumeInfo
+
+	md := &t.BookMetadata{
+		Publisher:     info.Publisher,
+		PublishedYear: parseYear(info.PublishedDate),
+		PageCount:     info.PageCount,
+		Subjects:      uniqueSubjects(info.Categories),
+		// Google serves the same covers over https
+		CoverURL: strings.Replace(info.ImageLinks.Thumbnail, "http://", "https://", 1),
+	}
+	for _, id := range info.IndustryIdentifiers {
+		if id.Type == "ISBN_13" {
+			md.ISBN13 = id.Identifier
+		}
+	}
+
+	return md, nil
+}
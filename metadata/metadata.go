@@ -0,0 +1,140 @@
+package metadata
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/sikozonpc/notebase/book"
+	t "github.com/sikozonpc/notebase/types"
+)
+
+// ErrNotFound is returned by a provider that doesn't know the book
+var ErrNotFound = errors.New("book metadata not found")
+
+// Provider looks up what is known about a book. Providers only fill in what
+// they know, the enricher merges the answers of several of them.
+type Provider interface {
+	Name() string
+	Lookup(ctx context.Context, q Query) (*t.BookMetadata, error)
+}
+
+// Query identifies the book to look up. Providers prefer the ISBN and fall
+// back to the title and authors, Kindle books often only have an ASIN.
+type Query struct {
+	ISBN13  string
+	ASIN    string
+	Title   string
+	Authors string
+}
+
+// QueryFor builds the query for a book from its identifier, title and
+// authors
+func QueryFor(b *t.Book) Query {
+	q := Query{Title: b.Title, Authors: b.Authors}
+
+	id, err := book.ParseIdentifier(b.ISBN)
+	if err != nil {
+		return q
+	}
+
+	switch id.Type {
+	case book.IdentifierISBN10:
+		q.ISBN13 = book.ISBN10To13(id.Value)
+	case book.IdentifierISBN13:
+		q.ISBN13 = id.Value
+	case book.IdentifierASIN:
+		q.ASIN = id.Value
+	}
+
+	return q
+}
+
+// CacheKey is where the answers about the book are cached
+func CacheKey(b *t.Book) string {
+	return QueryFor(b).key()
+}
+
+// key identifies the query in the cache
+func (q Query) key() string {
+	if q.ISBN13 != "" {
+		return "isbn13:" + q.ISBN13
+	}
+
+	return "title:" + normalize(q.Title) + "|" + normalize(q.Authors) + "|" + q.ASIN
+}
+
+// merge fills what md doesn't know yet with what the provider found
+func merge(md *t.BookMetadata, found *t.BookMetadata, source string) {
+	if md.ISBN13 == "" {
+		md.ISBN13 = found.ISBN13
+	}
+	if md.Publisher == "" {
+		md.Publisher = found.Publisher
+	}
+	if md.PublishedYear == 0 {
+		md.PublishedYear = found.PublishedYear
+	}
+	if md.PageCount == 0 {
+		md.PageCount = found.PageCount
+	}
+	if len(md.Subjects) == 0 {
+		md.Subjects = found.Subjects
+	}
+	if md.CoverURL == "" {
+		md.CoverURL = found.CoverURL
+	}
+
+	md.Sources = append(md.Sources, source)
+}
+
+// complete reports whether every provider would be asked in vain
+func complete(md *t.BookMetadata) bool {
+	return md.ISBN13 != "" && md.Publisher != "" && md.PublishedYear != 0 && md.PageCount != 0 && len(md.Subjects) > 0 && md.CoverURL != ""
+}
+
+var yearPattern = regexp.MustCompile(`\b(\d{4})\b`)
+
+// parseYear finds the year in dates like "2016", "2016-01-05" or
+// "January 5, 2016"
+func parseYear(date string) int {
+	m := yearPattern.FindStringSubmatch(date)
+	if m == nil {
+		return 0
+	}
+
+	year, _ := strconv.Atoi(m[1])
+	return year
+}
+
+// normalize makes titles and authors comparable regardless of case and
+// punctuation
+func normalize(s string) string {
+	return strings.Join(strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r > 127)
+	}), " ")
+}
+
+// uniqueSubjects drops empty and repeated subjects, keeping the first
+// spelling of each
+func uniqueSubjects(subjects []string) []string {
+	seen := make(map[string]bool, len(subjects))
+	unique := make([]string, 0, len(subjects))
+	for _, s := range subjects {
+		s = strings.TrimSpace(s)
+		if s == "" || seen[normalize(s)] {
+			continue
+		}
+
+		seen[normalize(s)] = true
+		unique = append(unique, s)
+	}
+
+	if len(unique) == 0 {
+		return nil
+	}
+
+	return unique
+}
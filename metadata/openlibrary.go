@@ -0,0 +1,166 @@
+package metadata
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	t "github.com/sikozonpc/notebase/types"
+)
+
+// OpenLibrary looks books up in the Open Library API, by ISBN when there is
+// one and by title and author otherwise
+type OpenLibrary struct {
+	baseURL string
+	client  *http.Client
+}
+
+func NewOpenLibrary(baseURL string) *OpenLibrary {
+	return &OpenLibrary{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (o *OpenLibrary) Name() string {
+	return "openlibrary"
+}
+
+func (o *OpenLibrary) Lookup(ctx context.Context, q Query) (*t.BookMetadata, error) {
+	if q.ISBN13 != "" {
+		return o.lookupISBN(ctx, q.ISBN13)
+	}
+
+	if q.Title == "" {
+		return nil, ErrNotFound
+	}
+
+	return o.search(ctx, q)
+}
+
+type openLibraryBook struct {
+	Publishers []struct {
+		Name string `json:"name"`
+	} `json:"publishers"`
+	PublishDate   string `json:"publish_date"`
+	NumberOfPages int    `json:"number_of_pages"`
+	Subjects      []struct {
+		Name string `json:"name"`
+	} `json:"subjects"`
+	Cover struct {
+		Large string `json:"large"`
+	} `json:"cover"`
+}
+
+func (o *OpenLibrary) lookupISBN(ctx context.Context, isbn13 string) (*t.BookMetadata, error) {
+	params := url.Values{
+		"bibkeys": {"ISBN:" + isbn13},
+		"format":  {"json"},
+		"jscmd":   {"data"},
+	}
+
+	var res map[string]openLibraryBook
+	if err := o.get(ctx, "/api/books?"+params.Encode(), &res); err != nil {
+		return nil, err
+	}
+
+	b, ok := res["ISBN:"+isbn13]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	md := &t.BookMetadata{
+		ISBN13:        isbn13,
+		PublishedYear: parseYear(b.PublishDate),
+		PageCount:     b.NumberOfPages,
+		CoverURL:      b.Cover.Large,
+	}
+	if len(b.Publishers) > 0 {
+		md.Publisher = b.Publishers[0].Name
+	}
+
+	subjects := make([]string, len(b.Subjects))
+	for i, s := range b.Subjects {
+		subjects[i] = s.Name
+	}
+	md.Subjects = uniqueSubjects(subjects)
+
+	return md, nil
+}
+
+type openLibrarySearch struct {
+	Docs []struct {
+		ISBN                []string `json:"isbn"`
+		Publisher           []string `json:"publisher"`
+		FirstPublishYear    int      `json:"first_publish_year"`
+		NumberOfPagesMedian int      `json:"number_of_pages_median"`
+		Subject             []string `json:"subject"`
+		CoverID             int      `json:"cover_i"`
+	} `json:"docs"`
+}
+
+func (o *OpenLibrary) search(ctx context.Context, q Query) (*t.BookMetadata, error) {
+	params := url.Values{
+		"title": {q.Title},
+		"limit": {"1"},
+	}
+	if q.Authors != "" {
+		params.Set("author", q.Authors)
+	}
+
+	var res openLibrarySearch
+	if err := o.get(ctx, "/search.json?"+params.Encode(), &res); err != nil {
+		return nil, err
+	}
+
+	if len(res.Docs) == 0 {
+		return nil, ErrNotFound
+	}
+	doc := res.Docs[0]
+
+	md := &t.BookMetadata{
+		PublishedYear: doc.FirstPublishYear,
+		PageCount:     doc.NumberOfPagesMedian,
+		Subjects:      uniqueSubjects(doc.Subject),
+	}
+	for _, isbn := range doc.ISBN {
+		if len(isbn) == 13 {
+			md.ISBN13 = isbn
+			break
+		}
+	}
+	if len(doc.Publisher) > 0 {
+		md.Publisher = doc.Publisher[0]
+	}
+	if doc.CoverID > 0 {
+		md.CoverURL = fmt.Sprintf("https://covers.openlibrary.org/b/id/%d-L.jpg", doc.CoverID)
+	}
+
+	return md, nil
+}
+
+func (o *OpenLibrary) get(ctx context.Context, path string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, o.baseURL+path, nil)
+	if err != nil {
+		return err
+	}
+
+	res, err := o.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("open library responded with status %d", res.StatusCode)
+	}
+
+	return json.NewDecoder(res.Body).Decode(v)
+}
@@ -0,0 +1,139 @@
+package metadata
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	types "github.com/sikozonpc/notebase/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpenLibrary(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/books":
+			if r.URL.Query().Get("bibkeys") != "ISBN:9781455586691" {
+				w.Write([]byte(`{}`))
+				return
+			}
+
+			w.Write([]byte(`{"ISBN:9781455586691": {
+				"publishers": [{"name": "Grand Central Publishing"}],
+				"publish_date": "January 5, 2016",
+				"number_of_pages": 296,
+				"subjects": [{"name": "Work"}, {"name": "Attention"}, {"name": "work"}],
+				"cover": {"large": "https://covers.openlibrary.org/b/id/8091016-L.jpg"}
+			}}`))
+		case "/search.json":
+			assert.Equal(t, "Deep Work", r.URL.Query().Get("title"))
+			assert.Equal(t, "Cal Newport", r.URL.Query().Get("author"))
+
+			w.Write([]byte(`{"docs": [{
+				"isbn": ["1455586692", "9781455586691"],
+				"publisher": ["Grand Central Publishing"],
+				"first_publish_year": 2016,
+				"number_of_pages_median": 304,
+				"subject": ["Work", "Attention"],
+				"cover_i": 8091016
+			}]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	provider := NewOpenLibrary(server.URL)
+
+	t.Run("should look a book up by ISBN", func(t *testing.T) {
+		md, err := provider.Lookup(context.Background(), Query{ISBN13: "9781455586691"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, &types.BookMetadata{
+			ISBN13:        "9781455586691",
+			Publisher:     "Grand Central Publishing",
+			PublishedYear: 2016,
+			PageCount:     296,
+			Subjects:      []string{"Work", "Attention"},
+			CoverURL:      "https://covers.openlibrary.org/b/id/8091016-L.jpg",
+		}, md)
+	})
+
+	t.Run("should search a book without ISBN by title and author", func(t *testing.T) {
+		md, err := provider.Lookup(context.Background(), Query{ASIN: "B0189PVAWY", Title: "Deep Work", Authors: "Cal Newport"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, "9781455586691", md.ISBN13)
+		assert.Equal(t, 304, md.PageCount)
+		assert.Equal(t, "https://covers.openlibrary.org/b/id/8091016-L.jpg", md.CoverURL)
+	})
+
+	t.Run("should not find an unknown book", func(t *testing.T) {
+		_, err := provider.Lookup(context.Background(), Query{ISBN13: "9780306406157"})
+		if !errors.Is(err, ErrNotFound) {
+			t.Errorf("expected ErrNotFound, got %v", err)
+		}
+	})
+}
+
+func TestGoogleBooks(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/volumes", r.URL.Path)
+		assert.Equal(t, "secret", r.URL.Query().Get("key"))
+
+		switch r.URL.Query().Get("q") {
+		case "isbn:9781455586691":
+			w.Write([]byte(`{"items": [{"volumeInfo": {
+				"publisher": "Grand Central Publishing",
+				"publishedDate": "2016-01-05",
+				"pageCount": 304,
+				"categories": ["Business & Economics"],
+				"industryIdentifiers": [{"type": "ISBN_10", "identifier": "1455586692"}, {"type": "ISBN_13", "identifier": "9781455586691"}],
+				"imageLinks": {"thumbnail": "http://books.google.com/books/content?id=foo"}
+			}}]}`))
+		case "quota":
+			w.WriteHeader(http.StatusTooManyRequests)
+		default:
+			w.Write([]byte(`{"totalItems": 0}`))
+		}
+	}))
+	defer server.Close()
+
+	provider := NewGoogleBooks(server.URL, "secret")
+
+	md, err := provider.Lookup(context.Background(), Query{ISBN13: "9781455586691"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, &types.BookMetadata{
+		ISBN13:        "9781455586691",
+		Publisher:     "Grand Central Publishing",
+		PublishedYear: 2016,
+		PageCount:     304,
+		Subjects:      []string{"Business & Economics"},
+		CoverURL:      "https://books.google.com/books/content?id=foo",
+	}, md)
+
+	_, err = provider.Lookup(context.Background(), Query{Title: "Unknown"})
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+	_, err = provider.Lookup(context.Background(), Query{})
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound without anything to search, got %v", err)
+	}
+}
+
+func TestQueryFor(t *testing.T) {
+	assert.Equal(t, Query{ISBN13: "9781455586691", Title: "Deep Work"}, QueryFor(&types.Book{ISBN: "1455586692", Title: "Deep Work"}))
+	assert.Equal(t, Query{ASIN: "B0189PVAWY", Title: "Deep Work"}, QueryFor(&types.Book{ISBN: "B0189PVAWY", Title: "Deep Work"}))
+	assert.Equal(t, Query{Title: "Deep Work"}, QueryFor(&types.Book{ISBN: "SOMERANDOMASIN", Title: "Deep Work"}))
+}
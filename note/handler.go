@@ -0,0 +1,242 @@
+package note
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/sikozonpc/notebase/auth"
+	"github.com/sikozonpc/notebase/link"
+	t "github.com/sikozonpc/notebase/types"
+	u "github.com/sikozonpc/notebase/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type Handler struct {
+	store        t.NoteStore
+	libraryStore t.LibraryStore
+	linkStore    t.LinkStore
+	userStore    t.UserStore
+}
+
+func NewHandler(store t.NoteStore, libraryStore t.LibraryStore, linkStore t.LinkStore, userStore t.UserStore) *Handler {
+	return &Handler{
+		store:        store,
+		libraryStore: libraryStore,
+		linkStore:    linkStore,
+		userStore:    userStore,
+	}
+}
+
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc(
+		"/user/{userID}/notes",
+		auth.WithJWTAuth(u.MakeHTTPHandler(h.handleGetUserNotes), h.userStore),
+	).Methods("GET")
+
+	router.HandleFunc(
+		"/user/{userID}/notes",
+		auth.WithJWTAuth(u.MakeHTTPHandler(h.handleCreateNote), h.userStore),
+	).Methods("POST")
+
+	router.HandleFunc(
+		"/user/{userID}/notes/{id}",
+		auth.WithJWTAuth(u.MakeHTTPHandler(h.handleGetNote), h.userStore),
+	).Methods("GET")
+
+	router.HandleFunc(
+		"/user/{userID}/notes/{id}",
+		auth.WithJWTAuth(u.MakeHTTPHandler(h.handleUpdateNote), h.userStore),
+	).Methods("PATCH")
+
+	router.HandleFunc(
+		"/user/{userID}/notes/{id}",
+		auth.WithJWTAuth(u.MakeHTTPHandler(h.handleDeleteNote), h.userStore),
+	).Methods("DELETE")
+}
+
+// handleGetUserNotes lists the user's notes, most recently updated first,
+// optionally only the ones about ?bookId= or with ?tag=
+func (h *Handler) handleGetUserNotes(w http.ResponseWriter, r *http.Request) error {
+	userID, err := u.GetStringParamFromRequest(r, "userID")
+	if err != nil {
+		return err
+	}
+	oUserID, _ := primitive.ObjectIDFromHex(userID)
+
+	filter := &t.NoteFilter{Tag: r.URL.Query().Get("tag")}
+	if v := r.URL.Query().Get("bookId"); v != "" {
+		oBookID, err := primitive.ObjectIDFromHex(v)
+		if err != nil {
+			return u.WriteJSON(w, http.StatusBadRequest, t.APIError{Error: fmt.Errorf("invalid bookId %v", v).Error()})
+		}
+		filter.BookID = &oBookID
+	}
+
+	notes, err := h.store.GetUserNotes(r.Context(), oUserID, filter)
+	if err != nil {
+		return err
+	}
+
+	return u.WriteJSON(w, http.StatusOK, notes)
+}
+
+func (h *Handler) handleCreateNote(w http.ResponseWriter, r *http.Request) error {
+	userID, err := u.GetStringParamFromRequest(r, "userID")
+	if err != nil {
+		return err
+	}
+	oUserID, _ := primitive.ObjectIDFromHex(userID)
+
+	payload := new(t.CreateNoteRequest)
+	if err := json.NewDecoder(r.Body).Decode(payload); err != nil {
+		return u.WriteJSON(w, http.StatusBadRequest, t.APIError{Error: err.Error()})
+	}
+
+	if strings.TrimSpace(payload.Body) == "" {
+		return u.WriteJSON(w, http.StatusBadRequest, t.APIError{Error: fmt.Errorf("body is required").Error()})
+	}
+
+	if err := h.checkBook(r.Context(), oUserID, payload.BookID); err != nil {
+		return u.WriteJSON(w, http.StatusBadRequest, t.APIError{Error: err.Error()})
+	}
+
+	payload.UserID = oUserID
+	n, err := h.store.CreateNote(r.Context(), payload)
+	if err != nil {
+		return err
+	}
+
+	if err := h.linkStore.SetLinks(r.Context(), oUserID, t.LinkNodeNote, n.ID, link.Parse(n.Body)); err != nil {
+		return err
+	}
+
+	return u.WriteJSON(w, http.StatusCreated, n)
+}
+
+func (h *Handler) handleGetNote(w http.ResponseWriter, r *http.Request) error {
+	oUserID, oID, err := getIDsFromRequest(r)
+	if err != nil {
+		return err
+	}
+
+	n, err := h.store.GetNoteByID(r.Context(), oID, oUserID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return notFound(w, oID)
+	}
+	if err != nil {
+		return err
+	}
+
+	return u.WriteJSON(w, http.StatusOK, n)
+}
+
+func (h *Handler) handleUpdateNote(w http.ResponseWriter, r *http.Request) error {
+	oUserID, oID, err := getIDsFromRequest(r)
+	if err != nil {
+		return err
+	}
+
+	payload := new(t.UpdateNoteRequest)
+	if err := json.NewDecoder(r.Body).Decode(payload); err != nil {
+		return u.WriteJSON(w, http.StatusBadRequest, t.APIError{Error: err.Error()})
+	}
+
+	if payload.Body == nil && payload.BookID == nil && payload.Tags == nil {
+		return u.WriteJSON(w, http.StatusBadRequest, t.APIError{Error: fmt.Errorf("nothing to update").Error()})
+	}
+
+	if payload.Body != nil && strings.TrimSpace(*payload.Body) == "" {
+		return u.WriteJSON(w, http.StatusBadRequest, t.APIError{Error: fmt.Errorf("body cannot be empty").Error()})
+	}
+
+	if payload.BookID != nil {
+		if err := h.checkBook(r.Context(), oUserID, *payload.BookID); err != nil {
+			return u.WriteJSON(w, http.StatusBadRequest, t.APIError{Error: err.Error()})
+		}
+	}
+
+	n, err := h.store.UpdateNote(r.Context(), oID, oUserID, payload)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return notFound(w, oID)
+	}
+	if err != nil {
+		return err
+	}
+
+	if payload.Body != nil {
+		if err := h.linkStore.SetLinks(r.Context(), oUserID, t.LinkNodeNote, n.ID, link.Parse(n.Body)); err != nil {
+			return err
+		}
+	}
+
+	return u.WriteJSON(w, http.StatusOK, n)
+}
+
+func (h *Handler) handleDeleteNote(w http.ResponseWriter, r *http.Request) error {
+	oUserID, oID, err := getIDsFromRequest(r)
+	if err != nil {
+		return err
+	}
+
+	err = h.store.DeleteNote(r.Context(), oID, oUserID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return notFound(w, oID)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Links to the note are kept, they resolve again if a note with the same
+	// title is written
+	if err := h.linkStore.DeleteLinks(r.Context(), oUserID, t.LinkNodeNote, oID); err != nil {
+		return err
+	}
+
+	return u.WriteJSON(w, http.StatusOK, nil)
+}
+
+// checkBook makes sure a note is only linked to a book in the user's
+// library. An empty id leaves the note without a book.
+func (h *Handler) checkBook(ctx context.Context, userID primitive.ObjectID, bookID string) error {
+	if bookID == "" {
+		return nil
+	}
+
+	oBookID, err := primitive.ObjectIDFromHex(bookID)
+	if err != nil {
+		return fmt.Errorf("invalid bookId %v", bookID)
+	}
+
+	_, err = h.libraryStore.GetLibraryBook(ctx, userID, oBookID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return fmt.Errorf("book with id %v is not in the library", bookID)
+	}
+
+	return err
+}
+
+func getIDsFromRequest(r *http.Request) (primitive.ObjectID, primitive.ObjectID, error) {
+	userID, err := u.GetStringParamFromRequest(r, "userID")
+	if err != nil {
+		return primitive.NilObjectID, primitive.NilObjectID, err
+	}
+	oUserID, _ := primitive.ObjectIDFromHex(userID)
+
+	id, err := u.GetStringParamFromRequest(r, "id")
+	if err != nil {
+		return primitive.NilObjectID, primitive.NilObjectID, err
+	}
+	oID, _ := primitive.ObjectIDFromHex(id)
+
+	return oUserID, oID, nil
+}
+
+func notFound(w http.ResponseWriter, id primitive.ObjectID) error {
+	return u.WriteJSON(w, http.StatusNotFound, t.APIError{Error: fmt.Errorf("note with id %v not found", id.Hex()).Error()})
+}
@@ -0,0 +1,267 @@
+package note
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	types "github.com/sikozonpc/notebase/types"
+	u "github.com/sikozonpc/notebase/utils"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestNoteHandler(t *testing.T) {
+	userID := primitive.NewObjectID()
+	bookID := primitive.NewObjectID()
+
+	newHandler := func() (*Handler, *mockNoteStore) {
+		store := &mockNoteStore{}
+		libraryStore := &mockLibraryStore{entries: []*types.LibraryBook{{UserID: userID, BookID: bookID}}}
+
+		return NewHandler(store, libraryStore, &mockLinkStore{}, &mockUserStore{}), store
+	}
+
+	serve := func(t *testing.T, handler *Handler, method, path, body string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(method, "/user/"+userID.Hex()+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		router := mux.NewRouter()
+
+		router.HandleFunc("/user/{userID}/notes", u.MakeHTTPHandler(handler.handleGetUserNotes)).Methods(http.MethodGet)
+		router.HandleFunc("/user/{userID}/notes", u.MakeHTTPHandler(handler.handleCreateNote)).Methods(http.MethodPost)
+		router.HandleFunc("/user/{userID}/notes/{id}", u.MakeHTTPHandler(handler.handleGetNote)).Methods(http.MethodGet)
+		router.HandleFunc("/user/{userID}/notes/{id}", u.MakeHTTPHandler(handler.handleUpdateNote)).Methods(http.MethodPatch)
+		router.HandleFunc("/user/{userID}/notes/{id}", u.MakeHTTPHandler(handler.handleDeleteNote)).Methods(http.MethodDelete)
+
+		router.ServeHTTP(rr, req)
+
+		return rr
+	}
+
+	t.Run("should create a note about a book in the library", func(t *testing.T) {
+		handler, store := newHandler()
+
+		rr := serve(t, handler, http.MethodPost, "/notes", `{"body": "# Ideas\n\nRead it again.", "bookId": "`+bookID.Hex()+`", "tags": ["Rereads"]}`)
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("expected status code %d, got %d", http.StatusCreated, rr.Code)
+		}
+
+		var n types.Note
+		if err := json.NewDecoder(rr.Body).Decode(&n); err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, userID, n.UserID)
+		assert.Equal(t, bookID, *n.BookID)
+		assert.Len(t, store.notes, 1)
+
+		rr = serve(t, handler, http.MethodGet, "/notes?bookId="+bookID.Hex(), "")
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		var notes []*types.Note
+		if err := json.NewDecoder(rr.Body).Decode(&notes); err != nil {
+			t.Fatal(err)
+		}
+		assert.Len(t, notes, 1)
+	})
+
+	t.Run("should keep the links written in a note", func(t *testing.T) {
+		store := &mockNoteStore{}
+		linkStore := &mockLinkStore{}
+		handler := NewHandler(store, &mockLibraryStore{}, linkStore, &mockUserStore{})
+
+		rr := serve(t, handler, http.MethodPost, "/notes", `{"body": "See [[Deep  Work]] and [[deep work|again]]"}`)
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("expected status code %d, got %d", http.StatusCreated, rr.Code)
+		}
+
+		n := store.notes[0]
+		assert.Equal(t, []string{"deep work"}, linkStore.links[n.ID])
+
+		rr = serve(t, handler, http.MethodPatch, "/notes/"+n.ID.Hex(), `{"body": "No links anymore"}`)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+		assert.Empty(t, linkStore.links[n.ID])
+
+		serve(t, handler, http.MethodPatch, "/notes/"+n.ID.Hex(), `{"body": "Back to [[Deep Work]]"}`)
+		rr = serve(t, handler, http.MethodDelete, "/notes/"+n.ID.Hex(), "")
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		_, ok := linkStore.links[n.ID]
+		assert.False(t, ok)
+	})
+
+	t.Run("should reject invalid notes", func(t *testing.T) {
+		handler, store := newHandler()
+
+		cases := []string{
+			`{"body": "  "}`,
+			`{"body": "Some thought", "bookId": "not-an-id"}`,
+			`{"body": "Some thought", "bookId": "` + primitive.NewObjectID().Hex() + `"}`,
+		}
+
+		for _, body := range cases {
+			rr := serve(t, handler, http.MethodPost, "/notes", body)
+			assert.Equal(t, http.StatusBadRequest, rr.Code, body)
+		}
+
+		assert.Empty(t, store.notes)
+	})
+
+	t.Run("should update and unlink a note's book", func(t *testing.T) {
+		handler, store := newHandler()
+		n := &types.Note{ID: primitive.NewObjectID(), UserID: userID, Body: "Some thought", BookID: &bookID}
+		store.notes = append(store.notes, n)
+
+		rr := serve(t, handler, http.MethodPatch, "/notes/"+n.ID.Hex(), `{"body": "A better thought", "bookId": ""}`)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		assert.Equal(t, "A better thought", n.Body)
+		assert.Nil(t, n.BookID)
+
+		rr = serve(t, handler, http.MethodPatch, "/notes/"+n.ID.Hex(), `{}`)
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+
+	t.Run("should not find another user's note", func(t *testing.T) {
+		handler, store := newHandler()
+		n := &types.Note{ID: primitive.NewObjectID(), UserID: primitive.NewObjectID(), Body: "Not yours"}
+		store.notes = append(store.notes, n)
+
+		for _, method := range []string{http.MethodGet, http.MethodDelete} {
+			rr := serve(t, handler, method, "/notes/"+n.ID.Hex(), "")
+			assert.Equal(t, http.StatusNotFound, rr.Code, method)
+		}
+
+		assert.Len(t, store.notes, 1)
+	})
+}
+
+type mockNoteStore struct {
+	types.NoteStore
+	notes []*types.Note
+}
+
+func (m *mockNoteStore) CreateNote(_ context.Context, req *types.CreateNoteRequest) (*types.Note, error) {
+	n := &types.Note{
+		ID:     primitive.NewObjectID(),
+		UserID: req.UserID,
+		Body:   req.Body,
+		BookID: bookID(req.BookID),
+		Tags:   u.NormalizeTags(req.Tags),
+	}
+	m.notes = append(m.notes, n)
+
+	return n, nil
+}
+
+func (m *mockNoteStore) GetUserNotes(_ context.Context, userID primitive.ObjectID, filter *types.NoteFilter) ([]*types.Note, error) {
+	notes := make([]*types.Note, 0)
+	for _, n := range m.notes {
+		if n.UserID != userID {
+			continue
+		}
+		if filter != nil && filter.BookID != nil && (n.BookID == nil || *n.BookID != *filter.BookID) {
+			continue
+		}
+
+		notes = append(notes, n)
+	}
+
+	return notes, nil
+}
+
+func (m *mockNoteStore) GetNoteByID(_ context.Context, id primitive.ObjectID, userID primitive.ObjectID) (*types.Note, error) {
+	for _, n := range m.notes {
+		if n.ID == id && n.UserID == userID {
+			return n, nil
+		}
+	}
+
+	return nil, mongo.ErrNoDocuments
+}
+
+func (m *mockNoteStore) UpdateNote(ctx context.Context, id primitive.ObjectID, userID primitive.ObjectID, req *types.UpdateNoteRequest) (*types.Note, error) {
+	n, err := m.GetNoteByID(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Body != nil {
+		n.Body = *req.Body
+	}
+	if req.BookID != nil {
+		n.BookID = bookID(*req.BookID)
+	}
+	if req.Tags != nil {
+		n.Tags = u.NormalizeTags(*req.Tags)
+	}
+
+	return n, nil
+}
+
+func (m *mockNoteStore) DeleteNote(ctx context.Context, id primitive.ObjectID, userID primitive.ObjectID) error {
+	for i, n := range m.notes {
+		if n.ID == id && n.UserID == userID {
+			m.notes = append(m.notes[:i], m.notes[i+1:]...)
+			return nil
+		}
+	}
+
+	return mongo.ErrNoDocuments
+}
+
+type mockLibraryStore struct {
+	types.LibraryStore
+	entries []*types.LibraryBook
+}
+
+func (m *mockLibraryStore) GetLibraryBook(_ context.Context, userID primitive.ObjectID, bookID primitive.ObjectID) (*types.LibraryBook, error) {
+	for _, e := range m.entries {
+		if e.UserID == userID && e.BookID == bookID {
+			return e, nil
+		}
+	}
+
+	return nil, mongo.ErrNoDocuments
+}
+
+type mockLinkStore struct {
+	types.LinkStore
+	links map[primitive.ObjectID][]string
+}
+
+func (m *mockLinkStore) SetLinks(_ context.Context, _ primitive.ObjectID, _ types.LinkNodeType, sourceID primitive.ObjectID, targets []string) error {
+	if m.links == nil {
+		m.links = make(map[primitive.ObjectID][]string)
+	}
+	m.links[sourceID] = targets
+
+	return nil
+}
+
+func (m *mockLinkStore) DeleteLinks(_ context.Context, _ primitive.ObjectID, _ types.LinkNodeType, sourceID primitive.ObjectID) error {
+	delete(m.links, sourceID)
+
+	return nil
+}
+
+type mockUserStore struct {
+	types.UserStore
+}
@@ -0,0 +1,195 @@
+package note
+
+import (
+	"context"
+	"time"
+
+	t "github.com/sikozonpc/notebase/types"
+	u "github.com/sikozonpc/notebase/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const CollName = "notes"
+
+type Store struct {
+	db *mongo.Database
+}
+
+func NewStore(db *mongo.Database) *Store {
+	return &Store{db: db}
+}
+
+func (s *Store) CreateNote(ctx context.Context, req *t.CreateNoteRequest) (*t.Note, error) {
+	col := s.db.Collection(CollName)
+
+	now := time.Now().UTC()
+	n := &t.Note{
+		ID:        primitive.NewObjectID(),
+		UserID:    req.UserID,
+		Body:      req.Body,
+		BookID:    bookID(req.BookID),
+		Tags:      u.NormalizeTags(req.Tags),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	if _, err := col.InsertOne(ctx, n); err != nil {
+		return nil, err
+	}
+
+	return n, nil
+}
+
+// GetUserNotes returns the user's notes, most recently updated first
+func (s *Store) GetUserNotes(ctx context.Context, userID primitive.ObjectID, filter *t.NoteFilter) ([]*t.Note, error) {
+	col := s.db.Collection(CollName)
+
+	cursor, err := col.Find(ctx, filterQuery(userID, filter), options.Find().SetSort(bson.D{{Key: "updatedAt", Value: -1}, {Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+
+	notes := make([]*t.Note, 0)
+	if err = cursor.All(ctx, &notes); err != nil {
+		return nil, err
+	}
+
+	return notes, nil
+}
+
+func (s *Store) GetNoteByID(ctx context.Context, id primitive.ObjectID, userID primitive.ObjectID) (*t.Note, error) {
+	col := s.db.Collection(CollName)
+
+	var n t.Note
+	err := col.FindOne(ctx, bson.M{
+		"_id":    id,
+		"userId": userID,
+	}).Decode(&n)
+	if err != nil {
+		return nil, err
+	}
+
+	return &n, nil
+}
+
+func (s *Store) UpdateNote(ctx context.Context, id primitive.ObjectID, userID primitive.ObjectID, req *t.UpdateNoteRequest) (*t.Note, error) {
+	col := s.db.Collection(CollName)
+
+	set := bson.M{
+		"updatedAt": time.Now().UTC(),
+	}
+	update := bson.M{}
+
+	if req.Body != nil {
+		set["body"] = *req.Body
+	}
+	if req.Tags != nil {
+		set["tags"] = u.NormalizeTags(*req.Tags)
+	}
+	if req.BookID != nil {
+		if oBookID := bookID(*req.BookID); oBookID != nil {
+			set["bookId"] = oBookID
+		} else {
+			update["$unset"] = bson.M{"bookId": ""}
+		}
+	}
+	update["$set"] = set
+
+	var n t.Note
+	err := col.FindOneAndUpdate(ctx, bson.M{
+		"_id":    id,
+		"userId": userID,
+	}, update, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&n)
+	if err != nil {
+		return nil, err
+	}
+
+	return &n, nil
+}
+
+func (s *Store) DeleteNote(ctx context.Context, id primitive.ObjectID, userID primitive.ObjectID) error {
+	col := s.db.Collection(CollName)
+
+	res, err := col.DeleteOne(ctx, bson.M{
+		"_id":    id,
+		"userId": userID,
+	})
+	if err != nil {
+		return err
+	}
+
+	if res.DeletedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+
+	return nil
+}
+
+func (s *Store) GetRandomNotes(ctx context.Context, userID primitive.ObjectID, limit int) ([]*t.Note, error) {
+	col := s.db.Collection(CollName)
+
+	cursor, err := col.Aggregate(ctx, mongo.Pipeline{
+		bson.D{{Key: "$match", Value: bson.M{"userId": userID}}},
+		bson.D{{Key: "$sample", Value: bson.M{"size": limit}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	notes := make([]*t.Note, 0)
+	if err = cursor.All(ctx, &notes); err != nil {
+		return nil, err
+	}
+
+	return notes, nil
+}
+
+// MoveBooks links the user's notes of the books to another one, after they
+// were merged into it, or every user's when userID is nil
+func (s *Store) MoveBooks(ctx context.Context, userID *primitive.ObjectID, from []primitive.ObjectID, to primitive.ObjectID) error {
+	col := s.db.Collection(CollName)
+
+	query := bson.M{"bookId": bson.M{"$in": from}}
+	if userID != nil {
+		query["userId"] = *userID
+	}
+
+	_, err := col.UpdateMany(ctx, query, bson.M{
+		"$set": bson.M{"bookId": to},
+	})
+
+	return err
+}
+
+func filterQuery(userID primitive.ObjectID, filter *t.NoteFilter) bson.M {
+	query := bson.M{"userId": userID}
+	if filter == nil {
+		return query
+	}
+
+	if filter.IDs != nil {
+		query["_id"] = bson.M{"$in": filter.IDs}
+	}
+	if filter.BookID != nil {
+		query["bookId"] = *filter.BookID
+	}
+	if filter.Tag != "" {
+		query["tags"] = u.NormalizeTag(filter.Tag)
+	}
+
+	return query
+}
+
+// bookID is nil for an empty or invalid id, the handler checks the book
+// exists beforehand
+func bookID(id string) *primitive.ObjectID {
+	oID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil
+	}
+
+	return &oID
+}
@@ -0,0 +1,360 @@
+package reading
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/sikozonpc/notebase/auth"
+	t "github.com/sikozonpc/notebase/types"
+	u "github.com/sikozonpc/notebase/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type Handler struct {
+	store        t.ReadingLogStore
+	bookStore    t.BookStore
+	libraryStore t.LibraryStore
+	userStore    t.UserStore
+}
+
+func NewHandler(store t.ReadingLogStore, bookStore t.BookStore, libraryStore t.LibraryStore, userStore t.UserStore) *Handler {
+	return &Handler{
+		store:        store,
+		bookStore:    bookStore,
+		libraryStore: libraryStore,
+		userStore:    userStore,
+	}
+}
+
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc(
+		"/user/{userID}/reading",
+		auth.WithJWTAuth(u.MakeHTTPHandler(h.handleGetSessions), h.userStore),
+	).Methods("GET")
+
+	router.HandleFunc(
+		"/user/{userID}/book/{id}/reading",
+		auth.WithJWTAuth(u.MakeHTTPHandler(h.handleGetBookReadingLog), h.userStore),
+	).Methods("GET")
+
+	router.HandleFunc(
+		"/user/{userID}/book/{id}/progress",
+		auth.WithJWTAuth(u.MakeHTTPHandler(h.handleUpdateProgress), h.userStore),
+	).Methods("PUT")
+
+	router.HandleFunc(
+		"/user/{userID}/book/{id}/sessions",
+		auth.WithJWTAuth(u.MakeHTTPHandler(h.handleCreateSession), h.userStore),
+	).Methods("POST")
+
+	router.HandleFunc(
+		"/user/{userID}/book/{id}/sessions/{sessionID}",
+		auth.WithJWTAuth(u.MakeHTTPHandler(h.handleDeleteSession), h.userStore),
+	).Methods("DELETE")
+}
+
+// handleGetSessions lists the user's reading sessions of every book, most
+// recent first, optionally started between ?from= and ?to=
+func (h *Handler) handleGetSessions(w http.ResponseWriter, r *http.Request) error {
+	userID, err := u.GetStringParamFromRequest(r, "userID")
+	if err != nil {
+		return err
+	}
+	oUserID, _ := primitive.ObjectIDFromHex(userID)
+
+	from, to, err := u.ParseDateRange(r.URL.Query().Get("from"), r.URL.Query().Get("to"))
+	if err != nil {
+		return u.WriteJSON(w, http.StatusBadRequest, t.APIError{Error: err.Error()})
+	}
+	filter := &t.ReadingSessionFilter{From: from, To: to}
+
+	sessions, err := h.store.GetSessions(r.Context(), oUserID, filter)
+	if err != nil {
+		return err
+	}
+
+	return u.WriteJSON(w, http.StatusOK, sessions)
+}
+
+// handleGetBookReadingLog returns where the user is in the book and their
+// sessions of it
+func (h *Handler) handleGetBookReadingLog(w http.ResponseWriter, r *http.Request) error {
+	oUserID, oID, err := getIDsFromRequest(r)
+	if err != nil {
+		return err
+	}
+
+	entry, err := h.libraryStore.GetLibraryBook(r.Context(), oUserID, oID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return notFound(w, oID)
+	}
+	if err != nil {
+		return err
+	}
+
+	return h.writeReadingLog(w, r.Context(), entry)
+}
+
+// handleUpdateProgress sets where the user is in the book. Unlike progress
+// derived from highlights or sessions it can go back. Reaching the end
+// finishes the book.
+func (h *Handler) handleUpdateProgress(w http.ResponseWriter, r *http.Request) error {
+	oUserID, oID, err := getIDsFromRequest(r)
+	if err != nil {
+		return err
+	}
+
+	payload := new(t.UpdateReadingProgressRequest)
+	if err := json.NewDecoder(r.Body).Decode(payload); err != nil {
+		return u.WriteJSON(w, http.StatusBadRequest, t.APIError{Error: err.Error()})
+	}
+
+	if err := validateUpdateProgressRequest(payload); err != nil {
+		return u.WriteJSON(w, http.StatusBadRequest, t.APIError{Error: err.Error()})
+	}
+
+	book, entry, err := h.getUserBook(r.Context(), oUserID, oID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return notFound(w, oID)
+	}
+	if err != nil {
+		return err
+	}
+
+	if payload.Page != nil && pageCount(book) > 0 && *payload.Page > pageCount(book) {
+		return u.WriteJSON(w, http.StatusBadRequest, t.APIError{Error: fmt.Errorf("page cannot be past the book's %d pages", pageCount(book)).Error()})
+	}
+
+	var progress *t.ReadingProgress
+	switch {
+	case payload.Page != nil:
+		progress = FromPage(book, *payload.Page, t.ProgressSourceManual)
+	case payload.Location != nil:
+		progress = FromLocation(book, *payload.Location, t.ProgressSourceManual)
+	default:
+		progress = &t.ReadingProgress{Source: t.ProgressSourceManual, UpdatedAt: time.Now().UTC()}
+	}
+	if payload.Location != nil {
+		progress.Location = *payload.Location
+	}
+	if payload.Percent != nil {
+		progress.Percent = *payload.Percent
+	}
+
+	entry, err = h.setProgress(r.Context(), entry, progress, time.Now().UTC())
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return notFound(w, oID)
+	}
+	if err != nil {
+		return err
+	}
+
+	return h.writeReadingLog(w, r.Context(), entry)
+}
+
+// handleCreateSession logs a reading session. A book not being read yet
+// starts being read, and the session's last page moves the progress forward.
+func (h *Handler) handleCreateSession(w http.ResponseWriter, r *http.Request) error {
+	oUserID, oID, err := getIDsFromRequest(r)
+	if err != nil {
+		return err
+	}
+
+	payload := new(t.CreateReadingSessionRequest)
+	if err := json.NewDecoder(r.Body).Decode(payload); err != nil {
+		return u.WriteJSON(w, http.StatusBadRequest, t.APIError{Error: err.Error()})
+	}
+
+	if err := validateCreateSessionRequest(payload); err != nil {
+		return u.WriteJSON(w, http.StatusBadRequest, t.APIError{Error: err.Error()})
+	}
+
+	book, entry, err := h.getUserBook(r.Context(), oUserID, oID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return notFound(w, oID)
+	}
+	if err != nil {
+		return err
+	}
+
+	session, err := h.store.CreateSession(r.Context(), oUserID, oID, payload)
+	if err != nil {
+		return err
+	}
+
+	if entry.Status == "" || entry.Status == t.ReadingStatusToRead {
+		status := t.ReadingStatusReading
+		update := &t.UpdateLibraryBookRequest{Status: &status}
+		if entry.StartedAt == nil {
+			update.StartedAt = &session.StartedAt
+		}
+
+		if entry, err = h.libraryStore.UpdateLibraryBook(r.Context(), oUserID, oID, update); err != nil {
+			return err
+		}
+	}
+
+	if payload.EndPage > 0 {
+		progress := FromPage(book, payload.EndPage, t.ProgressSourceSession)
+		if Ahead(entry.Progress, progress) {
+			if _, err := h.setProgress(r.Context(), entry, progress, session.EndedAt); err != nil {
+				return err
+			}
+		}
+	}
+
+	return u.WriteJSON(w, http.StatusCreated, session)
+}
+
+func (h *Handler) handleDeleteSession(w http.ResponseWriter, r *http.Request) error {
+	oUserID, _, err := getIDsFromRequest(r)
+	if err != nil {
+		return err
+	}
+
+	sessionID, err := u.GetStringParamFromRequest(r, "sessionID")
+	if err != nil {
+		return err
+	}
+	oSessionID, _ := primitive.ObjectIDFromHex(sessionID)
+
+	err = h.store.DeleteSession(r.Context(), oUserID, oSessionID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return u.WriteJSON(w, http.StatusNotFound, t.APIError{Error: fmt.Errorf("session with id %v not found", oSessionID.Hex()).Error()})
+	}
+	if err != nil {
+		return err
+	}
+
+	return u.WriteJSON(w, http.StatusOK, nil)
+}
+
+// setProgress records the progress, and finishes the book at the given time
+// once it reaches the end
+func (h *Handler) setProgress(ctx context.Context, entry *t.LibraryBook, progress *t.ReadingProgress, at time.Time) (*t.LibraryBook, error) {
+	entry, err := h.libraryStore.SetProgress(ctx, entry.UserID, entry.BookID, progress)
+	if err != nil {
+		return nil, err
+	}
+
+	if progress.Percent < 100 || entry.Status == t.ReadingStatusFinished {
+		return entry, nil
+	}
+
+	status := t.ReadingStatusFinished
+	update := &t.UpdateLibraryBookRequest{Status: &status}
+	if entry.FinishedAt == nil {
+		update.FinishedAt = &at
+	}
+
+	return h.libraryStore.UpdateLibraryBook(ctx, entry.UserID, entry.BookID, update)
+}
+
+// getUserBook returns the book and its entry in the user's library, or
+// mongo.ErrNoDocuments if it's not in it
+func (h *Handler) getUserBook(ctx context.Context, userID, id primitive.ObjectID) (*t.Book, *t.LibraryBook, error) {
+	entry, err := h.libraryStore.GetLibraryBook(ctx, userID, id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	book, err := h.bookStore.GetByID(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return book, entry, nil
+}
+
+func (h *Handler) writeReadingLog(w http.ResponseWriter, ctx context.Context, entry *t.LibraryBook) error {
+	sessions, err := h.store.GetSessions(ctx, entry.UserID, &t.ReadingSessionFilter{BookID: entry.BookID})
+	if err != nil {
+		return err
+	}
+
+	log := &t.BookReadingLog{
+		Status:     entry.Status,
+		StartedAt:  entry.StartedAt,
+		FinishedAt: entry.FinishedAt,
+		Progress:   entry.Progress,
+		Sessions:   sessions,
+	}
+	for _, s := range sessions {
+		log.Minutes += int(s.EndedAt.Sub(s.StartedAt).Minutes())
+		if s.EndPage > s.StartPage {
+			log.Pages += s.EndPage - s.StartPage
+		}
+	}
+
+	return u.WriteJSON(w, http.StatusOK, log)
+}
+
+func validateUpdateProgressRequest(req *t.UpdateReadingProgressRequest) error {
+	if req.Location == nil && req.Page == nil && req.Percent == nil {
+		return fmt.Errorf("location, page or percent is required")
+	}
+
+	if req.Location != nil && *req.Location < 1 {
+		return fmt.Errorf("location must be positive")
+	}
+
+	if req.Page != nil && *req.Page < 1 {
+		return fmt.Errorf("page must be positive")
+	}
+
+	if req.Percent != nil && (*req.Percent < 0 || *req.Percent > 100) {
+		return fmt.Errorf("percent must be between 0 and 100")
+	}
+
+	return nil
+}
+
+func validateCreateSessionRequest(req *t.CreateReadingSessionRequest) error {
+	if req.StartedAt.IsZero() || req.EndedAt.IsZero() {
+		return fmt.Errorf("startedAt and endedAt are required")
+	}
+
+	if !req.EndedAt.After(req.StartedAt) {
+		return fmt.Errorf("endedAt must be after startedAt")
+	}
+
+	if req.EndedAt.After(time.Now().Add(time.Minute)) {
+		return fmt.Errorf("endedAt cannot be in the future")
+	}
+
+	if req.StartPage < 0 || req.EndPage < 0 {
+		return fmt.Errorf("pages cannot be negative")
+	}
+
+	if req.EndPage > 0 && req.EndPage < req.StartPage {
+		return fmt.Errorf("endPage cannot be before startPage")
+	}
+
+	return nil
+}
+
+func getIDsFromRequest(r *http.Request) (primitive.ObjectID, primitive.ObjectID, error) {
+	userID, err := u.GetStringParamFromRequest(r, "userID")
+	if err != nil {
+		return primitive.NilObjectID, primitive.NilObjectID, err
+	}
+	oUserID, _ := primitive.ObjectIDFromHex(userID)
+
+	id, err := u.GetStringParamFromRequest(r, "id")
+	if err != nil {
+		return primitive.NilObjectID, primitive.NilObjectID, err
+	}
+	oID, _ := primitive.ObjectIDFromHex(id)
+
+	return oUserID, oID, nil
+}
+
+func notFound(w http.ResponseWriter, id primitive.ObjectID) error {
+	return u.WriteJSON(w, http.StatusNotFound, t.APIError{Error: fmt.Errorf("book with id %v not found", id.Hex()).Error()})
+}
@@ -0,0 +1,256 @@
+package reading
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	types "github.com/sikozonpc/notebase/types"
+	u "github.com/sikozonpc/notebase/utils"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestReadingHandler(t *testing.T) {
+	userID := primitive.NewObjectID()
+	book := &types.Book{ID: primitive.NewObjectID(), ISBN: "B01", Title: "Deep Work", Metadata: &types.BookMetadata{PageCount: 200}}
+	other := &types.Book{ID: primitive.NewObjectID(), ISBN: "B02", Title: "Not in the library"}
+
+	newHandler := func() (*Handler, *mockLibraryStore) {
+		libraryStore := &mockLibraryStore{entries: []*types.LibraryBook{{UserID: userID, BookID: book.ID}}}
+		bookStore := &mockBookStore{books: []*types.Book{book, other}}
+
+		return NewHandler(&mockReadingStore{}, bookStore, libraryStore, &mockUserStore{}), libraryStore
+	}
+
+	serve := func(t *testing.T, handler *Handler, method, path, body string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(method, path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		router := mux.NewRouter()
+
+		router.HandleFunc("/user/{userID}/book/{id}/reading", u.MakeHTTPHandler(handler.handleGetBookReadingLog)).Methods(http.MethodGet)
+		router.HandleFunc("/user/{userID}/book/{id}/progress", u.MakeHTTPHandler(handler.handleUpdateProgress)).Methods(http.MethodPut)
+		router.HandleFunc("/user/{userID}/book/{id}/sessions", u.MakeHTTPHandler(handler.handleCreateSession)).Methods(http.MethodPost)
+
+		router.ServeHTTP(rr, req)
+
+		return rr
+	}
+
+	bookPath := func(b *types.Book, rest string) string {
+		return "/user/" + userID.Hex() + "/book/" + b.ID.Hex() + rest
+	}
+
+	startedAt := time.Date(2024, 3, 1, 20, 0, 0, 0, time.UTC)
+	session := func(startPage, endPage int) string {
+		body, _ := json.Marshal(types.CreateReadingSessionRequest{
+			StartedAt: startedAt,
+			EndedAt:   startedAt.Add(45 * time.Minute),
+			StartPage: startPage,
+			EndPage:   endPage,
+		})
+		return string(body)
+	}
+
+	t.Run("should start the book and move the progress with a session", func(t *testing.T) {
+		handler, libraryStore := newHandler()
+
+		rr := serve(t, handler, http.MethodPost, bookPath(book, "/sessions"), session(0, 50))
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("expected status code %d, got %d", http.StatusCreated, rr.Code)
+		}
+
+		entry := libraryStore.entries[0]
+		assert.Equal(t, types.ReadingStatusReading, entry.Status)
+		assert.True(t, startedAt.Equal(*entry.StartedAt))
+		assert.Equal(t, 50, entry.Progress.Page)
+		assert.Equal(t, 25.0, entry.Progress.Percent)
+		assert.Equal(t, types.ProgressSourceSession, entry.Progress.Source)
+
+		// An older session doesn't move it back
+		rr = serve(t, handler, http.MethodPost, bookPath(book, "/sessions"), session(10, 20))
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("expected status code %d, got %d", http.StatusCreated, rr.Code)
+		}
+		assert.Equal(t, 50, entry.Progress.Page)
+
+		rr = serve(t, handler, http.MethodGet, bookPath(book, "/reading"), "")
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		var log types.BookReadingLog
+		if err := json.NewDecoder(rr.Body).Decode(&log); err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Len(t, log.Sessions, 2)
+		assert.Equal(t, 90, log.Minutes)
+		assert.Equal(t, 60, log.Pages)
+	})
+
+	t.Run("should finish the book at the last page", func(t *testing.T) {
+		handler, libraryStore := newHandler()
+
+		rr := serve(t, handler, http.MethodPut, bookPath(book, "/progress"), `{"page": 200}`)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		entry := libraryStore.entries[0]
+		assert.Equal(t, 100.0, entry.Progress.Percent)
+		assert.Equal(t, types.ReadingStatusFinished, entry.Status)
+		assert.NotNil(t, entry.FinishedAt)
+	})
+
+	t.Run("should let the progress go back when set by hand", func(t *testing.T) {
+		handler, libraryStore := newHandler()
+
+		serve(t, handler, http.MethodPut, bookPath(book, "/progress"), `{"percent": 80}`)
+		rr := serve(t, handler, http.MethodPut, bookPath(book, "/progress"), `{"location": 600}`)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		assert.Equal(t, 20.0, libraryStore.entries[0].Progress.Percent)
+	})
+
+	t.Run("should reject invalid progress and sessions", func(t *testing.T) {
+		handler, _ := newHandler()
+
+		cases := []struct {
+			path string
+			body string
+		}{
+			{"/progress", `{}`},
+			{"/progress", `{"percent": 120}`},
+			{"/progress", `{"page": 201}`},
+			{"/sessions", session(30, 20)},
+			{"/sessions", `{"startedAt": "2024-03-01T21:00:00Z", "endedAt": "2024-03-01T20:00:00Z"}`},
+		}
+
+		for _, c := range cases {
+			method := http.MethodPut
+			if c.path == "/sessions" {
+				method = http.MethodPost
+			}
+
+			rr := serve(t, handler, method, bookPath(book, c.path), c.body)
+			assert.Equal(t, http.StatusBadRequest, rr.Code, c.body)
+		}
+	})
+
+	t.Run("should not log a book that isn't in the user's library", func(t *testing.T) {
+		handler, _ := newHandler()
+
+		rr := serve(t, handler, http.MethodPost, bookPath(other, "/sessions"), session(0, 10))
+		if rr.Code != http.StatusNotFound {
+			t.Fatalf("expected status code %d, got %d", http.StatusNotFound, rr.Code)
+		}
+	})
+}
+
+type mockReadingStore struct {
+	types.ReadingLogStore
+	sessions []*types.ReadingSession
+}
+
+func (m *mockReadingStore) CreateSession(_ context.Context, userID primitive.ObjectID, bookID primitive.ObjectID, req *types.CreateReadingSessionRequest) (*types.ReadingSession, error) {
+	s := &types.ReadingSession{
+		ID:        primitive.NewObjectID(),
+		UserID:    userID,
+		BookID:    bookID,
+		StartedAt: req.StartedAt,
+		EndedAt:   req.EndedAt,
+		StartPage: req.StartPage,
+		EndPage:   req.EndPage,
+	}
+	m.sessions = append(m.sessions, s)
+
+	return s, nil
+}
+
+func (m *mockReadingStore) GetSessions(_ context.Context, userID primitive.ObjectID, filter *types.ReadingSessionFilter) ([]*types.ReadingSession, error) {
+	sessions := make([]*types.ReadingSession, 0)
+	for _, s := range m.sessions {
+		if s.UserID == userID && (filter == nil || filter.BookID.IsZero() || s.BookID == filter.BookID) {
+			sessions = append(sessions, s)
+		}
+	}
+
+	return sessions, nil
+}
+
+type mockBookStore struct {
+	types.BookStore
+	books []*types.Book
+}
+
+func (m *mockBookStore) GetByID(_ context.Context, id primitive.ObjectID) (*types.Book, error) {
+	for _, b := range m.books {
+		if b.ID == id {
+			return b, nil
+		}
+	}
+
+	return nil, mongo.ErrNoDocuments
+}
+
+type mockLibraryStore struct {
+	types.LibraryStore
+	entries []*types.LibraryBook
+}
+
+func (m *mockLibraryStore) GetLibraryBook(_ context.Context, userID primitive.ObjectID, bookID primitive.ObjectID) (*types.LibraryBook, error) {
+	for _, e := range m.entries {
+		if e.UserID == userID && e.BookID == bookID {
+			return e, nil
+		}
+	}
+
+	return nil, mongo.ErrNoDocuments
+}
+
+func (m *mockLibraryStore) UpdateLibraryBook(ctx context.Context, userID primitive.ObjectID, bookID primitive.ObjectID, req *types.UpdateLibraryBookRequest) (*types.LibraryBook, error) {
+	e, err := m.GetLibraryBook(ctx, userID, bookID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Status != nil {
+		e.Status = *req.Status
+	}
+	if req.StartedAt != nil {
+		e.StartedAt = req.StartedAt
+	}
+	if req.FinishedAt != nil {
+		e.FinishedAt = req.FinishedAt
+	}
+
+	return e, nil
+}
+
+func (m *mockLibraryStore) SetProgress(ctx context.Context, userID primitive.ObjectID, bookID primitive.ObjectID, progress *types.ReadingProgress) (*types.LibraryBook, error) {
+	e, err := m.GetLibraryBook(ctx, userID, bookID)
+	if err != nil {
+		return nil, err
+	}
+
+	e.Progress = progress
+
+	return e, nil
+}
+
+type mockUserStore struct {
+	types.UserStore
+}
@@ -0,0 +1,74 @@
+package reading
+
+import (
+	"math"
+	"time"
+
+	t "github.com/sikozonpc/notebase/types"
+)
+
+// Kindle locations are fixed runs of bytes, not pages. A printed page holds
+// about this many, which is enough to estimate a percentage from a location
+// when the book's page count is known.
+const LocationsPerPage = 15
+
+// FromLocation is the progress of a reader at the Kindle location
+func FromLocation(b *t.Book, location int, source string) *t.ReadingProgress {
+	p := &t.ReadingProgress{
+		Location:  location,
+		Source:    source,
+		UpdatedAt: time.Now().UTC(),
+	}
+
+	if pages := pageCount(b); pages > 0 {
+		p.Percent = percent(float64(location), float64(pages*LocationsPerPage))
+	}
+
+	return p
+}
+
+// FromPage is the progress of a reader at the page
+func FromPage(b *t.Book, page int, source string) *t.ReadingProgress {
+	p := &t.ReadingProgress{
+		Page:      page,
+		Source:    source,
+		UpdatedAt: time.Now().UTC(),
+	}
+
+	if pages := pageCount(b); pages > 0 {
+		p.Percent = percent(float64(page), float64(pages))
+	}
+
+	return p
+}
+
+// Ahead reports whether next is further in the book than current. They are
+// compared by what both of them know, percent first.
+func Ahead(current, next *t.ReadingProgress) bool {
+	switch {
+	case current == nil:
+		return true
+	case current.Percent > 0 && next.Percent > 0:
+		return next.Percent > current.Percent
+	case current.Location > 0 && next.Location > 0:
+		return next.Location > current.Location
+	case current.Page > 0 && next.Page > 0:
+		return next.Page > current.Page
+	}
+
+	// Nothing to compare by, only replace an empty progress
+	return current.Percent == 0 && current.Location == 0 && current.Page == 0
+}
+
+func pageCount(b *t.Book) int {
+	if b.Metadata == nil {
+		return 0
+	}
+
+	return b.Metadata.PageCount
+}
+
+// percent rounds to one decimal, capped at 100
+func percent(n, total float64) float64 {
+	return math.Min(math.Round(n/total*1000)/10, 100)
+}
@@ -0,0 +1,44 @@
+package reading
+
+import (
+	"testing"
+
+	types "github.com/sikozonpc/notebase/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProgress(t *testing.T) {
+	book := &types.Book{Metadata: &types.BookMetadata{PageCount: 200}}
+	unknown := &types.Book{}
+
+	t.Run("should estimate the percent from a location", func(t *testing.T) {
+		p := FromLocation(book, 1500, types.ProgressSourceHighlights)
+
+		assert.Equal(t, 1500, p.Location)
+		assert.Equal(t, 50.0, p.Percent)
+		assert.Equal(t, types.ProgressSourceHighlights, p.Source)
+	})
+
+	t.Run("should compute the percent from a page", func(t *testing.T) {
+		assert.Equal(t, 33.5, FromPage(book, 67, types.ProgressSourceManual).Percent)
+		assert.Equal(t, 100.0, FromPage(book, 250, types.ProgressSourceManual).Percent)
+	})
+
+	t.Run("should leave the percent out without a page count", func(t *testing.T) {
+		assert.Zero(t, FromLocation(unknown, 1500, types.ProgressSourceHighlights).Percent)
+		assert.Zero(t, FromPage(unknown, 67, types.ProgressSourceManual).Percent)
+	})
+
+	t.Run("should compare by what both progresses know", func(t *testing.T) {
+		assert.True(t, Ahead(nil, FromPage(unknown, 1, types.ProgressSourceSession)))
+
+		assert.True(t, Ahead(FromPage(book, 10, ""), FromLocation(book, 300, "")))
+		assert.False(t, Ahead(FromPage(book, 30, ""), FromLocation(book, 300, "")))
+
+		assert.True(t, Ahead(FromLocation(unknown, 100, ""), FromLocation(unknown, 200, "")))
+		assert.False(t, Ahead(FromPage(unknown, 100, ""), FromPage(unknown, 20, "")))
+
+		// A page and a location of an unknown length can't be compared
+		assert.False(t, Ahead(FromPage(unknown, 10, ""), FromLocation(unknown, 3000, "")))
+	})
+}
@@ -0,0 +1,115 @@
+package reading
+
+import (
+	"context"
+	"time"
+
+	t "github.com/sikozonpc/notebase/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// CollName holds the reading sessions of every user. Where each user is in a
+// book is kept in their library.
+const CollName = "reading_sessions"
+
+type Store struct {
+	db *mongo.Database
+}
+
+func NewStore(db *mongo.Database) *Store {
+	return &Store{db: db}
+}
+
+func (s *Store) CreateSession(ctx context.Context, userID primitive.ObjectID, bookID primitive.ObjectID, req *t.CreateReadingSessionRequest) (*t.ReadingSession, error) {
+	col := s.db.Collection(CollName)
+
+	session := &t.ReadingSession{
+		ID:        primitive.NewObjectID(),
+		UserID:    userID,
+		BookID:    bookID,
+		StartedAt: req.StartedAt.UTC(),
+		EndedAt:   req.EndedAt.UTC(),
+		StartPage: req.StartPage,
+		EndPage:   req.EndPage,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	if _, err := col.InsertOne(ctx, session); err != nil {
+		return nil, err
+	}
+
+	return session, nil
+}
+
+// GetSessions returns the user's reading sessions, most recent first
+func (s *Store) GetSessions(ctx context.Context, userID primitive.ObjectID, filter *t.ReadingSessionFilter) ([]*t.ReadingSession, error) {
+	col := s.db.Collection(CollName)
+
+	query := bson.M{"userId": userID}
+	if filter != nil {
+		if !filter.BookID.IsZero() {
+			query["bookId"] = filter.BookID
+		}
+
+		startedAt := bson.M{}
+		if filter.From != nil {
+			startedAt["$gte"] = *filter.From
+		}
+		if filter.To != nil {
+			startedAt["$lt"] = *filter.To
+		}
+		if len(startedAt) > 0 {
+			query["startedAt"] = startedAt
+		}
+	}
+
+	cursor, err := col.Find(ctx, query, options.Find().SetSort(bson.D{{Key: "startedAt", Value: -1}, {Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make([]*t.ReadingSession, 0)
+	if err = cursor.All(ctx, &sessions); err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+func (s *Store) DeleteSession(ctx context.Context, userID primitive.ObjectID, id primitive.ObjectID) error {
+	col := s.db.Collection(CollName)
+
+	res, err := col.DeleteOne(ctx, bson.M{
+		"_id":    id,
+		"userId": userID,
+	})
+	if err != nil {
+		return err
+	}
+
+	if res.DeletedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+
+	return nil
+}
+
+// MoveBooks moves the user's sessions of the books to another one, after they
+// were merged into it, or every user's when userID is nil
+func (s *Store) MoveBooks(ctx context.Context, userID *primitive.ObjectID, from []primitive.ObjectID, to primitive.ObjectID) error {
+	col := s.db.Collection(CollName)
+
+	query := bson.M{"bookId": bson.M{"$in": from}}
+	if userID != nil {
+		query["userId"] = *userID
+	}
+
+	_, err := col.UpdateMany(ctx, query, bson.M{
+		"$set": bson.M{"bookId": to},
+	})
+
+	return err
+}
@@ -0,0 +1,56 @@
+package search
+
+// Terms shorter than this only match exactly or with typos, "go" would
+// otherwise match half the index
+const minPrefixLength = 3
+
+// maxEdits is how many typos a term may have, more for longer terms
+func maxEdits(term string) int {
+	switch n := len([]rune(term)); {
+	case n < 4:
+		return 0
+	case n < 8:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// withinDistance reports whether the Levenshtein distance between a and b is
+// at most max, giving up as soon as a row exceeds it
+func withinDistance(a, b string, max int) bool {
+	ra, rb := []rune(a), []rune(b)
+
+	if d := len(ra) - len(rb); d > max || -d > max {
+		return false
+	}
+
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		rowMin := curr[0]
+
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+			rowMin = min(rowMin, curr[j])
+		}
+
+		if rowMin > max {
+			return false
+		}
+
+		prev, curr = curr, prev
+	}
+
+	return prev[len(rb)] <= max
+}
@@ -0,0 +1,62 @@
+package search
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/sikozonpc/notebase/auth"
+	t "github.com/sikozonpc/notebase/types"
+	u "github.com/sikozonpc/notebase/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	DefaultLimit = 20
+	MaxLimit     = 100
+)
+
+type Handler struct {
+	searcher  t.Searcher
+	userStore t.UserStore
+}
+
+func NewHandler(searcher t.Searcher, userStore t.UserStore) *Handler {
+	return &Handler{searcher: searcher, userStore: userStore}
+}
+
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc(
+		"/user/{userID}/search",
+		auth.WithJWTAuth(u.MakeHTTPHandler(h.handleSearch), h.userStore),
+	).Methods("GET")
+}
+
+func (h *Handler) handleSearch(w http.ResponseWriter, r *http.Request) error {
+	userID, err := u.GetStringParamFromRequest(r, "userID")
+	if err != nil {
+		return err
+	}
+	oUserID, _ := primitive.ObjectIDFromHex(userID)
+
+	q := ParseQuery(r.URL.Query().Get("q"))
+	if IsEmpty(q) {
+		return u.WriteJSON(w, http.StatusBadRequest, t.APIError{Error: fmt.Errorf("q is required").Error()})
+	}
+
+	limit := DefaultLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > MaxLimit {
+			return u.WriteJSON(w, http.StatusBadRequest, t.APIError{Error: fmt.Errorf("limit must be between 1 and %d", MaxLimit).Error()})
+		}
+	}
+
+	results, err := h.searcher.Search(r.Context(), oUserID, q, limit)
+	if err != nil {
+		return err
+	}
+
+	return u.WriteJSON(w, http.StatusOK, results)
+}
@@ -0,0 +1,153 @@
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/gorilla/mux"
+	types "github.com/sikozonpc/notebase/types"
+	u "github.com/sikozonpc/notebase/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestSearchHandler(t *testing.T) {
+	userID := primitive.NewObjectID()
+
+	highlightStore := &mockHighlightStore{highlights: []*types.Highlight{
+		{ID: primitive.NewObjectID(), Text: "You have power over your mind, not outside events.", BookID: "meditations", Tags: []string{"stoicism"}},
+		{ID: primitive.NewObjectID(), Text: "The mind is everything. What you think you become.", Note: "power of habits", BookID: "other"},
+		{ID: primitive.NewObjectID(), Text: "All warfare is based on deception.", BookID: "art-of-war"},
+	}}
+	bookStore := &mockBookStore{books: map[string]*types.Book{
+		"meditations": {ISBN: "meditations", Title: "Meditations", Authors: "Marcus Aurelius"},
+		"art-of-war":  {ISBN: "art-of-war", Title: "The Art of War", Authors: "Sun Tzu"},
+	}}
+
+	handler := NewHandler(NewScanSearcher(highlightStore, bookStore), nil)
+
+	search := func(t *testing.T, q string) (int, []*types.SearchResult) {
+		req, err := http.NewRequest(http.MethodGet, "/user/"+userID.Hex()+"/search?q="+url.QueryEscape(q), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		router := mux.NewRouter()
+
+		router.HandleFunc("/user/{userID}/search", u.MakeHTTPHandler(handler.handleSearch)).Methods(http.MethodGet)
+
+		router.ServeHTTP(rr, req)
+
+		var results []*types.SearchResult
+		if rr.Code == http.StatusOK {
+			if err := json.NewDecoder(rr.Body).Decode(&results); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		return rr.Code, results
+	}
+
+	t.Run("should rank matches in the text above matches in the note", func(t *testing.T) {
+		code, results := search(t, "power")
+
+		if code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d", http.StatusOK, code)
+		}
+
+		if len(results) != 2 {
+			t.Fatalf("expected 2 results, got %d", len(results))
+		}
+
+		if results[0].Highlight.BookID != "meditations" {
+			t.Errorf("expected the text match first, got %s", results[0].Highlight.BookID)
+		}
+
+		if results[0].TextSnippet != "You have <mark>power</mark> over your mind, not outside events." {
+			t.Errorf("unexpected snippet %s", results[0].TextSnippet)
+		}
+	})
+
+	t.Run("should match phrases as written", func(t *testing.T) {
+		_, results := search(t, `"mind is everything"`)
+
+		if len(results) != 1 || results[0].Highlight.BookID != "other" {
+			t.Errorf("expected only the phrase match, got %d results", len(results))
+		}
+	})
+
+	t.Run("should restrict results with qualifiers", func(t *testing.T) {
+		_, results := search(t, "mind author:aurelius")
+		if len(results) != 1 || results[0].Book.Title != "Meditations" {
+			t.Errorf("expected only the Meditations highlight, got %d results", len(results))
+		}
+
+		_, results = search(t, `book:"art of war"`)
+		if len(results) != 1 || results[0].Highlight.BookID != "art-of-war" {
+			t.Errorf("expected only the Art of War highlight, got %d results", len(results))
+		}
+
+		_, results = search(t, "mind tag:stoicism")
+		if len(results) != 1 || results[0].Highlight.BookID != "meditations" {
+			t.Errorf("expected only the tagged highlight, got %d results", len(results))
+		}
+	})
+
+	t.Run("should match book titles and authors", func(t *testing.T) {
+		_, results := search(t, "tzu")
+
+		if len(results) != 1 || results[0].Highlight.BookID != "art-of-war" {
+			t.Errorf("expected the Sun Tzu highlight, got %d results", len(results))
+		}
+	})
+
+	t.Run("should fail without a query", func(t *testing.T) {
+		code, _ := search(t, " ")
+
+		if code != http.StatusBadRequest {
+			t.Errorf("expected status code %d, got %d", http.StatusBadRequest, code)
+		}
+	})
+}
+
+// Only the methods used by the searcher are implemented
+type mockHighlightStore struct {
+	types.HighlightStore
+	highlights []*types.Highlight
+}
+
+func (m *mockHighlightStore) GetUserHighlights(context.Context, primitive.ObjectID, *types.HighlightFilter) (*types.HighlightPage, error) {
+	return &types.HighlightPage{Highlights: m.highlights, Total: int64(len(m.highlights))}, nil
+}
+
+type mockBookStore struct {
+	types.BookStore
+	books map[string]*types.Book
+}
+
+func (m *mockBookStore) GetByIdentifier(_ context.Context, isbn string) (*types.Book, error) {
+	b, ok := m.books[isbn]
+	if !ok {
+		return nil, mongo.ErrNoDocuments
+	}
+
+	return b, nil
+}
+
+func (m *mockBookStore) GetByIDs(_ context.Context, ids []primitive.ObjectID) ([]*types.Book, error) {
+	books := make([]*types.Book, 0)
+	for _, b := range m.books {
+		for _, id := range ids {
+			if b.ID == id {
+				books = append(books, b)
+			}
+		}
+	}
+
+	return books, nil
+}
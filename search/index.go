@@ -0,0 +1,453 @@
+package search
+
+import (
+	"context"
+	"math"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sikozonpc/notebase/analysis"
+	"github.com/sikozonpc/notebase/highlight"
+	t "github.com/sikozonpc/notebase/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// BM25 parameters
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// Prefix and fuzzy matches of a term count less than exact ones
+const (
+	prefixFactor = 0.7
+	fuzzyFactor  = 0.4
+)
+
+// Fields of an indexed highlight, in the order of fieldWeights
+const (
+	fieldText = iota
+	fieldNote
+	fieldTags
+	fieldTitle
+	fieldAuthors
+	numFields
+)
+
+var fieldWeights = [numFields]float64{textWeight, noteWeight, tagsWeight, titleWeight, authorsWeight}
+
+// Index is an in-process inverted index over every user's highlights. It is
+// kept in sync by registering it as a hook on the highlight store.
+type Index struct {
+	mu    sync.RWMutex
+	users map[primitive.ObjectID]*userIndex
+	// Counts the writes, to know if there are any since the last save
+	changes uint64
+	saved   atomic.Uint64
+	// When the content was last saved or loaded
+	savedAt time.Time
+
+	books t.BookStore
+}
+
+// userIndex holds the documents and postings of one user, searches never
+// cross users. Fields are exported to be persisted.
+type userIndex struct {
+	Docs map[primitive.ObjectID]*indexedDoc
+	// Term to document to frequencies per field
+	Postings map[string]map[primitive.ObjectID][numFields]int
+	// Sum of the field lengths of all documents, for the average
+	Lengths [numFields]int
+}
+
+type indexedDoc struct {
+	Highlight *t.Highlight
+	Book      *t.Book
+	Lengths   [numFields]int
+	Terms     []string
+}
+
+func NewIndex(books t.BookStore) *Index {
+	return &Index{
+		users: make(map[primitive.ObjectID]*userIndex),
+		books: books,
+	}
+}
+
+func newUserIndex() *userIndex {
+	return &userIndex{
+		Docs:     make(map[primitive.ObjectID]*indexedDoc),
+		Postings: make(map[string]map[primitive.ObjectID][numFields]int),
+	}
+}
+
+// HighlightSaved adds or replaces a highlight in the index
+func (idx *Index) HighlightSaved(ctx context.Context, h *t.Highlight) {
+	bk, err := idx.books.GetByIdentifier(ctx, h.BookID)
+	if err != nil {
+		// Still searchable by its own text
+		bk = nil
+	}
+
+	idx.Add(h, bk)
+}
+
+// HighlightDeleted removes a highlight from the index
+func (idx *Index) HighlightDeleted(_ context.Context, userID, id primitive.ObjectID) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	if ui, ok := idx.users[userID]; ok {
+		ui.remove(id)
+		idx.changes++
+	}
+}
+
+// Add indexes a highlight with its book, which may be nil
+func (idx *Index) Add(h *t.Highlight, bk *t.Book) {
+	fields := [numFields][]analysis.Token{
+		fieldText: analysis.Analyze(h.Text),
+		fieldNote: analysis.Analyze(h.Note),
+		fieldTags: analysis.Analyze(strings.Join(h.Tags, " ")),
+	}
+	if bk != nil {
+		fields[fieldTitle] = analysis.Analyze(bk.Title)
+		fields[fieldAuthors] = analysis.Analyze(bk.Authors)
+	}
+
+	freqs := make(map[string][numFields]int)
+	doc := &indexedDoc{Highlight: h, Book: bk}
+	for f, tokens := range fields {
+		doc.Lengths[f] = len(tokens)
+		for _, tok := range tokens {
+			fr := freqs[tok.Term]
+			fr[f]++
+			freqs[tok.Term] = fr
+		}
+	}
+
+	doc.Terms = make([]string, 0, len(freqs))
+	for term := range freqs {
+		doc.Terms = append(doc.Terms, term)
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	ui, ok := idx.users[h.UserID]
+	if !ok {
+		ui = newUserIndex()
+		idx.users[h.UserID] = ui
+	}
+
+	ui.remove(h.ID)
+
+	ui.Docs[h.ID] = doc
+	for f := range doc.Lengths {
+		ui.Lengths[f] += doc.Lengths[f]
+	}
+	for term, fr := range freqs {
+		postings, ok := ui.Postings[term]
+		if !ok {
+			postings = make(map[primitive.ObjectID][numFields]int)
+			ui.Postings[term] = postings
+		}
+		postings[h.ID] = fr
+	}
+
+	idx.changes++
+}
+
+func (ui *userIndex) remove(id primitive.ObjectID) {
+	doc, ok := ui.Docs[id]
+	if !ok {
+		return
+	}
+
+	for _, term := range doc.Terms {
+		delete(ui.Postings[term], id)
+		if len(ui.Postings[term]) == 0 {
+			delete(ui.Postings, term)
+		}
+	}
+
+	for f := range doc.Lengths {
+		ui.Lengths[f] -= doc.Lengths[f]
+	}
+
+	delete(ui.Docs, id)
+}
+
+// Len returns the number of indexed highlights
+func (idx *Index) Len() int {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	n := 0
+	for _, ui := range idx.users {
+		n += len(ui.Docs)
+	}
+
+	return n
+}
+
+// Search ranks the user's highlights with BM25 over the weighted fields.
+// Terms also match indexed terms they are a prefix of or a small typo away
+// from, phrases must appear as written.
+func (idx *Index) Search(_ context.Context, userID primitive.ObjectID, q *t.SearchQuery, limit int) ([]*t.SearchResult, error) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	results := make([]*t.SearchResult, 0)
+
+	ui, ok := idx.users[userID]
+	if !ok {
+		return results, nil
+	}
+
+	scores := make(map[primitive.ObjectID]float64)
+
+	if len(q.Phrases) > 0 {
+		for id, score := range ui.phraseMatches(q) {
+			scores[id] = score
+		}
+
+		// Terms only rank the documents that have every phrase
+		for _, term := range q.Terms {
+			for id, score := range ui.termScores(term) {
+				if _, ok := scores[id]; ok {
+					scores[id] += score
+				}
+			}
+		}
+	} else if len(q.Terms) > 0 {
+		for _, term := range q.Terms {
+			for id, score := range ui.termScores(term) {
+				scores[id] += score
+			}
+		}
+	} else {
+		// Only qualifiers, every document is a candidate
+		for id := range ui.Docs {
+			scores[id] = 0
+		}
+	}
+
+	for id, score := range scores {
+		doc := ui.Docs[id]
+		if !matchesQualifiers(doc.Highlight.Tags, doc.Book, q) {
+			continue
+		}
+
+		results = append(results, &t.SearchResult{
+			Highlight:   doc.Highlight,
+			Book:        doc.Book,
+			Score:       score,
+			TextSnippet: Snippet(doc.Highlight.Text, q, SnippetLength),
+			NoteSnippet: Snippet(doc.Highlight.Note, q, SnippetLength),
+		})
+	}
+
+	sortResults(results)
+	if len(results) > limit {
+		results = results[:limit]
+	}
+
+	return results, nil
+}
+
+// termScores scores the documents matching a query term. Each document
+// counts its best match among the exact, prefix and fuzzy expansions.
+func (ui *userIndex) termScores(term string) map[primitive.ObjectID]float64 {
+	scores := make(map[primitive.ObjectID]float64)
+
+	if analysis.IsStopWord(term) {
+		return scores
+	}
+
+	for indexed, factor := range ui.expand(term) {
+		for id, score := range ui.bm25(indexed) {
+			if score*factor > scores[id] {
+				scores[id] = score * factor
+			}
+		}
+	}
+
+	return scores
+}
+
+// expand returns the indexed terms a query term matches with their factor
+func (ui *userIndex) expand(term string) map[string]float64 {
+	stem := analysis.Stem(term)
+	edits := maxEdits(stem)
+
+	terms := make(map[string]float64)
+	for indexed := range ui.Postings {
+		switch {
+		case indexed == stem:
+			terms[indexed] = 1
+		case len(term) >= minPrefixLength && (strings.HasPrefix(indexed, stem) || strings.HasPrefix(indexed, term)):
+			terms[indexed] = prefixFactor
+		case edits > 0 && withinDistance(indexed, stem, edits):
+			terms[indexed] = fuzzyFactor
+		}
+	}
+
+	return terms
+}
+
+func (ui *userIndex) bm25(term string) map[primitive.ObjectID]float64 {
+	postings := ui.Postings[term]
+
+	n := float64(len(ui.Docs))
+	df := float64(len(postings))
+	idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+
+	var avg [numFields]float64
+	for f := range avg {
+		avg[f] = float64(ui.Lengths[f]) / n
+	}
+
+	scores := make(map[primitive.ObjectID]float64, len(postings))
+	for id, freqs := range postings {
+		doc := ui.Docs[id]
+
+		// BM25F: field frequencies are normalized by the field's length and
+		// weighted before saturating
+		tf := 0.0
+		for f, freq := range freqs {
+			if freq == 0 {
+				continue
+			}
+			norm := 1 - bm25B + bm25B*float64(doc.Lengths[f])/avg[f]
+			tf += fieldWeights[f] * float64(freq) / norm
+		}
+
+		scores[id] = idf * tf * (bm25K1 + 1) / (bm25K1 + tf)
+	}
+
+	return scores
+}
+
+// phraseMatches returns the documents containing every phrase, scored by the
+// phrases' terms. Candidates come from the postings and are checked against
+// the original text, since the index doesn't keep positions.
+func (ui *userIndex) phraseMatches(q *t.SearchQuery) map[primitive.ObjectID]float64 {
+	var scores map[primitive.ObjectID]float64
+
+	for _, phrase := range q.Phrases {
+		words := strings.Fields(phrase)
+
+		var candidates map[primitive.ObjectID]float64
+		for _, word := range words {
+			if analysis.IsStopWord(word) {
+				continue
+			}
+
+			wordScores := ui.bm25(analysis.Stem(word))
+			if candidates == nil {
+				candidates = wordScores
+				continue
+			}
+
+			for id, score := range candidates {
+				if s, ok := wordScores[id]; ok {
+					candidates[id] = score + s
+				} else {
+					delete(candidates, id)
+				}
+			}
+		}
+
+		// A phrase of stop words can only be checked against every document
+		if candidates == nil {
+			candidates = make(map[primitive.ObjectID]float64, len(ui.Docs))
+			for id := range ui.Docs {
+				candidates[id] = 0
+			}
+		}
+
+		for id := range candidates {
+			if !ui.Docs[id].hasPhrase(words) {
+				delete(candidates, id)
+			}
+		}
+
+		if scores == nil {
+			scores = candidates
+			continue
+		}
+
+		for id, score := range scores {
+			if s, ok := candidates[id]; ok {
+				scores[id] = score + s
+			} else {
+				delete(scores, id)
+			}
+		}
+	}
+
+	return scores
+}
+
+func (d *indexedDoc) hasPhrase(words []string) bool {
+	fields := []string{d.Highlight.Text, d.Highlight.Note, strings.Join(d.Highlight.Tags, " ")}
+	if d.Book != nil {
+		fields = append(fields, d.Book.Title, d.Book.Authors)
+	}
+
+	for _, text := range fields {
+		tokens := analysis.Tokens(text)
+		for i := 0; i+len(words) <= len(tokens); i++ {
+			if phraseAt(tokens, i, words) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Rebuild replaces the index content with every highlight in the database.
+// Searches keep using the previous content until it's done.
+func (idx *Index) Rebuild(ctx context.Context, db *mongo.Database) error {
+	cursor, err := db.Collection(highlight.CollName).Find(ctx, bson.M{"deletedAt": nil})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	fresh := NewIndex(idx.books)
+	books := make(map[string]*t.Book)
+	for cursor.Next(ctx) {
+		h := new(t.Highlight)
+		if err := cursor.Decode(h); err != nil {
+			return err
+		}
+
+		bk, ok := books[h.BookID]
+		if !ok {
+			bk, err = idx.books.GetByIdentifier(ctx, h.BookID)
+			if err != nil {
+				bk = nil
+			}
+			books[h.BookID] = bk
+		}
+
+		fresh.Add(h, bk)
+	}
+	if err := cursor.Err(); err != nil {
+		return err
+	}
+
+	idx.mu.Lock()
+	idx.users = fresh.users
+	idx.changes++
+	idx.mu.Unlock()
+
+	return nil
+}
@@ -0,0 +1,178 @@
+package search
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	types "github.com/sikozonpc/notebase/types"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestIndex(t *testing.T) {
+	userID := primitive.NewObjectID()
+	otherUserID := primitive.NewObjectID()
+
+	meditations := &types.Book{ISBN: "meditations", Title: "Meditations", Authors: "Marcus Aurelius"}
+	habits := &types.Highlight{ID: primitive.NewObjectID(), UserID: userID, Text: "We are what we repeatedly do. Excellence is a habit."}
+	mind := &types.Highlight{ID: primitive.NewObjectID(), UserID: userID, Text: "You have power over your mind, not outside events.", BookID: "meditations", Tags: []string{"stoicism"}}
+	running := &types.Highlight{ID: primitive.NewObjectID(), UserID: userID, Text: "Running every morning clears the mind.", Note: "connected to habits"}
+	private := &types.Highlight{ID: primitive.NewObjectID(), UserID: otherUserID, Text: "Excellence in all things."}
+
+	newIndex := func() *Index {
+		idx := NewIndex(&mockBookStore{books: map[string]*types.Book{"meditations": meditations}})
+		for _, h := range []*types.Highlight{habits, mind, running, private} {
+			idx.HighlightSaved(context.Background(), h)
+		}
+
+		return idx
+	}
+
+	search := func(t *testing.T, idx *Index, q string) []*types.SearchResult {
+		results, err := idx.Search(context.Background(), userID, ParseQuery(q), 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return results
+	}
+
+	ids := func(results []*types.SearchResult) []primitive.ObjectID {
+		ids := make([]primitive.ObjectID, len(results))
+		for i, r := range results {
+			ids[i] = r.Highlight.ID
+		}
+
+		return ids
+	}
+
+	t.Run("should match stemmed terms and rank by relevance", func(t *testing.T) {
+		results := search(t, newIndex(), "habits")
+
+		if len(results) != 2 {
+			t.Fatalf("expected 2 results, got %d", len(results))
+		}
+
+		// A match in the text counts more than one in the note
+		if results[0].Highlight.ID != habits.ID {
+			t.Errorf("expected the text match first")
+		}
+	})
+
+	t.Run("should match prefixes and typos", func(t *testing.T) {
+		if results := search(t, newIndex(), "excel"); len(results) != 1 || results[0].Highlight.ID != habits.ID {
+			t.Errorf("expected the prefix to match, got %v", ids(results))
+		}
+
+		if results := search(t, newIndex(), "repeatdly"); len(results) != 1 || results[0].Highlight.ID != habits.ID {
+			t.Errorf("expected the typo to match, got %v", ids(results))
+		}
+
+		if results := search(t, newIndex(), "xyz"); len(results) != 0 {
+			t.Errorf("expected no results, got %v", ids(results))
+		}
+	})
+
+	t.Run("should ignore stop words", func(t *testing.T) {
+		if results := search(t, newIndex(), "the"); len(results) != 0 {
+			t.Errorf("expected no results, got %v", ids(results))
+		}
+	})
+
+	t.Run("should match phrases as written", func(t *testing.T) {
+		if results := search(t, newIndex(), `"power over your mind"`); len(results) != 1 || results[0].Highlight.ID != mind.ID {
+			t.Errorf("expected the phrase to match, got %v", ids(results))
+		}
+
+		if results := search(t, newIndex(), `"mind over power"`); len(results) != 0 {
+			t.Errorf("expected no results, got %v", ids(results))
+		}
+	})
+
+	t.Run("should search book fields and apply qualifiers", func(t *testing.T) {
+		if results := search(t, newIndex(), "aurelius"); len(results) != 1 || results[0].Book != meditations {
+			t.Errorf("expected the Meditations highlight, got %v", ids(results))
+		}
+
+		if results := search(t, newIndex(), "mind tag:stoicism"); len(results) != 1 || results[0].Highlight.ID != mind.ID {
+			t.Errorf("expected the tagged highlight, got %v", ids(results))
+		}
+	})
+
+	t.Run("should keep users apart", func(t *testing.T) {
+		if results := search(t, newIndex(), "things"); len(results) != 0 {
+			t.Errorf("expected no results, got %v", ids(results))
+		}
+	})
+
+	t.Run("should follow updates and deletes", func(t *testing.T) {
+		idx := newIndex()
+
+		updated := *habits
+		updated.Text = "Quality is not an act."
+		idx.HighlightSaved(context.Background(), &updated)
+		idx.HighlightDeleted(context.Background(), userID, running.ID)
+
+		if results := search(t, idx, "habits"); len(results) != 0 {
+			t.Errorf("expected no results, got %v", ids(results))
+		}
+
+		if results := search(t, idx, "quality"); len(results) != 1 {
+			t.Errorf("expected the updated highlight, got %v", ids(results))
+		}
+
+		if idx.Len() != 3 {
+			t.Errorf("expected 3 indexed highlights, got %d", idx.Len())
+		}
+	})
+
+	t.Run("should save and load the index", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "search.idx")
+
+		idx := newIndex()
+		if err := idx.Save(path); err != nil {
+			t.Fatal(err)
+		}
+
+		if idx.isDirty() {
+			t.Errorf("expected the index to be saved")
+		}
+
+		loaded := NewIndex(nil)
+		if err := loaded.Load(path); err != nil {
+			t.Fatal(err)
+		}
+
+		if loaded.Len() != idx.Len() {
+			t.Errorf("expected %d highlights, got %d", idx.Len(), loaded.Len())
+		}
+
+		if !loaded.savedAt.Equal(idx.savedAt) {
+			t.Errorf("expected the save time %v, got %v", idx.savedAt, loaded.savedAt)
+		}
+
+		if results := search(t, loaded, `aurelius "outside events"`); len(results) != 1 || results[0].Book.Title != "Meditations" {
+			t.Errorf("expected the Meditations highlight, got %v", ids(results))
+		}
+	})
+}
+
+func TestWithinDistance(t *testing.T) {
+	cases := []struct {
+		a, b string
+		max  int
+		want bool
+	}{
+		{"habit", "habit", 0, true},
+		{"habit", "habti", 1, false},
+		{"habit", "habti", 2, true},
+		{"excel", "excell", 1, true},
+		{"excel", "excellent", 2, false},
+	}
+
+	for _, c := range cases {
+		if got := withinDistance(c.a, c.b, c.max); got != c.want {
+			t.Errorf("withinDistance(%q, %q, %d) = %v, want %v", c.a, c.b, c.max, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,320 @@
+package search
+
+import (
+	"context"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sikozonpc/notebase/book"
+	"github.com/sikozonpc/notebase/highlight"
+	t "github.com/sikozonpc/notebase/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Highlights whose book title or authors match are ranked below the ones
+// matching in their own text
+const bookMatchWeight = 0.5
+
+// MongoSearcher searches using the text indexes on highlights and books
+type MongoSearcher struct {
+	db *mongo.Database
+}
+
+func NewMongoSearcher(db *mongo.Database) *MongoSearcher {
+	return &MongoSearcher{db: db}
+}
+
+type scoredHighlight struct {
+	t.Highlight `bson:",inline"`
+	Score       float64 `bson:"score"`
+}
+
+type scoredBook struct {
+	ISBN  string  `bson:"isbn"`
+	Score float64 `bson:"score"`
+}
+
+func (s *MongoSearcher) Search(ctx context.Context, userID primitive.ObjectID, q *t.SearchQuery, limit int) ([]*t.SearchResult, error) {
+	filter := bson.M{
+		"userId":    userID,
+		"deletedAt": nil,
+	}
+	if len(q.Tags) > 0 {
+		filter["tags"] = bson.M{"$all": q.Tags}
+	}
+
+	var qualified []string
+	if len(q.Authors) > 0 || len(q.Books) > 0 {
+		var err error
+		qualified, err = s.qualifiedBooks(ctx, q)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(qualified) == 0 {
+			return []*t.SearchResult{}, nil
+		}
+
+		filter["bookId"] = bson.M{"$in": qualified}
+	}
+
+	var (
+		results []*t.SearchResult
+		err     error
+	)
+	if len(q.Terms) == 0 && len(q.Phrases) == 0 {
+		results, err = s.latest(ctx, filter, limit)
+	} else {
+		results, err = s.textSearch(ctx, filter, qualified, q, limit)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.attachBooks(ctx, results); err != nil {
+		return nil, err
+	}
+
+	for _, r := range results {
+		r.TextSnippet = Snippet(r.Highlight.Text, q, SnippetLength)
+		r.NoteSnippet = Snippet(r.Highlight.Note, q, SnippetLength)
+	}
+
+	return results, nil
+}
+
+// latest returns the most recent highlights, for searches with only qualifiers
+func (s *MongoSearcher) latest(ctx context.Context, filter bson.M, limit int) ([]*t.SearchResult, error) {
+	col := s.db.Collection(highlight.CollName)
+
+	cursor, err := col.Find(ctx, filter, options.Find().
+		SetSort(bson.D{{Key: "createdAt", Value: -1}}).
+		SetLimit(int64(limit)))
+	if err != nil {
+		return nil, err
+	}
+
+	var hs []*t.Highlight
+	if err = cursor.All(ctx, &hs); err != nil {
+		return nil, err
+	}
+
+	results := make([]*t.SearchResult, len(hs))
+	for i, h := range hs {
+		results[i] = &t.SearchResult{Highlight: h}
+	}
+
+	return results, nil
+}
+
+func (s *MongoSearcher) textSearch(ctx context.Context, filter bson.M, qualified []string, q *t.SearchQuery, limit int) ([]*t.SearchResult, error) {
+	col := s.db.Collection(highlight.CollName)
+	search := textSearchString(q)
+
+	byID := make(map[primitive.ObjectID]*t.SearchResult)
+
+	textFilter := copyFilter(filter)
+	textFilter["$text"] = bson.M{"$search": search}
+
+	cursor, err := col.Find(ctx, textFilter, options.Find().
+		SetProjection(bson.M{"score": bson.M{"$meta": "textScore"}}).
+		SetSort(bson.D{{Key: "score", Value: bson.M{"$meta": "textScore"}}}).
+		SetLimit(int64(limit)))
+	if err != nil {
+		return nil, err
+	}
+
+	var hs []*scoredHighlight
+	if err = cursor.All(ctx, &hs); err != nil {
+		return nil, err
+	}
+
+	for _, h := range hs {
+		h := h
+		byID[h.ID] = &t.SearchResult{Highlight: &h.Highlight, Score: h.Score}
+	}
+
+	books, err := s.matchingBooks(ctx, search, qualified)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(books) > 0 {
+		bookScores := make(map[string]float64, len(books))
+		isbns := make([]string, len(books))
+		for i, b := range books {
+			bookScores[b.ISBN] = b.Score
+			isbns[i] = b.ISBN
+		}
+
+		bookFilter := copyFilter(filter)
+		bookFilter["bookId"] = bson.M{"$in": isbns}
+
+		cursor, err := col.Find(ctx, bookFilter, options.Find().SetLimit(int64(limit)))
+		if err != nil {
+			return nil, err
+		}
+
+		var bhs []*t.Highlight
+		if err = cursor.All(ctx, &bhs); err != nil {
+			return nil, err
+		}
+
+		for _, h := range bhs {
+			score := bookScores[h.BookID] * bookMatchWeight
+			if r, ok := byID[h.ID]; ok {
+				r.Score += score
+				continue
+			}
+
+			byID[h.ID] = &t.SearchResult{Highlight: h, Score: score}
+		}
+	}
+
+	results := make([]*t.SearchResult, 0, len(byID))
+	for _, r := range byID {
+		results = append(results, r)
+	}
+
+	sortResults(results)
+	if len(results) > limit {
+		results = results[:limit]
+	}
+
+	return results, nil
+}
+
+// matchingBooks returns the books whose title or authors match the search,
+// restricted to the qualified ones if there are any
+func (s *MongoSearcher) matchingBooks(ctx context.Context, search string, qualified []string) ([]*scoredBook, error) {
+	col := s.db.Collection(book.CollName)
+
+	filter := bson.M{
+		"$text": bson.M{"$search": search},
+	}
+	if qualified != nil {
+		filter["isbn"] = bson.M{"$in": qualified}
+	}
+
+	cursor, err := col.Find(ctx, filter, options.Find().
+		SetProjection(bson.M{"isbn": 1, "score": bson.M{"$meta": "textScore"}}).
+		SetLimit(50))
+	if err != nil {
+		return nil, err
+	}
+
+	var books []*scoredBook
+	if err = cursor.All(ctx, &books); err != nil {
+		return nil, err
+	}
+
+	return books, nil
+}
+
+// qualifiedBooks returns the ids of the books matching every author: and
+// book: qualifier
+func (s *MongoSearcher) qualifiedBooks(ctx context.Context, q *t.SearchQuery) ([]string, error) {
+	col := s.db.Collection(book.CollName)
+
+	and := bson.A{}
+	for _, a := range q.Authors {
+		and = append(and, bson.M{"authors": bson.M{"$regex": regexp.QuoteMeta(a), "$options": "i"}})
+	}
+	for _, b := range q.Books {
+		and = append(and, bson.M{"title": bson.M{"$regex": regexp.QuoteMeta(b), "$options": "i"}})
+	}
+
+	cursor, err := col.Find(ctx, bson.M{"$and": and}, options.Find().SetProjection(bson.M{"isbn": 1}))
+	if err != nil {
+		return nil, err
+	}
+
+	var books []*t.Book
+	if err = cursor.All(ctx, &books); err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, len(books))
+	for i, b := range books {
+		ids[i] = b.ISBN
+	}
+
+	return ids, nil
+}
+
+func (s *MongoSearcher) attachBooks(ctx context.Context, results []*t.SearchResult) error {
+	if len(results) == 0 {
+		return nil
+	}
+
+	col := s.db.Collection(book.CollName)
+
+	ids := make([]string, 0, len(results))
+	for _, r := range results {
+		ids = append(ids, r.Highlight.BookID)
+	}
+
+	cursor, err := col.Find(ctx, bson.M{"isbn": bson.M{"$in": ids}})
+	if err != nil {
+		return err
+	}
+
+	var books []*t.Book
+	if err = cursor.All(ctx, &books); err != nil {
+		return err
+	}
+
+	byISBN := make(map[string]*t.Book, len(books))
+	for _, b := range books {
+		byISBN[b.ISBN] = b
+	}
+
+	for _, r := range results {
+		r.Book = byISBN[r.Highlight.BookID]
+	}
+
+	return nil
+}
+
+// textSearchString builds a $text search: any of the terms, all of the phrases
+func textSearchString(q *t.SearchQuery) string {
+	parts := make([]string, 0, len(q.Terms)+len(q.Phrases))
+	parts = append(parts, q.Terms...)
+	for _, p := range q.Phrases {
+		parts = append(parts, `"`+p+`"`)
+	}
+
+	return strings.Join(parts, " ")
+}
+
+func copyFilter(filter bson.M) bson.M {
+	c := make(bson.M, len(filter))
+	for k, v := range filter {
+		c[k] = v
+	}
+
+	return c
+}
+
+func sortResults(results []*t.SearchResult) {
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+
+		return createdAt(results[i]).After(createdAt(results[j]))
+	})
+}
+
+func createdAt(r *t.SearchResult) time.Time {
+	if r.Note != nil {
+		return r.Note.CreatedAt
+	}
+
+	return r.Highlight.CreatedAt
+}
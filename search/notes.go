@@ -0,0 +1,112 @@
+package search
+
+import (
+	"context"
+	"strings"
+
+	"github.com/sikozonpc/notebase/analysis"
+	t "github.com/sikozonpc/notebase/types"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// NoteSearcher adds the user's standalone notes to what another searcher
+// finds in their highlights. Users write far fewer notes than they import
+// highlights, so notes are scanned and scored like the ScanSearcher does,
+// whatever backend searches the highlights.
+type NoteSearcher struct {
+	searcher t.Searcher
+	notes    t.NoteStore
+	books    t.BookStore
+}
+
+func WithNotes(searcher t.Searcher, notes t.NoteStore, books t.BookStore) *NoteSearcher {
+	return &NoteSearcher{searcher: searcher, notes: notes, books: books}
+}
+
+func (s *NoteSearcher) Search(ctx context.Context, userID primitive.ObjectID, q *t.SearchQuery, limit int) ([]*t.SearchResult, error) {
+	results, err := s.searcher.Search(ctx, userID, q, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	notes, err := s.notes.GetUserNotes(ctx, userID, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]primitive.ObjectID, 0)
+	for _, n := range notes {
+		if n.BookID != nil {
+			ids = append(ids, *n.BookID)
+		}
+	}
+
+	books, err := s.books.GetByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[primitive.ObjectID]*t.Book, len(books))
+	for _, b := range books {
+		byID[b.ID] = b
+	}
+
+	docs := make([]*document, 0, len(notes))
+	for _, n := range notes {
+		var b *t.Book
+		if n.BookID != nil {
+			b = byID[*n.BookID]
+		}
+
+		if !matchesQualifiers(n.Tags, b, q) {
+			continue
+		}
+
+		docs = append(docs, newNoteDocument(n, b))
+	}
+
+	idf := inverseDocumentFrequencies(docs, q.Terms)
+
+	for _, d := range docs {
+		score, ok := scoreDocument(d, q, idf)
+		if !ok {
+			continue
+		}
+
+		results = append(results, &t.SearchResult{
+			Note:        d.note,
+			Book:        d.book,
+			Score:       score,
+			TextSnippet: Snippet(d.note.Body, q, SnippetLength),
+		})
+	}
+
+	sortResults(results)
+	if len(results) > limit {
+		results = results[:limit]
+	}
+
+	return results, nil
+}
+
+// newNoteDocument weighs a note's body like a highlight's text, it's the
+// user's own words either way
+func newNoteDocument(n *t.Note, b *t.Book) *document {
+	d := &document{
+		note: n,
+		book: b,
+		fields: []field{
+			{analysis.Tokens(n.Body), textWeight},
+			{analysis.Tokens(strings.Join(n.Tags, " ")), tagsWeight},
+		},
+	}
+
+	if b != nil {
+		d.fields = append(d.fields,
+			field{analysis.Tokens(b.Title), titleWeight},
+			field{analysis.Tokens(b.Authors), authorsWeight},
+		)
+	}
+
+	return d
+}
@@ -0,0 +1,88 @@
+package search
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	types "github.com/sikozonpc/notebase/types"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestWithNotes(t *testing.T) {
+	userID := primitive.NewObjectID()
+	meditations := &types.Book{ID: primitive.NewObjectID(), ISBN: "meditations", Title: "Meditations", Authors: "Marcus Aurelius"}
+
+	highlightStore := &mockHighlightStore{highlights: []*types.Highlight{
+		{ID: primitive.NewObjectID(), Text: "You have power over your mind, not outside events.", BookID: "meditations", CreatedAt: time.Now()},
+	}}
+	bookStore := &mockBookStore{books: map[string]*types.Book{"meditations": meditations}}
+	noteStore := &mockNoteStore{notes: []*types.Note{
+		{ID: primitive.NewObjectID(), Body: "The *mind* adapts to whatever gets in its way.", BookID: &meditations.ID, Tags: []string{"stoicism"}, CreatedAt: time.Now()},
+		{ID: primitive.NewObjectID(), Body: "Write more about habits.", Tags: []string{"ideas"}, CreatedAt: time.Now()},
+	}}
+
+	searcher := WithNotes(NewScanSearcher(highlightStore, bookStore), noteStore, bookStore)
+
+	search := func(t *testing.T, q string, limit int) []*types.SearchResult {
+		results, err := searcher.Search(context.Background(), userID, ParseQuery(q), limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return results
+	}
+
+	t.Run("should find notes alongside highlights", func(t *testing.T) {
+		results := search(t, "mind", 10)
+
+		if len(results) != 2 {
+			t.Fatalf("expected 2 results, got %d", len(results))
+		}
+
+		var note *types.SearchResult
+		for _, r := range results {
+			if r.Note != nil {
+				note = r
+			}
+		}
+
+		if note == nil || note.Highlight != nil {
+			t.Fatalf("expected a note result without a highlight")
+		}
+
+		if note.Book == nil || note.Book.Title != "Meditations" {
+			t.Errorf("expected the note's book to be resolved")
+		}
+
+		if note.TextSnippet != "The *<mark>mind</mark>* adapts to whatever gets in its way." {
+			t.Errorf("unexpected snippet %s", note.TextSnippet)
+		}
+	})
+
+	t.Run("should apply qualifiers to notes", func(t *testing.T) {
+		if results := search(t, "tag:ideas", 10); len(results) != 1 || results[0].Note == nil {
+			t.Errorf("expected only the tagged note, got %d results", len(results))
+		}
+
+		// A note without a book never matches a book qualifier
+		if results := search(t, "habits book:meditations", 10); len(results) != 0 {
+			t.Errorf("expected no results, got %d", len(results))
+		}
+	})
+
+	t.Run("should keep to the limit", func(t *testing.T) {
+		if results := search(t, "mind", 1); len(results) != 1 {
+			t.Errorf("expected 1 result, got %d", len(results))
+		}
+	})
+}
+
+type mockNoteStore struct {
+	types.NoteStore
+	notes []*types.Note
+}
+
+func (m *mockNoteStore) GetUserNotes(context.Context, primitive.ObjectID, *types.NoteFilter) ([]*types.Note, error) {
+	return m.notes, nil
+}
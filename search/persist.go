@@ -0,0 +1,188 @@
+package search
+
+import (
+	"context"
+	"encoding/gob"
+	"errors"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/sikozonpc/notebase/highlight"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Bumped when the persisted format changes, older files are rebuilt
+const indexFormatVersion = 2
+
+// Highlights written this long before a save may have reached the index
+// after it, their hook was still waiting
+const saveMargin = time.Minute
+
+type indexFile struct {
+	Version int
+	SavedAt time.Time
+	Users   map[primitive.ObjectID]*userIndex
+}
+
+// ErrIndexOutdated is returned when loading an index saved in an older format
+var ErrIndexOutdated = errors.New("search index format is outdated")
+
+// Save writes the index to path. It writes to a temporary file first so a
+// crash never leaves a truncated index behind.
+func (idx *Index) Save(path string) error {
+	// Searches can go on while saving, changes made meanwhile are saved next time
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	savedAt := time.Now().UTC()
+	err = gob.NewEncoder(tmp).Encode(&indexFile{
+		Version: indexFormatVersion,
+		SavedAt: savedAt,
+		Users:   idx.users,
+	})
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+
+	idx.saved.Store(idx.changes)
+	idx.savedAt = savedAt
+
+	return nil
+}
+
+// Load replaces the index content with the one saved at path. It returns an
+// error wrapping fs.ErrNotExist when nothing was saved yet.
+func (idx *Index) Load(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var file indexFile
+	if err := gob.NewDecoder(f).Decode(&file); err != nil {
+		return err
+	}
+
+	if file.Version != indexFormatVersion {
+		return ErrIndexOutdated
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.users = file.Users
+	if idx.users == nil {
+		idx.users = make(map[primitive.ObjectID]*userIndex)
+	}
+	idx.changes++
+	idx.saved.Store(idx.changes)
+	idx.savedAt = file.SavedAt
+
+	return nil
+}
+
+// LoadOrRebuild loads the index saved at path, rebuilding it from the
+// database when there is none, it can't be read, or highlights changed
+// since it was saved
+func (idx *Index) LoadOrRebuild(ctx context.Context, path string, db *mongo.Database) error {
+	err := idx.Load(path)
+	if err == nil {
+		stale, err := idx.isStale(ctx, db)
+		if err != nil || !stale {
+			return err
+		}
+
+		log.Printf("Rebuilding search index, highlights changed since %s was saved", path)
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		log.Printf("Rebuilding search index, failed to load %s: %v", path, err)
+	}
+
+	if err := idx.Rebuild(ctx, db); err != nil {
+		return err
+	}
+
+	return idx.Save(path)
+}
+
+// AutoSave saves the index to path every interval when it changed, and a
+// last time when ctx is done
+func (idx *Index) AutoSave(ctx context.Context, path string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+		}
+
+		if idx.isDirty() {
+			if err := idx.Save(path); err != nil {
+				log.Printf("failed to save search index: %v", err)
+			}
+		}
+
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// isStale tells if the loaded index misses writes, made after it was saved
+// by a process that stopped before saving again. Restored highlights don't
+// change updatedAt, they show in the number of highlights instead.
+func (idx *Index) isStale(ctx context.Context, db *mongo.Database) (bool, error) {
+	col := db.Collection(highlight.CollName)
+
+	since := idx.savedAt.Add(-saveMargin)
+	changed, err := col.CountDocuments(ctx, bson.M{"$or": bson.A{
+		bson.M{"updatedAt": bson.M{"$gte": since}},
+		bson.M{"deletedAt": bson.M{"$gte": since}},
+	}}, options.Count().SetLimit(1))
+	if err != nil {
+		return false, err
+	}
+	if changed > 0 {
+		return true, nil
+	}
+
+	live, err := col.CountDocuments(ctx, bson.M{"deletedAt": nil})
+	if err != nil {
+		return false, err
+	}
+
+	return live != int64(idx.Len()), nil
+}
+
+func (idx *Index) isDirty() bool {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	return idx.changes != idx.saved.Load()
+}
@@ -0,0 +1,104 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/sikozonpc/notebase/analysis"
+	t "github.com/sikozonpc/notebase/types"
+)
+
+// ParseQuery parses a search string. Words are matched individually, text in
+// double quotes is matched as a phrase and author:, book: and tag: restrict
+// the results, e.g. `stoic "inner citadel" author:aurelius tag:"ancient philosophy"`.
+func ParseQuery(s string) *t.SearchQuery {
+	q := &t.SearchQuery{}
+
+	for _, part := range splitQuery(s) {
+		if part.quoted {
+			addPhrase(q, part.value)
+			continue
+		}
+
+		field, value, ok := strings.Cut(part.value, ":")
+		if ok && value != "" {
+			switch strings.ToLower(field) {
+			case "author":
+				q.Authors = append(q.Authors, strings.ToLower(value))
+				continue
+			case "book":
+				q.Books = append(q.Books, strings.ToLower(value))
+				continue
+			case "tag":
+				q.Tags = append(q.Tags, strings.ToLower(strings.Join(strings.Fields(value), " ")))
+				continue
+			}
+		}
+
+		q.Terms = append(q.Terms, analysis.Tokenize(part.value)...)
+	}
+
+	return q
+}
+
+// IsEmpty reports whether the query has nothing to search for
+func IsEmpty(q *t.SearchQuery) bool {
+	return len(q.Terms) == 0 && len(q.Phrases) == 0 && len(q.Authors) == 0 && len(q.Books) == 0 && len(q.Tags) == 0
+}
+
+// A phrase with a single word is just a term
+func addPhrase(q *t.SearchQuery, phrase string) {
+	terms := analysis.Tokenize(phrase)
+
+	switch len(terms) {
+	case 0:
+	case 1:
+		q.Terms = append(q.Terms, terms[0])
+	default:
+		q.Phrases = append(q.Phrases, strings.Join(terms, " "))
+	}
+}
+
+type queryPart struct {
+	value  string
+	quoted bool
+}
+
+// splitQuery splits on whitespace, keeping quoted text together. Quotes right
+// after a qualifier belong to it, as in book:"the art of war".
+func splitQuery(s string) []queryPart {
+	parts := make([]queryPart, 0)
+
+	var b strings.Builder
+	inQuotes, quotedPart := false, false
+
+	flush := func() {
+		if b.Len() > 0 {
+			parts = append(parts, queryPart{value: b.String(), quoted: quotedPart})
+		}
+		b.Reset()
+		quotedPart = false
+	}
+
+	for _, r := range s {
+		switch {
+		case r == '"':
+			if inQuotes {
+				inQuotes = false
+				flush()
+				continue
+			}
+
+			inQuotes = true
+			// A quote starting a new part makes it a phrase
+			quotedPart = b.Len() == 0
+		case unicode.IsSpace(r) && !inQuotes:
+			flush()
+		default:
+			b.WriteRune(r)
+		}
+	}
+	flush()
+
+	return parts
+}
@@ -0,0 +1,228 @@
+package search
+
+import (
+	"context"
+	"math"
+	"strings"
+
+	"github.com/sikozonpc/notebase/analysis"
+	t "github.com/sikozonpc/notebase/types"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// How much a match in each field counts towards the score. The Mongo text
+// indexes use the same proportions.
+const (
+	textWeight    = 3
+	noteWeight    = 2
+	tagsWeight    = 1
+	titleWeight   = 1.5
+	authorsWeight = 1
+)
+
+// ScanSearcher ranks a user's highlights in memory using only the store
+// interfaces, so it works with any store backend
+type ScanSearcher struct {
+	highlights t.HighlightStore
+	books      t.BookStore
+}
+
+func NewScanSearcher(highlights t.HighlightStore, books t.BookStore) *ScanSearcher {
+	return &ScanSearcher{highlights: highlights, books: books}
+}
+
+type field struct {
+	tokens []analysis.Token
+	weight float64
+}
+
+type document struct {
+	highlight *t.Highlight
+	note      *t.Note // Set instead of the highlight for standalone notes
+	book      *t.Book
+	fields    []field
+}
+
+func (s *ScanSearcher) Search(ctx context.Context, userID primitive.ObjectID, q *t.SearchQuery, limit int) ([]*t.SearchResult, error) {
+	page, err := s.highlights.GetUserHighlights(ctx, userID, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	books := make(map[string]*t.Book)
+	docs := make([]*document, 0, len(page.Highlights))
+	for _, h := range page.Highlights {
+		b, ok := books[h.BookID]
+		if !ok {
+			// A highlight without a book can still match on its own text
+			b, err = s.books.GetByIdentifier(ctx, h.BookID)
+			if err != nil {
+				b = nil
+			}
+			books[h.BookID] = b
+		}
+
+		if !matchesQualifiers(h.Tags, b, q) {
+			continue
+		}
+
+		docs = append(docs, newDocument(h, b))
+	}
+
+	idf := inverseDocumentFrequencies(docs, q.Terms)
+
+	results := make([]*t.SearchResult, 0)
+	for _, d := range docs {
+		score, ok := scoreDocument(d, q, idf)
+		if !ok {
+			continue
+		}
+
+		results = append(results, &t.SearchResult{
+			Highlight:   d.highlight,
+			Book:        d.book,
+			Score:       score,
+			TextSnippet: Snippet(d.highlight.Text, q, SnippetLength),
+			NoteSnippet: Snippet(d.highlight.Note, q, SnippetLength),
+		})
+	}
+
+	sortResults(results)
+	if len(results) > limit {
+		results = results[:limit]
+	}
+
+	return results, nil
+}
+
+func newDocument(h *t.Highlight, b *t.Book) *document {
+	d := &document{
+		highlight: h,
+		book:      b,
+		fields: []field{
+			{analysis.Tokens(h.Text), textWeight},
+			{analysis.Tokens(h.Note), noteWeight},
+			{analysis.Tokens(strings.Join(h.Tags, " ")), tagsWeight},
+		},
+	}
+
+	if b != nil {
+		d.fields = append(d.fields,
+			field{analysis.Tokens(b.Title), titleWeight},
+			field{analysis.Tokens(b.Authors), authorsWeight},
+		)
+	}
+
+	return d
+}
+
+// matchesQualifiers checks the tags of a highlight or note, and its book
+func matchesQualifiers(tags []string, b *t.Book, q *t.SearchQuery) bool {
+	for _, tag := range q.Tags {
+		if !contains(tags, tag) {
+			return false
+		}
+	}
+
+	if len(q.Authors) == 0 && len(q.Books) == 0 {
+		return true
+	}
+
+	if b == nil {
+		return false
+	}
+
+	for _, a := range q.Authors {
+		if !strings.Contains(strings.ToLower(b.Authors), a) {
+			return false
+		}
+	}
+
+	for _, title := range q.Books {
+		if !strings.Contains(strings.ToLower(b.Title), title) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// scoreDocument weighs each term match by its field and rarity. A document
+// must contain every phrase and, when there are terms, at least one of them.
+func scoreDocument(d *document, q *t.SearchQuery, idf map[string]float64) (float64, bool) {
+	score := 0.0
+
+	for _, phrase := range q.Phrases {
+		words := strings.Fields(phrase)
+
+		found := false
+		for _, f := range d.fields {
+			for i := 0; i+len(words) <= len(f.tokens); i++ {
+				if phraseAt(f.tokens, i, words) {
+					score += f.weight * float64(len(words))
+					found = true
+				}
+			}
+		}
+
+		if !found {
+			return 0, false
+		}
+	}
+
+	termScore := 0.0
+	for _, term := range q.Terms {
+		for _, f := range d.fields {
+			for _, tok := range f.tokens {
+				if matchesTerm(tok.Term, term) {
+					termScore += f.weight * idf[term]
+				}
+			}
+		}
+	}
+
+	if len(q.Terms) > 0 && len(q.Phrases) == 0 && termScore == 0 {
+		return 0, false
+	}
+
+	return score + termScore, true
+}
+
+func inverseDocumentFrequencies(docs []*document, terms []string) map[string]float64 {
+	idf := make(map[string]float64, len(terms))
+
+	for _, term := range terms {
+		df := 0
+		for _, d := range docs {
+			if documentHasTerm(d, term) {
+				df++
+			}
+		}
+
+		idf[term] = math.Log(1 + float64(len(docs))/float64(1+df))
+	}
+
+	return idf
+}
+
+func documentHasTerm(d *document, term string) bool {
+	for _, f := range d.fields {
+		for _, tok := range f.tokens {
+			if matchesTerm(tok.Term, term) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,60 @@
+package search
+
+import (
+	"strings"
+	"testing"
+
+	types "github.com/sikozonpc/notebase/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseQuery(t *testing.T) {
+	t.Run("should parse terms, phrases and qualifiers", func(t *testing.T) {
+		q := ParseQuery(`Stoic "inner  Citadel" author:Aurelius book:"the art of war" tag:"Ancient Philosophy" mind`)
+
+		assert.Equal(t, []string{"stoic", "mind"}, q.Terms)
+		assert.Equal(t, []string{"inner citadel"}, q.Phrases)
+		assert.Equal(t, []string{"aurelius"}, q.Authors)
+		assert.Equal(t, []string{"the art of war"}, q.Books)
+		assert.Equal(t, []string{"ancient philosophy"}, q.Tags)
+	})
+
+	t.Run("should treat single word phrases and unknown qualifiers as terms", func(t *testing.T) {
+		q := ParseQuery(`"focus" note:deep`)
+
+		assert.Equal(t, []string{"focus", "note", "deep"}, q.Terms)
+		assert.Empty(t, q.Phrases)
+	})
+
+	t.Run("should report empty queries", func(t *testing.T) {
+		assert.True(t, IsEmpty(ParseQuery(`  "" ...`)))
+		assert.False(t, IsEmpty(ParseQuery(`tag:go`)))
+	})
+}
+
+func TestSnippet(t *testing.T) {
+	t.Run("should mark terms and phrases", func(t *testing.T) {
+		q := ParseQuery(`lead "inner citadel"`)
+
+		snippet := Snippet("Leaders retreat into the inner citadel <always>.", q, SnippetLength)
+
+		assert.Equal(t, "<mark>Leaders</mark> retreat into the <mark>inner citadel</mark> &lt;always&gt;.", snippet)
+	})
+
+	t.Run("should cut long texts around the matches", func(t *testing.T) {
+		text := strings.Repeat("filler words here ", 30) + "the stoic mind " + strings.Repeat("more filler text ", 30)
+
+		snippet := Snippet(text, ParseQuery("stoic"), 80)
+
+		assert.True(t, strings.HasPrefix(snippet, "…"))
+		assert.True(t, strings.HasSuffix(snippet, "…"))
+		assert.Contains(t, snippet, "<mark>stoic</mark>")
+		assert.LessOrEqual(t, len(snippet), 80+len("<mark></mark>")+2*len("…"))
+	})
+
+	t.Run("should return the start of the text without matches", func(t *testing.T) {
+		snippet := Snippet("Nothing to see here", &types.SearchQuery{Terms: []string{"stoic"}}, SnippetLength)
+
+		assert.Equal(t, "Nothing to see here", snippet)
+	})
+}
@@ -0,0 +1,164 @@
+package search
+
+import (
+	"html"
+	"sort"
+	"strings"
+
+	"github.com/sikozonpc/notebase/analysis"
+	t "github.com/sikozonpc/notebase/types"
+)
+
+// SnippetLength is the approximate length, in bytes, of a snippet
+const SnippetLength = 200
+
+type span struct {
+	start, end int
+}
+
+// Snippet returns an HTML excerpt of text around the part with the most
+// matches of the query, with each match wrapped in <mark>.
+func Snippet(text string, q *t.SearchQuery, length int) string {
+	tokens := analysis.Tokens(text)
+	matches := findMatches(tokens, q)
+
+	start, end := window(text, tokens, matches, length)
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+
+	pos := start
+	for _, m := range matches {
+		if m.start < start || m.end > end {
+			continue
+		}
+
+		b.WriteString(html.EscapeString(text[pos:m.start]))
+		b.WriteString("<mark>")
+		b.WriteString(html.EscapeString(text[m.start:m.end]))
+		b.WriteString("</mark>")
+		pos = m.end
+	}
+	b.WriteString(html.EscapeString(text[pos:end]))
+
+	if end < len(text) {
+		b.WriteString("…")
+	}
+
+	return b.String()
+}
+
+func findMatches(tokens []analysis.Token, q *t.SearchQuery) []span {
+	matches := make([]span, 0)
+
+	for _, tok := range tokens {
+		for _, term := range q.Terms {
+			if matchesTerm(tok.Term, term) {
+				matches = append(matches, span{tok.Start, tok.End})
+				break
+			}
+		}
+	}
+
+	for _, phrase := range q.Phrases {
+		words := strings.Fields(phrase)
+		for i := 0; i+len(words) <= len(tokens); i++ {
+			if phraseAt(tokens, i, words) {
+				matches = append(matches, span{tokens[i].Start, tokens[i+len(words)-1].End})
+			}
+		}
+	}
+
+	return mergeSpans(matches)
+}
+
+// Terms of three letters or more also match longer words, so "lead" finds "leaders"
+func matchesTerm(token, term string) bool {
+	return token == term || (len(term) >= 3 && strings.HasPrefix(token, term))
+}
+
+func phraseAt(tokens []analysis.Token, i int, words []string) bool {
+	for j, w := range words {
+		if tokens[i+j].Term != w {
+			return false
+		}
+	}
+
+	return true
+}
+
+func mergeSpans(spans []span) []span {
+	if len(spans) == 0 {
+		return spans
+	}
+
+	sort.Slice(spans, func(i, j int) bool {
+		return spans[i].start < spans[j].start
+	})
+
+	merged := []span{spans[0]}
+	for _, s := range spans[1:] {
+		last := &merged[len(merged)-1]
+		if s.start <= last.end {
+			if s.end > last.end {
+				last.end = s.end
+			}
+			continue
+		}
+
+		merged = append(merged, s)
+	}
+
+	return merged
+}
+
+// window picks the byte range of the snippet, aligned to word boundaries,
+// that contains the most matches
+func window(text string, tokens []analysis.Token, matches []span, length int) (int, int) {
+	if len(text) <= length {
+		return 0, len(text)
+	}
+
+	anchor := 0
+	if len(matches) > 0 {
+		best, bestCount := 0, 0
+		for i, m := range matches {
+			count := 0
+			for _, other := range matches[i:] {
+				if other.end-m.start > length {
+					break
+				}
+				count++
+			}
+
+			if count > bestCount {
+				best, bestCount = i, count
+			}
+		}
+
+		// Leave some context before the first match
+		anchor = matches[best].start - length/4
+	}
+
+	start := 0
+	if anchor > 0 {
+		for _, tok := range tokens {
+			if tok.Start > anchor {
+				break
+			}
+			start = tok.Start
+		}
+	}
+
+	end := len(text)
+	for _, tok := range tokens {
+		if tok.Start > start && tok.End > start+length {
+			end = start + len(strings.TrimRight(text[start:tok.Start], " \t\n"))
+			break
+		}
+	}
+
+	return start, end
+}
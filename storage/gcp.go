@@ -3,16 +3,18 @@ package storage
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 
 	"cloud.google.com/go/storage"
-	"github.com/sikozonpc/notebase/config"
 )
 
+// GCPStorage keeps files in a Google Cloud Storage bucket
 type GCPStorage struct {
 	client *storage.Client
+	bucket string
 
 	w   io.Writer
 	ctx context.Context
@@ -20,7 +22,7 @@ type GCPStorage struct {
 	failed bool
 }
 
-func NewGCPStorage(ctx context.Context) (*GCPStorage, error) {
+func NewGCPStorage(ctx context.Context, bucket string) (*GCPStorage, error) {
 	client, err := storage.NewClient(ctx)
 	if err != nil {
 		log.Println(ctx, "failed to create client: %v", err)
@@ -33,6 +35,7 @@ func NewGCPStorage(ctx context.Context) (*GCPStorage, error) {
 		w:      buf,
 		ctx:    ctx,
 		client: client,
+		bucket: bucket,
 	}
 
 	return config, nil
@@ -45,10 +48,13 @@ func (d *GCPStorage) errorf(format string, args ...interface{}) {
 }
 
 func (s *GCPStorage) Read(filename string) (string, error) {
-	bucketName := config.Envs.GCPBooksBucketName
+	bucketName := s.bucket
 	bucket := s.client.Bucket(bucketName)
 
 	rc, err := bucket.Object(filename).NewReader(s.ctx)
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return "", fmt.Errorf("%w: %s", ErrNotFound, filename)
+	}
 	if err != nil {
 		s.errorf("unable to open file from bucket %q, file %q: %v", bucketName, filename, err)
 		return "", err
@@ -71,3 +77,38 @@ func (s *GCPStorage) Read(filename string) (string, error) {
 
 	return string(slurp), nil
 }
+
+func (s *GCPStorage) Write(filename string, data []byte) error {
+	bucketName := s.bucket
+	bucket := s.client.Bucket(bucketName)
+
+	wc := bucket.Object(filename).NewWriter(s.ctx)
+	if _, err := wc.Write(data); err != nil {
+		s.errorf("unable to write data to bucket %q, file %q: %v", bucketName, filename, err)
+		wc.Close()
+		return err
+	}
+
+	if err := wc.Close(); err != nil {
+		s.errorf("unable to write data to bucket %q, file %q: %v", bucketName, filename, err)
+		return err
+	}
+
+	return nil
+}
+
+func (s *GCPStorage) Delete(filename string) error {
+	bucketName := s.bucket
+	bucket := s.client.Bucket(bucketName)
+
+	err := bucket.Object(filename).Delete(s.ctx)
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return nil
+	}
+	if err != nil {
+		s.errorf("unable to delete file from bucket %q, file %q: %v", bucketName, filename, err)
+		return err
+	}
+
+	return nil
+}
@@ -1,16 +1,51 @@
 package storage
 
-type MemoryStorage struct{}
+import (
+	"fmt"
+	"sync"
+)
+
+// MemoryStorage keeps written files in memory
+type MemoryStorage struct {
+	mu    sync.Mutex
+	files map[string]string
+}
 
 func NewMemoryStorage() *MemoryStorage {
-	return &MemoryStorage{}
+	return &MemoryStorage{files: make(map[string]string)}
 }
 
 func (m *MemoryStorage) Read(filename string) (string, error) {
-	return fileContent, nil
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if data, ok := m.files[filename]; ok {
+		return data, nil
+	}
+
+	return "", fmt.Errorf("%w: %s", ErrNotFound, filename)
+}
+
+func (m *MemoryStorage) Write(filename string, data []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.files[filename] = string(data)
+
+	return nil
+}
+
+func (m *MemoryStorage) Delete(filename string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.files, filename)
+
+	return nil
 }
 
-var fileContent = `
+// SampleKindleExtract is a Kindle extract as uploaded to be parsed
+var SampleKindleExtract = `
 	{
   "asin": "SOMERANDOMASIN",
   "title": "Some random book on kindle",
@@ -1,7 +1,14 @@
 package storage
 
+import "errors"
+
+// ErrNotFound is returned when reading a file that was never written
+var ErrNotFound = errors.New("file not found")
+
 // Storage is an interface for interacting with the File System
 // or a cloud storage service like GCP
 type Storage interface {
 	Read(filename string) (string, error)
+	Write(filename string, data []byte) error
+	Delete(filename string) error // Deleting a file that isn't there succeeds
 }
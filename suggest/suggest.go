@@ -0,0 +1,159 @@
+package suggest
+
+import (
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/sikozonpc/notebase/analysis"
+	t "github.com/sikozonpc/notebase/types"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Highlights less similar than this are not worth suggesting
+const MinSimilarity = 0.1
+
+// vector is a sparse TF-IDF vector, normalized to unit length
+type vector map[string]float64
+
+// Corpus holds the TF-IDF vectors of a user's highlights to find the ones
+// most similar to each other, without any external service
+type Corpus struct {
+	highlights map[primitive.ObjectID]*t.Highlight
+	vectors    map[primitive.ObjectID]vector
+	df         map[string]int
+}
+
+// NewCorpus weighs the terms of each highlight's text, note and tags by how
+// rare they are across the given highlights
+func NewCorpus(hs []*t.Highlight) *Corpus {
+	c := &Corpus{
+		highlights: make(map[primitive.ObjectID]*t.Highlight, len(hs)),
+		vectors:    make(map[primitive.ObjectID]vector, len(hs)),
+		df:         make(map[string]int),
+	}
+
+	tfs := make(map[primitive.ObjectID]map[string]float64, len(hs))
+	for _, h := range hs {
+		tf := termFrequencies(h)
+		for term := range tf {
+			c.df[term]++
+		}
+
+		tfs[h.ID] = tf
+		c.highlights[h.ID] = h
+	}
+
+	n := float64(len(hs))
+	for id, tf := range tfs {
+		v := make(vector, len(tf))
+		for term, f := range tf {
+			// Smoothed so terms in every highlight still count a little
+			v[term] = f * (math.Log((1+n)/(1+float64(c.df[term]))) + 1)
+		}
+
+		c.vectors[id] = normalize(v)
+	}
+
+	return c
+}
+
+func termFrequencies(h *t.Highlight) map[string]float64 {
+	text := strings.Join(append([]string{h.Text, h.Note}, h.Tags...), " ")
+
+	tf := make(map[string]float64)
+	for _, tok := range analysis.Analyze(text) {
+		tf[tok.Term]++
+	}
+
+	// Sublinear, a term repeated ten times isn't ten times as relevant
+	for term, f := range tf {
+		tf[term] = 1 + math.Log(f)
+	}
+
+	return tf
+}
+
+func normalize(v vector) vector {
+	norm := 0.0
+	for _, w := range v {
+		norm += w * w
+	}
+
+	if norm == 0 {
+		return v
+	}
+
+	norm = math.Sqrt(norm)
+	for term := range v {
+		v[term] /= norm
+	}
+
+	return v
+}
+
+// Vector returns the unit TF-IDF vector of a highlight, from stemmed terms
+// to weights. It must not be modified.
+func (c *Corpus) Vector(id primitive.ObjectID) map[string]float64 {
+	return c.vectors[id]
+}
+
+// DocumentFrequency returns how many highlights contain a stemmed term
+func (c *Corpus) DocumentFrequency(term string) int {
+	return c.df[term]
+}
+
+// Similarity is the cosine similarity of two highlights in the corpus
+func (c *Corpus) Similarity(a, b primitive.ObjectID) float64 {
+	va, vb := c.vectors[a], c.vectors[b]
+	if len(vb) < len(va) {
+		va, vb = vb, va
+	}
+
+	sim := 0.0
+	for term, w := range va {
+		sim += w * vb[term]
+	}
+
+	return sim
+}
+
+// Related returns up to limit highlights most similar to the one with id,
+// skipping the excluded ones
+func (c *Corpus) Related(id primitive.ObjectID, limit int, exclude ...primitive.ObjectID) []*t.RelatedHighlight {
+	related := make([]*t.RelatedHighlight, 0)
+
+	if _, ok := c.vectors[id]; !ok {
+		return related
+	}
+
+	skip := make(map[primitive.ObjectID]bool, len(exclude)+1)
+	skip[id] = true
+	for _, e := range exclude {
+		skip[e] = true
+	}
+
+	for other, h := range c.highlights {
+		if skip[other] {
+			continue
+		}
+
+		if score := c.Similarity(id, other); score >= MinSimilarity {
+			related = append(related, &t.RelatedHighlight{Highlight: h, Score: score})
+		}
+	}
+
+	sort.Slice(related, func(i, j int) bool {
+		if related[i].Score != related[j].Score {
+			return related[i].Score > related[j].Score
+		}
+
+		return related[i].Highlight.ID.Hex() < related[j].Highlight.ID.Hex()
+	})
+
+	if len(related) > limit {
+		related = related[:limit]
+	}
+
+	return related
+}
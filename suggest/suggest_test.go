@@ -0,0 +1,44 @@
+package suggest
+
+import (
+	"testing"
+
+	"github.com/sikozonpc/notebase/types"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestRelated(t *testing.T) {
+	habits := &types.Highlight{ID: primitive.NewObjectID(), Text: "Habits are the compound interest of self-improvement."}
+	routine := &types.Highlight{ID: primitive.NewObjectID(), Text: "Small habits, repeated daily, build a routine.", Tags: []string{"habits"}}
+	compound := &types.Highlight{ID: primitive.NewObjectID(), Text: "Compound interest is the eighth wonder of the world."}
+	war := &types.Highlight{ID: primitive.NewObjectID(), Text: "All warfare is based on deception."}
+	empty := &types.Highlight{ID: primitive.NewObjectID()}
+
+	c := NewCorpus([]*types.Highlight{habits, routine, compound, war, empty})
+
+	related := c.Related(habits.ID, 5)
+	assert.Len(t, related, 2)
+	assert.Contains(t, []primitive.ObjectID{routine.ID, compound.ID}, related[0].Highlight.ID)
+	assert.GreaterOrEqual(t, related[0].Score, related[1].Score)
+
+	assert.Len(t, c.Related(habits.ID, 1), 1)
+	assert.Empty(t, c.Related(war.ID, 5))
+	assert.Empty(t, c.Related(empty.ID, 5))
+	assert.Empty(t, c.Related(primitive.NewObjectID(), 5))
+
+	related = c.Related(habits.ID, 5, routine.ID)
+	assert.Len(t, related, 1)
+	assert.Equal(t, compound.ID, related[0].Highlight.ID)
+}
+
+func TestSimilarity(t *testing.T) {
+	a := &types.Highlight{ID: primitive.NewObjectID(), Text: "Concurrency is not parallelism."}
+	b := &types.Highlight{ID: primitive.NewObjectID(), Text: "Concurrency is not parallelism."}
+	other := &types.Highlight{ID: primitive.NewObjectID(), Text: "Leadership is a choice."}
+
+	c := NewCorpus([]*types.Highlight{a, b, other})
+
+	assert.InDelta(t, 1.0, c.Similarity(a.ID, b.ID), 1e-9)
+	assert.Equal(t, 0.0, c.Similarity(a.ID, other.ID))
+}
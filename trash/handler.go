@@ -0,0 +1,217 @@
+package trash
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/sikozonpc/notebase/auth"
+	t "github.com/sikozonpc/notebase/types"
+	u "github.com/sikozonpc/notebase/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type Handler struct {
+	highlightStore t.HighlightStore
+	bookStore      t.BookStore
+	libraryStore   t.LibraryStore
+	userStore      t.UserStore
+}
+
+func NewHandler(highlightStore t.HighlightStore, bookStore t.BookStore, libraryStore t.LibraryStore, userStore t.UserStore) *Handler {
+	return &Handler{
+		highlightStore: highlightStore,
+		bookStore:      bookStore,
+		libraryStore:   libraryStore,
+		userStore:      userStore,
+	}
+}
+
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc(
+		"/user/{userID}/trash",
+		auth.WithJWTAuth(u.MakeHTTPHandler(h.handleGetTrash), h.userStore),
+	).Methods("GET")
+
+	router.HandleFunc(
+		"/user/{userID}/trash/highlights/{id}/restore",
+		auth.WithJWTAuth(u.MakeHTTPHandler(h.handleRestoreHighlight), h.userStore),
+	).Methods("POST")
+
+	router.HandleFunc(
+		"/user/{userID}/trash/books/{id}/restore",
+		auth.WithJWTAuth(u.MakeHTTPHandler(h.handleRestoreBook), h.userStore),
+	).Methods("POST")
+}
+
+func (h *Handler) handleGetTrash(w http.ResponseWriter, r *http.Request) error {
+	userID, err := u.GetStringParamFromRequest(r, "userID")
+	if err != nil {
+		return err
+	}
+	oUserID, _ := primitive.ObjectIDFromHex(userID)
+
+	highlights, err := h.highlightStore.GetDeletedHighlights(r.Context(), oUserID)
+	if err != nil {
+		return err
+	}
+
+	// Books have no owner, the user's are the ones they deleted highlights of
+	books, err := h.bookStore.GetDeletedByISBNs(r.Context(), bookIDs(highlights))
+	if err != nil {
+		return err
+	}
+
+	return u.WriteJSON(w, http.StatusOK, &t.Trash{
+		Highlights: highlights,
+		Books:      books,
+	})
+}
+
+// handleRestoreHighlight also restores the book of the highlight when it
+// went to the trash with it, and puts it back in the user's library
+func (h *Handler) handleRestoreHighlight(w http.ResponseWriter, r *http.Request) error {
+	oUserID, oID, err := getIDsFromRequest(r)
+	if err != nil {
+		return err
+	}
+
+	highlight, err := h.highlightStore.RestoreHighlight(r.Context(), oID, oUserID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return u.WriteJSON(w, http.StatusNotFound, t.APIError{Error: fmt.Errorf("highlight with id %v not found in the trash", oID.Hex()).Error()})
+	}
+	if err != nil {
+		return err
+	}
+
+	books, err := h.bookStore.GetDeletedByISBNs(r.Context(), []string{highlight.BookID})
+	if err != nil {
+		return err
+	}
+
+	for _, b := range books {
+		if _, err := h.bookStore.Restore(r.Context(), b.ID); err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+			return err
+		}
+	}
+
+	books, err = h.bookStore.GetByISBNs(r.Context(), []string{highlight.BookID})
+	if err != nil {
+		return err
+	}
+
+	for _, b := range books {
+		if _, err := h.libraryStore.AddBook(r.Context(), oUserID, b.ID); err != nil {
+			return err
+		}
+	}
+
+	return u.WriteJSON(w, http.StatusOK, highlight)
+}
+
+// handleRestoreBook restores the book with all of the user's highlights of
+// it that are in the trash, back in the user's library
+func (h *Handler) handleRestoreBook(w http.ResponseWriter, r *http.Request) error {
+	oUserID, oID, err := getIDsFromRequest(r)
+	if err != nil {
+		return err
+	}
+
+	book, err := h.findUserBook(r, oUserID, oID)
+	if err != nil {
+		return err
+	}
+	if book == nil {
+		return u.WriteJSON(w, http.StatusNotFound, t.APIError{Error: fmt.Errorf("book with id %v not found in the trash", oID.Hex()).Error()})
+	}
+
+	if book.DeletedAt != nil {
+		restored, err := h.bookStore.Restore(r.Context(), oID)
+		if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+			return err
+		}
+		if restored != nil {
+			book = restored
+		}
+	}
+
+	if _, err := h.highlightStore.RestoreBookHighlights(r.Context(), oUserID, book.ISBN); err != nil {
+		return err
+	}
+
+	if _, err := h.libraryStore.AddBook(r.Context(), oUserID, book.ID); err != nil {
+		return err
+	}
+
+	return u.WriteJSON(w, http.StatusOK, book)
+}
+
+// findUserBook returns the book if the user has highlights of it in the
+// trash. The book itself is only in the trash when no other user has
+// highlights of it.
+func (h *Handler) findUserBook(r *http.Request, userID, id primitive.ObjectID) (*t.Book, error) {
+	highlights, err := h.highlightStore.GetDeletedHighlights(r.Context(), userID)
+	if err != nil {
+		return nil, err
+	}
+
+	isbns := bookIDs(highlights)
+
+	deleted, err := h.bookStore.GetDeletedByISBNs(r.Context(), isbns)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, b := range deleted {
+		if b.ID == id {
+			return b, nil
+		}
+	}
+
+	book, err := h.bookStore.GetByID(r.Context(), id)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, isbn := range isbns {
+		if book.ISBN == isbn {
+			return book, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func bookIDs(highlights []*t.Highlight) []string {
+	seen := make(map[string]bool)
+	ids := make([]string, 0)
+	for _, h := range highlights {
+		if !seen[h.BookID] {
+			seen[h.BookID] = true
+			ids = append(ids, h.BookID)
+		}
+	}
+
+	return ids
+}
+
+func getIDsFromRequest(r *http.Request) (primitive.ObjectID, primitive.ObjectID, error) {
+	userID, err := u.GetStringParamFromRequest(r, "userID")
+	if err != nil {
+		return primitive.NilObjectID, primitive.NilObjectID, err
+	}
+	oUserID, _ := primitive.ObjectIDFromHex(userID)
+
+	id, err := u.GetStringParamFromRequest(r, "id")
+	if err != nil {
+		return primitive.NilObjectID, primitive.NilObjectID, err
+	}
+	oID, _ := primitive.ObjectIDFromHex(id)
+
+	return oUserID, oID, nil
+}
@@ -0,0 +1,405 @@
+package trash
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/sikozonpc/notebase/cover"
+	"github.com/sikozonpc/notebase/metadata"
+	"github.com/sikozonpc/notebase/storage"
+	types "github.com/sikozonpc/notebase/types"
+	u "github.com/sikozonpc/notebase/utils"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestTrashHandler(t *testing.T) {
+	userID := primitive.NewObjectID()
+	deletedAt := time.Now().UTC()
+
+	book := &types.Book{ID: primitive.NewObjectID(), ISBN: "B01", Title: "Deep Work", DeletedAt: &deletedAt}
+	h1 := &types.Highlight{ID: primitive.NewObjectID(), UserID: userID, BookID: book.ISBN, Text: "first", DeletedAt: &deletedAt}
+	h2 := &types.Highlight{ID: primitive.NewObjectID(), UserID: userID, BookID: book.ISBN, Text: "second", DeletedAt: &deletedAt}
+
+	newHandler := func() (*Handler, *mockHighlightStore, *mockBookStore) {
+		highlightStore := &mockHighlightStore{highlights: []*types.Highlight{copyHighlight(h1), copyHighlight(h2)}}
+		bookStore := &mockBookStore{books: []*types.Book{copyBook(book)}}
+		return NewHandler(highlightStore, bookStore, &mockLibraryStore{}, &mockUserStore{}), highlightStore, bookStore
+	}
+
+	t.Run("should list the highlights and books in the trash", func(t *testing.T) {
+		handler, _, _ := newHandler()
+
+		req, err := http.NewRequest(http.MethodGet, "/user/"+userID.Hex()+"/trash", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		router := mux.NewRouter()
+
+		router.HandleFunc("/user/{userID}/trash", u.MakeHTTPHandler(handler.handleGetTrash)).Methods(http.MethodGet)
+
+		router.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		var trash types.Trash
+		if err := json.NewDecoder(rr.Body).Decode(&trash); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(trash.Highlights) != 2 || len(trash.Books) != 1 {
+			t.Errorf("expected 2 highlights and 1 book, got %d and %d", len(trash.Highlights), len(trash.Books))
+		}
+	})
+
+	t.Run("should restore a highlight with its book", func(t *testing.T) {
+		handler, highlightStore, bookStore := newHandler()
+
+		req, err := http.NewRequest(http.MethodPost, "/user/"+userID.Hex()+"/trash/highlights/"+h1.ID.Hex()+"/restore", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		router := mux.NewRouter()
+
+		router.HandleFunc("/user/{userID}/trash/highlights/{id}/restore", u.MakeHTTPHandler(handler.handleRestoreHighlight)).Methods(http.MethodPost)
+
+		router.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		if highlightStore.highlights[0].DeletedAt != nil || highlightStore.highlights[1].DeletedAt == nil {
+			t.Errorf("expected only the first highlight to be restored")
+		}
+
+		if bookStore.books[0].DeletedAt != nil {
+			t.Errorf("expected the book to be restored")
+		}
+	})
+
+	t.Run("should not restore another user's highlight", func(t *testing.T) {
+		handler, _, _ := newHandler()
+
+		req, err := http.NewRequest(http.MethodPost, "/user/"+primitive.NewObjectID().Hex()+"/trash/highlights/"+h1.ID.Hex()+"/restore", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		router := mux.NewRouter()
+
+		router.HandleFunc("/user/{userID}/trash/highlights/{id}/restore", u.MakeHTTPHandler(handler.handleRestoreHighlight)).Methods(http.MethodPost)
+
+		router.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("expected status code %d, got %d", http.StatusNotFound, rr.Code)
+		}
+	})
+
+	t.Run("should restore a book with its highlights", func(t *testing.T) {
+		handler, highlightStore, bookStore := newHandler()
+		libraryStore := handler.libraryStore.(*mockLibraryStore)
+
+		req, err := http.NewRequest(http.MethodPost, "/user/"+userID.Hex()+"/trash/books/"+book.ID.Hex()+"/restore", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		router := mux.NewRouter()
+
+		router.HandleFunc("/user/{userID}/trash/books/{id}/restore", u.MakeHTTPHandler(handler.handleRestoreBook)).Methods(http.MethodPost)
+
+		router.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		for _, h := range highlightStore.highlights {
+			if h.DeletedAt != nil {
+				t.Errorf("expected highlight %s to be restored", h.ID.Hex())
+			}
+		}
+
+		if bookStore.books[0].DeletedAt != nil {
+			t.Errorf("expected the book to be restored")
+		}
+
+		if len(libraryStore.added) != 1 || libraryStore.added[0] != book.ID {
+			t.Errorf("expected the book to be back in the user's library")
+		}
+	})
+
+	t.Run("should not restore a book the user has no highlights of", func(t *testing.T) {
+		handler, _, bookStore := newHandler()
+
+		req, err := http.NewRequest(http.MethodPost, "/user/"+primitive.NewObjectID().Hex()+"/trash/books/"+book.ID.Hex()+"/restore", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		router := mux.NewRouter()
+
+		router.HandleFunc("/user/{userID}/trash/books/{id}/restore", u.MakeHTTPHandler(handler.handleRestoreBook)).Methods(http.MethodPost)
+
+		router.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("expected status code %d, got %d", http.StatusNotFound, rr.Code)
+		}
+
+		if bookStore.books[0].DeletedAt == nil {
+			t.Errorf("expected the book to stay in the trash")
+		}
+	})
+}
+
+func TestPurger(t *testing.T) {
+	old := time.Now().UTC().Add(-48 * time.Hour)
+	recent := time.Now().UTC()
+
+	purged := &types.Highlight{ID: primitive.NewObjectID(), DeletedAt: &old}
+	kept := &types.Highlight{ID: primitive.NewObjectID(), DeletedAt: &recent}
+	highlightStore := &mockHighlightStore{highlights: []*types.Highlight{
+		purged,
+		kept,
+		{ID: primitive.NewObjectID()},
+	}}
+	book := &types.Book{ID: primitive.NewObjectID(), ISBN: "0306406152", DeletedAt: &old}
+	bookStore := &mockBookStore{books: []*types.Book{book}}
+
+	collectionStore := &mockCollectionStore{collection: &types.Collection{HighlightIDs: []primitive.ObjectID{purged.ID, kept.ID}}}
+	linkStore := &mockLinkStore{links: []*types.Link{{Target: purged.ID.Hex()}, {Target: kept.ID.Hex()}}}
+
+	coverStorage := storage.NewMemoryStorage()
+	coverStorage.Write(cover.Path(book.ID, false), []byte("cover"))
+	coverStorage.Write(cover.Path(book.ID, true), []byte("thumb"))
+
+	cache := metadata.NewMemoryCache(time.Hour)
+	cache.Set(context.Background(), metadata.CacheKey(book), &types.BookMetadata{Publisher: "Penguin"})
+
+	purger := NewPurger(highlightStore, bookStore, &mockLibraryStore{}, collectionStore, linkStore, cover.NewCovers(coverStorage, bookStore), cache, 24*time.Hour, time.Hour)
+	if err := purger.Purge(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(highlightStore.highlights) != 2 {
+		t.Errorf("expected 2 highlights left, got %d", len(highlightStore.highlights))
+	}
+
+	if len(bookStore.books) != 0 {
+		t.Errorf("expected no books left, got %d", len(bookStore.books))
+	}
+
+	assert.Equal(t, []primitive.ObjectID{kept.ID}, collectionStore.collection.HighlightIDs)
+	assert.Equal(t, []*types.Link{{Target: kept.ID.Hex()}}, linkStore.links)
+
+	_, err := coverStorage.Read(cover.Path(book.ID, true))
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+
+	_, ok, err := cache.Get(context.Background(), metadata.CacheKey(book))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.False(t, ok)
+}
+
+func copyHighlight(h *types.Highlight) *types.Highlight {
+	c := *h
+	return &c
+}
+
+func copyBook(b *types.Book) *types.Book {
+	c := *b
+	return &c
+}
+
+type mockHighlightStore struct {
+	types.HighlightStore
+	highlights []*types.Highlight
+}
+
+func (m *mockHighlightStore) GetDeletedHighlights(_ context.Context, userID primitive.ObjectID) ([]*types.Highlight, error) {
+	hs := make([]*types.Highlight, 0)
+	for _, h := range m.highlights {
+		if h.UserID == userID && h.DeletedAt != nil {
+			hs = append(hs, h)
+		}
+	}
+
+	return hs, nil
+}
+
+func (m *mockHighlightStore) RestoreHighlight(_ context.Context, id primitive.ObjectID, userID primitive.ObjectID) (*types.Highlight, error) {
+	for _, h := range m.highlights {
+		if h.ID == id && h.UserID == userID && h.DeletedAt != nil {
+			h.DeletedAt = nil
+			return h, nil
+		}
+	}
+
+	return nil, mongo.ErrNoDocuments
+}
+
+func (m *mockHighlightStore) RestoreBookHighlights(_ context.Context, userID primitive.ObjectID, bookID string) (int64, error) {
+	var restored int64
+	for _, h := range m.highlights {
+		if h.UserID == userID && h.BookID == bookID && h.DeletedAt != nil {
+			h.DeletedAt = nil
+			restored++
+		}
+	}
+
+	return restored, nil
+}
+
+func (m *mockHighlightStore) PurgeDeletedHighlights(_ context.Context, before time.Time) ([]primitive.ObjectID, error) {
+	kept := make([]*types.Highlight, 0)
+	purged := make([]primitive.ObjectID, 0)
+	for _, h := range m.highlights {
+		if h.DeletedAt == nil || !h.DeletedAt.Before(before) {
+			kept = append(kept, h)
+		} else {
+			purged = append(purged, h.ID)
+		}
+	}
+
+	m.highlights = kept
+	return purged, nil
+}
+
+type mockBookStore struct {
+	types.BookStore
+	books []*types.Book
+}
+
+func (m *mockBookStore) GetByID(_ context.Context, id primitive.ObjectID) (*types.Book, error) {
+	for _, b := range m.books {
+		if b.ID == id && b.DeletedAt == nil {
+			return b, nil
+		}
+	}
+
+	return nil, mongo.ErrNoDocuments
+}
+
+func (m *mockBookStore) GetByISBNs(_ context.Context, isbns []string) ([]*types.Book, error) {
+	books := make([]*types.Book, 0)
+	for _, b := range m.books {
+		for _, isbn := range isbns {
+			if b.ISBN == isbn && b.DeletedAt == nil {
+				books = append(books, b)
+			}
+		}
+	}
+
+	return books, nil
+}
+
+func (m *mockBookStore) GetDeletedByISBNs(_ context.Context, isbns []string) ([]*types.Book, error) {
+	books := make([]*types.Book, 0)
+	for _, b := range m.books {
+		for _, isbn := range isbns {
+			if b.ISBN == isbn && b.DeletedAt != nil {
+				books = append(books, b)
+			}
+		}
+	}
+
+	return books, nil
+}
+
+func (m *mockBookStore) Restore(_ context.Context, id primitive.ObjectID) (*types.Book, error) {
+	for _, b := range m.books {
+		if b.ID == id && b.DeletedAt != nil {
+			b.DeletedAt = nil
+			return b, nil
+		}
+	}
+
+	return nil, mongo.ErrNoDocuments
+}
+
+func (m *mockBookStore) PurgeDeleted(_ context.Context, before time.Time) ([]*types.Book, error) {
+	kept := make([]*types.Book, 0)
+	purged := make([]*types.Book, 0)
+	for _, b := range m.books {
+		if b.DeletedAt == nil || !b.DeletedAt.Before(before) {
+			kept = append(kept, b)
+		} else {
+			purged = append(purged, b)
+		}
+	}
+
+	m.books = kept
+	return purged, nil
+}
+
+type mockLibraryStore struct {
+	types.LibraryStore
+	added []primitive.ObjectID
+}
+
+func (m *mockLibraryStore) AddBook(_ context.Context, userID primitive.ObjectID, bookID primitive.ObjectID) (*types.LibraryBook, error) {
+	m.added = append(m.added, bookID)
+	return &types.LibraryBook{UserID: userID, BookID: bookID}, nil
+}
+
+func (m *mockLibraryStore) PurgeDeleted(context.Context, time.Time) (int64, error) {
+	return 0, nil
+}
+
+type mockCollectionStore struct {
+	types.CollectionStore
+	collection *types.Collection
+}
+
+func (m *mockCollectionStore) PurgeHighlights(_ context.Context, ids []primitive.ObjectID) error {
+	kept := make([]primitive.ObjectID, 0)
+	for _, id := range m.collection.HighlightIDs {
+		if !slices.Contains(ids, id) {
+			kept = append(kept, id)
+		}
+	}
+
+	m.collection.HighlightIDs = kept
+	return nil
+}
+
+type mockLinkStore struct {
+	types.LinkStore
+	links []*types.Link
+}
+
+func (m *mockLinkStore) DeleteLinksTo(_ context.Context, targets []string) error {
+	kept := make([]*types.Link, 0)
+	for _, l := range m.links {
+		if !slices.Contains(targets, l.Target) {
+			kept = append(kept, l)
+		}
+	}
+
+	m.links = kept
+	return nil
+}
+
+type mockUserStore struct {
+	types.UserStore
+}
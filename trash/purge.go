@@ -0,0 +1,127 @@
+package trash
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/sikozonpc/notebase/cover"
+	"github.com/sikozonpc/notebase/metadata"
+	t "github.com/sikozonpc/notebase/types"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Purger permanently deletes what stayed in the trash longer than the
+// retention period
+type Purger struct {
+	highlights  t.HighlightStore
+	books       t.BookStore
+	library     t.LibraryStore
+	collections t.CollectionStore
+	links       t.LinkStore
+	covers      *cover.Covers
+	metadata    metadata.Cache
+	retention   time.Duration
+	interval    time.Duration
+}
+
+func NewPurger(highlights t.HighlightStore, books t.BookStore, library t.LibraryStore, collections t.CollectionStore, links t.LinkStore, covers *cover.Covers, metadata metadata.Cache, retention, interval time.Duration) *Purger {
+	return &Purger{
+		highlights:  highlights,
+		books:       books,
+		library:     library,
+		collections: collections,
+		links:       links,
+		covers:      covers,
+		metadata:    metadata,
+		retention:   retention,
+		interval:    interval,
+	}
+}
+
+// Run purges the trash every interval until ctx is done
+func (p *Purger) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := p.Purge(ctx); err != nil {
+			log.Printf("failed to purge the trash: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Purge deletes the highlights, books and library entries that went to the
+// trash before the retention period, with what still refers to them
+func (p *Purger) Purge(ctx context.Context) error {
+	before := time.Now().UTC().Add(-p.retention)
+
+	highlights, err := p.highlights.PurgeDeletedHighlights(ctx, before)
+	// The purged ones are cleaned up even if it stopped early
+	if cleanupErr := p.cleanupHighlights(ctx, highlights); cleanupErr != nil {
+		err = errors.Join(err, cleanupErr)
+	}
+	if err != nil {
+		return err
+	}
+
+	books, err := p.books.PurgeDeleted(ctx, before)
+	if cleanupErr := p.cleanupBooks(ctx, books); cleanupErr != nil {
+		err = errors.Join(err, cleanupErr)
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, err := p.library.PurgeDeleted(ctx, before); err != nil {
+		return err
+	}
+
+	if len(highlights) > 0 || len(books) > 0 {
+		log.Printf("purged %d highlights and %d books from the trash", len(highlights), len(books))
+	}
+
+	return nil
+}
+
+// cleanupHighlights takes the purged highlights out of collections, and
+// removes the links to them
+func (p *Purger) cleanupHighlights(ctx context.Context, ids []primitive.ObjectID) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	if err := p.collections.PurgeHighlights(ctx, ids); err != nil {
+		return err
+	}
+
+	targets := make([]string, len(ids))
+	for i, id := range ids {
+		targets[i] = id.Hex()
+	}
+
+	return p.links.DeleteLinksTo(ctx, targets)
+}
+
+// cleanupBooks deletes the stored covers and cached metadata of the purged
+// books
+func (p *Purger) cleanupBooks(ctx context.Context, books []*t.Book) error {
+	for _, b := range books {
+		if err := p.covers.Delete(b.ID); err != nil {
+			return err
+		}
+
+		if err := p.metadata.Delete(ctx, metadata.CacheKey(b)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -2,25 +2,54 @@ package types
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
 type EndpointHandler func(w http.ResponseWriter, r *http.Request) error
 
 type Config struct {
-	Env                string
-	Port               string
-	MongoURI           string
-	JWTSecret          string // Used for signing JWT tokens
-	GCPID              string // Google Cloud Project ID
-	GCPBooksBucketName string // Google CLoud Storage Bucket Name from where upload books are parsed
-	SendGridAPIKey     string
-	SendGridFromEmail  string
-	PublicURL          string // Used for generating links in emails
-	APIKey             string // Used for authentication with external clients like GCP pub/sub
+	Env                         string
+	Port                        string
+	MongoURI                    string
+	MongoDBName                 string
+	MongoMaxPoolSize            uint64
+	MongoMinPoolSize            uint64
+	MongoConnectTimeout         time.Duration
+	MongoServerSelectionTimeout time.Duration
+	MongoSocketTimeout          time.Duration // Zero means no timeout
+	MongoReadConcern            string        // local, available, majority, linearizable or snapshot. Empty uses the server default
+	MongoWriteConcern           string        // majority, a tag set name or a number of nodes. Empty uses the server default
+	MongoTLS                    bool
+	MongoTLSCAFile              string // PEM file with the CA used to verify the server
+	MongoTLSCertKeyFile         string // PEM file with the client certificate and key
+	MongoTLSInsecure            bool   // Skips server certificate verification, only for development
+	JWTSecret                   string // Used for signing JWT tokens
+	GCPID                       string // Google Cloud Project ID
+	GCPBooksBucketName          string // Google CLoud Storage Bucket Name from where upload books are parsed
+	GCPCoversBucketName         string // Google Cloud Storage Bucket Name where book covers are stored
+	SendGridAPIKey              string
+	SendGridFromEmail           string
+	PublicURL                   string // Used for generating links in emails
+	APIKey                      string // Used for authentication with external clients like GCP pub/sub
+	SearchBackend               string // mongo uses Mongo text indexes, scan ranks highlights in memory, index uses the embedded index
+	SearchIndexPath             string // Where the embedded search index is persisted
+	SearchIndexSaveInterval     time.Duration
+	CategorizeDelay             time.Duration // How long a library must stay unchanged before it's categorized again
+	TrashRetention              time.Duration // How long deleted highlights and books can be restored
+	TrashPurgeInterval          time.Duration
+	WeightInsightsByRating      bool   // Daily insights favor higher rated and favorite highlights
+	MetadataProviders           string // Comma separated providers to enrich books with, in order of preference: catalog, openlibrary, googlebooks
+	MetadataCatalogPath         string // Local ONIX (.xml) or CSV catalog used by the catalog provider
+	MetadataEnrichInterval      time.Duration
+	MetadataCacheTTL            time.Duration // How long provider answers are reused, including not knowing a book
+	OpenLibraryURL              string
+	GoogleBooksURL              string
+	GoogleBooksAPIKey           string // Optional, raises the Google Books quota
 }
 
 type APIError struct {
@@ -28,14 +57,85 @@ type APIError struct {
 }
 
 type Highlight struct {
-	ID        primitive.ObjectID `json:"id" bson:"_id"`
-	Text      string             `json:"text" bson:"text"`
-	Location  string             `json:"location" bson:"location"`
-	Note      string             `json:"note" bson:"note"`
-	UserID    primitive.ObjectID `json:"userId" bson:"userId"`
-	BookID    string             `json:"bookId" bson:"bookId"`
-	CreatedAt time.Time          `json:"createdAt" bson:"createdAt"`
-	UpdatedAt time.Time          `json:"updatedAt" bson:"updatedAt"`
+	ID            primitive.ObjectID `json:"id" bson:"_id"`
+	Text          string             `json:"text" bson:"text"`
+	Location      string             `json:"location" bson:"location"`
+	Position      int                `json:"position" bson:"position"` // Numeric location in the book, used for reading order
+	Note          string             `json:"note" bson:"note"`
+	UserID        primitive.ObjectID `json:"userId" bson:"userId"`
+	BookID        string             `json:"bookId" bson:"bookId"`
+	Tags          []string           `json:"tags" bson:"tags"`
+	SuggestedTags []string           `json:"suggestedTags,omitempty" bson:"suggestedTags,omitempty"` // Found by the categorizer, until accepted or rejected
+	RejectedTags  []string           `json:"rejectedTags,omitempty" bson:"rejectedTags,omitempty"`   // Never suggested again
+	Favorite      bool               `json:"favorite" bson:"favorite,omitempty"`
+	Rating        int                `json:"rating,omitempty" bson:"rating,omitempty"` // From 1 to 5, zero when unrated
+	Revision      int                `json:"-" bson:"revision,omitempty"`              // Edits recorded so far, the latest revision is one more
+	CreatedAt     time.Time          `json:"createdAt" bson:"createdAt"`
+	UpdatedAt     time.Time          `json:"updatedAt" bson:"updatedAt"`
+	DeletedAt     *time.Time         `json:"deletedAt,omitempty" bson:"deletedAt,omitempty"` // Set while in the trash
+}
+
+// HighlightFilter narrows down, sorts and paginates the highlights returned
+// from a listing. The zero value returns every highlight.
+type HighlightFilter struct {
+	IDs           []primitive.ObjectID
+	BookID        string
+	Tag           string
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	HasNote       *bool
+	Favorite      *bool
+	MinRating     int    // Zero includes unrated highlights
+	Sort          string // One of HighlightSortCreated (default), HighlightSortUpdated, HighlightSortLocation or HighlightSortBook
+	Desc          bool
+	Limit         int    // Zero means no limit
+	Cursor        string // Returned as NextCursor by the previous page
+	// GetRandomHighlights picks higher rated and favorite highlights more
+	// often instead of sampling uniformly
+	WeightByRating bool
+}
+
+const (
+	HighlightSortCreated  = "created"
+	HighlightSortUpdated  = "updated"
+	HighlightSortLocation = "location"
+	HighlightSortBook     = "book"
+)
+
+type HighlightPage struct {
+	Highlights []*Highlight `json:"highlights"`
+	Total      int64        `json:"total"`      // Number of highlights matching the filter across all pages
+	NextCursor string       `json:"nextCursor"` // Empty on the last page
+}
+
+type TagCount struct {
+	Tag   string `json:"tag" bson:"_id"`
+	Count int    `json:"count" bson:"count"`
+}
+
+// HighlightRevision records a single edit of a highlight
+type HighlightRevision struct {
+	ID          primitive.ObjectID `json:"id" bson:"_id"`
+	HighlightID primitive.ObjectID `json:"highlightId" bson:"highlightId"`
+	UserID      primitive.ObjectID `json:"userId" bson:"userId"` // The user who made the change
+	Version     int                `json:"version" bson:"version"`
+	Changes     []FieldChange      `json:"changes" bson:"changes"`
+	Snapshot    HighlightSnapshot  `json:"snapshot" bson:"snapshot"` // State of the highlight after the change
+	CreatedAt   time.Time          `json:"createdAt" bson:"createdAt"`
+}
+
+type FieldChange struct {
+	Field string `json:"field" bson:"field"`
+	From  string `json:"from" bson:"from"`
+	To    string `json:"to" bson:"to"`
+}
+
+// The editable fields of a highlight
+type HighlightSnapshot struct {
+	Text     string `json:"text" bson:"text"`
+	Location string `json:"location" bson:"location"`
+	Note     string `json:"note" bson:"note"`
+	BookID   string `json:"bookId" bson:"bookId"`
 }
 
 type User struct {
@@ -45,15 +145,54 @@ type User struct {
 	Email     string             `json:"email" bson:"email"`
 	Password  string             `json:"-" bson:"password"`
 	IsActive  bool               `json:"isActive" bson:"isActive"`
-	CreatedAt time.Time          `json:"createdAt" bson:"createdAt"`
+	// When set daily insights are picked from this collection instead of the whole library
+	InsightsCollectionID *primitive.ObjectID `json:"insightsCollectionId,omitempty" bson:"insightsCollectionId,omitempty"`
+	CreatedAt            time.Time           `json:"createdAt" bson:"createdAt"`
+}
+
+// Collection is a user curated group of highlights, possibly from many books
+type Collection struct {
+	ID           primitive.ObjectID   `json:"id" bson:"_id"`
+	UserID       primitive.ObjectID   `json:"userId" bson:"userId"`
+	Name         string               `json:"name" bson:"name"`
+	Description  string               `json:"description" bson:"description"`
+	HighlightIDs []primitive.ObjectID `json:"highlightIds" bson:"highlightIds"` // In display order
+	CreatedAt    time.Time            `json:"createdAt" bson:"createdAt"`
+	UpdatedAt    time.Time            `json:"updatedAt" bson:"updatedAt"`
 }
 
 type Book struct {
-	ID        primitive.ObjectID `json:"id" bson:"_id"`
-	ISBN      string             `json:"isbn" bson:"isbn"`
-	Title     string             `json:"title" bson:"title"`
-	Authors   string             `json:"authors" bson:"authors"`
-	CreatedAt time.Time          `json:"createdAt" bson:"createdAt"`
+	ID          primitive.ObjectID   `json:"id" bson:"_id"`
+	ISBN        string               `json:"isbn" bson:"isbn"`
+	Title       string               `json:"title" bson:"title"`
+	Authors     string               `json:"authors" bson:"authors"`
+	CreatedAt   time.Time            `json:"createdAt" bson:"createdAt"`
+	DeletedAt   *time.Time           `json:"deletedAt,omitempty" bson:"deletedAt,omitempty"`     // Set while in the trash
+	Metadata    *BookMetadata        `json:"metadata,omitempty" bson:"metadata,omitempty"`       // Set once the book has been enriched
+	CoverAt     *time.Time           `json:"coverAt,omitempty" bson:"coverAt,omitempty"`         // When the cover was last stored, nil without one
+	AuthorIDs   []primitive.ObjectID `json:"authorIds,omitempty" bson:"authorIds,omitempty"`     // Authors parsed from Authors
+	Identifiers []string             `json:"identifiers,omitempty" bson:"identifiers,omitempty"` // ISBNs and ASINs of the books merged into it
+}
+
+// DuplicateBookGroup is a set of books that look like editions or imports
+// of the same book. Similarity is the lowest of the similarities that
+// grouped them, from 0 to 1.
+type DuplicateBookGroup struct {
+	Books      []*Book `json:"books"`
+	Similarity float64 `json:"similarity"`
+}
+
+// BookMetadata is what metadata providers know about a book beyond what the
+// Kindle extract has
+type BookMetadata struct {
+	ISBN13        string    `json:"isbn13,omitempty" bson:"isbn13,omitempty"`
+	Publisher     string    `json:"publisher,omitempty" bson:"publisher,omitempty"`
+	PublishedYear int       `json:"publishedYear,omitempty" bson:"publishedYear,omitempty"`
+	PageCount     int       `json:"pageCount,omitempty" bson:"pageCount,omitempty"`
+	Subjects      []string  `json:"subjects,omitempty" bson:"subjects,omitempty"`
+	CoverURL      string    `json:"coverUrl,omitempty" bson:"coverUrl,omitempty"`
+	Sources       []string  `json:"sources,omitempty" bson:"sources,omitempty"` // Providers that contributed, empty if none knew the book
+	EnrichedAt    time.Time `json:"enrichedAt" bson:"enrichedAt"`
 }
 
 // This is the format of the file that is downloaded from web tool
@@ -86,14 +225,330 @@ type UserStore interface {
 type HighlightStore interface {
 	CreateHighlight(context.Context, *CreateHighlightRequest) (primitive.ObjectID, error)
 	GetHighlightByID(context.Context, primitive.ObjectID, primitive.ObjectID) (*Highlight, error)
-	GetUserHighlights(context.Context, primitive.ObjectID) ([]*Highlight, error)
-	DeleteHighlight(context.Context, primitive.ObjectID) error
-	GetRandomHighlights(context.Context, primitive.ObjectID, int) ([]*Highlight, error)
+	GetUserHighlights(context.Context, primitive.ObjectID, *HighlightFilter) (*HighlightPage, error)
+	UpdateHighlight(context.Context, primitive.ObjectID, primitive.ObjectID, *UpdateHighlightRequest) (*Highlight, error)
+	GetHighlightRevisions(context.Context, primitive.ObjectID, primitive.ObjectID) ([]*HighlightRevision, error)
+	GetHighlightRevision(context.Context, primitive.ObjectID, primitive.ObjectID, int) (*HighlightRevision, error)
+	DeleteHighlight(context.Context, primitive.ObjectID, primitive.ObjectID) error
+	DeleteBookHighlights(context.Context, primitive.ObjectID, string) (int64, error)
+	GetDeletedHighlights(context.Context, primitive.ObjectID) ([]*Highlight, error)
+	RestoreHighlight(context.Context, primitive.ObjectID, primitive.ObjectID) (*Highlight, error)
+	RestoreBookHighlights(context.Context, primitive.ObjectID, string) (int64, error)
+	PurgeDeletedHighlights(context.Context, time.Time) ([]primitive.ObjectID, error)
+	GetRandomHighlights(context.Context, primitive.ObjectID, int, *HighlightFilter) ([]*Highlight, error)
+	GetUserBookStats(context.Context, primitive.ObjectID) ([]*BookHighlightStats, error)
+	SetFavorite(context.Context, primitive.ObjectID, primitive.ObjectID, bool) (*Highlight, error)
+	BulkUpdate(context.Context, primitive.ObjectID, []primitive.ObjectID, *BulkOperation) ([]*BulkItemResult, error)
+	SetRating(context.Context, primitive.ObjectID, primitive.ObjectID, int) (*Highlight, error)
+	AddTags(context.Context, primitive.ObjectID, []primitive.ObjectID, []string) (int64, error)
+	RemoveTags(context.Context, primitive.ObjectID, []primitive.ObjectID, []string) (int64, error)
+	GetUserTags(context.Context, primitive.ObjectID) ([]*TagCount, error)
+	MergeTags(context.Context, primitive.ObjectID, []string, string) (int64, error)
+	SetSuggestedTags(context.Context, primitive.ObjectID, map[primitive.ObjectID][]string) error
+	AcceptSuggestedTags(context.Context, primitive.ObjectID, primitive.ObjectID, []string) (*Highlight, error)
+	RejectSuggestedTags(context.Context, primitive.ObjectID, primitive.ObjectID, []string) (*Highlight, error)
+	MergeHighlights(context.Context, primitive.ObjectID, primitive.ObjectID, []primitive.ObjectID) (*Highlight, error)
+	MoveBookHighlights(context.Context, *primitive.ObjectID, []string, string) (int64, error)
+}
+
+// DuplicateGroup is a set of highlights with nearly the same text.
+// Similarity is the lowest of the similarities that grouped them, from 0 to 1.
+type DuplicateGroup struct {
+	Highlights []*Highlight `json:"highlights"`
+	Similarity float64      `json:"similarity"`
+}
+
+// BulkOperation is applied to many highlights with a single bulk write
+type BulkOperation struct {
+	Action string   // One of the BulkAction constants
+	Tags   []string // Tags added or removed by BulkActionTag and BulkActionUntag
+	BookID string   // Book the highlights are moved to by BulkActionMove
+}
+
+const (
+	BulkActionDelete     = "delete"
+	BulkActionTag        = "tag"
+	BulkActionUntag      = "untag"
+	BulkActionMove       = "move"
+	BulkActionCollect    = "collect"
+	BulkActionFavorite   = "favorite"
+	BulkActionUnfavorite = "unfavorite"
+)
+
+// BulkItemResult reports what a bulk operation did to one highlight
+type BulkItemResult struct {
+	ID     primitive.ObjectID `json:"id"`
+	Status string             `json:"status"` // One of the BulkStatus constants
+	Error  string             `json:"error,omitempty"`
+}
+
+const (
+	BulkStatusOK       = "ok"
+	BulkStatusNotFound = "not_found"
+	BulkStatusFailed   = "failed"
+)
+
+// Topic is a group of similar highlights found by the categorizer
+type Topic struct {
+	ID           primitive.ObjectID   `json:"id" bson:"_id"`
+	UserID       primitive.ObjectID   `json:"userId" bson:"userId"`
+	Label        string               `json:"label" bson:"label"`
+	Keywords     []string             `json:"keywords" bson:"keywords"`
+	HighlightIDs []primitive.ObjectID `json:"highlightIds" bson:"highlightIds"`
+	CreatedAt    time.Time            `json:"createdAt" bson:"createdAt"`
+}
+
+type TopicStore interface {
+	GetUserTopics(context.Context, primitive.ObjectID) ([]*Topic, error)
+	ReplaceUserTopics(context.Context, primitive.ObjectID, []*Topic) error
+}
+
+// HighlightHook is notified after highlights are written so data derived
+// from them, like a search index, stays in sync
+type HighlightHook interface {
+	HighlightSaved(ctx context.Context, h *Highlight)
+	HighlightDeleted(ctx context.Context, userID, id primitive.ObjectID)
+}
+
+type CollectionStore interface {
+	CreateCollection(context.Context, *CreateCollectionRequest) (*Collection, error)
+	GetUserCollections(context.Context, primitive.ObjectID) ([]*Collection, error)
+	GetCollectionByID(context.Context, primitive.ObjectID, primitive.ObjectID) (*Collection, error)
+	UpdateCollection(context.Context, primitive.ObjectID, primitive.ObjectID, *UpdateCollectionRequest) (*Collection, error)
+	DeleteCollection(context.Context, primitive.ObjectID, primitive.ObjectID) error
+	AddHighlights(context.Context, primitive.ObjectID, primitive.ObjectID, []primitive.ObjectID, int) (*Collection, error)
+	RemoveHighlight(context.Context, primitive.ObjectID, primitive.ObjectID, primitive.ObjectID) (*Collection, error)
+	ReorderHighlights(context.Context, primitive.ObjectID, primitive.ObjectID, []primitive.ObjectID) (*Collection, error)
+	PurgeHighlights(context.Context, []primitive.ObjectID) error
+	ReplaceHighlights(context.Context, primitive.ObjectID, []primitive.ObjectID, primitive.ObjectID) error
 }
 
 type BookStore interface {
-	GetByISBN(context.Context, string) (*Book, error)
+	GetByIdentifier(context.Context, string) (*Book, error)
+	GetByID(context.Context, primitive.ObjectID) (*Book, error)
+	GetByISBNs(context.Context, []string) ([]*Book, error)
+	GetByIDs(context.Context, []primitive.ObjectID) ([]*Book, error)
 	Create(context.Context, *CreateBookRequest) (primitive.ObjectID, error)
+	GetOrCreate(context.Context, *CreateBookRequest) (*Book, error)
+	Delete(context.Context, primitive.ObjectID) error
+	GetDeletedByISBNs(context.Context, []string) ([]*Book, error)
+	Restore(context.Context, primitive.ObjectID) (*Book, error)
+	PurgeDeleted(context.Context, time.Time) ([]*Book, error)
+	GetUnenriched(context.Context, int) ([]*Book, error)
+	SetMetadata(context.Context, primitive.ObjectID, *BookMetadata) error
+	SetCover(context.Context, primitive.ObjectID, time.Time) error
+	SetAuthors(context.Context, primitive.ObjectID, []primitive.ObjectID) error
+	ReplaceAuthors(context.Context, []primitive.ObjectID, []primitive.ObjectID, primitive.ObjectID) error
+	CountAuthorBooks(context.Context, primitive.ObjectID) (int64, error)
+	Merge(context.Context, primitive.ObjectID, []primitive.ObjectID) (*Book, error)
+}
+
+type Author struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id"`
+	Name      string             `json:"name" bson:"name"`                           // As displayed, "Ursula K. Le Guin"
+	SortName  string             `json:"sortName" bson:"sortName"`                   // "Le Guin, Ursula K."
+	Aliases   []string           `json:"aliases,omitempty" bson:"aliases,omitempty"` // Other spellings, kept when authors are merged
+	Keys      []string           `json:"-" bson:"keys"`                              // Normalized name and aliases
+	CreatedAt time.Time          `json:"createdAt" bson:"createdAt"`
+}
+
+// AuthorSummary is an author with how much of them is in a user's library
+type AuthorSummary struct {
+	*Author
+	BookCount      int `json:"bookCount"`
+	HighlightCount int `json:"highlightCount"`
+}
+
+type AuthorStore interface {
+	GetOrCreateAll(context.Context, string) ([]*Author, error)
+	GetByIDs(context.Context, []primitive.ObjectID) ([]*Author, error)
+	Merge(context.Context, primitive.ObjectID, []primitive.ObjectID) (*Author, error)
+}
+
+// BookNotFoundError is returned when no book has the identifier. It matches
+// mongo.ErrNoDocuments with errors.Is.
+type BookNotFoundError struct {
+	Identifier string
+}
+
+func (e *BookNotFoundError) Error() string {
+	return fmt.Sprintf("book %s not found", e.Identifier)
+}
+
+func (e *BookNotFoundError) Unwrap() error {
+	return mongo.ErrNoDocuments
+}
+
+// BookHighlightStats summarizes a user's highlights of one book
+type BookHighlightStats struct {
+	BookID            string    `bson:"_id"`
+	HighlightCount    int       `bson:"count"`
+	LastHighlightedAt time.Time `bson:"lastHighlightedAt"`
+}
+
+// LibraryBook puts a book in a user's library, with what the user changed
+// about it. The book itself is shared between users.
+type LibraryBook struct {
+	ID         primitive.ObjectID `json:"id" bson:"_id"`
+	UserID     primitive.ObjectID `json:"userId" bson:"userId"`
+	BookID     primitive.ObjectID `json:"bookId" bson:"bookId"`
+	Title      string             `json:"title,omitempty" bson:"title,omitempty"`     // Replaces the book's title for the user
+	Authors    string             `json:"authors,omitempty" bson:"authors,omitempty"` // Replaces the book's authors for the user
+	Status     string             `json:"status,omitempty" bson:"status,omitempty"`   // One of the ReadingStatus constants
+	StartedAt  *time.Time         `json:"startedAt,omitempty" bson:"startedAt,omitempty"`
+	FinishedAt *time.Time         `json:"finishedAt,omitempty" bson:"finishedAt,omitempty"`
+	CreatedAt  time.Time          `json:"createdAt" bson:"createdAt"`
+	UpdatedAt  time.Time          `json:"updatedAt" bson:"updatedAt"`
+	DeletedAt  *time.Time         `json:"deletedAt,omitempty" bson:"deletedAt,omitempty"` // Set while in the trash
+	Progress   *ReadingProgress   `json:"progress,omitempty" bson:"progress,omitempty"`
+}
+
+// ReadingProgress is how far a user got in a book. Which of location, page
+// and percent are known depends on where it came from.
+type ReadingProgress struct {
+	Location  int       `json:"location,omitempty" bson:"location,omitempty"` // Kindle location
+	Page      int       `json:"page,omitempty" bson:"page,omitempty"`
+	Percent   float64   `json:"percent,omitempty" bson:"percent,omitempty"` // From 0 to 100
+	Source    string    `json:"source" bson:"source"`                       // One of the ProgressSource constants
+	UpdatedAt time.Time `json:"updatedAt" bson:"updatedAt"`
+}
+
+const (
+	ProgressSourceHighlights = "highlights" // The furthest highlight imported
+	ProgressSourceSession    = "session"    // The last page of a reading session
+	ProgressSourceManual     = "manual"
+)
+
+const (
+	ReadingStatusToRead    = "to-read"
+	ReadingStatusReading   = "reading"
+	ReadingStatusFinished  = "finished"
+	ReadingStatusAbandoned = "abandoned"
+)
+
+// UpdateLibraryBookRequest changes a book for one user. An empty title,
+// authors or status resets it.
+type UpdateLibraryBookRequest struct {
+	Title      *string    `json:"title"`
+	Authors    *string    `json:"authors"`
+	Status     *string    `json:"status"`
+	StartedAt  *time.Time `json:"startedAt"`
+	FinishedAt *time.Time `json:"finishedAt"`
+}
+
+// UserBook is a book as a user sees it in their library
+type UserBook struct {
+	*Book
+	Status            string           `json:"status,omitempty"`
+	StartedAt         *time.Time       `json:"startedAt,omitempty"`
+	FinishedAt        *time.Time       `json:"finishedAt,omitempty"`
+	HighlightCount    int              `json:"highlightCount"`
+	LastHighlightedAt *time.Time       `json:"lastHighlightedAt,omitempty"`
+	CoverURL          string           `json:"coverUrl,omitempty"`
+	Progress          *ReadingProgress `json:"progress,omitempty"`
+}
+
+// BookDetail is a book with the user's highlights of it in reading order,
+// and their notes about it
+type BookDetail struct {
+	UserBook
+	Highlights []*Highlight `json:"highlights"`
+	Notes      []*Note      `json:"notes"`
+}
+
+// ReadingSession is a stretch of time a user spent reading a book
+type ReadingSession struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id"`
+	UserID    primitive.ObjectID `json:"userId" bson:"userId"`
+	BookID    primitive.ObjectID `json:"bookId" bson:"bookId"`
+	StartedAt time.Time          `json:"startedAt" bson:"startedAt"`
+	EndedAt   time.Time          `json:"endedAt" bson:"endedAt"`
+	StartPage int                `json:"startPage,omitempty" bson:"startPage,omitempty"`
+	EndPage   int                `json:"endPage,omitempty" bson:"endPage,omitempty"`
+	CreatedAt time.Time          `json:"createdAt" bson:"createdAt"`
+}
+
+type CreateReadingSessionRequest struct {
+	StartedAt time.Time `json:"startedAt"`
+	EndedAt   time.Time `json:"endedAt"`
+	StartPage int       `json:"startPage"`
+	EndPage   int       `json:"endPage"`
+}
+
+// ReadingSessionFilter narrows down the sessions of a reading log. The zero
+// value returns every session.
+type ReadingSessionFilter struct {
+	BookID primitive.ObjectID // Sessions of one book, all of them if nil
+	From   *time.Time         // Sessions started at or after
+	To     *time.Time         // Sessions started before
+}
+
+// UpdateReadingProgressRequest sets where the user is in a book. At least one
+// of the fields is required.
+type UpdateReadingProgressRequest struct {
+	Location *int     `json:"location"`
+	Page     *int     `json:"page"`
+	Percent  *float64 `json:"percent"`
+}
+
+// BookReadingLog is what a user's reading of a book looks like so far
+type BookReadingLog struct {
+	Status     string            `json:"status,omitempty"`
+	StartedAt  *time.Time        `json:"startedAt,omitempty"`
+	FinishedAt *time.Time        `json:"finishedAt,omitempty"`
+	Progress   *ReadingProgress  `json:"progress,omitempty"`
+	Sessions   []*ReadingSession `json:"sessions"`
+	Minutes    int               `json:"minutes"` // Total time spent in the sessions
+	Pages      int               `json:"pages"`   // Total pages read in the sessions
+}
+
+type ReadingLogStore interface {
+	CreateSession(context.Context, primitive.ObjectID, primitive.ObjectID, *CreateReadingSessionRequest) (*ReadingSession, error)
+	GetSessions(context.Context, primitive.ObjectID, *ReadingSessionFilter) ([]*ReadingSession, error)
+	DeleteSession(context.Context, primitive.ObjectID, primitive.ObjectID) error
+	MoveBooks(context.Context, *primitive.ObjectID, []primitive.ObjectID, primitive.ObjectID) error
+}
+
+// Trash holds what a user deleted and can still restore
+type Trash struct {
+	Highlights []*Highlight `json:"highlights"`
+	Books      []*Book      `json:"books"`
+}
+
+type LibraryStore interface {
+	AddBook(context.Context, primitive.ObjectID, primitive.ObjectID) (*LibraryBook, error)
+	GetLibrary(context.Context, primitive.ObjectID) ([]*LibraryBook, error)
+	GetLibraryBook(context.Context, primitive.ObjectID, primitive.ObjectID) (*LibraryBook, error)
+	UpdateLibraryBook(context.Context, primitive.ObjectID, primitive.ObjectID, *UpdateLibraryBookRequest) (*LibraryBook, error)
+	RemoveBook(context.Context, primitive.ObjectID, primitive.ObjectID) error
+	CountBookReaders(context.Context, primitive.ObjectID) (int64, error)
+	PurgeDeleted(context.Context, time.Time) (int64, error)
+	MoveBooks(context.Context, *primitive.ObjectID, []primitive.ObjectID, primitive.ObjectID) error
+	SetProgress(context.Context, primitive.ObjectID, primitive.ObjectID, *ReadingProgress) (*LibraryBook, error)
+}
+
+type Searcher interface {
+	Search(context.Context, primitive.ObjectID, *SearchQuery, int) ([]*SearchResult, error)
+}
+
+// SearchQuery is a parsed search like `stoic "inner citadel" author:aurelius`
+type SearchQuery struct {
+	Terms   []string // Match any of them
+	Phrases []string // Must all appear as written
+	Authors []string // author: qualifiers, matched against the book authors
+	Books   []string // book: qualifiers, matched against the book title
+	Tags    []string // tag: qualifiers, the highlight must have all of them
+}
+
+// SearchResult is a matching highlight or standalone note, only one of them
+// is set
+type SearchResult struct {
+	Highlight   *Highlight `json:"highlight,omitempty"`
+	Note        *Note      `json:"note,omitempty"`
+	Book        *Book      `json:"book,omitempty"`
+	Score       float64    `json:"score"`
+	TextSnippet string     `json:"textSnippet"` // HTML with matches wrapped in <mark>
+	NoteSnippet string     `json:"noteSnippet"`
 }
 
 type CreateBookRequest struct {
@@ -105,16 +560,144 @@ type CreateBookRequest struct {
 type CreateHighlightRequest struct {
 	Text     string             `json:"text" bson:"text"`
 	Location string             `json:"location" bson:"location"`
+	Position int                `json:"position" bson:"position"` // Parsed from the location when not set
 	Note     string             `json:"note" bson:"note"`
 	UserID   primitive.ObjectID `json:"userId" bson:"userId"`
 	BookID   string             `json:"bookId" bson:"bookId"`
+	Tags     []string           `json:"tags" bson:"tags"`
+}
+
+// Only the fields that are set are updated
+type UpdateHighlightRequest struct {
+	Text     *string `json:"text"`
+	Location *string `json:"location"`
+	Note     *string `json:"note"`
+	BookID   *string `json:"bookId"`
+}
+
+// Note is a thought the user wrote down, about a book or on its own, with no
+// quote from it
+type Note struct {
+	ID        primitive.ObjectID  `json:"id" bson:"_id"`
+	UserID    primitive.ObjectID  `json:"userId" bson:"userId"`
+	Body      string              `json:"body" bson:"body"`                         // Markdown
+	BookID    *primitive.ObjectID `json:"bookId,omitempty" bson:"bookId,omitempty"` // Nil when not about a book
+	Tags      []string            `json:"tags" bson:"tags"`
+	CreatedAt time.Time           `json:"createdAt" bson:"createdAt"`
+	UpdatedAt time.Time           `json:"updatedAt" bson:"updatedAt"`
+}
+
+type CreateNoteRequest struct {
+	Body   string             `json:"body"`
+	BookID string             `json:"bookId"`
+	Tags   []string           `json:"tags"`
+	UserID primitive.ObjectID `json:"-"`
+}
+
+// Only the fields that are set are updated, an empty bookId unlinks the note
+// from its book
+type UpdateNoteRequest struct {
+	Body   *string   `json:"body"`
+	BookID *string   `json:"bookId"`
+	Tags   *[]string `json:"tags"`
+}
+
+// NoteFilter narrows down the notes returned from a listing. The zero value
+// returns every note.
+type NoteFilter struct {
+	IDs    []primitive.ObjectID
+	BookID *primitive.ObjectID
+	Tag    string
+}
+
+type NoteStore interface {
+	CreateNote(context.Context, *CreateNoteRequest) (*Note, error)
+	GetUserNotes(context.Context, primitive.ObjectID, *NoteFilter) ([]*Note, error)
+	GetNoteByID(context.Context, primitive.ObjectID, primitive.ObjectID) (*Note, error)
+	UpdateNote(context.Context, primitive.ObjectID, primitive.ObjectID, *UpdateNoteRequest) (*Note, error)
+	DeleteNote(context.Context, primitive.ObjectID, primitive.ObjectID) error
+	GetRandomNotes(context.Context, primitive.ObjectID, int) ([]*Note, error)
+	MoveBooks(context.Context, *primitive.ObjectID, []primitive.ObjectID, primitive.ObjectID) error
+}
+
+// LinkNodeType is what a [[wiki-link]] is written in or points to
+type LinkNodeType string
+
+const (
+	LinkNodeHighlight LinkNodeType = "highlight"
+	LinkNodeNote      LinkNodeType = "note"
+)
+
+// Link is a [[wiki-link]] written in a highlight's note or a standalone note.
+// The target is kept as written, normalized, and resolved when read, so a
+// link can point to a note written after it.
+type Link struct {
+	ID         primitive.ObjectID `json:"id" bson:"_id"`
+	UserID     primitive.ObjectID `json:"userId" bson:"userId"`
+	SourceType LinkNodeType       `json:"sourceType" bson:"sourceType"`
+	SourceID   primitive.ObjectID `json:"sourceId" bson:"sourceId"`
+	Target     string             `json:"target" bson:"target"` // An id, or a note's title
+}
+
+type LinkStore interface {
+	SetLinks(context.Context, primitive.ObjectID, LinkNodeType, primitive.ObjectID, []string) error
+	DeleteLinks(context.Context, primitive.ObjectID, LinkNodeType, primitive.ObjectID) error
+	DeleteLinksTo(context.Context, []string) error
+	GetLinksTo(context.Context, primitive.ObjectID, []string) ([]*Link, error)
+	GetUserLinks(context.Context, primitive.ObjectID) ([]*Link, error)
+}
+
+// Backlink is a highlight or note linking to another one
+type Backlink struct {
+	Type      LinkNodeType `json:"type"`
+	Highlight *Highlight   `json:"highlight,omitempty"`
+	Note      *Note        `json:"note,omitempty"`
+}
+
+type GraphNode struct {
+	ID    primitive.ObjectID `json:"id"`
+	Type  LinkNodeType       `json:"type"`
+	Label string             `json:"label"`
+}
+
+// GraphEdge goes from the highlight or note a link is written in to the one
+// it points to
+type GraphEdge struct {
+	Source primitive.ObjectID `json:"source"`
+	Target primitive.ObjectID `json:"target"`
+}
+
+type LinkGraph struct {
+	Nodes []*GraphNode `json:"nodes"`
+	Edges []*GraphEdge `json:"edges"`
+}
+
+type CreateCollectionRequest struct {
+	Name        string             `json:"name" bson:"name"`
+	Description string             `json:"description" bson:"description"`
+	UserID      primitive.ObjectID `json:"userId" bson:"userId"`
+}
+
+// Only the fields that are set are updated
+type UpdateCollectionRequest struct {
+	Name        *string `json:"name"`
+	Description *string `json:"description"`
 }
 
 type DailyInsight struct {
-	Text        string
-	Note        string
-	BookAuthors string
-	BookTitle   string
+	Text         string
+	Note         string
+	BookAuthors  string
+	BookTitle    string
+	BookCoverURL string // Thumbnail of the cover, empty without one
+	Related      []*DailyInsight
+}
+
+// RelatedHighlight is a highlight similar to another one, with a score from
+// 0 to 1
+type RelatedHighlight struct {
+	Highlight *Highlight `json:"highlight"`
+	Score     float64    `json:"score"`
 }
 
 type RegisterRequest struct {
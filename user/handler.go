@@ -10,6 +10,7 @@ import (
 	"github.com/sikozonpc/notebase/config"
 	t "github.com/sikozonpc/notebase/types"
 	u "github.com/sikozonpc/notebase/utils"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
 type Handler struct {
@@ -99,6 +100,9 @@ func (h *Handler) handleRegister(w http.ResponseWriter, r *http.Request) error {
 	payload.Password = string(hashedPassword)
 
 	id, err := h.store.Create(r.Context(), *payload)
+	if mongo.IsDuplicateKeyError(err) {
+		return u.WriteJSON(w, http.StatusConflict, t.APIError{Error: fmt.Errorf("user with email %s already exists", payload.Email).Error()})
+	}
 	if err != nil {
 		return err
 	}
@@ -9,30 +9,30 @@ import (
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
-const (
-	DbName   = "notebase"
-	CollName = "users"
-)
+const CollName = "users"
 
 type Store struct {
-	db *mongo.Client
+	db *mongo.Database
 }
 
-func NewStore(db *mongo.Client) *Store {
+func NewStore(db *mongo.Database) *Store {
 	return &Store{db: db}
 }
 
 func (s *Store) Create(ctx context.Context, b t.RegisterRequest) (primitive.ObjectID, error) {
-	col := s.db.Database(DbName).Collection(CollName)
+	col := s.db.Collection(CollName)
 
 	newUser, err := col.InsertOne(ctx, b)
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
 
 	id := newUser.InsertedID.(primitive.ObjectID)
-	return id, err
+	return id, nil
 }
 
 func (s *Store) GetUserByEmail(ctx context.Context, email string) (*t.User, error) {
-	col := s.db.Database(DbName).Collection(CollName)
+	col := s.db.Collection(CollName)
 
 	var u t.User
 	err := col.FindOne(ctx, bson.M{
@@ -43,7 +43,7 @@ func (s *Store) GetUserByEmail(ctx context.Context, email string) (*t.User, erro
 }
 
 func (s *Store) GetUserByID(ctx context.Context, id string) (*t.User, error) {
-	col := s.db.Database(DbName).Collection(CollName)
+	col := s.db.Collection(CollName)
 
 	oID, _ := primitive.ObjectIDFromHex(id)
 
@@ -56,7 +56,7 @@ func (s *Store) GetUserByID(ctx context.Context, id string) (*t.User, error) {
 }
 
 func (s *Store) GetUsers(ctx context.Context) ([]*t.User, error) {
-	col := s.db.Database(DbName).Collection(CollName)
+	col := s.db.Collection(CollName)
 
 	cursor, err := col.Find(ctx, bson.M{})
 	if err != nil {
@@ -77,11 +77,9 @@ func (s *Store) GetUsers(ctx context.Context) ([]*t.User, error) {
 }
 
 func (s *Store) UpdateUser(ctx context.Context, u t.User) error {
-	col := s.db.Database(DbName).Collection(CollName)
+	col := s.db.Collection(CollName)
 
-	_, err := col.UpdateOne(ctx, bson.M{
-		"_id": u.ID,
-	}, bson.M{
+	update := bson.M{
 		"$set": bson.M{
 			"firstName": u.FirstName,
 			"lastName":  u.LastName,
@@ -89,7 +87,17 @@ func (s *Store) UpdateUser(ctx context.Context, u t.User) error {
 			"password":  u.Password,
 			"isActive":  u.IsActive,
 		},
-	})
+	}
+
+	if u.InsightsCollectionID != nil {
+		update["$set"].(bson.M)["insightsCollectionId"] = u.InsightsCollectionID
+	} else {
+		update["$unset"] = bson.M{"insightsCollectionId": ""}
+	}
+
+	_, err := col.UpdateOne(ctx, bson.M{
+		"_id": u.ID,
+	}, update)
 
 	return err
 }
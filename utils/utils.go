@@ -5,9 +5,12 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
 	t "github.com/sikozonpc/notebase/types"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 func WriteJSON(w http.ResponseWriter, status int, v any) error {
@@ -51,4 +54,85 @@ func GetTokenFromRequest(r *http.Request) string {
 	}
 
 	return ""
-}
\ No newline at end of file
+}
+
+// Parses a list of hex encoded ObjectIDs, failing on the first invalid one
+func ParseObjectIDs(ids []string) ([]primitive.ObjectID, error) {
+	oIDs := make([]primitive.ObjectID, len(ids))
+	for i, id := range ids {
+		oID, err := primitive.ObjectIDFromHex(id)
+		if err != nil {
+			return nil, fmt.Errorf("invalid id %v", id)
+		}
+
+		oIDs[i] = oID
+	}
+
+	return oIDs, nil
+}
+
+// Parses the bounds of a date range, RFC 3339 timestamps or plain dates like
+// 2024-01-31. A plain date ends the range at the end of that day. Empty
+// bounds are nil.
+func ParseDateRange(from, to string) (*time.Time, *time.Time, error) {
+	var start, end *time.Time
+
+	if from != "" {
+		d, _, err := parseDate(from)
+		if err != nil {
+			return nil, nil, err
+		}
+		start = &d
+	}
+
+	if to != "" {
+		d, dateOnly, err := parseDate(to)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if dateOnly {
+			d = d.AddDate(0, 0, 1)
+		}
+		end = &d
+	}
+
+	return start, end, nil
+}
+
+func parseDate(v string) (time.Time, bool, error) {
+	if d, err := time.Parse(time.DateOnly, v); err == nil {
+		return d, true, nil
+	}
+
+	d, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("invalid date %v", v)
+	}
+
+	return d, false, nil
+}
+
+// Tags are case insensitive and whitespace is collapsed, so "Go  Concurrency"
+// and "go concurrency" are the same tag. Highlights and notes share them.
+func NormalizeTag(tag string) string {
+	return strings.ToLower(strings.Join(strings.Fields(tag), " "))
+}
+
+// Normalizes the tags, dropping empty ones and duplicates
+func NormalizeTags(tags []string) []string {
+	normalized := make([]string, 0, len(tags))
+	seen := make(map[string]bool, len(tags))
+
+	for _, tag := range tags {
+		tag = NormalizeTag(tag)
+		if tag == "" || seen[tag] {
+			continue
+		}
+
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+
+	return normalized
+}
@@ -0,0 +1,29 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeTags(t *testing.T) {
+	tags := NormalizeTags([]string{"Go", " go ", "Go  Concurrency", "", "leadership"})
+
+	assert.Equal(t, []string{"go", "go concurrency", "leadership"}, tags)
+}
+
+func TestParseDateRange(t *testing.T) {
+	from, to, err := ParseDateRange("2024-01-01", "2024-01-31")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), *from)
+	assert.Equal(t, time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), *to, "a plain date should include the whole day")
+
+	from, to, err = ParseDateRange("", "2024-01-31T12:00:00Z")
+	assert.NoError(t, err)
+	assert.Nil(t, from)
+	assert.Equal(t, time.Date(2024, 1, 31, 12, 0, 0, 0, time.UTC), *to)
+
+	_, _, err = ParseDateRange("yesterday", "")
+	assert.Error(t, err)
+}